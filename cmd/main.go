@@ -12,15 +12,18 @@ import (
 
 	"github.com/atmega-p471/forum-service/internal/config"
 	grpcClient "github.com/atmega-p471/forum-service/internal/delivery/grpc/client"
+	"github.com/atmega-p471/forum-service/internal/delivery/grpc/interceptor"
 	"github.com/atmega-p471/forum-service/internal/delivery/grpc/server"
 	httpHandler "github.com/atmega-p471/forum-service/internal/delivery/http"
 	wsHandler "github.com/atmega-p471/forum-service/internal/delivery/ws"
+	"github.com/atmega-p471/forum-service/internal/ratelimit"
 	"github.com/atmega-p471/forum-service/internal/repository"
 	"github.com/atmega-p471/forum-service/internal/usecase"
 	"github.com/atmega-p471/forum-service/proto/forum"
+	"github.com/redis/go-redis/v9"
 	httpSwagger "github.com/swaggo/http-swagger"
 
-	_ "github.com/atmega-p471/forum-service/docs"
+	"github.com/atmega-p471/forum-service/docs"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -39,6 +42,9 @@ func main() {
 
 	// Load config
 	cfg := config.NewConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
 
 	// Connect to Auth service
 	authConn, err := grpc.Dial(cfg.AuthServiceAddr, grpc.WithInsecure())
@@ -50,11 +56,10 @@ func main() {
 	authClient := grpcClient.NewAuthClient(authConn)
 
 	// Create repository layer
-	db, err := sql.Open("sqlite3", cfg.DBPath)
+	db, err := sql.Open("sqlite3", repository.DSN(cfg.DBPath, cfg.DBBusyTimeout, cfg.DBJournalMode))
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to database")
 	}
-	defer db.Close()
 
 	// Initialize database schema
 	if err := repository.InitSchema(db); err != nil {
@@ -62,41 +67,59 @@ func main() {
 	}
 
 	// Create WebSocket hub
-	hub := wsHandler.NewHub()
+	hub := wsHandler.NewHubWithBuffer(int(cfg.HubBufferSize))
+	hub.SetMaxConnsPerIP(int(cfg.WSMaxConnsPerIP))
+	hub.SetReplaySize(int(cfg.WSReplaySize))
 
 	// Create usecase layer
-	messageRepo := repository.NewMessageRepository(db)
-	messageUseCase := usecase.NewMessageUseCase(messageRepo, authClient, hub)
+	repo := repository.NewRepository(db)
+	repo.LogSlowQueries(log.Logger, cfg.SlowQueryThreshold)
+	messageUseCase := usecase.NewUseCase(repo.Message, authClient, hub, cfg)
 
 	// Start expired comments cleanup scheduler
-	if uc, ok := messageUseCase.(*usecase.MessageUseCase); ok {
-		uc.StartCleanupScheduler()
-	}
+	messageUseCase.StartCleanupScheduler()
+	messageUseCase.StartRetentionScheduler(cfg.MessageRetention)
 
 	// Start WebSocket hub
 	go hub.Run()
 
 	// Create delivery layer - HTTP and WebSocket with AUTHENTICATION
-	handler := httpHandler.NewHandler(messageUseCase, hub, authClient)
+	handler := httpHandler.NewHandler(messageUseCase, hub, authClient, cfg)
+	// Banned users must not keep using a cached token
+	messageUseCase.OnUserBanned(handler.ForgetUser)
+	if cfg.RateLimitBackend == config.RateLimitBackendRedis {
+		// Share rate limits with every other instance using the same Redis
+		rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		defer rdb.Close()
+		if err := rdb.Ping(context.Background()).Err(); err != nil {
+			log.Fatal().Err(err).Msg("Failed to connect to Redis")
+		}
+		handler.SetRateLimiters(
+			ratelimit.NewRedis(rdb, "messages", cfg.MessagesPerMinute),
+			ratelimit.NewRedis(rdb, "comments", cfg.CommentsPerMinute),
+			ratelimit.NewRedis(rdb, "previews", cfg.PreviewsPerMinute),
+		)
+	}
 
 	// Create HTTP server
 	router := http.NewServeMux()
 	handler.RegisterRoutes(router)
 
 	// Serve Swagger UI
+	httpHandler.ConfigureSwagger(docs.SwaggerInfo, cfg)
 	router.Handle("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
 	))
 
-	// --- CORS middleware ---
+	// CORS headers are set per route by the handler
 	httpServer := &http.Server{
 		Addr:    cfg.HTTPAddr,
-		Handler: httpHandler.CORSMiddleware(router),
+		Handler: router,
 	}
 
 	// Create gRPC server
-	grpcServer := grpc.NewServer()
-	forumServer := server.NewForumServer(messageUseCase, log.Logger)
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(interceptor.AuthUnary(authClient)))
+	forumServer := server.NewForumServer(messageUseCase, hub, log.Logger)
 	forum.RegisterForumServiceServer(grpcServer, forumServer)
 	reflection.Register(grpcServer)
 
@@ -128,8 +151,9 @@ func main() {
 	<-quit
 	log.Info().Msg("Shutting down servers...")
 
-	// Stop gRPC server
-	grpcServer.GracefulStop()
+	// Stopping the hub ends open message streams, which GracefulStop waits for
+	messageUseCase.StopSchedulers()
+	hub.Stop()
 
 	// Stop HTTP server
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -139,5 +163,13 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to shutdown HTTP server gracefully")
 	}
 
+	// Stop gRPC server
+	grpcServer.GracefulStop()
+
+	// Release the database
+	if err := repo.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close repository")
+	}
+
 	log.Info().Msg("Servers stopped")
 }
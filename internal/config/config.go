@@ -3,14 +3,251 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Default pagination limits
+const (
+	DefaultPageSize    = 10
+	DefaultMaxPageSize = 100
+	DefaultMaxOffset   = 10000
+)
+
+// DefaultHubBufferSize is the default size of the hub's broadcast queue
+const DefaultHubBufferSize = 256
+
+// Default comment lifetimes
+const (
+	DefaultCommentTTL    = 5 * time.Minute
+	DefaultMaxCommentTTL = 7 * 24 * time.Hour
+)
+
+// DefaultGzipMinSize is the smallest response body, in bytes, that is gzip-compressed
+const DefaultGzipMinSize = 1024
+
+// DefaultWSHistorySize is how many recent messages a new WebSocket client receives
+const DefaultWSHistorySize = 50
+
+// DefaultWSReplaySize is how many recent broadcasts are kept for WebSocket clients that reconnect
+const DefaultWSReplaySize = 256
+
+// DefaultWSMaxConnsPerIP is how many WebSocket connections one client IP may hold open
+const DefaultWSMaxConnsPerIP = 20
+
+// DefaultMaxUploadSize is the largest file, in bytes, accepted by the uploads endpoint
+const DefaultMaxUploadSize = 5 << 20
+
+// DefaultUploadAllowedTypes are the MIME types accepted by the uploads endpoint
+var DefaultUploadAllowedTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// DefaultCORSAllowedHeaders are the request headers browsers may send cross-origin by default
+var DefaultCORSAllowedHeaders = []string{"Content-Type", "Authorization", "X-Requested-With"}
+
+// DefaultCORSMaxAge is how long browsers may cache a CORS preflight response by default
+const DefaultCORSMaxAge = time.Hour
+
+// DefaultAllowedOrigins are the browser origins allowed by default: the web frontend
+var DefaultAllowedOrigins = []string{"http://localhost:8000"}
+
+// Default per-user creation rate limits, per minute
+const (
+	DefaultMessagesPerMinute = 10
+	DefaultCommentsPerMinute = 30
+	DefaultPreviewsPerMinute = 60
+)
+
+// Rate limit backends: counters kept in each instance's memory, or in Redis
+// where every instance shares them
+const (
+	RateLimitBackendMemory = "memory"
+	RateLimitBackendRedis  = "redis"
+)
+
+// Content modes: content stored as submitted, with HTML tags stripped, or
+// rendered from markdown to sanitized HTML
+const (
+	ContentModePlain     = "plain"
+	ContentModeSanitized = "sanitized"
+	ContentModeMarkdown  = "markdown"
+)
+
+// Default content length limits
+const (
+	DefaultMaxMessageLength  = 1000
+	DefaultMaxCommentLength  = 500
+	DefaultMaxUsernameLength = 50
+)
+
+// DefaultListCacheTTL is how long the first page of message lists is served from memory
+const DefaultListCacheTTL = time.Second
+
+// DefaultSlowQueryThreshold is how long a database query may run before it is logged as slow
+const DefaultSlowQueryThreshold = 100 * time.Millisecond
+
+// DefaultDBBusyTimeout is how long a database connection waits for another writer's lock
+const DefaultDBBusyTimeout = 5 * time.Second
+
+// DefaultDBJournalMode is the SQLite journal mode the database is opened with
+const DefaultDBJournalMode = "WAL"
+
+// DefaultTrendingWindow is how far back comments count towards a message trending
+const DefaultTrendingWindow = 24 * time.Hour
+
+// DefaultTrashRetention is how long deleted messages can be restored before they are purged
+const DefaultTrashRetention = 7 * 24 * time.Hour
+
+// DefaultRequestTimeout bounds how long a single HTTP request may spend on database work
+const DefaultRequestTimeout = 10 * time.Second
+
+// DefaultTokenCacheTTL is how long a validated auth token is trusted before
+// it is checked with the auth service again
+const DefaultTokenCacheTTL = 30 * time.Second
+
 // Config holds the service configuration
 type Config struct {
 	HTTPAddr        string
 	GRPCAddr        string
 	DBPath          string
 	AuthServiceAddr string
+	// TokenCacheTTL is how long the HTTP API reuses a token's validation
+	// instead of asking the auth service again. Zero disables the cache.
+	TokenCacheTTL time.Duration
+	// DBBusyTimeout is how long a write waits for a lock held by another
+	// connection before failing with "database is locked"
+	DBBusyTimeout time.Duration
+	// DBJournalMode is the SQLite journal mode, e.g. "WAL" or "DELETE"
+	DBJournalMode string
+	// SlowQueryThreshold is how long a database query may run before it is
+	// logged as slow. Zero disables the log.
+	SlowQueryThreshold time.Duration
+	// PublicURL is the externally visible base URL, used in the API docs
+	PublicURL string
+
+	// DefaultPageSize is the limit list endpoints use when none is given
+	DefaultPageSize int64
+	// MaxPageSize caps the limit accepted by list endpoints
+	MaxPageSize int64
+	// MaxOffset caps the offset accepted by list endpoints
+	MaxOffset int64
+
+	// MessageRetention is how long messages are kept before being deleted.
+	// Zero disables the retention job.
+	MessageRetention time.Duration
+	// TrashRetention is how long deleted messages stay restorable before
+	// they are purged. Zero deletes messages permanently straight away.
+	TrashRetention time.Duration
+
+	// HubBufferSize is how many broadcasts can be queued before new ones are dropped
+	HubBufferSize int64
+
+	// WSHistorySize is how many recent messages are replayed to a new
+	// WebSocket client. Zero disables the replay.
+	WSHistorySize int64
+	// WSReplaySize is how many of the latest message and comment broadcasts
+	// are kept for WebSocket clients reconnecting with since_seq. Zero
+	// disables the replay.
+	WSReplaySize int64
+	// WSMaxConnsPerIP caps the WebSocket connections one client IP may hold
+	// open; further upgrades get 429. Zero disables the cap.
+	WSMaxConnsPerIP int64
+
+	// RequestTimeout cancels an HTTP request's context after this long.
+	// Zero disables the timeout.
+	RequestTimeout time.Duration
+
+	// MessagesPerMinute and CommentsPerMinute limit how many messages and
+	// comments each user may create per minute. Zero disables the limit.
+	MessagesPerMinute int64
+	CommentsPerMinute int64
+	// PreviewsPerMinute limits content previews per client IP. Zero
+	// disables the limit.
+	PreviewsPerMinute int64
+	// RateLimitBackend is where rate limit counters are kept, one of the
+	// RateLimitBackend constants
+	RateLimitBackend string
+	// RedisAddr is the Redis server used by the redis rate limit backend
+	RedisAddr string
+
+	// ListCacheTTL is how long first pages of message lists are cached.
+	// Zero disables the cache; concurrent identical queries are still shared.
+	ListCacheTTL time.Duration
+
+	// CommentTTL is how long comments live on messages without their own comment TTL
+	CommentTTL time.Duration
+	// MaxCommentTTL caps the comment TTL a message can set
+	MaxCommentTTL time.Duration
+	// CommentsExpire turns comment expiry on. When false comments are kept
+	// forever and the TTL settings are ignored.
+	CommentsExpire bool
+	// ResetCommentExpiryOnEdit gives an edited comment a fresh lifetime
+	// instead of keeping its original expiry
+	ResetCommentExpiryOnEdit bool
+
+	// BumpWindow is how long after posting a message's comments still bump
+	// it in sort=active. Zero lets every comment bump.
+	BumpWindow time.Duration
+	// DuplicateWindow rejects a message identical to one the same user
+	// posted this recently. Zero disables the check.
+	DuplicateWindow time.Duration
+	// TrendingWindow is how far back comments count when ranking trending messages
+	TrendingWindow time.Duration
+
+	// GzipMinSize is the smallest response body compressed for clients that
+	// accept gzip. Zero disables compression.
+	GzipMinSize int64
+
+	// BannedWords are rejected in message and comment content and usernames
+	BannedWords []string
+
+	// UploadDir is where uploaded attachments are stored
+	UploadDir string
+	// MaxUploadSize is the largest uploaded file accepted, in bytes
+	MaxUploadSize int64
+	// UploadAllowedTypes are the MIME types accepted for uploads
+	UploadAllowedTypes []string
+
+	// TrustedProxies are CIDRs or IPs of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed
+	TrustedProxies []string
+
+	// MaxMessageLength and MaxCommentLength cap message and comment content,
+	// in bytes; MaxUsernameLength caps usernames, in characters
+	MaxMessageLength  int64
+	MaxCommentLength  int64
+	MaxUsernameLength int64
+
+	// AllowedOrigins are the browser origins allowed to make cross-origin
+	// requests and open WebSockets; "*" allows any
+	AllowedOrigins []string
+	// CORSAllowedHeaders are the request headers cross-origin requests may send
+	CORSAllowedHeaders []string
+	// CORSAllowCredentials lets cross-origin requests carry credentials
+	CORSAllowCredentials bool
+	// CORSMaxAge is how long browsers may cache a preflight response. Zero
+	// leaves it to the browser.
+	CORSMaxAge time.Duration
+
+	// StrictJSON rejects request bodies containing unknown fields
+	StrictJSON bool
+	// JSONStringIDs serializes IDs in every JSON response as strings, as
+	// clients can otherwise request with Accept: application/json; ids=string
+	JSONStringIDs bool
+
+	// ContentMode is how message content is processed before storage:
+	// "plain", "sanitized" or "markdown"
+	ContentMode string
+	// NormalizeContent stores message and comment content in Unicode NFC,
+	// so visually identical text compares and searches the same
+	NormalizeContent bool
+	// RequireAuthForMessages and RequireAuthForComments reject anonymous
+	// posts of that kind, e.g. to keep messages open but stop comment spam
+	RequireAuthForMessages bool
+	RequireAuthForComments bool
+	// ReadOnly starts the service with writes blocked, e.g. during a
+	// migration; admins can switch it at runtime
+	ReadOnly bool
 }
 
 // NewConfig creates a new config instance
@@ -21,14 +258,76 @@ func NewConfig() *Config {
 		cwd = "."
 	}
 
-	// Construct absolute path for the database
+	// Construct absolute paths for the database and uploads
 	dbPath := filepath.Join(cwd, "data", "forum.db")
+	uploadDir := filepath.Join(cwd, "data", "uploads")
+
+	uploadAllowedTypes := getEnvList("UPLOAD_ALLOWED_TYPES")
+	if len(uploadAllowedTypes) == 0 {
+		uploadAllowedTypes = DefaultUploadAllowedTypes
+	}
+	allowedOrigins := getEnvList("ALLOWED_ORIGINS")
+	if len(allowedOrigins) == 0 {
+		allowedOrigins = DefaultAllowedOrigins
+	}
+	corsAllowedHeaders := getEnvList("CORS_ALLOWED_HEADERS")
+	if len(corsAllowedHeaders) == 0 {
+		corsAllowedHeaders = DefaultCORSAllowedHeaders
+	}
 
 	return &Config{
-		HTTPAddr:        getEnv("HTTP_ADDR", "localhost:8082"),
-		GRPCAddr:        getEnv("GRPC_ADDR", "localhost:9082"),
-		DBPath:          getEnv("DB_PATH", dbPath),
-		AuthServiceAddr: getEnv("AUTH_SERVICE_ADDR", "localhost:9081"),
+		HTTPAddr:                 getEnv("HTTP_ADDR", "localhost:8082"),
+		GRPCAddr:                 getEnv("GRPC_ADDR", "localhost:9082"),
+		DBPath:                   getEnv("DB_PATH", dbPath),
+		DBBusyTimeout:            getEnvDuration("DB_BUSY_TIMEOUT", DefaultDBBusyTimeout),
+		DBJournalMode:            getEnv("DB_JOURNAL_MODE", DefaultDBJournalMode),
+		SlowQueryThreshold:       getEnvDuration("SLOW_QUERY_THRESHOLD", DefaultSlowQueryThreshold),
+		AuthServiceAddr:          getEnv("AUTH_SERVICE_ADDR", "localhost:9081"),
+		TokenCacheTTL:            getEnvDuration("TOKEN_CACHE_TTL", DefaultTokenCacheTTL),
+		PublicURL:                getEnv("PUBLIC_URL", ""),
+		DefaultPageSize:          getEnvInt("DEFAULT_PAGE_SIZE", DefaultPageSize),
+		MaxPageSize:              getEnvInt("MAX_PAGE_SIZE", DefaultMaxPageSize),
+		MaxOffset:                getEnvInt("MAX_OFFSET", DefaultMaxOffset),
+		MessageRetention:         getEnvDuration("MESSAGE_RETENTION", 0),
+		TrashRetention:           getEnvDuration("TRASH_RETENTION", DefaultTrashRetention),
+		HubBufferSize:            getEnvInt("HUB_BUFFER_SIZE", DefaultHubBufferSize),
+		WSHistorySize:            getEnvInt("WS_HISTORY_SIZE", DefaultWSHistorySize),
+		WSReplaySize:             getEnvInt("WS_REPLAY_SIZE", DefaultWSReplaySize),
+		WSMaxConnsPerIP:          getEnvInt("WS_MAX_CONNS_PER_IP", DefaultWSMaxConnsPerIP),
+		RequestTimeout:           getEnvDuration("REQUEST_TIMEOUT", DefaultRequestTimeout),
+		MessagesPerMinute:        getEnvInt("MESSAGES_PER_MINUTE", DefaultMessagesPerMinute),
+		CommentsPerMinute:        getEnvInt("COMMENTS_PER_MINUTE", DefaultCommentsPerMinute),
+		PreviewsPerMinute:        getEnvInt("PREVIEWS_PER_MINUTE", DefaultPreviewsPerMinute),
+		RateLimitBackend:         getEnv("RATE_LIMIT_BACKEND", RateLimitBackendMemory),
+		RedisAddr:                getEnv("REDIS_ADDR", "localhost:6379"),
+		ListCacheTTL:             getEnvDuration("LIST_CACHE_TTL", DefaultListCacheTTL),
+		CommentTTL:               getEnvDuration("COMMENT_TTL", DefaultCommentTTL),
+		MaxCommentTTL:            getEnvDuration("MAX_COMMENT_TTL", DefaultMaxCommentTTL),
+		CommentsExpire:           getEnvBool("COMMENTS_EXPIRE", true),
+		ResetCommentExpiryOnEdit: getEnvBool("RESET_COMMENT_EXPIRY_ON_EDIT", false),
+		BumpWindow:               getEnvDuration("BUMP_WINDOW", 0),
+		DuplicateWindow:          getEnvDuration("DUPLICATE_WINDOW", 0),
+		TrendingWindow:           getEnvDuration("TRENDING_WINDOW", DefaultTrendingWindow),
+		GzipMinSize:              getEnvInt("GZIP_MIN_SIZE", DefaultGzipMinSize),
+		BannedWords:              getEnvList("BANNED_WORDS"),
+		UploadDir:                getEnv("UPLOAD_DIR", uploadDir),
+		MaxUploadSize:            getEnvInt("MAX_UPLOAD_SIZE", DefaultMaxUploadSize),
+		UploadAllowedTypes:       uploadAllowedTypes,
+		TrustedProxies:           getEnvList("TRUSTED_PROXIES"),
+		MaxMessageLength:         getEnvInt("MAX_MESSAGE_LENGTH", DefaultMaxMessageLength),
+		MaxCommentLength:         getEnvInt("MAX_COMMENT_LENGTH", DefaultMaxCommentLength),
+		MaxUsernameLength:        getEnvInt("MAX_USERNAME_LENGTH", DefaultMaxUsernameLength),
+		AllowedOrigins:           allowedOrigins,
+		CORSAllowedHeaders:       corsAllowedHeaders,
+		CORSAllowCredentials:     getEnvBool("CORS_ALLOW_CREDENTIALS", true),
+		CORSMaxAge:               getEnvDuration("CORS_MAX_AGE", DefaultCORSMaxAge),
+		StrictJSON:               getEnvBool("STRICT_JSON", false),
+		JSONStringIDs:            getEnvBool("JSON_STRING_IDS", false),
+		ContentMode:              getEnv("CONTENT_MODE", ContentModePlain),
+		NormalizeContent:         getEnvBool("NORMALIZE_CONTENT", false),
+		RequireAuthForMessages:   getEnvBool("REQUIRE_AUTH_FOR_MESSAGES", false),
+		RequireAuthForComments:   getEnvBool("REQUIRE_AUTH_FOR_COMMENTS", false),
+		ReadOnly:                 getEnvBool("READ_ONLY", false),
 	}
 }
 
@@ -39,3 +338,44 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// Helper function to get an integer environment variable with a default value
+func getEnvInt(key string, defaultValue int64) int64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// Helper function to get a comma-separated list environment variable
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Helper function to get a boolean environment variable with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// Helper function to get a duration environment variable (e.g. "720h") with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewConfig_Defaults(t *testing.T) {
+	for _, key := range []string{"HTTP_ADDR", "GRPC_ADDR", "AUTH_SERVICE_ADDR"} {
+		// Setenv restores the original value once the test finishes
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	cfg := NewConfig()
+
+	if cfg.HTTPAddr != "localhost:8082" {
+		t.Errorf("Expected default HTTP address localhost:8082, got %s", cfg.HTTPAddr)
+	}
+	if cfg.GRPCAddr != "localhost:9082" {
+		t.Errorf("Expected default gRPC address localhost:9082, got %s", cfg.GRPCAddr)
+	}
+	if cfg.AuthServiceAddr != "localhost:9081" {
+		t.Errorf("Expected default auth service address localhost:9081, got %s", cfg.AuthServiceAddr)
+	}
+}
+
+func TestNewConfig_Env(t *testing.T) {
+	t.Setenv("HTTP_ADDR", ":18082")
+	t.Setenv("GRPC_ADDR", ":19082")
+	t.Setenv("AUTH_SERVICE_ADDR", "auth:9081")
+	t.Setenv("DB_PATH", "/tmp/forum-test.db")
+	t.Setenv("REQUEST_TIMEOUT", "3s")
+	t.Setenv("STRICT_JSON", "true")
+
+	cfg := NewConfig()
+
+	if cfg.HTTPAddr != ":18082" {
+		t.Errorf("Expected HTTP address :18082, got %s", cfg.HTTPAddr)
+	}
+	if cfg.GRPCAddr != ":19082" {
+		t.Errorf("Expected gRPC address :19082, got %s", cfg.GRPCAddr)
+	}
+	if cfg.AuthServiceAddr != "auth:9081" {
+		t.Errorf("Expected auth service address auth:9081, got %s", cfg.AuthServiceAddr)
+	}
+	if cfg.DBPath != "/tmp/forum-test.db" {
+		t.Errorf("Expected DB path /tmp/forum-test.db, got %s", cfg.DBPath)
+	}
+	if cfg.RequestTimeout != 3*time.Second {
+		t.Errorf("Expected request timeout 3s, got %s", cfg.RequestTimeout)
+	}
+	if !cfg.StrictJSON {
+		t.Error("Expected strict JSON to be enabled")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config {
+		cfg := NewConfig()
+		cfg.DBPath = filepath.Join(t.TempDir(), "forum.db")
+		return cfg
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+
+	notADir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notADir, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{name: "Empty auth address", modify: func(cfg *Config) { cfg.AuthServiceAddr = "" }, want: []string{"AUTH_SERVICE_ADDR"}},
+		{name: "Missing port", modify: func(cfg *Config) { cfg.HTTPAddr = "localhost" }, want: []string{"HTTP_ADDR"}},
+		{name: "Unparseable port", modify: func(cfg *Config) { cfg.GRPCAddr = ":grpc" }, want: []string{"GRPC_ADDR"}},
+		{name: "Port out of range", modify: func(cfg *Config) { cfg.HTTPAddr = ":70000" }, want: []string{"HTTP_ADDR"}},
+		{name: "DB directory is a file", modify: func(cfg *Config) { cfg.DBPath = filepath.Join(notADir, "forum.db") }, want: []string{"DB_PATH"}},
+		{name: "Negative duration", modify: func(cfg *Config) { cfg.RequestTimeout = -time.Second }, want: []string{"REQUEST_TIMEOUT"}},
+		{name: "Zero default page size", modify: func(cfg *Config) { cfg.DefaultPageSize = 0 }, want: []string{"DEFAULT_PAGE_SIZE"}},
+		{name: "Negative hub buffer size", modify: func(cfg *Config) { cfg.HubBufferSize = -1 }, want: []string{"HUB_BUFFER_SIZE"}},
+		{name: "Negative replay size", modify: func(cfg *Config) { cfg.WSReplaySize = -1 }, want: []string{"WS_REPLAY_SIZE"}},
+		{name: "Zero length limits", modify: func(cfg *Config) {
+			cfg.MaxMessageLength = 0
+			cfg.MaxCommentLength = -1
+			cfg.MaxUsernameLength = 0
+		}, want: []string{"MAX_MESSAGE_LENGTH", "MAX_COMMENT_LENGTH", "MAX_USERNAME_LENGTH"}},
+		{name: "Unknown content mode", modify: func(cfg *Config) { cfg.ContentMode = "html" }, want: []string{"CONTENT_MODE"}},
+		{name: "Unknown rate limit backend", modify: func(cfg *Config) { cfg.RateLimitBackend = "memcached" }, want: []string{"RATE_LIMIT_BACKEND"}},
+		{name: "Redis backend without address", modify: func(cfg *Config) {
+			cfg.RateLimitBackend = RateLimitBackendRedis
+			cfg.RedisAddr = ""
+		}, want: []string{"REDIS_ADDR"}},
+		{name: "Zero comment TTL", modify: func(cfg *Config) { cfg.CommentTTL = 0 }, want: []string{"COMMENT_TTL"}},
+		{
+			name: "Every problem is reported",
+			modify: func(cfg *Config) {
+				cfg.HTTPAddr = ""
+				cfg.TrashRetention = -time.Hour
+			},
+			want: []string{"HTTP_ADDR", "TRASH_RETENTION"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected the error to mention %s, got %v", want, err)
+				}
+			}
+		})
+	}
+
+	// Comment TTLs don't matter while comments never expire
+	cfg := valid()
+	cfg.CommentsExpire = false
+	cfg.CommentTTL = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected zero COMMENT_TTL to be allowed without expiry, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks the config for values that would otherwise only fail later,
+// at dial or listen time, with a less helpful error. Every problem found is
+// reported, not just the first.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	for _, addr := range []struct {
+		env, value string
+	}{
+		{"HTTP_ADDR", c.HTTPAddr},
+		{"GRPC_ADDR", c.GRPCAddr},
+		{"AUTH_SERVICE_ADDR", c.AuthServiceAddr},
+	} {
+		if err := validateAddr(addr.value); err != nil {
+			add("%s %q: %v", addr.env, addr.value, err)
+		}
+	}
+
+	if err := validateDBPath(c.DBPath); err != nil {
+		add("DB_PATH %q: %v", c.DBPath, err)
+	}
+
+	switch c.RateLimitBackend {
+	case RateLimitBackendMemory:
+	case RateLimitBackendRedis:
+		if err := validateAddr(c.RedisAddr); err != nil {
+			add("REDIS_ADDR %q: %v", c.RedisAddr, err)
+		}
+	default:
+		add("RATE_LIMIT_BACKEND must be %q or %q, got %q", RateLimitBackendMemory, RateLimitBackendRedis, c.RateLimitBackend)
+	}
+
+	switch c.ContentMode {
+	case ContentModePlain, ContentModeSanitized, ContentModeMarkdown:
+	default:
+		add("CONTENT_MODE must be %q, %q or %q, got %q", ContentModePlain, ContentModeSanitized, ContentModeMarkdown, c.ContentMode)
+	}
+
+	// These size channels and buffers, or cap content, so zero is no use
+	for _, n := range []struct {
+		env   string
+		value int64
+	}{
+		{"DEFAULT_PAGE_SIZE", c.DefaultPageSize},
+		{"MAX_PAGE_SIZE", c.MaxPageSize},
+		{"MAX_MESSAGE_LENGTH", c.MaxMessageLength},
+		{"MAX_COMMENT_LENGTH", c.MaxCommentLength},
+		{"MAX_USERNAME_LENGTH", c.MaxUsernameLength},
+	} {
+		if n.value <= 0 {
+			add("%s must be positive, got %d", n.env, n.value)
+		}
+	}
+	// A zero-size hub queue or replay buffer is unbuffered, not broken
+	for _, n := range []struct {
+		env   string
+		value int64
+	}{
+		{"HUB_BUFFER_SIZE", c.HubBufferSize},
+		{"WS_REPLAY_SIZE", c.WSReplaySize},
+	} {
+		if n.value < 0 {
+			add("%s must not be negative, got %d", n.env, n.value)
+		}
+	}
+
+	// Zero turns most of these off, so only negative values are invalid
+	for _, d := range []struct {
+		env   string
+		value time.Duration
+	}{
+		{"DB_BUSY_TIMEOUT", c.DBBusyTimeout},
+		{"SLOW_QUERY_THRESHOLD", c.SlowQueryThreshold},
+		{"MESSAGE_RETENTION", c.MessageRetention},
+		{"TRASH_RETENTION", c.TrashRetention},
+		{"REQUEST_TIMEOUT", c.RequestTimeout},
+		{"TOKEN_CACHE_TTL", c.TokenCacheTTL},
+		{"CORS_MAX_AGE", c.CORSMaxAge},
+		{"LIST_CACHE_TTL", c.ListCacheTTL},
+		{"BUMP_WINDOW", c.BumpWindow},
+		{"DUPLICATE_WINDOW", c.DuplicateWindow},
+	} {
+		if d.value < 0 {
+			add("%s must not be negative, got %s", d.env, d.value)
+		}
+	}
+	if c.TrendingWindow <= 0 {
+		add("TRENDING_WINDOW must be positive, got %s", c.TrendingWindow)
+	}
+	if c.CommentsExpire {
+		if c.CommentTTL <= 0 {
+			add("COMMENT_TTL must be positive, got %s", c.CommentTTL)
+		}
+		if c.MaxCommentTTL <= 0 {
+			add("MAX_COMMENT_TTL must be positive, got %s", c.MaxCommentTTL)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateAddr checks addr is a host:port pair with a numeric port
+func validateAddr(addr string) error {
+	if addr == "" {
+		return errors.New("address is required")
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.New("must be host:port")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// validateDBPath checks the database's directory is writable. A directory
+// that doesn't exist yet passes if its nearest existing parent is writable,
+// as with the default data directory on a fresh checkout.
+func validateDBPath(path string) error {
+	if path == "" {
+		return errors.New("path is required")
+	}
+	if path == ":memory:" || strings.HasPrefix(path, "file:") {
+		return nil
+	}
+	return checkWritableDir(filepath.Dir(path))
+}
+
+// checkWritableDir checks dir, or its nearest existing ancestor, is a
+// directory files can be created in
+func checkWritableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return err
+			}
+			dir = parent
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+
+		f, err := os.CreateTemp(dir, ".write-check-*")
+		if err != nil {
+			return fmt.Errorf("%s is not writable", dir)
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}
+}
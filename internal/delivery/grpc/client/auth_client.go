@@ -6,20 +6,36 @@ import (
 	"github.com/atmega-p471/forum-auth-service/proto/auth"
 	"github.com/atmega-p471/forum-service/internal/domain"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 // AuthClient is a client for the auth service
 type AuthClient struct {
+	conn   *grpc.ClientConn
 	client auth.AuthServiceClient
 }
 
 // NewAuthClient creates a new auth client
 func NewAuthClient(conn *grpc.ClientConn) *AuthClient {
 	return &AuthClient{
+		conn:   conn,
 		client: auth.NewAuthServiceClient(conn),
 	}
 }
 
+// Ping reports whether the auth service connection is usable without making
+// a call. It returns domain.ErrAuthUnavailable if the connection has failed
+// or been closed; an idle connection is asked to reconnect.
+func (c *AuthClient) Ping(ctx context.Context) error {
+	switch c.conn.GetState() {
+	case connectivity.Shutdown, connectivity.TransientFailure:
+		return domain.ErrAuthUnavailable
+	case connectivity.Idle:
+		c.conn.Connect()
+	}
+	return ctx.Err()
+}
+
 // ValidateToken validates a JWT token against the auth service
 func (c *AuthClient) ValidateToken(token string) (*domain.User, error) {
 	resp, err := c.client.ValidateToken(context.Background(), &auth.ValidateTokenRequest{
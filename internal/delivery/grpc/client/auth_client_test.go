@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestAuthClient_PingClosedConnection(t *testing.T) {
+	conn, err := grpc.NewClient("passthrough:///localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create connection: %v", err)
+	}
+	authClient := NewAuthClient(conn)
+
+	if err := authClient.Ping(context.Background()); err != nil {
+		t.Errorf("Expected idle connection to be usable, got %v", err)
+	}
+
+	conn.Close()
+	if err := authClient.Ping(context.Background()); !errors.Is(err, domain.ErrAuthUnavailable) {
+		t.Errorf("Expected ErrAuthUnavailable on a closed connection, got %v", err)
+	}
+}
@@ -2,9 +2,14 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"time"
 
+	"github.com/atmega-p471/forum-service/internal/delivery/grpc/grpcstatus"
+	"github.com/atmega-p471/forum-service/internal/delivery/grpc/interceptor"
 	"github.com/atmega-p471/forum-service/internal/domain"
+	"github.com/atmega-p471/forum-service/internal/version"
 	"github.com/atmega-p471/forum-service/proto/forum"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
@@ -15,13 +20,20 @@ import (
 type ForumServer struct {
 	forum.UnimplementedForumServiceServer
 	messageUsecase domain.MessageUseCase
+	hub            MessageHub
 	logger         zerolog.Logger
 }
 
+// MessageHub is the broadcast source used for streaming messages
+type MessageHub interface {
+	Subscribe() (<-chan []byte, func())
+}
+
 // NewForumServer creates a new forum gRPC server
-func NewForumServer(messageUsecase domain.MessageUseCase, logger zerolog.Logger) *ForumServer {
+func NewForumServer(messageUsecase domain.MessageUseCase, hub MessageHub, logger zerolog.Logger) *ForumServer {
 	return &ForumServer{
 		messageUsecase: messageUsecase,
+		hub:            hub,
 		logger:         logger,
 	}
 }
@@ -33,10 +45,19 @@ func (s *ForumServer) Register(server *grpc.Server) {
 
 // GetMessages gets messages from the general chat
 func (s *ForumServer) GetMessages(ctx context.Context, req *forum.GetMessagesRequest) (*forum.GetMessagesResponse, error) {
-	messages, total, err := s.messageUsecase.GetMessages(req.Limit, req.Offset)
+	var messages []*domain.Message
+	var total int64
+	var err error
+
+	// Banned messages are only visible to admins who ask for them
+	if req.IncludeBanned && interceptor.IsAdmin(ctx) {
+		messages, total, err = s.getAllMessages(ctx, req.Limit, req.Offset)
+	} else {
+		messages, total, err = s.messageUsecase.GetMessages(ctx, req.Limit, req.Offset)
+	}
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to get messages")
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, grpcstatus.FromError(err)
 	}
 
 	response := &forum.GetMessagesResponse{
@@ -45,46 +66,48 @@ func (s *ForumServer) GetMessages(ctx context.Context, req *forum.GetMessagesReq
 	}
 
 	for _, message := range messages {
-		if !message.IsBanned {
-			response.Messages = append(response.Messages, &forum.Message{
-				Id:        message.ID,
-				UserId:    message.UserID,
-				Username:  message.Username,
-				Content:   message.Content,
-				CreatedAt: message.CreatedAt.Format(time.RFC3339),
-				IsBanned:  message.IsBanned,
-			})
-		}
+		response.Messages = append(response.Messages, toProtoMessage(message))
 	}
 
 	return response, nil
 }
 
+// getAllMessages gets a page of all messages, including banned ones
+func (s *ForumServer) getAllMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	messages, err := s.messageUsecase.GetAllMessages(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := int64(len(messages))
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return messages[offset:end], total, nil
+}
+
 // CreateMessage creates a new message
 func (s *ForumServer) CreateMessage(ctx context.Context, req *forum.CreateMessageRequest) (*forum.CreateMessageResponse, error) {
-	message, err := s.messageUsecase.CreateMessage(req.UserId, req.Username, req.Content)
+	message, err := s.messageUsecase.CreateMessage(ctx, req.UserId, req.Username, req.Content)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to create message")
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, grpcstatus.FromError(err)
 	}
 
 	return &forum.CreateMessageResponse{
-		Message: &forum.Message{
-			Id:        message.ID,
-			UserId:    message.UserID,
-			Username:  message.Username,
-			Content:   message.Content,
-			CreatedAt: message.CreatedAt.Format(time.RFC3339),
-			IsBanned:  message.IsBanned,
-		},
+		Message: toProtoMessage(message),
 	}, nil
 }
 
 // BanMessage bans a message by ID
 func (s *ForumServer) BanMessage(ctx context.Context, req *forum.BanMessageRequest) (*forum.BanMessageResponse, error) {
-	if err := s.messageUsecase.BanMessage(req.Id); err != nil {
+	if err := s.messageUsecase.BanMessage(ctx, req.Id); err != nil {
 		s.logger.Error().Err(err).Int64("id", req.Id).Msg("Failed to ban message")
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, grpcstatus.FromError(err)
 	}
 
 	return &forum.BanMessageResponse{
@@ -94,12 +117,170 @@ func (s *ForumServer) BanMessage(ctx context.Context, req *forum.BanMessageReque
 
 // UnbanMessage unbans a message by ID
 func (s *ForumServer) UnbanMessage(ctx context.Context, req *forum.UnbanMessageRequest) (*forum.UnbanMessageResponse, error) {
-	if err := s.messageUsecase.UnbanMessage(req.Id); err != nil {
+	if err := s.messageUsecase.UnbanMessage(ctx, req.Id); err != nil {
 		s.logger.Error().Err(err).Int64("id", req.Id).Msg("Failed to unban message")
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, grpcstatus.FromError(err)
 	}
 
 	return &forum.UnbanMessageResponse{
 		Success: true,
 	}, nil
 }
+
+// maxBatchModerationIDs caps how many messages one BanMessages or
+// UnbanMessages call can moderate
+const maxBatchModerationIDs = 100
+
+// BanMessages bans several messages at once. Only admins may call it.
+func (s *ForumServer) BanMessages(ctx context.Context, req *forum.BatchModerationRequest) (*forum.BatchModerationResponse, error) {
+	return s.moderateBatch(ctx, "ban", req.Ids, s.messageUsecase.BanMessages)
+}
+
+// UnbanMessages unbans several messages at once. Only admins may call it.
+func (s *ForumServer) UnbanMessages(ctx context.Context, req *forum.BatchModerationRequest) (*forum.BatchModerationResponse, error) {
+	return s.moderateBatch(ctx, "unban", req.Ids, s.messageUsecase.UnbanMessages)
+}
+
+// moderateBatch checks the caller is an admin, applies moderate to ids and
+// reports a result for each ID in the order given
+func (s *ForumServer) moderateBatch(ctx context.Context, action string, ids []int64, moderate func(context.Context, []int64) ([]int64, []int64, error)) (*forum.BatchModerationResponse, error) {
+	admin, ok := interceptor.UserFromContext(ctx)
+	if !ok || admin.Role != "admin" {
+		return nil, status.Error(codes.PermissionDenied, "admin access required")
+	}
+	if len(ids) == 0 || len(ids) > maxBatchModerationIDs {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d ids are required", maxBatchModerationIDs)
+	}
+
+	succeeded, failed, err := moderate(ctx, ids)
+	if err != nil {
+		s.logger.Error().Err(err).Ints64("ids", ids).Str("action", action).Msg("Failed to moderate messages")
+		return nil, grpcstatus.FromError(err)
+	}
+	s.logger.Info().Int64("admin_id", admin.ID).Str("action", action).Ints64("succeeded", succeeded).Ints64("failed", failed).Msg("Moderated messages")
+
+	return &forum.BatchModerationResponse{Results: batchResults(ids, failed)}, nil
+}
+
+// batchResults reports each of ids as successful unless it is in failed
+func batchResults(ids, failed []int64) []*forum.BatchModerationResult {
+	missing := make(map[int64]bool, len(failed))
+	for _, id := range failed {
+		missing[id] = true
+	}
+	results := make([]*forum.BatchModerationResult, len(ids))
+	for i, id := range ids {
+		results[i] = &forum.BatchModerationResult{Id: id, Success: !missing[id]}
+		if missing[id] {
+			results[i].Error = domain.ErrMessageNotFound.Error()
+		}
+	}
+	return results
+}
+
+// StreamMessages streams every new or updated message broadcast by the hub
+// until the client disconnects
+func (s *ForumServer) StreamMessages(req *forum.StreamMessagesRequest, stream forum.ForumService_StreamMessagesServer) error {
+	updates, unsubscribe := s.hub.Subscribe()
+	defer unsubscribe()
+
+	s.logger.Info().Msg("Message stream opened")
+	defer s.logger.Info().Msg("Message stream closed")
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case data, ok := <-updates:
+			if !ok {
+				return status.Error(codes.Unavailable, "message stream closed")
+			}
+
+			var message domain.Message
+			if err := json.Unmarshal(data, &message); err != nil {
+				s.logger.Error().Err(err).Msg("Failed to decode streamed message")
+				continue
+			}
+
+			if err := stream.Send(toProtoMessage(&message)); err != nil {
+				s.logger.Error().Err(err).Msg("Failed to send streamed message")
+				return err
+			}
+		}
+	}
+}
+
+// SyncUsername propagates a username change from the auth service to the
+// user's existing messages and comments
+func (s *ForumServer) SyncUsername(ctx context.Context, req *forum.SyncUsernameRequest) (*forum.SyncUsernameResponse, error) {
+	if req.UserId <= 0 || strings.TrimSpace(req.Username) == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and username are required")
+	}
+
+	updated, err := s.messageUsecase.SyncUsername(ctx, req.UserId, req.Username)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("user_id", req.UserId).Msg("Failed to sync username")
+		return nil, grpcstatus.FromError(err)
+	}
+
+	return &forum.SyncUsernameResponse{
+		Updated: updated,
+	}, nil
+}
+
+// BanUserContent hides all of a user's messages when the auth service bans them
+func (s *ForumServer) BanUserContent(ctx context.Context, req *forum.UserContentRequest) (*forum.UserContentResponse, error) {
+	if req.UserId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	updated, err := s.messageUsecase.BanUserContent(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("user_id", req.UserId).Msg("Failed to ban user content")
+		return nil, grpcstatus.FromError(err)
+	}
+
+	return &forum.UserContentResponse{
+		Updated: updated,
+	}, nil
+}
+
+// UnbanUserContent restores all of a user's messages when the auth service unbans them
+func (s *ForumServer) UnbanUserContent(ctx context.Context, req *forum.UserContentRequest) (*forum.UserContentResponse, error) {
+	if req.UserId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	updated, err := s.messageUsecase.UnbanUserContent(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error().Err(err).Int64("user_id", req.UserId).Msg("Failed to unban user content")
+		return nil, grpcstatus.FromError(err)
+	}
+
+	return &forum.UserContentResponse{
+		Updated: updated,
+	}, nil
+}
+
+// GetVersion reports which build of the service is running
+func (s *ForumServer) GetVersion(ctx context.Context, req *forum.GetVersionRequest) (*forum.GetVersionResponse, error) {
+	info := version.Get()
+	return &forum.GetVersionResponse{
+		Version:   info.Version,
+		Commit:    info.Commit,
+		BuildTime: info.BuildTime,
+		GoVersion: info.GoVersion,
+	}, nil
+}
+
+// toProtoMessage converts a domain message to its protobuf representation
+func toProtoMessage(message *domain.Message) *forum.Message {
+	return &forum.Message{
+		Id:        message.ID,
+		UserId:    message.UserID,
+		Username:  message.Username,
+		Content:   message.Content,
+		CreatedAt: message.CreatedAt.Format(time.RFC3339),
+		IsBanned:  message.IsBanned,
+	}
+}
@@ -0,0 +1,308 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/delivery/grpc/interceptor"
+	"github.com/atmega-p471/forum-service/internal/delivery/ws"
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"github.com/atmega-p471/forum-service/internal/repository"
+	"github.com/atmega-p471/forum-service/internal/usecase"
+	"github.com/atmega-p471/forum-service/internal/version"
+	"github.com/atmega-p471/forum-service/proto/forum"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// mockTokenValidator accepts a fixed set of tokens for testing
+type mockTokenValidator map[string]*domain.User
+
+func (m mockTokenValidator) ValidateToken(token string) (*domain.User, error) {
+	if user, ok := m[token]; ok {
+		return user, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// setupTestServer starts a ForumServer over an in-memory listener and returns a client for it along with the usecase behind it
+func setupTestServer(t *testing.T) (forum.ForumServiceClient, domain.MessageUseCase) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	// Every connection to :memory: is a separate database, so pin the pool to one
+	db.SetMaxOpenConns(1)
+	if err := repository.InitSchema(db); err != nil {
+		t.Fatalf("Failed to initialize test schema: %v", err)
+	}
+
+	hub := ws.NewHub()
+	go hub.Run()
+
+	messageUsecase := usecase.NewMessageUseCase(repository.NewMessageRepository(db), nil, hub)
+
+	lis := bufconn.Listen(1024 * 1024)
+	validator := mockTokenValidator{
+		"user_token":  {ID: 1, Username: "testuser", Role: "user"},
+		"admin_token": {ID: 2, Username: "admin", Role: "admin"},
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(interceptor.AuthUnary(validator)))
+	NewForumServer(messageUsecase, hub, zerolog.Nop()).Register(server)
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial test server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+		db.Close()
+	})
+
+	return forum.NewForumServiceClient(conn), messageUsecase
+}
+
+func TestForumServer_StreamMessages(t *testing.T) {
+	client, _ := setupTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.StreamMessages(ctx, &forum.StreamMessagesRequest{})
+	if err != nil {
+		t.Fatalf("Failed to open message stream: %v", err)
+	}
+
+	// The stream subscribes asynchronously, so keep posting until one arrives
+	received := make(chan *forum.Message, 1)
+	go func() {
+		message, err := stream.Recv()
+		if err == nil {
+			received <- message
+		}
+	}()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message := <-received:
+			if message.Content != "Streamed message" {
+				t.Errorf("Expected streamed content 'Streamed message', got '%s'", message.Content)
+			}
+			if message.Id == 0 {
+				t.Error("Expected streamed message to have an ID")
+			}
+			return
+		case <-ticker.C:
+			_, err := client.CreateMessage(ctx, &forum.CreateMessageRequest{
+				UserId:   0,
+				Username: "anonymous",
+				Content:  "Streamed message",
+			})
+			if err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for streamed message")
+		}
+	}
+}
+
+func TestForumServer_GetMessagesIncludeBanned(t *testing.T) {
+	client, messageUsecase := setupTestServer(t)
+	ctx := context.Background()
+
+	visible, err := messageUsecase.CreateMessage(ctx, 0, "anonymous", "Visible")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	banned, err := messageUsecase.CreateMessage(ctx, 0, "anonymous", "Banned")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if err := messageUsecase.BanMessage(ctx, banned.ID); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		expectedCount int
+	}{
+		{name: "Admin sees banned messages", token: "admin_token", expectedCount: 2},
+		{name: "Flag is ignored for non-admins", token: "user_token", expectedCount: 1},
+		{name: "Flag is ignored for anonymous callers", expectedCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callCtx := ctx
+			if tt.token != "" {
+				callCtx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tt.token)
+			}
+
+			resp, err := client.GetMessages(callCtx, &forum.GetMessagesRequest{Limit: 10, IncludeBanned: true})
+			if err != nil {
+				t.Fatalf("Failed to get messages: %v", err)
+			}
+			if len(resp.Messages) != tt.expectedCount {
+				t.Fatalf("Expected %d messages, got %d", tt.expectedCount, len(resp.Messages))
+			}
+			for _, message := range resp.Messages {
+				if message.IsBanned && tt.expectedCount == 1 {
+					t.Errorf("Expected banned message %d to be hidden", message.Id)
+				}
+				if !message.IsBanned && message.Id != visible.ID {
+					t.Errorf("Unexpected message %d", message.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestForumServer_BatchModeration(t *testing.T) {
+	client, messageUsecase := setupTestServer(t)
+	ctx := context.Background()
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer admin_token")
+
+	var ids []int64
+	for i := 0; i < 2; i++ {
+		message, err := messageUsecase.CreateMessage(ctx, 0, "anonymous", "Message")
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+	request := &forum.BatchModerationRequest{Ids: []int64{ids[0], 999, ids[1]}}
+
+	// Only admins may moderate in bulk
+	for _, callCtx := range []context.Context{ctx, metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer user_token")} {
+		if _, err := client.BanMessages(callCtx, request); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Expected PermissionDenied for a non-admin, got %v", err)
+		}
+	}
+	if _, err := client.BanMessages(adminCtx, &forum.BatchModerationRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without IDs, got %v", err)
+	}
+
+	checkResults := func(resp *forum.BatchModerationResponse) {
+		t.Helper()
+		if len(resp.Results) != 3 {
+			t.Fatalf("Expected 3 results, got %d", len(resp.Results))
+		}
+		for i, result := range resp.Results {
+			if result.Id != request.Ids[i] {
+				t.Errorf("Expected result %d for ID %d, got %d", i, request.Ids[i], result.Id)
+			}
+			if wantSuccess := result.Id != 999; result.Success != wantSuccess || (result.Error == "") != wantSuccess {
+				t.Errorf("Unexpected result for ID %d: %+v", result.Id, result)
+			}
+		}
+	}
+
+	resp, err := client.BanMessages(adminCtx, request)
+	if err != nil {
+		t.Fatalf("BanMessages failed: %v", err)
+	}
+	checkResults(resp)
+	for _, id := range ids {
+		if message, err := messageUsecase.GetByID(ctx, id); err != nil || !message.IsBanned {
+			t.Errorf("Expected message %d to be banned, got %+v (%v)", id, message, err)
+		}
+	}
+
+	resp, err = client.UnbanMessages(adminCtx, request)
+	if err != nil {
+		t.Fatalf("UnbanMessages failed: %v", err)
+	}
+	checkResults(resp)
+	for _, id := range ids {
+		if message, err := messageUsecase.GetByID(ctx, id); err != nil || message.IsBanned {
+			t.Errorf("Expected message %d to be unbanned, got %+v (%v)", id, message, err)
+		}
+	}
+}
+
+func TestForumServer_ReadOnly(t *testing.T) {
+	client, messageUsecase := setupTestServer(t)
+	ctx := context.Background()
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer admin_token")
+
+	message, err := messageUsecase.CreateMessage(ctx, 0, "anonymous", "Before maintenance")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	messageUsecase.SetReadOnly(true)
+
+	writes := map[string]func() error{
+		"CreateMessage": func() error {
+			_, err := client.CreateMessage(ctx, &forum.CreateMessageRequest{Username: "anonymous", Content: "During maintenance"})
+			return err
+		},
+		"BanMessage": func() error {
+			_, err := client.BanMessage(ctx, &forum.BanMessageRequest{Id: message.ID})
+			return err
+		},
+		"BanMessages": func() error {
+			_, err := client.BanMessages(adminCtx, &forum.BatchModerationRequest{Ids: []int64{message.ID}})
+			return err
+		},
+		"SyncUsername": func() error {
+			_, err := client.SyncUsername(ctx, &forum.SyncUsernameRequest{UserId: 1, Username: "renamed"})
+			return err
+		},
+		"BanUserContent": func() error {
+			_, err := client.BanUserContent(ctx, &forum.UserContentRequest{UserId: 1})
+			return err
+		},
+	}
+	for name, write := range writes {
+		if err := write(); status.Code(err) != codes.Unavailable {
+			t.Errorf("%s: expected Unavailable in read-only mode, got %v", name, err)
+		}
+	}
+
+	resp, err := client.GetMessages(ctx, &forum.GetMessagesRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("Expected reads to work in read-only mode, got %v", err)
+	}
+	if len(resp.Messages) != 1 || resp.Messages[0].IsBanned {
+		t.Errorf("Expected the message to be untouched in read-only mode, got %+v", resp.Messages)
+	}
+}
+
+func TestForumServer_GetVersion(t *testing.T) {
+	client, _ := setupTestServer(t)
+
+	defer func(v, c, b string) { version.Version, version.Commit, version.BuildTime = v, c, b }(version.Version, version.Commit, version.BuildTime)
+	version.Version, version.Commit, version.BuildTime = "v1.2.3", "abc1234", "2024-05-01T12:00:00Z"
+
+	resp, err := client.GetVersion(context.Background(), &forum.GetVersionRequest{})
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if resp.Version != "v1.2.3" || resp.Commit != "abc1234" || resp.BuildTime != "2024-05-01T12:00:00Z" || resp.GoVersion != runtime.Version() {
+		t.Errorf("Unexpected version response: %+v", resp)
+	}
+}
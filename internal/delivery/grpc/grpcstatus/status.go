@@ -0,0 +1,30 @@
+// Package grpcstatus maps use case errors to gRPC statuses for the forum servers
+package grpcstatus
+
+import (
+	"errors"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FromError converts a use case error into a gRPC status: Unauthenticated
+// when the caller must sign in, Unavailable while the service is read-only,
+// AlreadyExists for duplicate content, and Internal for anything else.
+// Errors that already carry a status are returned as is.
+func FromError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, domain.ErrAuthRequired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, domain.ErrReadOnly):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, domain.ErrDuplicateContent):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,33 @@
+package grpcstatus
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected codes.Code
+	}{
+		{name: "auth required", err: domain.ErrAuthRequired, expected: codes.Unauthenticated},
+		{name: "read-only", err: domain.ErrReadOnly, expected: codes.Unavailable},
+		{name: "duplicate content", err: fmt.Errorf("create: %w", domain.ErrDuplicateContent), expected: codes.AlreadyExists},
+		{name: "existing status", err: status.Error(codes.InvalidArgument, "bad"), expected: codes.InvalidArgument},
+		{name: "other", err: errors.New("database error"), expected: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(FromError(tt.err)); code != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TokenValidator validates bearer tokens against the auth service
+type TokenValidator interface {
+	ValidateToken(token string) (*domain.User, error)
+}
+
+type userContextKey struct{}
+
+// AuthUnary returns a unary interceptor that resolves the caller from the
+// "authorization: Bearer <token>" metadata and stores them in the context.
+// Calls without a valid token still go through, anonymously.
+func AuthUnary(validator TokenValidator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if token := bearerToken(ctx); token != "" && validator != nil {
+			if user, err := validator.ValidateToken(token); err == nil {
+				ctx = context.WithValue(ctx, userContextKey{}, user)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// UserFromContext returns the authenticated caller, if any
+func UserFromContext(ctx context.Context) (*domain.User, bool) {
+	user, ok := ctx.Value(userContextKey{}).(*domain.User)
+	return user, ok
+}
+
+// IsAdmin reports whether the authenticated caller is an admin
+func IsAdmin(ctx context.Context) bool {
+	user, ok := UserFromContext(ctx)
+	return ok && user.Role == "admin"
+}
+
+// bearerToken extracts the token from the incoming authorization metadata
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, value := range md.Get("authorization") {
+		if token, found := strings.CutPrefix(value, "Bearer "); found {
+			return token
+		}
+	}
+	return ""
+}
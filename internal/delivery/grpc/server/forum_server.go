@@ -2,30 +2,53 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"time"
 
+	"github.com/atmega-p471/forum-service/internal/delivery/grpc/grpcstatus"
+	"github.com/atmega-p471/forum-service/internal/delivery/grpc/interceptor"
 	"github.com/atmega-p471/forum-service/internal/domain"
+	"github.com/atmega-p471/forum-service/internal/version"
 	"github.com/atmega-p471/forum-service/proto/forum"
 	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ForumServer struct {
 	forum.UnimplementedForumServiceServer
 	uc     domain.MessageUseCase
+	hub    MessageHub
 	logger zerolog.Logger
 }
 
-func NewForumServer(uc domain.MessageUseCase, logger zerolog.Logger) *ForumServer {
+// MessageHub is the broadcast source used for streaming messages
+type MessageHub interface {
+	Subscribe() (<-chan []byte, func())
+}
+
+func NewForumServer(uc domain.MessageUseCase, hub MessageHub, logger zerolog.Logger) *ForumServer {
 	return &ForumServer{
 		uc:     uc,
+		hub:    hub,
 		logger: logger,
 	}
 }
 
 func (s *ForumServer) GetMessages(ctx context.Context, req *forum.GetMessagesRequest) (*forum.GetMessagesResponse, error) {
-	messages, total, err := s.uc.GetMessages(req.Limit, req.Offset)
+	var messages []*domain.Message
+	var total int64
+	var err error
+
+	// Banned messages are only visible to admins who ask for them
+	if req.IncludeBanned && interceptor.IsAdmin(ctx) {
+		messages, total, err = s.getAllMessages(ctx, req.Limit, req.Offset)
+	} else {
+		messages, total, err = s.uc.GetMessages(ctx, req.Limit, req.Offset)
+	}
 	if err != nil {
-		return nil, err
+		return nil, grpcstatus.FromError(err)
 	}
 
 	var protoMessages []*forum.Message
@@ -46,10 +69,28 @@ func (s *ForumServer) GetMessages(ctx context.Context, req *forum.GetMessagesReq
 	}, nil
 }
 
+// getAllMessages gets a page of all messages, including banned ones
+func (s *ForumServer) getAllMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	messages, err := s.uc.GetAllMessages(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := int64(len(messages))
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return messages[offset:end], total, nil
+}
+
 func (s *ForumServer) CreateMessage(ctx context.Context, req *forum.CreateMessageRequest) (*forum.CreateMessageResponse, error) {
-	msg, err := s.uc.CreateMessage(req.UserId, req.Username, req.Content)
+	msg, err := s.uc.CreateMessage(ctx, req.UserId, req.Username, req.Content)
 	if err != nil {
-		return nil, err
+		return nil, grpcstatus.FromError(err)
 	}
 
 	return &forum.CreateMessageResponse{
@@ -65,17 +106,141 @@ func (s *ForumServer) CreateMessage(ctx context.Context, req *forum.CreateMessag
 }
 
 func (s *ForumServer) BanMessage(ctx context.Context, req *forum.BanMessageRequest) (*forum.BanMessageResponse, error) {
-	err := s.uc.BanMessage(req.Id)
+	err := s.uc.BanMessage(ctx, req.Id)
 	if err != nil {
-		return nil, err
+		return nil, grpcstatus.FromError(err)
 	}
 	return &forum.BanMessageResponse{Success: true}, nil
 }
 
 func (s *ForumServer) UnbanMessage(ctx context.Context, req *forum.UnbanMessageRequest) (*forum.UnbanMessageResponse, error) {
-	err := s.uc.UnbanMessage(req.Id)
+	err := s.uc.UnbanMessage(ctx, req.Id)
 	if err != nil {
-		return nil, err
+		return nil, grpcstatus.FromError(err)
 	}
 	return &forum.UnbanMessageResponse{Success: true}, nil
 }
+
+// maxBatchModerationIDs caps how many messages one BanMessages or
+// UnbanMessages call can moderate
+const maxBatchModerationIDs = 100
+
+// BanMessages bans several messages at once (admin only)
+func (s *ForumServer) BanMessages(ctx context.Context, req *forum.BatchModerationRequest) (*forum.BatchModerationResponse, error) {
+	return s.moderateBatch(ctx, "ban", req.Ids, s.uc.BanMessages)
+}
+
+// UnbanMessages unbans several messages at once (admin only)
+func (s *ForumServer) UnbanMessages(ctx context.Context, req *forum.BatchModerationRequest) (*forum.BatchModerationResponse, error) {
+	return s.moderateBatch(ctx, "unban", req.Ids, s.uc.UnbanMessages)
+}
+
+// moderateBatch checks the caller is an admin, applies moderate to ids and
+// reports a result for each ID in the order given
+func (s *ForumServer) moderateBatch(ctx context.Context, action string, ids []int64, moderate func(context.Context, []int64) ([]int64, []int64, error)) (*forum.BatchModerationResponse, error) {
+	admin, ok := interceptor.UserFromContext(ctx)
+	if !ok || admin.Role != "admin" {
+		return nil, status.Error(codes.PermissionDenied, "admin access required")
+	}
+	if len(ids) == 0 || len(ids) > maxBatchModerationIDs {
+		return nil, status.Errorf(codes.InvalidArgument, "between 1 and %d ids are required", maxBatchModerationIDs)
+	}
+
+	_, failed, err := moderate(ctx, ids)
+	if err != nil {
+		return nil, grpcstatus.FromError(err)
+	}
+	s.logger.Info().Int64("admin_id", admin.ID).Str("action", action).Ints64("ids", ids).Msg("Moderated messages")
+
+	missing := make(map[int64]bool, len(failed))
+	for _, id := range failed {
+		missing[id] = true
+	}
+	results := make([]*forum.BatchModerationResult, len(ids))
+	for i, id := range ids {
+		results[i] = &forum.BatchModerationResult{Id: id, Success: !missing[id]}
+		if missing[id] {
+			results[i].Error = domain.ErrMessageNotFound.Error()
+		}
+	}
+	return &forum.BatchModerationResponse{Results: results}, nil
+}
+
+func (s *ForumServer) StreamMessages(req *forum.StreamMessagesRequest, stream forum.ForumService_StreamMessagesServer) error {
+	updates, unsubscribe := s.hub.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case data, ok := <-updates:
+			if !ok {
+				return status.Error(codes.Unavailable, "message stream closed")
+			}
+
+			var msg domain.Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				continue
+			}
+
+			err := stream.Send(&forum.Message{
+				Id:        msg.ID,
+				UserId:    msg.UserID,
+				Username:  msg.Username,
+				Content:   msg.Content,
+				CreatedAt: msg.CreatedAt.Format(time.RFC3339),
+				IsBanned:  msg.IsBanned,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *ForumServer) SyncUsername(ctx context.Context, req *forum.SyncUsernameRequest) (*forum.SyncUsernameResponse, error) {
+	if req.UserId <= 0 || strings.TrimSpace(req.Username) == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and username are required")
+	}
+
+	updated, err := s.uc.SyncUsername(ctx, req.UserId, req.Username)
+	if err != nil {
+		return nil, grpcstatus.FromError(err)
+	}
+	return &forum.SyncUsernameResponse{Updated: updated}, nil
+}
+
+func (s *ForumServer) BanUserContent(ctx context.Context, req *forum.UserContentRequest) (*forum.UserContentResponse, error) {
+	if req.UserId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	updated, err := s.uc.BanUserContent(ctx, req.UserId)
+	if err != nil {
+		return nil, grpcstatus.FromError(err)
+	}
+	return &forum.UserContentResponse{Updated: updated}, nil
+}
+
+func (s *ForumServer) UnbanUserContent(ctx context.Context, req *forum.UserContentRequest) (*forum.UserContentResponse, error) {
+	if req.UserId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	updated, err := s.uc.UnbanUserContent(ctx, req.UserId)
+	if err != nil {
+		return nil, grpcstatus.FromError(err)
+	}
+	return &forum.UserContentResponse{Updated: updated}, nil
+}
+
+func (s *ForumServer) GetVersion(ctx context.Context, req *forum.GetVersionRequest) (*forum.GetVersionResponse, error) {
+	info := version.Get()
+	return &forum.GetVersionResponse{
+		Version:   info.Version,
+		Commit:    info.Commit,
+		BuildTime: info.BuildTime,
+		GoVersion: info.GoVersion,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/config"
+)
+
+// corsPolicy is the CORS configuration shared by every route. Each route
+// advertises only the methods it handles.
+type corsPolicy struct {
+	allowedOrigins   []string
+	allowedHeaders   []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+// newCORSPolicy reads the CORS settings from cfg
+func newCORSPolicy(cfg *config.Config) corsPolicy {
+	return corsPolicy{
+		allowedOrigins:   cfg.AllowedOrigins,
+		allowedHeaders:   cfg.CORSAllowedHeaders,
+		allowCredentials: cfg.CORSAllowCredentials,
+		maxAge:           cfg.CORSMaxAge,
+	}
+}
+
+// wrap sets CORS headers on responses from a route handling methods, and
+// answers preflight requests without calling next
+func (p corsPolicy) wrap(methods []string, next http.HandlerFunc) http.HandlerFunc {
+	allowMethods := strings.Join(append(methods[:len(methods):len(methods)], http.MethodOptions), ", ")
+	allowHeaders := strings.Join(p.allowedHeaders, ", ")
+	maxAge := strconv.FormatInt(int64(p.maxAge/time.Second), 10)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		setAllowOrigin(w, r, p.allowedOrigins)
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		if p.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if p.maxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -2,79 +2,73 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/atmega-p471/forum-service/internal/config"
 	"github.com/atmega-p471/forum-service/internal/domain"
 	"github.com/gorilla/mux"
 )
 
 // ForumHandler handles HTTP requests for forum operations
 type ForumHandler struct {
-	usecase domain.MessageUseCase
+	usecase         domain.MessageUseCase
+	defaultPageSize int64
+	maxPageSize     int64
 }
 
 // NewForumHandler creates a new forum handler
 func NewForumHandler(usecase domain.MessageUseCase) *ForumHandler {
 	return &ForumHandler{
-		usecase: usecase,
+		usecase:         usecase,
+		defaultPageSize: config.DefaultPageSize,
+		maxPageSize:     config.DefaultMaxPageSize,
 	}
 }
 
+// SetPageSize sets the limit used when a list request gives none and the
+// largest limit accepted
+func (h *ForumHandler) SetPageSize(defaultSize, maxSize int64) {
+	h.defaultPageSize = defaultSize
+	h.maxPageSize = maxSize
+}
+
 // ListMessages handles GET /api/v1/messages
 func (h *ForumHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	limit := int64(10) // default
-	offset := int64(0) // default
-
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
-	}
-
-	if offsetStr != "" {
-		if o, err := strconv.ParseInt(offsetStr, 10, 64); err == nil {
-			offset = o
-		}
+	limit, offset, err := parsePagination(r, h.defaultPageSize, h.maxPageSize, config.DefaultMaxOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	messages, total, err := h.usecase.GetMessages(limit, offset)
+	messages, total, err := h.usecase.GetMessages(r.Context(), limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	response := map[string]interface{}{
-		"messages": messages,
-		"total":    total,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(newPagedResponse(messages, total, limit, offset))
 }
 
 // CreateMessage handles POST /api/v1/messages
 func (h *ForumHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Content string `json:"content"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.Content == "" {
-		http.Error(w, "Content is required", http.StatusBadRequest)
+	var req CreateMessageRequest
+	if !decodeRequest(w, r, &req, false) {
 		return
 	}
 
 	// For testing, use anonymous user
-	message, err := h.usecase.CreateMessage(0, "anonymous", req.Content)
+	message, err := h.usecase.CreateMessage(r.Context(), 0, "anonymous", req.Content)
 	if err != nil {
+		if errors.Is(err, domain.ErrAuthRequired) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if writeValidationErrors(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -95,14 +89,15 @@ func (h *ForumHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comments, err := h.usecase.GetComments(messageID)
+	comments, err := h.usecase.GetComments(r.Context(), messageID, domain.SortAsc)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
+	total := int64(len(comments))
+	json.NewEncoder(w).Encode(newPagedResponse(comments, total, total, 0))
 }
 
 // CreateComment handles POST /api/v1/messages/{id}/comments
@@ -116,26 +111,24 @@ func (h *ForumHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Content string `json:"content"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req CreateCommentRequest
+	if !decodeRequest(w, r, &req, false) {
 		return
 	}
 
-	if req.Content == "" {
-		http.Error(w, "Content is required", http.StatusBadRequest)
-		return
-	}
-
-	comment, err := h.usecase.CreateComment(messageID, 0, "anonymous", req.Content)
+	comment, err := h.usecase.CreateComment(r.Context(), messageID, 0, "anonymous", req.Content)
 	if err != nil {
-		if err.Error() == "message not found" {
+		if errors.Is(err, domain.ErrMessageNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, domain.ErrThreadLocked) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if writeValidationErrors(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -156,9 +149,9 @@ func (h *ForumHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.usecase.DeleteComment(commentID)
+	err = h.usecase.DeleteComment(r.Context(), commentID)
 	if err != nil {
-		if err.Error() == "comment not found" {
+		if errors.Is(err, domain.ErrCommentNotFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
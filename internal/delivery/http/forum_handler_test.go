@@ -2,11 +2,15 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,23 +22,34 @@ import (
 type MockMessageUseCase struct {
 	messages map[int64]*domain.Message
 	comments map[int64]*domain.Comment
+	mentions []*domain.Mention
+	lastRead map[int64]int64
+	trash    map[int64]*domain.Message
 	nextID   int64
+
+	subscriptions map[int64]map[int64]bool
+	notifications []*domain.Notification
+	readOnly      bool
 }
 
 func NewMockMessageUseCase() *MockMessageUseCase {
 	return &MockMessageUseCase{
 		messages: make(map[int64]*domain.Message),
 		comments: make(map[int64]*domain.Comment),
+		lastRead: make(map[int64]int64),
+		trash:    make(map[int64]*domain.Message),
 		nextID:   1,
+
+		subscriptions: make(map[int64]map[int64]bool),
 	}
 }
 
-func (m *MockMessageUseCase) GetMessages(limit, offset int64) ([]*domain.Message, int64, error) {
+func (m *MockMessageUseCase) GetMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
 	var messages []*domain.Message
 	var count int64
 
 	for _, msg := range m.messages {
-		if !msg.IsBanned {
+		if !msg.IsBanned && !msg.IsHidden && !msg.IsExpired() {
 			count++
 			if count > offset && int64(len(messages)) < limit {
 				messages = append(messages, msg)
@@ -45,7 +60,48 @@ func (m *MockMessageUseCase) GetMessages(limit, offset int64) ([]*domain.Message
 	return messages, count, nil
 }
 
-func (m *MockMessageUseCase) GetAllMessages() ([]*domain.Message, error) {
+func (m *MockMessageUseCase) GetActiveMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	return m.GetMessages(ctx, limit, offset)
+}
+
+func (m *MockMessageUseCase) GetLatestMessage(ctx context.Context) (*domain.Message, error) {
+	var latest *domain.Message
+	for _, msg := range m.messages {
+		if !msg.IsBanned && !msg.IsHidden && (latest == nil || msg.ID > latest.ID) {
+			latest = msg
+		}
+	}
+	if latest == nil {
+		return nil, domain.ErrMessageNotFound
+	}
+	return latest, nil
+}
+
+func (m *MockMessageUseCase) GetTrendingMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	counts := make(map[int64]int)
+	for _, comment := range m.comments {
+		counts[comment.MessageID]++
+	}
+	var messages []*domain.Message
+	for id := range counts {
+		if msg, exists := m.messages[id]; exists && !msg.IsBanned {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return counts[messages[i].ID] > counts[messages[j].ID] })
+
+	total := int64(len(messages))
+	if offset >= total {
+		return nil, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return messages[offset:end], total, nil
+}
+
+func (m *MockMessageUseCase) GetAllMessages(ctx context.Context) ([]*domain.Message, error) {
 	var messages []*domain.Message
 	for _, msg := range m.messages {
 		messages = append(messages, msg)
@@ -53,58 +109,296 @@ func (m *MockMessageUseCase) GetAllMessages() ([]*domain.Message, error) {
 	return messages, nil
 }
 
-func (m *MockMessageUseCase) CreateMessage(userID int64, username, content string) (*domain.Message, error) {
+func (m *MockMessageUseCase) CreateMessage(ctx context.Context, userID int64, username, content string) (*domain.Message, error) {
+	return m.CreateMessageWithOptions(ctx, userID, username, content, domain.MessageOptions{})
+}
+
+func (m *MockMessageUseCase) CreateMessageWithOptions(ctx context.Context, userID int64, username, content string, opts domain.MessageOptions) (*domain.Message, error) {
+	var quoted *domain.Message
+	if opts.ReplyToMessageID != 0 {
+		var exists bool
+		if quoted, exists = m.messages[opts.ReplyToMessageID]; !exists || quoted.IsBanned {
+			return nil, domain.ErrInvalidReplyTarget
+		}
+	}
+
 	if content == "" {
 		return nil, errors.New("content is required")
 	}
 
-	id := m.nextID
-	m.nextID++
-
 	message := &domain.Message{
-		ID:        id,
 		UserID:    userID,
 		Username:  username,
 		Content:   content,
 		CreatedAt: time.Now(),
 		IsBanned:  false,
+		Version:   1,
+	}
+	if err := message.Validate(domain.Limits{}); err != nil {
+		return nil, err
+	}
+
+	id := m.nextID
+	m.nextID++
+	message.ID = id
+	message.Slug = domain.MessageSlug(id, content)
+	if quoted != nil {
+		message.ReplyToMessageID = &quoted.ID
+		message.ReplyTo = domain.NewMessageReference(quoted)
+	}
+	if opts.ExpiresIn > 0 {
+		expiresAt := message.CreatedAt.Add(opts.ExpiresIn)
+		message.ExpiresAt = &expiresAt
 	}
+	message.CommentTTLSeconds = int64(opts.CommentTTL / time.Second)
 
 	m.messages[id] = message
 	return message, nil
 }
 
-func (m *MockMessageUseCase) BanMessage(id int64) error {
+func (m *MockMessageUseCase) UpdateMessage(ctx context.Context, id, userID int64, content string, expectedVersion int64) (*domain.Message, error) {
+	msg, exists := m.messages[id]
+	if !exists {
+		return nil, domain.ErrMessageNotFound
+	}
+	if msg.UserID != userID {
+		return nil, domain.ErrNotMessageAuthor
+	}
+	if msg.Version != expectedVersion {
+		return nil, domain.ErrVersionConflict
+	}
+	msg.Content = content
+	msg.Version++
+	return msg, nil
+}
+
+func (m *MockMessageUseCase) BanMessage(ctx context.Context, id int64) error {
 	if msg, exists := m.messages[id]; exists {
 		msg.IsBanned = true
 		return nil
 	}
-	return errors.New("message not found")
+	return domain.ErrMessageNotFound
 }
 
-func (m *MockMessageUseCase) UnbanMessage(id int64) error {
+func (m *MockMessageUseCase) UnbanMessage(ctx context.Context, id int64) error {
 	if msg, exists := m.messages[id]; exists {
 		msg.IsBanned = false
 		return nil
 	}
-	return errors.New("message not found")
+	return domain.ErrMessageNotFound
+}
+
+func (m *MockMessageUseCase) BanMessages(ctx context.Context, ids []int64) ([]int64, []int64, error) {
+	var banned, failed []int64
+	for _, id := range ids {
+		if msg, exists := m.messages[id]; exists {
+			msg.IsBanned = true
+			banned = append(banned, id)
+		} else {
+			failed = append(failed, id)
+		}
+	}
+	return banned, failed, nil
+}
+
+func (m *MockMessageUseCase) UnbanMessages(ctx context.Context, ids []int64) ([]int64, []int64, error) {
+	var unbanned, failed []int64
+	for _, id := range ids {
+		if msg, exists := m.messages[id]; exists {
+			msg.IsBanned = false
+			unbanned = append(unbanned, id)
+		} else {
+			failed = append(failed, id)
+		}
+	}
+	return unbanned, failed, nil
+}
+
+func (m *MockMessageUseCase) BanUserContent(ctx context.Context, userID int64) (int64, error) {
+	return m.setBannedByUser(userID, true), nil
+}
+
+func (m *MockMessageUseCase) UnbanUserContent(ctx context.Context, userID int64) (int64, error) {
+	return m.setBannedByUser(userID, false), nil
+}
+
+func (m *MockMessageUseCase) setBannedByUser(userID int64, banned bool) int64 {
+	var updated int64
+	for _, msg := range m.messages {
+		if msg.UserID == userID && msg.IsBanned != banned {
+			msg.IsBanned = banned
+			updated++
+		}
+	}
+	return updated
+}
+
+func (m *MockMessageUseCase) PinMessage(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	if !msg.IsPinned {
+		now := time.Now()
+		msg.IsPinned = true
+		msg.PinnedAt = &now
+	}
+	return nil
 }
 
-func (m *MockMessageUseCase) GetByID(id int64) (*domain.Message, error) {
+func (m *MockMessageUseCase) UnpinMessage(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsPinned = false
+	msg.PinnedAt = nil
+	return nil
+}
+
+func (m *MockMessageUseCase) LockMessage(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsLocked = true
+	return nil
+}
+
+func (m *MockMessageUseCase) UnlockMessage(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsLocked = false
+	return nil
+}
+
+func (m *MockMessageUseCase) HideMessage(ctx context.Context, id, userID int64) error {
+	return m.setHidden(id, userID, true)
+}
+
+func (m *MockMessageUseCase) UnhideMessage(ctx context.Context, id, userID int64) error {
+	return m.setHidden(id, userID, false)
+}
+
+func (m *MockMessageUseCase) setHidden(id, userID int64, hidden bool) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	if msg.UserID != userID {
+		return domain.ErrNotMessageAuthor
+	}
+	msg.IsHidden = hidden
+	return nil
+}
+
+func (m *MockMessageUseCase) GetPinnedMessages(ctx context.Context) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for _, msg := range m.messages {
+		if msg.IsPinned && !msg.IsBanned {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].PinnedAt.Before(*messages[j].PinnedAt)
+	})
+	return messages, nil
+}
+
+func (m *MockMessageUseCase) GetByID(ctx context.Context, id int64) (*domain.Message, error) {
 	if msg, exists := m.messages[id]; exists {
 		return msg, nil
 	}
-	return nil, errors.New("message not found")
+	return nil, domain.ErrMessageNotFound
+}
+
+func (m *MockMessageUseCase) GetBySlug(ctx context.Context, slug string) (*domain.Message, error) {
+	for _, msg := range m.messages {
+		if msg.Slug == slug {
+			return msg, nil
+		}
+	}
+	return nil, domain.ErrMessageNotFound
+}
+
+func (m *MockMessageUseCase) GetMessageWithComments(ctx context.Context, id int64) (*domain.MessageDetail, error) {
+	msg, exists := m.messages[id]
+	if !exists {
+		return nil, domain.ErrMessageNotFound
+	}
+	comments, _ := m.GetComments(ctx, id, domain.SortAsc)
+	return &domain.MessageDetail{Message: msg, Comments: comments}, nil
+}
+
+func (m *MockMessageUseCase) GetModerationView(ctx context.Context, id int64) (*domain.MessageModerationView, error) {
+	msg, exists := m.messages[id]
+	if !exists {
+		return nil, domain.ErrMessageNotFound
+	}
+	comments, _ := m.GetComments(ctx, id, domain.SortAsc)
+	view := *msg
+	view.CommentCount = int64(len(comments))
+	return &domain.MessageModerationView{Message: &view}, nil
+}
+
+func (m *MockMessageUseCase) GetMessagesByIDs(ctx context.Context, ids []int64, includeBanned bool) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for _, id := range ids {
+		if msg, exists := m.messages[id]; exists && (includeBanned || !msg.IsBanned && !msg.IsHidden) {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (m *MockMessageUseCase) IsReadOnly() bool {
+	return m.readOnly
+}
+
+func (m *MockMessageUseCase) SetReadOnly(readOnly bool) {
+	m.readOnly = readOnly
+}
+
+func (m *MockMessageUseCase) ImportComments(ctx context.Context, comments []*domain.Comment) ([]int64, error) {
+	for i, comment := range comments {
+		if comment.Content == "" || comment.Username == "" {
+			return nil, fmt.Errorf("comment %d: %w", i, domain.ErrInvalidComment)
+		}
+		msg, exists := m.messages[comment.MessageID]
+		if !exists {
+			return nil, domain.ErrMessageNotFound
+		}
+		if msg.IsLocked {
+			return nil, fmt.Errorf("comment %d: %w", i, domain.ErrThreadLocked)
+		}
+	}
+
+	ids := make([]int64, 0, len(comments))
+	for _, comment := range comments {
+		comment.ID = m.nextID
+		m.nextID++
+		comment.CreatedAt = time.Now()
+		comment.ExpiresAt = comment.CreatedAt.Add(24 * time.Hour)
+		m.comments[comment.ID] = comment
+		ids = append(ids, comment.ID)
+	}
+	return ids, nil
 }
 
-func (m *MockMessageUseCase) CreateComment(messageID, userID int64, username, content string) (*domain.Comment, error) {
+func (m *MockMessageUseCase) CreateComment(ctx context.Context, messageID, userID int64, username, content string) (*domain.Comment, error) {
 	if content == "" {
 		return nil, errors.New("content is required")
 	}
 
-	// Check if message exists
-	if _, exists := m.messages[messageID]; !exists {
-		return nil, errors.New("message not found")
+	// Check if message exists and is open for comments
+	msg, exists := m.messages[messageID]
+	if !exists {
+		return nil, domain.ErrMessageNotFound
+	}
+	if msg.IsLocked {
+		return nil, domain.ErrThreadLocked
 	}
 
 	id := m.nextID
@@ -121,33 +415,321 @@ func (m *MockMessageUseCase) CreateComment(messageID, userID int64, username, co
 	}
 
 	m.comments[id] = comment
+	for subscriber := range m.subscriptions[messageID] {
+		if subscriber != userID {
+			m.notifications = append(m.notifications, &domain.Notification{
+				ID:        int64(len(m.notifications) + 1),
+				UserID:    subscriber,
+				MessageID: messageID,
+				CommentID: id,
+				CreatedAt: comment.CreatedAt,
+			})
+		}
+	}
 	return comment, nil
 }
 
-func (m *MockMessageUseCase) GetComments(messageID int64) ([]*domain.Comment, error) {
+func (m *MockMessageUseCase) GetComments(ctx context.Context, messageID int64, order domain.SortOrder) ([]*domain.Comment, error) {
 	var comments []*domain.Comment
 	for _, comment := range m.comments {
 		if comment.MessageID == messageID && !comment.IsExpired() {
 			comments = append(comments, comment)
 		}
 	}
+	sort.Slice(comments, func(i, j int) bool {
+		if order == domain.SortDesc {
+			return comments[i].ID > comments[j].ID
+		}
+		return comments[i].ID < comments[j].ID
+	})
 	return comments, nil
 }
 
-func (m *MockMessageUseCase) DeleteMessage(id int64) error {
-	if _, exists := m.messages[id]; exists {
+func (m *MockMessageUseCase) DeleteMessage(ctx context.Context, id int64) error {
+	if msg, exists := m.messages[id]; exists {
 		delete(m.messages, id)
+		m.trash[id] = msg
 		return nil
 	}
-	return errors.New("message not found")
+	return domain.ErrMessageNotFound
+}
+
+func (m *MockMessageUseCase) RestoreMessage(ctx context.Context, id int64) (*domain.Message, error) {
+	msg, exists := m.trash[id]
+	if !exists {
+		return nil, domain.ErrMessageNotFound
+	}
+	delete(m.trash, id)
+	m.messages[id] = msg
+	return msg, nil
+}
+
+func (m *MockMessageUseCase) GetMessageTimeSeries(ctx context.Context, interval domain.StatsInterval) ([]domain.TimeBucket, error) {
+	counts := make(map[time.Time]int64)
+	for _, msg := range m.messages {
+		bucket := msg.CreatedAt.UTC().Truncate(time.Hour)
+		if interval == domain.IntervalDay {
+			bucket = time.Date(bucket.Year(), bucket.Month(), bucket.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		counts[bucket]++
+	}
+
+	buckets := []domain.TimeBucket{}
+	for bucket, count := range counts {
+		buckets = append(buckets, domain.TimeBucket{Bucket: bucket, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Bucket.Before(buckets[j].Bucket) })
+	return buckets, nil
+}
+
+func (m *MockMessageUseCase) BanMatchingMessages(ctx context.Context, pattern string) (int64, error) {
+	re, err := domain.CompilePattern(pattern)
+	if err != nil {
+		return 0, err
+	}
+	var banned int64
+	for _, msg := range m.messages {
+		if !msg.IsBanned && re.MatchString(msg.Content) {
+			msg.IsBanned = true
+			banned++
+		}
+	}
+	return banned, nil
+}
+
+func (m *MockMessageUseCase) PurgeBannedMessages(ctx context.Context) (int64, error) {
+	var deleted int64
+	for id, msg := range m.messages {
+		if msg.IsBanned {
+			delete(m.messages, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MockMessageUseCase) UpdateComment(ctx context.Context, id, userID int64, content string) (*domain.Comment, error) {
+	comment, exists := m.comments[id]
+	if !exists {
+		return nil, domain.ErrCommentNotFound
+	}
+	if comment.UserID != userID {
+		return nil, domain.ErrNotCommentAuthor
+	}
+	if comment.IsExpired() {
+		return nil, domain.ErrCommentExpired
+	}
+	comment.Content = content
+	return comment, nil
 }
 
-func (m *MockMessageUseCase) DeleteComment(id int64) error {
+func (m *MockMessageUseCase) DeleteComment(ctx context.Context, id int64) error {
 	if _, exists := m.comments[id]; exists {
 		delete(m.comments, id)
 		return nil
 	}
-	return errors.New("comment not found")
+	return domain.ErrCommentNotFound
+}
+
+func (m *MockMessageUseCase) RenameUser(ctx context.Context, userID int64, username string) (int64, error) {
+	if err := domain.ValidateUsername(username, domain.Limits{}); err != nil {
+		return 0, err
+	}
+	return m.SyncUsername(ctx, userID, username)
+}
+
+func (m *MockMessageUseCase) SyncUsername(ctx context.Context, userID int64, username string) (int64, error) {
+	var updated int64
+	for _, msg := range m.messages {
+		if msg.UserID == userID {
+			msg.Username = username
+			updated++
+		}
+	}
+	for _, comment := range m.comments {
+		if comment.UserID == userID {
+			comment.Username = username
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (m *MockMessageUseCase) PreviewContent(ctx context.Context, content string) (*domain.RenderedContent, error) {
+	return &domain.RenderedContent{Content: content}, nil
+}
+
+func (m *MockMessageUseCase) GetMessagesBetween(ctx context.Context, from, to time.Time, limit, offset int64) ([]*domain.Message, int64, error) {
+	var messages []*domain.Message
+	for _, message := range m.messages {
+		if !message.IsBanned && (from.IsZero() || !message.CreatedAt.Before(from)) && (to.IsZero() || !message.CreatedAt.After(to)) {
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID > messages[j].ID })
+
+	total := int64(len(messages))
+	if offset >= total {
+		return []*domain.Message{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return messages[offset:end], total, nil
+}
+
+func (m *MockMessageUseCase) GetMessagesByUser(ctx context.Context, userID, limit, offset int64) ([]*domain.Message, int64, error) {
+	var messages []*domain.Message
+	for _, message := range m.messages {
+		if message.UserID == userID {
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID > messages[j].ID })
+
+	total := int64(len(messages))
+	if offset >= total {
+		return []*domain.Message{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return messages[offset:end], total, nil
+}
+
+func (m *MockMessageUseCase) GetCommentsByUser(ctx context.Context, userID, limit, offset int64, includeExpired bool) ([]*domain.Comment, int64, error) {
+	var comments []*domain.Comment
+	for _, comment := range m.comments {
+		if comment.UserID == userID && (includeExpired || comment.ExpiresAt.After(time.Now())) {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID > comments[j].ID })
+
+	total := int64(len(comments))
+	if offset >= total {
+		return []*domain.Comment{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return comments[offset:end], total, nil
+}
+
+func (m *MockMessageUseCase) GetRecentComments(ctx context.Context, limit, offset int64) ([]*domain.Comment, int64, error) {
+	var comments []*domain.Comment
+	for _, comment := range m.comments {
+		if msg, exists := m.messages[comment.MessageID]; exists && comment.ExpiresAt.After(time.Now()) {
+			listed := *comment
+			listed.Message = domain.NewMessageReference(msg)
+			comments = append(comments, &listed)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID > comments[j].ID })
+
+	total := int64(len(comments))
+	if offset >= total {
+		return []*domain.Comment{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return comments[offset:end], total, nil
+}
+
+func (m *MockMessageUseCase) SearchMessages(ctx context.Context, query string, limit, offset int64, highlight bool) ([]*domain.SearchResult, int64, error) {
+	query, err := domain.NormalizeSearchQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var results []*domain.SearchResult
+	for _, message := range m.messages {
+		if message.IsBanned || !strings.Contains(strings.ToLower(message.Content), strings.ToLower(query)) {
+			continue
+		}
+		result := &domain.SearchResult{Message: message}
+		if highlight {
+			result.Snippet, result.MatchRanges = domain.Highlight(message.Content, query)
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID > results[j].ID })
+
+	total := int64(len(results))
+	if offset >= total {
+		return []*domain.SearchResult{}, total, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return results[offset:end], total, nil
+}
+
+func (m *MockMessageUseCase) GetUserMentions(ctx context.Context, userID, limit, offset int64) ([]*domain.Mention, int64, error) {
+	var mentions []*domain.Mention
+	for _, mention := range m.mentions {
+		if mention.MentionedUserID == userID {
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions, int64(len(mentions)), nil
+}
+
+func (m *MockMessageUseCase) MarkRead(ctx context.Context, userID, messageID int64) error {
+	if messageID > m.lastRead[userID] {
+		m.lastRead[userID] = messageID
+	}
+	return nil
+}
+
+func (m *MockMessageUseCase) CountUnread(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	for id, msg := range m.messages {
+		if id > m.lastRead[userID] && msg.UserID != userID && !msg.IsBanned {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MockMessageUseCase) SubscribeToMessage(ctx context.Context, messageID, userID int64) error {
+	if msg, exists := m.messages[messageID]; !exists || msg.IsBanned {
+		return domain.ErrMessageNotFound
+	}
+	if m.subscriptions[messageID] == nil {
+		m.subscriptions[messageID] = make(map[int64]bool)
+	}
+	m.subscriptions[messageID][userID] = true
+	return nil
+}
+
+func (m *MockMessageUseCase) UnsubscribeFromMessage(ctx context.Context, messageID, userID int64) error {
+	delete(m.subscriptions[messageID], userID)
+	return nil
+}
+
+func (m *MockMessageUseCase) GetNotifications(ctx context.Context, userID, limit, offset int64) ([]*domain.Notification, int64, error) {
+	var notifications []*domain.Notification
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		if m.notifications[i].UserID == userID {
+			notifications = append(notifications, m.notifications[i])
+		}
+	}
+	total := int64(len(notifications))
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	if offset > end {
+		offset = end
+	}
+	return notifications[offset:end], total, nil
 }
 
 func TestForumHandler_ListMessages(t *testing.T) {
@@ -155,11 +737,11 @@ func TestForumHandler_ListMessages(t *testing.T) {
 	handler := NewForumHandler(usecase)
 
 	// Create test messages
-	_, err := usecase.CreateMessage(1, "user1", "Test message 1")
+	_, err := usecase.CreateMessage(context.Background(), 1, "user1", "Test message 1")
 	if err != nil {
 		t.Fatalf("Failed to create test message: %v", err)
 	}
-	_, err = usecase.CreateMessage(2, "user2", "Test message 2")
+	_, err = usecase.CreateMessage(context.Background(), 2, "user2", "Test message 2")
 	if err != nil {
 		t.Fatalf("Failed to create test message: %v", err)
 	}
@@ -182,9 +764,9 @@ func TestForumHandler_ListMessages(t *testing.T) {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
 
-	messages, ok := response["messages"].([]interface{})
+	messages, ok := response["items"].([]interface{})
 	if !ok {
-		t.Error("Response should contain messages array")
+		t.Error("Response should contain items array")
 	}
 
 	if len(messages) != 2 {
@@ -201,6 +783,83 @@ func TestForumHandler_ListMessages(t *testing.T) {
 	}
 }
 
+func TestForumHandler_ListMessagesPagination(t *testing.T) {
+	usecase := NewMockMessageUseCase()
+	handler := NewForumHandler(usecase)
+
+	for i := 0; i < 150; i++ {
+		if _, err := usecase.CreateMessage(context.Background(), 1, "user1", "Test message"); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "Default limit", query: "", expectedStatus: http.StatusOK, expectedCount: 10},
+		{name: "Limit over cap", query: "?limit=500", expectedStatus: http.StatusOK, expectedCount: 100},
+		{name: "Negative offset", query: "?offset=-10", expectedStatus: http.StatusBadRequest},
+		{name: "Non-numeric limit", query: "?limit=ten", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/v1/messages"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ListMessages(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			messages, _ := response["items"].([]interface{})
+			if len(messages) != tt.expectedCount {
+				t.Errorf("Expected %d messages, got %d", tt.expectedCount, len(messages))
+			}
+		})
+	}
+}
+
+func TestForumHandler_SetPageSize(t *testing.T) {
+	usecase := NewMockMessageUseCase()
+	handler := NewForumHandler(usecase)
+	handler.SetPageSize(3, 5)
+
+	for i := 0; i < 10; i++ {
+		if _, err := usecase.CreateMessage(context.Background(), 1, "user1", "Test message"); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	for query, expected := range map[string]int{"": 3, "?limit=4": 4, "?limit=50": 5} {
+		rr := httptest.NewRecorder()
+		handler.ListMessages(rr, httptest.NewRequest("GET", "/api/v1/messages"+query, nil))
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		messages, _ := response["items"].([]interface{})
+		if len(messages) != expected {
+			t.Errorf("%q: expected %d messages, got %d", query, expected, len(messages))
+		}
+	}
+}
+
 func TestForumHandler_CreateMessage(t *testing.T) {
 	usecase := NewMockMessageUseCase()
 	handler := NewForumHandler(usecase)
@@ -280,13 +939,13 @@ func TestForumHandler_GetComments(t *testing.T) {
 	handler := NewForumHandler(usecase)
 
 	// Create test message
-	message, err := usecase.CreateMessage(1, "user1", "Test message")
+	message, err := usecase.CreateMessage(context.Background(), 1, "user1", "Test message")
 	if err != nil {
 		t.Fatalf("Failed to create test message: %v", err)
 	}
 
 	// Create test comment
-	_, err = usecase.CreateComment(message.ID, 2, "user2", "Test comment")
+	_, err = usecase.CreateComment(context.Background(), message.ID, 2, "user2", "Test comment")
 	if err != nil {
 		t.Fatalf("Failed to create test comment: %v", err)
 	}
@@ -307,17 +966,20 @@ func TestForumHandler_GetComments(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	var comments []map[string]interface{}
-	err = json.Unmarshal(rr.Body.Bytes(), &comments)
+	var response struct {
+		Items []map[string]interface{} `json:"items"`
+		Total int64                    `json:"total"`
+	}
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
 	if err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
 	}
 
-	if len(comments) != 1 {
-		t.Errorf("Expected 1 comment, got %d", len(comments))
+	if len(response.Items) != 1 || response.Total != 1 {
+		t.Fatalf("Expected 1 comment of 1, got %d of %d", len(response.Items), response.Total)
 	}
 
-	if content, ok := comments[0]["content"].(string); !ok || content != "Test comment" {
+	if content, ok := response.Items[0]["content"].(string); !ok || content != "Test comment" {
 		t.Error("Comment should have correct content")
 	}
 }
@@ -327,7 +989,7 @@ func TestForumHandler_CreateComment(t *testing.T) {
 	handler := NewForumHandler(usecase)
 
 	// Create test message
-	message, err := usecase.CreateMessage(1, "user1", "Test message")
+	message, err := usecase.CreateMessage(context.Background(), 1, "user1", "Test message")
 	if err != nil {
 		t.Fatalf("Failed to create test message: %v", err)
 	}
@@ -408,12 +1070,12 @@ func TestForumHandler_DeleteComment(t *testing.T) {
 	handler := NewForumHandler(usecase)
 
 	// Create test message and comment
-	message, err := usecase.CreateMessage(1, "user1", "Test message")
+	message, err := usecase.CreateMessage(context.Background(), 1, "user1", "Test message")
 	if err != nil {
 		t.Fatalf("Failed to create test message: %v", err)
 	}
 
-	comment, err := usecase.CreateComment(message.ID, 2, "user2", "Test comment")
+	comment, err := usecase.CreateComment(context.Background(), message.ID, 2, "user2", "Test comment")
 	if err != nil {
 		t.Fatalf("Failed to create test comment: %v", err)
 	}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipMiddleware compresses responses of at least minSize bytes for clients
+// that send Accept-Encoding: gzip. Smaller responses are sent as-is, and a
+// non-positive minSize disables compression. It buffers the start of the
+// body, so it must not wrap streaming endpoints.
+func gzipMiddleware(minSize int64, next http.HandlerFunc) http.HandlerFunc {
+	if minSize <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: int(minSize)}
+		defer gw.Close()
+		next(gw, r)
+	}
+}
+
+// acceptsGzip reports whether the client accepts gzip-encoded responses
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the response until it knows whether the body
+// reaches minSize, then either compresses it or writes it unchanged
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	// direct is set once the body is being written uncompressed
+	direct bool
+}
+
+// WriteHeader records the status code until the encoding is decided
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+// Write buffers the body until it reaches minSize, then starts compressing
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	switch {
+	case g.gz != nil:
+		return g.gz.Write(p)
+	case g.direct:
+		return g.ResponseWriter.Write(p)
+	}
+
+	g.buf = append(g.buf, p...)
+	if len(g.buf) < g.minSize {
+		return len(p), nil
+	}
+
+	// Leave bodies the handler already encoded alone
+	if g.Header().Get("Content-Encoding") != "" {
+		if err := g.flushDirect(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	g.Header().Set("Content-Encoding", "gzip")
+	g.Header().Del("Content-Length")
+	g.writeHeader()
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	if _, err := g.gz.Write(g.buf); err != nil {
+		return 0, err
+	}
+	g.buf = nil
+	return len(p), nil
+}
+
+// Close finishes the response, writing any buffered body that stayed below minSize
+func (g *gzipResponseWriter) Close() error {
+	switch {
+	case g.gz != nil:
+		return g.gz.Close()
+	case g.direct:
+		return nil
+	}
+	return g.flushDirect()
+}
+
+// flushDirect writes the header and buffered body without compression
+func (g *gzipResponseWriter) flushDirect() error {
+	g.direct = true
+	g.writeHeader()
+	_, err := g.ResponseWriter.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+// writeHeader sends the recorded status code, defaulting to 200
+func (g *gzipResponseWriter) writeHeader() {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+}
@@ -3,13 +3,21 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/atmega-p471/forum-service/internal/config"
 	"github.com/atmega-p471/forum-service/internal/delivery/ws"
 	"github.com/atmega-p471/forum-service/internal/domain"
+	"github.com/atmega-p471/forum-service/internal/ratelimit"
+	"github.com/atmega-p471/forum-service/internal/version"
+	"github.com/gorilla/websocket"
 )
 
 // Handler handles HTTP requests
@@ -17,34 +25,143 @@ type Handler struct {
 	useCase    domain.MessageUseCase
 	hub        *ws.Hub
 	authClient AuthClient
+	cfg        *config.Config
+
+	// messageLimiter, commentLimiter and previewLimiter are independent per-user budgets
+	messageLimiter RateLimiter
+	commentLimiter RateLimiter
+	previewLimiter RateLimiter
+
+	// trustedProxies may report the client IP in forwarding headers
+	trustedProxies []*net.IPNet
+
+	// tokens caches validated tokens for cfg.TokenCacheTTL
+	tokens *tokenCache
+	// cors sets the CORS headers on every route
+	cors corsPolicy
+
+	upgrader websocket.Upgrader
 }
 
 // AuthClient interface for auth service client
 type AuthClient interface {
 	ValidateToken(token string) (*domain.User, error)
+	// Ping returns domain.ErrAuthUnavailable when the auth service can't be reached
+	Ping(ctx context.Context) error
 }
 
 // NewHandler creates a new handler
-func NewHandler(useCase domain.MessageUseCase, hub *ws.Hub, authClient AuthClient) *Handler {
-	return &Handler{
-		useCase:    useCase,
-		hub:        hub,
-		authClient: authClient,
+func NewHandler(useCase domain.MessageUseCase, hub *ws.Hub, authClient AuthClient, cfg *config.Config) *Handler {
+	h := &Handler{
+		useCase:        useCase,
+		hub:            hub,
+		authClient:     authClient,
+		cfg:            cfg,
+		messageLimiter: ratelimit.NewMemory(cfg.MessagesPerMinute),
+		commentLimiter: ratelimit.NewMemory(cfg.CommentsPerMinute),
+		previewLimiter: ratelimit.NewMemory(cfg.PreviewsPerMinute),
+		trustedProxies: parseTrustedProxies(cfg.TrustedProxies),
+		tokens:         newTokenCache(cfg.TokenCacheTTL),
+		cors:           newCORSPolicy(cfg),
 	}
+	h.upgrader.CheckOrigin = h.checkOrigin
+	return h
 }
 
-// RegisterRoutes registers the routes
+// RegisterRoutes registers the routes. Each route lists the methods it
+// handles, which its CORS preflight responses advertise.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	timeout, gzipMinSize, stringIDs := h.cfg.RequestTimeout, h.cfg.GzipMinSize, h.cfg.JSONStringIDs
+	handle := func(pattern string, handler http.HandlerFunc, methods ...string) {
+		mux.HandleFunc(pattern, h.cors.wrap(methods, gzipMiddleware(gzipMinSize, stringIDsMiddleware(stringIDs, timeoutMiddleware(timeout, readOnlyMiddleware(h.useCase, handler))))))
+	}
+
 	// Register specific routes first
-	mux.HandleFunc("/api/v1/messages/ban", h.handleBanMessage)
-	mux.HandleFunc("/api/v1/messages/unban", h.handleUnbanMessage)
+	handle("/api/v1/messages/ban", h.handleBanMessage, http.MethodPost)
+	handle("/api/v1/messages/unban", h.handleUnbanMessage, http.MethodPost)
+	handle("/api/v1/messages/unban-bulk", h.handleUnbanMessages, http.MethodPost)
+
+	// Streams stay open indefinitely, so they don't get a request timeout or
+	// compression. WebSocket handshakes aren't subject to CORS; checkOrigin
+	// vets them instead.
+	mux.HandleFunc("/api/v1/messages/stream", h.cors.wrap([]string{http.MethodGet}, h.handleMessageStream))
+	mux.HandleFunc("/ws", h.handleWebsocket)
+	handle("/api/v1/messages/pinned", h.handlePinnedMessages, http.MethodGet)
+	handle("/api/v1/messages/read", h.handleMarkRead, http.MethodPost)
+	handle("/api/v1/messages/unread-count", h.handleUnreadCount, http.MethodGet)
+	handle("/api/v1/messages/search", h.handleSearchMessages, http.MethodGet)
+	handle("/api/v1/messages/trending", h.handleTrendingMessages, http.MethodGet)
+	handle("/api/v1/messages/latest", h.handleLatestMessage, http.MethodGet)
+	handle("/api/v1/messages/slug/", h.handleMessageBySlug, http.MethodGet)
 
 	// Register exact match for messages list
-	mux.HandleFunc("/api/v1/messages", h.handleMessages)
+	handle("/api/v1/messages", h.handleMessages, http.MethodGet, http.MethodPost)
 
 	// Register specific message operations
-	mux.HandleFunc("/api/v1/messages/", h.handleMessageWithID)
-	mux.HandleFunc("/api/v1/comments/", h.handleCommentWithID)
+	handle("/api/v1/messages/", h.handleMessageWithID, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+	handle("/api/v1/comments/", h.handleCommentWithID, http.MethodPut, http.MethodDelete)
+	handle("/api/v1/admin/comments", h.handleRecentComments, http.MethodGet)
+	handle("/api/v1/admin/comments/bulk", h.handleBulkComments, http.MethodPost)
+	handle("/api/v1/admin/messages/purge-banned", h.handlePurgeBanned, http.MethodPost)
+	handle("/api/v1/admin/messages/ban-matching", h.handleBanMatching, http.MethodPost)
+	handle("/api/v1/admin/messages/", h.handleAdminMessageWithID, http.MethodGet, http.MethodPost)
+	handle("/api/v1/admin/stats/timeseries", h.handleMessageTimeSeries, http.MethodGet)
+	handle("/api/v1/admin/users/", h.handleAdminUserWithID, http.MethodPost)
+	handle("/api/v1/admin/readonly", h.handleReadOnly, http.MethodGet, http.MethodPost)
+	handle("/api/v1/users/", h.handleUserWithID, http.MethodGet)
+	handle("/api/v1/me/messages", h.handleMyMessages, http.MethodGet)
+	handle("/api/v1/me/notifications", h.handleMyNotifications, http.MethodGet)
+	handle("/api/v1/preview", h.handlePreview, http.MethodPost)
+	handle("/api/v1/uploads", h.handleUpload, http.MethodPost)
+	handle("/api/v1/uploads/", h.serveUpload, http.MethodGet)
+	handle("/api/v1/version", h.handleVersion, http.MethodGet)
+	handle("/api/v1/presence", h.handlePresence, http.MethodGet)
+	handle("/readyz", h.handleReadyz, http.MethodGet)
+}
+
+// handleReadyz reports whether the service's dependencies are usable,
+// responding 503 when the auth service is down
+func (h *Handler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	status, auth, code := "ready", "ok", http.StatusOK
+	if err := h.authClient.Ping(r.Context()); err != nil {
+		log.Printf("Readiness check: auth service unavailable: %v", err)
+		status, auth, code = "not ready", "down", http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": status,
+		"auth":   auth,
+	})
+}
+
+// handleVersion handles GET /api/v1/version, reporting which build is running
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
+
+// handlePresence handles GET /api/v1/presence, reporting how many WebSocket
+// clients are connected
+func (h *Handler) handlePresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"count": h.hub.Online()})
 }
 
 // authMiddleware extracts user info from token
@@ -67,24 +184,67 @@ func (h *Handler) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		log.Printf("Validating token: %s...", token[:min(len(token), 20)])
-
-		// Validate token and get user info
-		user, err := h.authClient.ValidateToken(token)
-		if err != nil {
-			log.Printf("Token validation failed: %v", err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+		user, cached := h.tokens.get(token)
+		if !cached {
+			// Fail fast instead of waiting on a dead auth connection
+			if err := h.authClient.Ping(r.Context()); err != nil {
+				log.Printf("Auth service unavailable: %v", err)
+				http.Error(w, "Auth service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			log.Printf("Validating token: %s...", token[:min(len(token), 20)])
+
+			// Validate token and get user info
+			var err error
+			user, err = h.authClient.ValidateToken(token)
+			if err != nil {
+				log.Printf("Token validation failed for %s: %v", clientIP(r, h.trustedProxies), err)
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			h.tokens.put(token, user)
+
+			log.Printf("Token validated successfully for user: ID=%d, Username='%s'", user.ID, user.Username)
 		}
 
-		log.Printf("Token validated successfully for user: ID=%d, Username='%s'", user.ID, user.Username)
-
 		// Add user to request context
 		ctx := context.WithValue(r.Context(), "user", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// isAdminRequest reports whether the request carries a valid admin token.
+// Unlike authMiddleware it never rejects the request, so it can be used on public endpoints.
+func (h *Handler) isAdminRequest(r *http.Request) bool {
+	user, ok := h.optionalUser(r)
+	return ok && user.Role == "admin"
+}
+
+// optionalUser returns the user a valid bearer token belongs to, if the request carries one
+func (h *Handler) optionalUser(r *http.Request) (*domain.User, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return nil, false
+	}
+
+	if user, ok := h.tokens.get(token); ok {
+		return user, true
+	}
+	user, err := h.authClient.ValidateToken(token)
+	if err != nil {
+		return nil, false
+	}
+	h.tokens.put(token, user)
+	return user, true
+}
+
+// ForgetUser drops the user's cached tokens, so their next request is
+// validated with the auth service again. Call it when the user is banned.
+func (h *Handler) ForgetUser(userID int64) {
+	h.tokens.forgetUser(userID)
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -100,65 +260,235 @@ func getUserFromContext(r *http.Request) (*domain.User, bool) {
 
 // handleMessages handles GET and POST requests to /api/v1/messages
 func (h *Handler) handleMessages(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:8000")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	w.Header().Set("Access-Control-Max-Age", "3600")
-
-	// Handle preflight request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	switch r.Method {
 	case http.MethodGet:
 		h.getMessages(w, r)
 	case http.MethodPost:
-		h.authMiddleware(h.createMessage)(w, r)
+		h.authMiddleware(h.rateLimit(h.messageLimiter, requireJSON(h.createMessage)))(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet, http.MethodPost)
+	}
+}
+
+// handlePreview handles POST /api/v1/preview, returning content as it would
+// be stored by POST /api/v1/messages without storing it
+func (h *Handler) handlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	h.rateLimit(h.previewLimiter, requireJSON(func(w http.ResponseWriter, r *http.Request) {
+		var req PreviewRequest
+		if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+			return
+		}
+
+		rendered, err := h.useCase.PreviewContent(r.Context(), req.Content)
+		if err != nil {
+			if writeValidationErrors(w, err) {
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(rendered); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	}))(w, r)
+}
+
+// handleSearchMessages handles GET /api/v1/messages/search?q=..., listing
+// matching messages newest first. With highlight=true each result includes a
+// snippet around the match and the match ranges within it.
+func (h *Handler) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	highlight := false
+	if highlightStr := r.URL.Query().Get("highlight"); highlightStr != "" {
+		highlight, err = strconv.ParseBool(highlightStr)
+		if err != nil {
+			http.Error(w, "invalid highlight parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, total, err := h.useCase.SearchMessages(r.Context(), r.URL.Query().Get("q"), limit, offset, highlight)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidSearchQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error searching messages: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newPagedResponse(results, total, limit, offset)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleTrendingMessages handles GET /api/v1/messages/trending, listing
+// messages with the most comments within the trending window first
+func (h *Handler) handleTrendingMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	truncate, err := parseTruncate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, total, err := h.useCase.GetTrendingMessages(r.Context(), limit, offset)
+	if err != nil {
+		log.Printf("Error getting trending messages: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newPagedResponse(truncateMessages(messages, truncate), total, limit, offset)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
 	}
 }
 
+// maxBulkMessageIDs caps how many IDs can be requested via ?ids= at once
+const maxBulkMessageIDs = 100
+
 // getMessages returns a list of messages
 func (h *Handler) getMessages(w http.ResponseWriter, r *http.Request) {
+	if idsStr := r.URL.Query().Get("ids"); idsStr != "" {
+		h.getMessagesByIDs(w, r, idsStr)
+		return
+	}
+
 	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
+	limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	limit := int64(10) // default limit
-	if limitStr != "" {
-		if l, err := strconv.ParseInt(limitStr, 10, 64); err == nil {
-			limit = l
-		}
+	truncate, err := parseTruncate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Pick the ordering: newest first by default, or most recently active
+	getMessages := h.useCase.GetMessages
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", "new":
+	case "active":
+		getMessages = h.useCase.GetActiveMessages
+	default:
+		http.Error(w, "Invalid sort option", http.StatusBadRequest)
+		return
 	}
 
-	offset := int64(0) // default offset
-	if offsetStr != "" {
-		if o, err := strconv.ParseInt(offsetStr, 10, 64); err == nil {
-			offset = o
+	// A date range lists messages by creation time, so it only goes with sort=new
+	from, to, ranged, err := parseDateRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ranged {
+		if r.URL.Query().Get("sort") == "active" {
+			http.Error(w, "from and to cannot be combined with sort=active", http.StatusBadRequest)
+			return
+		}
+		getMessages = func(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+			return h.useCase.GetMessagesBetween(ctx, from, to, limit, offset)
 		}
 	}
 
 	log.Printf("Getting messages with limit: %d, offset: %d", limit, offset)
 
 	// Get messages
-	messages, total, err := h.useCase.GetMessages(limit, offset)
+	messages, total, err := getMessages(r.Context(), limit, offset)
 	if err != nil {
 		log.Printf("Error getting messages: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	response := newPagedResponse(truncateMessages(messages, truncate), total, limit, offset)
+	response.Warning = deepPaginationWarning(total, limit, h.cfg.MaxOffset)
+	setPaginationLinks(w, r, total, limit, offset, h.cfg.MaxOffset)
+
 	// Return messages
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"messages": messages,
-		"total":    total,
-	}); err != nil {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding messages response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getMessagesByIDs returns the requested messages in the order given
+func (h *Handler) getMessagesByIDs(w http.ResponseWriter, r *http.Request, idsStr string) {
+	truncate, err := parseTruncate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, part := range strings.Split(idsStr, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid message ID in ids", http.StatusBadRequest)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > maxBulkMessageIDs {
+		http.Error(w, "Too many message IDs requested", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Getting %d messages by IDs", len(ids))
+
+	messages, err := h.useCase.GetMessagesByIDs(r.Context(), ids, h.isAdminRequest(r))
+	if err != nil {
+		log.Printf("Error getting messages by IDs: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	total := int64(len(messages))
+	if err := json.NewEncoder(w).Encode(newPagedResponse(truncateMessages(messages, truncate), total, total, 0)); err != nil {
 		log.Printf("Error encoding messages response: %v", err)
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
@@ -175,23 +505,31 @@ func (h *Handler) createMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse request
-	var req struct {
-		Content string `json:"content"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding message request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req CreateMessageRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
 		return
 	}
 
 	log.Printf("Creating message for user %d (%s): %s", user.ID, user.Username, req.Content)
 
 	// Create message using user info from token
-	message, err := h.useCase.CreateMessage(user.ID, user.Username, req.Content)
+	message, err := h.useCase.CreateMessageWithOptions(r.Context(), user.ID, user.Username, req.Content, domain.MessageOptions{
+		ReplyToMessageID: req.ReplyToMessageID,
+		ExpiresIn:        time.Duration(req.ExpiresInSeconds) * time.Second,
+		CommentTTL:       time.Duration(req.CommentTTLSeconds) * time.Second,
+	})
 	if err != nil {
 		log.Printf("Error creating message: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if writeValidationErrors(w, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidReplyTarget) || errors.Is(err, domain.ErrInvalidCommentTTL) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if errors.Is(err, domain.ErrDuplicateContent) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -208,22 +546,22 @@ func (h *Handler) createMessage(w http.ResponseWriter, r *http.Request) {
 // handleBanMessage handles POST requests to /api/v1/messages/ban
 func (h *Handler) handleBanMessage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
 		return
 	}
-
-	// Parse request
-	var req struct {
-		ID int64 `json:"id"`
+	if !isJSONRequest(r) {
+		writeUnsupportedMediaType(w)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Parse request
+	var req MessageIDRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
 		return
 	}
 
 	// Ban message
-	if err := h.useCase.BanMessage(req.ID); err != nil {
+	if err := h.useCase.BanMessage(r.Context(), req.ID); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -236,22 +574,22 @@ func (h *Handler) handleBanMessage(w http.ResponseWriter, r *http.Request) {
 // handleUnbanMessage handles POST requests to /api/v1/messages/unban
 func (h *Handler) handleUnbanMessage(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
 		return
 	}
-
-	// Parse request
-	var req struct {
-		ID int64 `json:"id"`
+	if !isJSONRequest(r) {
+		writeUnsupportedMediaType(w)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Parse request
+	var req MessageIDRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
 		return
 	}
 
 	// Unban message
-	if err := h.useCase.UnbanMessage(req.ID); err != nil {
+	if err := h.useCase.UnbanMessage(r.Context(), req.ID); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -261,6 +599,40 @@ func (h *Handler) handleUnbanMessage(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
+// handleUnbanMessages handles POST /api/v1/messages/unban-bulk, unbanning
+// several messages at once and reporting which IDs didn't exist (admin only)
+func (h *Handler) handleUnbanMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	h.authAdminMiddleware(requireJSON(func(w http.ResponseWriter, r *http.Request) {
+		var req MessageIDsRequest
+		if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+			return
+		}
+
+		unbanned, failed, err := h.useCase.UnbanMessages(r.Context(), req.IDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if unbanned == nil {
+			unbanned = []int64{}
+		}
+		if failed == nil {
+			failed = []int64{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]int64{
+			"succeeded": unbanned,
+			"failed":    failed,
+		})
+	}))(w, r)
+}
+
 // authAdminMiddleware checks for admin role
 func (h *Handler) authAdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
@@ -282,74 +654,65 @@ func (h *Handler) authAdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 // handleMessageWithID handles operations on specific messages
 func (h *Handler) handleMessageWithID(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:8000")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	// Handle preflight request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	path := r.URL.Path
 	log.Printf("Handling message with ID: %s %s", r.Method, path)
 
-	// Skip if it's ban/unban which are handled separately
-	if strings.HasSuffix(path, "/ban") || strings.HasSuffix(path, "/unban") {
-		http.Error(w, "Route handled elsewhere", http.StatusBadRequest)
+	// Paths are /api/v1/messages/{id} or /api/v1/messages/{id}/{action}
+	rest := strings.Trim(strings.TrimPrefix(path, "/api/v1/messages/"), "/")
+	if rest == "" {
+		http.Error(w, "Message ID required", http.StatusBadRequest)
 		return
 	}
 
-	// Handle comments endpoint: /api/v1/messages/{id}/comments
-	if strings.Contains(path, "/comments") {
-		parts := strings.Split(strings.TrimPrefix(path, "/api/v1/messages/"), "/")
-		if len(parts) != 2 || parts[1] != "comments" {
-			http.Error(w, "Invalid comments path", http.StatusBadRequest)
-			return
-		}
-
-		messageID, err := strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid message ID for comments", http.StatusBadRequest)
-			return
-		}
+	idStr, action, _ := strings.Cut(rest, "/")
+	messageID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		log.Printf("Failed to parse message ID '%s': %v", idStr, err)
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
 
+	switch action {
+	case "":
+		h.handleSingleMessage(w, r, messageID)
+	case "pin", "pin-status":
+		h.handleMessagePin(w, r, messageID, action)
+	case "lock", "unlock":
+		h.handleMessageLock(w, r, messageID, action)
+	case "hide", "unhide":
+		h.handleMessageHide(w, r, messageID, action)
+	case "subscribe":
+		h.handleMessageSubscribe(w, r, messageID)
+	case "comments":
 		switch r.Method {
 		case http.MethodGet:
 			h.getComments(w, r, messageID)
 		case http.MethodPost:
-			h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			h.authMiddleware(h.rateLimit(h.commentLimiter, requireJSON(func(w http.ResponseWriter, r *http.Request) {
 				h.createComment(w, r, messageID)
-			})(w, r)
+			})))(w, r)
 		default:
-			http.Error(w, "Method not allowed for comments", http.StatusMethodNotAllowed)
+			writeMethodNotAllowed(w, "Method not allowed for comments", http.MethodGet, http.MethodPost)
 		}
-		return
-	}
-
-	// Handle single message operations: /api/v1/messages/{id}
-	idStr := strings.TrimPrefix(path, "/api/v1/messages/")
-	idStr = strings.TrimSuffix(idStr, "/")
-
-	if idStr == "" {
-		http.Error(w, "Message ID required", http.StatusBadRequest)
-		return
-	}
-
-	messageID, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		log.Printf("Failed to parse message ID '%s': %v", idStr, err)
-		http.Error(w, "Invalid message ID", http.StatusBadRequest)
-		return
+	case "ban", "unban":
+		// Ban and unban are handled separately
+		http.Error(w, "Route handled elsewhere", http.StatusBadRequest)
+	default:
+		http.Error(w, "Invalid message path", http.StatusBadRequest)
 	}
+}
 
+// handleSingleMessage handles /api/v1/messages/{id}
+func (h *Handler) handleSingleMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
 	log.Printf("Processing message ID: %d, method: %s", messageID, r.Method)
 
 	switch r.Method {
 	case http.MethodGet:
 		h.getSingleMessage(w, r, messageID)
+	case http.MethodPut:
+		h.authMiddleware(requireJSON(func(w http.ResponseWriter, r *http.Request) {
+			h.updateMessage(w, r, messageID)
+		}))(w, r)
 	case http.MethodDelete:
 		// Check if this is a permanent delete (admin only)
 		if r.URL.Query().Get("action") == "delete" {
@@ -365,23 +728,12 @@ func (h *Handler) handleMessageWithID(w http.ResponseWriter, r *http.Request) {
 			})(w, r)
 		}
 	default:
-		http.Error(w, "Method not allowed for message", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Method not allowed for message", http.MethodGet, http.MethodPut, http.MethodDelete)
 	}
 }
 
 // handleCommentWithID handles operations on specific comments
 func (h *Handler) handleCommentWithID(w http.ResponseWriter, r *http.Request) {
-	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:8000")
-	w.Header().Set("Access-Control-Allow-Methods", "DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	// Handle preflight request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	path := r.URL.Path
 	log.Printf("Handling comment with ID: %s %s", r.Method, path)
 
@@ -404,43 +756,644 @@ func (h *Handler) handleCommentWithID(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Processing comment ID: %d, method: %s", commentID, r.Method)
 
 	switch r.Method {
+	case http.MethodPut:
+		h.authMiddleware(requireJSON(func(w http.ResponseWriter, r *http.Request) {
+			h.updateComment(w, r, commentID)
+		}))(w, r)
 	case http.MethodDelete:
 		h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
 			h.deleteComment(w, r, commentID)
 		})(w, r)
 	default:
-		http.Error(w, "Method not allowed for comment", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Method not allowed for comment", http.MethodPut, http.MethodDelete)
 	}
 }
 
-// getSingleMessage gets a single message by ID
-func (h *Handler) getSingleMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
-	log.Printf("Getting single message ID: %d", messageID)
-
-	// For now, just return a simple response
-	// In a real implementation, you'd get the message from the use case
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":      messageID,
-		"message": "Single message endpoint",
-	})
-}
+// handleUserWithID handles operations on specific users:
+// /api/v1/users/{id}/mentions and /api/v1/users/{id}/comments
+func (h *Handler) handleUserWithID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/users/"), "/"), "/")
+	if len(parts) != 2 || (parts[1] != "mentions" && parts[1] != "comments") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
 
-// banMessage bans a message (soft delete)
-func (h *Handler) banMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
-	log.Printf("Admin banning message ID: %d", messageID)
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
 
-	if err := h.useCase.BanMessage(messageID); err != nil {
-		log.Printf("Error banning message %d: %v", messageID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Message banned successfully",
+	h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if parts[1] == "comments" {
+			h.getUserComments(w, r, userID)
+			return
+		}
+		h.getUserMentions(w, r, userID)
+	})(w, r)
+}
+
+// getUserComments lists a user's comments, newest first. Admins also see
+// expired comments that haven't been cleaned up yet.
+func (h *Handler) getUserComments(w http.ResponseWriter, r *http.Request, userID int64) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	comments, total, err := h.useCase.GetCommentsByUser(r.Context(), userID, limit, offset, user.Role == "admin")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newPagedResponse(comments, total, limit, offset)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getUserMentions lists where a user was @mentioned. Users can only see
+// their own mentions unless they are an admin.
+func (h *Handler) getUserMentions(w http.ResponseWriter, r *http.Request, userID int64) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+	if user.ID != userID && user.Role != "admin" {
+		http.Error(w, "Cannot view another user's mentions", http.StatusForbidden)
+		return
+	}
+
+	limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mentions, total, err := h.useCase.GetUserMentions(r.Context(), userID, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newPagedResponse(mentions, total, limit, offset)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getSingleMessage gets a single message by ID, including its pin status.
+// Banned messages are only visible to admins.
+func (h *Handler) getSingleMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
+	log.Printf("Getting single message ID: %d", messageID)
+
+	if r.URL.Query().Get("include") == "comments" {
+		h.getMessageWithComments(w, r, messageID)
+		return
+	}
+
+	message, ok := h.lookupMessage(w, r, messageID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleLatestMessage handles GET /api/v1/messages/latest, returning the
+// newest message in the feed or 404 if there are none
+func (h *Handler) handleLatestMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	message, err := h.useCase.GetLatestMessage(r.Context())
+	if err != nil {
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			http.Error(w, "no messages yet", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleMessageBySlug handles GET /api/v1/messages/slug/{slug}
+func (h *Handler) handleMessageBySlug(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/messages/slug/"), "/")
+	if slug == "" {
+		http.Error(w, "Slug required", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.useCase.GetBySlug(r.Context(), slug)
+	if err != nil {
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !h.canView(r, message) {
+		http.Error(w, "message not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// lookupMessage fetches a message for display, writing a 404 if it doesn't
+// exist, or is banned or expired and the caller isn't an admin
+func (h *Handler) lookupMessage(w http.ResponseWriter, r *http.Request, messageID int64) (*domain.Message, bool) {
+	message, err := h.useCase.GetByID(r.Context(), messageID)
+	if err != nil {
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	if !h.canView(r, message) {
+		http.Error(w, "message not found", http.StatusNotFound)
+		return nil, false
+	}
+	return message, true
+}
+
+// canView reports whether the caller may see message: banned and expired
+// messages are only visible to admins, and hidden ones to their author too
+func (h *Handler) canView(r *http.Request, message *domain.Message) bool {
+	if !message.IsBanned && !message.IsHidden && !message.IsExpired() {
+		return true
+	}
+	user, ok := h.optionalUser(r)
+	if !ok {
+		return false
+	}
+	if user.Role == "admin" {
+		return true
+	}
+	return message.IsHidden && !message.IsBanned && !message.IsExpired() && user.ID == message.UserID
+}
+
+// getMessageWithComments handles GET /api/v1/messages/{id}?include=comments,
+// returning the message with its comments embedded
+func (h *Handler) getMessageWithComments(w http.ResponseWriter, r *http.Request, messageID int64) {
+	detail, err := h.useCase.GetMessageWithComments(r.Context(), messageID)
+	if err != nil {
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !h.canView(r, detail.Message) {
+		http.Error(w, "message not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(detail); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handlePinnedMessages handles GET /api/v1/messages/pinned
+func (h *Handler) handlePinnedMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	truncate, err := parseTruncate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := h.useCase.GetPinnedMessages(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	total := int64(len(messages))
+	if err := json.NewEncoder(w).Encode(newPagedResponse(truncateMessages(messages, truncate), total, total, 0)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleMarkRead handles POST /api/v1/messages/read, marking every message
+// up to message_id as read for the current user
+func (h *Handler) handleMarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	h.authMiddleware(requireJSON(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getUserFromContext(r)
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		var req MarkReadRequest
+		if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+			return
+		}
+
+		if err := h.useCase.MarkRead(r.Context(), user.ID, req.MessageID); err != nil {
+			if errors.Is(err, domain.ErrMessageNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	}))(w, r)
+}
+
+// handleUnreadCount handles GET /api/v1/messages/unread-count for the current user
+func (h *Handler) handleUnreadCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getUserFromContext(r)
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		unread, err := h.useCase.CountUnread(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"unread": unread})
+	})(w, r)
+}
+
+// handleMyMessages handles GET /api/v1/me/messages, listing the current user's
+// own messages newest first. Banned messages are included so users can see
+// what was moderated.
+func (h *Handler) handleMyMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getUserFromContext(r)
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		truncate, err := parseTruncate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		messages, total, err := h.useCase.GetMessagesByUser(r.Context(), user.ID, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newPagedResponse(truncateMessages(messages, truncate), total, limit, offset)); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
+
+// handleMyNotifications handles GET /api/v1/me/notifications, listing the
+// current user's notifications of new comments on messages they subscribed
+// to, newest first
+func (h *Handler) handleMyNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getUserFromContext(r)
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		notifications, total, err := h.useCase.GetNotifications(r.Context(), user.ID, limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newPagedResponse(notifications, total, limit, offset)); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
+
+// handleMessagePin handles /api/v1/messages/{id}/pin (POST to pin, DELETE to
+// unpin, admin only) and GET /api/v1/messages/{id}/pin-status
+func (h *Handler) handleMessagePin(w http.ResponseWriter, r *http.Request, messageID int64, action string) {
+	if action == "pin-status" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+			return
+		}
+
+		message, ok := h.lookupMessage(w, r, messageID)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":        message.ID,
+			"is_pinned": message.IsPinned,
+			"pinned_at": message.PinnedAt,
+		})
+		return
+	}
+
+	var pin func(ctx context.Context, id int64) error
+	switch r.Method {
+	case http.MethodPost:
+		pin = h.useCase.PinMessage
+	case http.MethodDelete:
+		pin = h.useCase.UnpinMessage
+	default:
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost, http.MethodDelete)
+		return
+	}
+
+	h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Admin %s pin on message ID: %d", r.Method, messageID)
+
+		if err := pin(r.Context(), messageID); err != nil {
+			switch {
+			case errors.Is(err, domain.ErrTooManyPinned):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, domain.ErrMessageNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"is_pinned": r.Method == http.MethodPost,
+		})
+	})(w, r)
+}
+
+// handleMessageLock handles POST /api/v1/messages/{id}/lock and
+// POST /api/v1/messages/{id}/unlock (admin only)
+func (h *Handler) handleMessageLock(w http.ResponseWriter, r *http.Request, messageID int64, action string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	lock := h.useCase.LockMessage
+	if action == "unlock" {
+		lock = h.useCase.UnlockMessage
+	}
+
+	h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Admin %s on message ID: %d", action, messageID)
+
+		if err := lock(r.Context(), messageID); err != nil {
+			if errors.Is(err, domain.ErrMessageNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"is_locked": action == "lock",
+		})
+	})(w, r)
+}
+
+// handleMessageHide handles POST /api/v1/messages/{id}/hide and
+// POST /api/v1/messages/{id}/unhide (author only)
+func (h *Handler) handleMessageHide(w http.ResponseWriter, r *http.Request, messageID int64, action string) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	hide := h.useCase.HideMessage
+	if action == "unhide" {
+		hide = h.useCase.UnhideMessage
+	}
+
+	h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getUserFromContext(r)
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("User %d %s on message ID: %d", user.ID, action, messageID)
+
+		if err := hide(r.Context(), messageID, user.ID); err != nil {
+			switch {
+			case errors.Is(err, domain.ErrNotMessageAuthor):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, domain.ErrMessageNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":   true,
+			"is_hidden": action == "hide",
+		})
+	})(w, r)
+}
+
+// handleMessageSubscribe handles POST /api/v1/messages/{id}/subscribe, which
+// notifies the current user of new comments on the message, and DELETE to stop
+func (h *Handler) handleMessageSubscribe(w http.ResponseWriter, r *http.Request, messageID int64) {
+	var subscribe func(ctx context.Context, messageID, userID int64) error
+	switch r.Method {
+	case http.MethodPost:
+		subscribe = h.useCase.SubscribeToMessage
+	case http.MethodDelete:
+		subscribe = h.useCase.UnsubscribeFromMessage
+	default:
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost, http.MethodDelete)
+		return
+	}
+
+	h.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getUserFromContext(r)
+		if !ok {
+			http.Error(w, "User not found in context", http.StatusInternalServerError)
+			return
+		}
+
+		if err := subscribe(r.Context(), messageID, user.ID); err != nil {
+			if errors.Is(err, domain.ErrMessageNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":    true,
+			"subscribed": r.Method == http.MethodPost,
+		})
+	})(w, r)
+}
+
+// updateMessage edits a message's content. The request must carry the version
+// the client last read; stale versions are rejected with 409 Conflict.
+func (h *Handler) updateMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
+	// Get user from context
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	// Parse request
+	var req UpdateMessageRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+		return
+	}
+
+	log.Printf("Updating message %d for user %d (%s) at version %d", messageID, user.ID, user.Username, req.Version)
+
+	message, err := h.useCase.UpdateMessage(r.Context(), messageID, user.ID, req.Content, req.Version)
+	if err != nil {
+		if writeValidationErrors(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, domain.ErrVersionConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, domain.ErrNotMessageAuthor):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, domain.ErrMessageNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// banMessage bans a message (soft delete)
+func (h *Handler) banMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
+	log.Printf("Admin banning message ID: %d", messageID)
+
+	if err := h.useCase.BanMessage(r.Context(), messageID); err != nil {
+		log.Printf("Error banning message %d: %v", messageID, err)
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Message banned successfully",
 	})
 }
 
@@ -448,8 +1401,12 @@ func (h *Handler) banMessage(w http.ResponseWriter, r *http.Request, messageID i
 func (h *Handler) deleteMessage(w http.ResponseWriter, r *http.Request, messageID int64) {
 	log.Printf("Admin deleting message ID: %d", messageID)
 
-	if err := h.useCase.DeleteMessage(messageID); err != nil {
+	if err := h.useCase.DeleteMessage(r.Context(), messageID); err != nil {
 		log.Printf("Error deleting message %d: %v", messageID, err)
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -462,12 +1419,57 @@ func (h *Handler) deleteMessage(w http.ResponseWriter, r *http.Request, messageI
 	})
 }
 
+// updateComment edits a comment's content. Only the author can edit, and
+// expired comments are rejected with 410 Gone.
+func (h *Handler) updateComment(w http.ResponseWriter, r *http.Request, commentID int64) {
+	user, ok := getUserFromContext(r)
+	if !ok {
+		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		return
+	}
+
+	var req UpdateCommentRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+		return
+	}
+
+	log.Printf("Updating comment %d for user %d (%s)", commentID, user.ID, user.Username)
+
+	comment, err := h.useCase.UpdateComment(r.Context(), commentID, user.ID, req.Content)
+	if err != nil {
+		if writeValidationErrors(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, domain.ErrNotCommentAuthor):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, domain.ErrCommentExpired):
+			http.Error(w, err.Error(), http.StatusGone)
+		case errors.Is(err, domain.ErrCommentNotFound), errors.Is(err, domain.ErrMessageNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(comment); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
 // deleteComment deletes a comment (admin only)
 func (h *Handler) deleteComment(w http.ResponseWriter, r *http.Request, commentID int64) {
 	log.Printf("Admin deleting comment ID: %d", commentID)
 
-	if err := h.useCase.DeleteComment(commentID); err != nil {
+	if err := h.useCase.DeleteComment(r.Context(), commentID); err != nil {
 		log.Printf("Error deleting comment %d: %v", commentID, err)
+		if errors.Is(err, domain.ErrCommentNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -480,18 +1482,24 @@ func (h *Handler) deleteComment(w http.ResponseWriter, r *http.Request, commentI
 	})
 }
 
-// getComments returns comments for a message
+// getComments returns comments for a message, oldest first unless ?order=desc.
+// They aren't paginated, so the whole thread comes back as a single page.
 func (h *Handler) getComments(w http.ResponseWriter, r *http.Request, messageID int64) {
-	comments, err := h.useCase.GetComments(messageID)
+	order, err := domain.ParseSortOrder(r.URL.Query().Get("order"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	comments, err := h.useCase.GetComments(r.Context(), messageID, order)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"comments": comments,
-	}); err != nil {
+	total := int64(len(comments))
+	if err := json.NewEncoder(w).Encode(newPagedResponse(comments, total, total, 0)); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
@@ -507,21 +1515,27 @@ func (h *Handler) createComment(w http.ResponseWriter, r *http.Request, messageI
 	}
 
 	// Parse request
-	var req struct {
-		Content string `json:"content"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req CreateCommentRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
 		return
 	}
 
 	log.Printf("Creating comment for user %d (%s) on message %d: %s", user.ID, user.Username, messageID, req.Content)
 
 	// Create comment using user info from token
-	comment, err := h.useCase.CreateComment(messageID, user.ID, user.Username, req.Content)
+	comment, err := h.useCase.CreateComment(r.Context(), messageID, user.ID, user.Username, req.Content)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if writeValidationErrors(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, domain.ErrThreadLocked):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, domain.ErrMessageNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -534,7 +1548,414 @@ func (h *Handler) createComment(w http.ResponseWriter, r *http.Request, messageI
 	}
 }
 
-// handleWebsocket handles WebSocket connections
+// maxBulkComments caps how many comments a single import request may contain
+const maxBulkComments = 500
+
+// handleRecentComments handles GET /api/v1/admin/comments, listing the newest
+// non-expired comments across all messages, each with a summary of the
+// message it is on (admin only)
+func (h *Handler) handleRecentComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := parsePagination(r, h.cfg.DefaultPageSize, h.cfg.MaxPageSize, h.cfg.MaxOffset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		comments, total, err := h.useCase.GetRecentComments(r.Context(), limit, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newPagedResponse(comments, total, limit, offset)); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+			return
+		}
+	})(w, r)
+}
+
+// handleBulkComments handles POST /api/v1/admin/comments/bulk for importing comments
+func (h *Handler) handleBulkComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+	h.authAdminMiddleware(requireJSON(h.importComments))(w, r)
+}
+
+// handlePurgeBanned handles POST /api/v1/admin/messages/purge-banned, permanently
+// deleting every banned message and its comments (admin only)
+func (h *Handler) handlePurgeBanned(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := h.useCase.PurgeBannedMessages(r.Context())
+		if err != nil {
+			log.Printf("Error purging banned messages: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"deleted": deleted})
+	})(w, r)
+}
+
+// handleMessageTimeSeries handles GET /api/v1/admin/stats/timeseries?interval=day|hour,
+// returning how many messages were created in each interval, oldest first (admin only)
+func (h *Handler) handleMessageTimeSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		interval, err := domain.ParseStatsInterval(r.URL.Query().Get("interval"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		buckets, err := h.useCase.GetMessageTimeSeries(r.Context(), interval)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"interval": interval,
+			"series":   buckets,
+		})
+	})(w, r)
+}
+
+// handleBanMatching handles POST /api/v1/admin/messages/ban-matching, banning
+// every message whose content matches a regular expression (admin only)
+func (h *Handler) handleBanMatching(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	h.authAdminMiddleware(requireJSON(func(w http.ResponseWriter, r *http.Request) {
+		var req BanMatchingRequest
+		if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+			return
+		}
+
+		banned, err := h.useCase.BanMatchingMessages(r.Context(), req.Pattern)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrInvalidPattern):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, context.DeadlineExceeded):
+				http.Error(w, "Matching took too long; try a narrower pattern", http.StatusServiceUnavailable)
+			default:
+				log.Printf("Error banning messages matching %q: %v", req.Pattern, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int64{"banned": banned})
+	}))(w, r)
+}
+
+// handleAdminMessageWithID handles GET /api/v1/admin/messages/{id}, which
+// returns a message's full moderation state, and POST
+// /api/v1/admin/messages/{id}/restore, which brings a deleted message back
+// from the trash (admin only)
+func (h *Handler) handleAdminMessageWithID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/messages/"), "/"), "/")
+	if len(parts) > 2 || len(parts) == 2 && parts[1] != "restore" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	messageID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || messageID <= 0 {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+			return
+		}
+		h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			h.getModerationView(w, r, messageID)
+		})(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	h.authAdminMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Admin restoring message ID: %d", messageID)
+
+		message, err := h.useCase.RestoreMessage(r.Context(), messageID)
+		if err != nil {
+			if errors.Is(err, domain.ErrMessageNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(message)
+	})(w, r)
+}
+
+// getModerationView returns everything a moderator needs to review a message
+func (h *Handler) getModerationView(w http.ResponseWriter, r *http.Request, messageID int64) {
+	view, err := h.useCase.GetModerationView(r.Context(), messageID)
+	if err != nil {
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			log.Printf("Error getting moderation view of message %d: %v", messageID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// handleAdminUserWithID handles POST /api/v1/admin/users/{id}/rename, which
+// replaces a user's username across all of their posts (admin only)
+func (h *Handler) handleAdminUserWithID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/admin/users/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "rename" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+
+	h.authAdminMiddleware(requireJSON(func(w http.ResponseWriter, r *http.Request) {
+		h.renameUser(w, r, userID)
+	}))(w, r)
+}
+
+// renameUser scrubs a user's username from all of their messages and comments
+func (h *Handler) renameUser(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req RenameUserRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+		return
+	}
+
+	updated, err := h.useCase.RenameUser(r.Context(), userID, req.Username)
+	if err != nil {
+		if writeValidationErrors(w, err) {
+			return
+		}
+		log.Printf("Error renaming user %d: %v", userID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logModeration(r, "rename_user", "user %d renamed to %q on %d posts", userID, req.Username, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": updated})
+}
+
+// logModeration records an admin action in the moderation log, attributed to
+// the admin making the request
+func logModeration(r *http.Request, action, format string, args ...interface{}) {
+	admin := "unknown"
+	if user, ok := getUserFromContext(r); ok {
+		admin = fmt.Sprintf("%d (%s)", user.ID, user.Username)
+	}
+	log.Printf("[moderation] admin=%s action=%s: %s", admin, action, fmt.Sprintf(format, args...))
+}
+
+// importComments creates a batch of comments atomically and returns their IDs in order
+func (h *Handler) importComments(w http.ResponseWriter, r *http.Request) {
+	var req ImportCommentsRequest
+	if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+		return
+	}
+
+	comments := make([]*domain.Comment, 0, len(req.Comments))
+	for _, c := range req.Comments {
+		comments = append(comments, &domain.Comment{
+			MessageID: c.MessageID,
+			UserID:    c.UserID,
+			Username:  c.Username,
+			Content:   c.Content,
+		})
+	}
+
+	ids, err := h.useCase.ImportComments(r.Context(), comments)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidComment), errors.Is(err, domain.ErrMessageNotFound):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrThreadLocked):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ids": ids,
+	})
+}
+
+// sseHeartbeatInterval is how often an idle SSE stream gets a keep-alive comment
+const sseHeartbeatInterval = 15 * time.Second
+
+// handleMessageStream handles GET /api/v1/messages/stream as Server-Sent Events
+func (h *Handler) handleMessageStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	updates, unsubscribe := h.hub.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ip := clientIP(r, h.trustedProxies)
+	log.Printf("SSE client connected: %s", ip)
+	defer log.Printf("SSE client disconnected: %s", ip)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, ok := <-updates:
+			if !ok {
+				return
+			}
+			// Each line of the payload needs its own data: prefix
+			for _, line := range strings.Split(string(data), "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// handleWebsocket handles WebSocket connections. New clients first receive a
+// {"type":"history","data":[...]} frame with the most recent messages, oldest first.
+// Handshakes from origins other than this host or AllowedOrigins get 403.
 func (h *Handler) handleWebsocket(w http.ResponseWriter, r *http.Request) {
-	ws.ServeWs(h.hub, w, r, nil)
+	// A reconnecting client passes the sequence number of the last broadcast
+	// it received to be sent what it missed instead of the history
+	var since int64 = -1
+	if v := r.URL.Query().Get("since_seq"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid since_seq", http.StatusBadRequest)
+			return
+		}
+		since = n
+	}
+
+	ip := clientIP(r, h.trustedProxies)
+	if !h.hub.AcquireConn(ip) {
+		http.Error(w, "Too many WebSocket connections", http.StatusTooManyRequests)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.hub.ReleaseConn(ip)
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	if since >= 0 {
+		ws.ResumeWs(h.hub, conn, ip, since)
+		return
+	}
+	ws.ServeWs(h.hub, conn, ip, h.historyFrame(r.Context()))
+}
+
+// historyFrame builds the frame of recent messages replayed to a new
+// WebSocket client, or returns nil if replay is disabled or fails
+func (h *Handler) historyFrame(ctx context.Context) []byte {
+	if h.cfg.WSHistorySize <= 0 {
+		return nil
+	}
+
+	// Read before loading so that anything broadcast in the meantime is
+	// replayed to a client resuming from seq
+	seq := h.hub.Seq()
+	messages, _, err := h.useCase.GetMessages(ctx, h.cfg.WSHistorySize, 0)
+	if err != nil {
+		log.Printf("Error loading WebSocket history: %v", err)
+		return nil
+	}
+
+	// Messages come newest first; replay them in the order they were posted
+	history := make([]*domain.Message, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		if !messages[i].IsBanned {
+			history = append(history, messages[i])
+		}
+	}
+
+	frame, err := json.Marshal(map[string]interface{}{
+		"type": "history",
+		"seq":  seq,
+		"data": history,
+	})
+	if err != nil {
+		log.Printf("Error encoding WebSocket history: %v", err)
+		return nil
+	}
+	return frame
 }
@@ -0,0 +1,2790 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/config"
+	"github.com/atmega-p471/forum-service/internal/delivery/ws"
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"github.com/atmega-p471/forum-service/internal/version"
+	"github.com/gorilla/websocket"
+)
+
+// MockAuthClient implements AuthClient for testing
+type MockAuthClient struct {
+	users map[string]*domain.User
+	// down makes Ping report the auth service as unavailable
+	down bool
+	// validations counts ValidateToken calls
+	validations int
+}
+
+func NewMockAuthClient() *MockAuthClient {
+	return &MockAuthClient{
+		users: map[string]*domain.User{
+			"user_token":  {ID: 1, Username: "testuser", Role: "user"},
+			"admin_token": {ID: 2, Username: "admin", Role: "admin"},
+			// nameless_token belongs to a user whose username is missing
+			"nameless_token": {ID: 3, Username: "", Role: "user"},
+		},
+	}
+}
+
+func (m *MockAuthClient) Ping(ctx context.Context) error {
+	if m.down {
+		return domain.ErrAuthUnavailable
+	}
+	return nil
+}
+
+func (m *MockAuthClient) ValidateToken(token string) (*domain.User, error) {
+	m.validations++
+	if user, exists := m.users[token]; exists {
+		return user, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// setupTestHandler registers a Handler backed by mocks on a fresh mux
+func setupTestHandler(t *testing.T) (*http.ServeMux, *MockMessageUseCase, *ws.Hub) {
+	usecase := NewMockMessageUseCase()
+	hub := ws.NewHub()
+	go hub.Run()
+
+	mux := http.NewServeMux()
+	NewHandler(usecase, hub, NewMockAuthClient(), config.NewConfig()).RegisterRoutes(mux)
+	return mux, usecase, hub
+}
+
+func TestHandler_GetMessagesDefaultPageSize(t *testing.T) {
+	usecase := NewMockMessageUseCase()
+	cfg := config.NewConfig()
+	cfg.DefaultPageSize = 25
+	cfg.MaxPageSize = 40
+	mux := http.NewServeMux()
+	NewHandler(usecase, ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+
+	for i := 0; i < 50; i++ {
+		if _, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Message"); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query         string
+		expectedLimit int64
+	}{
+		{query: "", expectedLimit: 25},
+		{query: "?limit=0", expectedLimit: 25},
+		{query: "?limit=5", expectedLimit: 5},
+		{query: "?limit=100", expectedLimit: 40},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/messages"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d", tt.query, rr.Code)
+		}
+		var response struct {
+			Items []*domain.Message `json:"items"`
+			Limit int64             `json:"limit"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if response.Limit != tt.expectedLimit || int64(len(response.Items)) != tt.expectedLimit {
+			t.Errorf("%q: expected %d messages, got limit %d and %d messages", tt.query, tt.expectedLimit, response.Limit, len(response.Items))
+		}
+	}
+}
+
+func TestHandler_MessageStream(t *testing.T) {
+	mux, _, hub := setupTestHandler(t)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/messages/stream")
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %s", ct)
+	}
+
+	// The subscription is registered before headers are sent
+	hub.BroadcastMessage(&domain.Message{ID: 1, Content: "first"})
+	hub.BroadcastMessage(&domain.Message{ID: 2, Content: "second"})
+
+	events := make(chan string, 2)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				events <- strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case event := <-events:
+			if !strings.Contains(event, `"content":"`+want+`"`) {
+				t.Errorf("Expected event with content %q, got %s", want, event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %q event", want)
+		}
+	}
+}
+
+func TestHandler_GetMessagesPagination(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	for i := 0; i < 150; i++ {
+		if _, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Message"); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "Default limit", query: "", expectedStatus: http.StatusOK, expectedCount: 10},
+		{name: "Limit over cap is clamped", query: "?limit=1000000", expectedStatus: http.StatusOK, expectedCount: 100},
+		{name: "Negative limit", query: "?limit=-5", expectedStatus: http.StatusBadRequest},
+		{name: "Negative offset", query: "?offset=-1", expectedStatus: http.StatusBadRequest},
+		{name: "Non-numeric limit", query: "?limit=abc", expectedStatus: http.StatusBadRequest},
+		{name: "Non-numeric offset", query: "?offset=abc", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/messages"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Messages []*domain.Message `json:"items"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if len(response.Messages) != tt.expectedCount {
+				t.Errorf("Expected %d messages, got %d", tt.expectedCount, len(response.Messages))
+			}
+		})
+	}
+}
+
+func TestHandler_GetMessagesDeepOffsetWarning(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.MaxOffset = 20
+	usecase := NewMockMessageUseCase()
+	mux := http.NewServeMux()
+	NewHandler(usecase, ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+
+	get := func(query string) (messages []*domain.Message, warning string) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/messages"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		var resp struct {
+			Messages []*domain.Message `json:"items"`
+			Warning  string            `json:"warning"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return resp.Messages, resp.Warning
+	}
+
+	for i := 0; i < 25; i++ {
+		usecase.CreateMessage(context.Background(), 1, "testuser", "Message")
+	}
+	if _, warning := get(""); warning != "" {
+		t.Errorf("Expected no warning when every message is reachable, got %q", warning)
+	}
+
+	for i := 0; i < 25; i++ {
+		usecase.CreateMessage(context.Background(), 1, "testuser", "Message")
+	}
+	messages, warning := get("?offset=1000")
+	if warning == "" {
+		t.Error("Expected a warning when total exceeds the reachable offset")
+	}
+	// The offset is capped at 20, so a page is still returned
+	if len(messages) != 10 {
+		t.Errorf("Expected a full page at the capped offset, got %d messages", len(messages))
+	}
+}
+
+func TestHandler_UpdateMessageVersion(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Original")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	update := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/v1/messages/"+strconv.FormatInt(message.ID, 10), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Current version succeeds and returns the new version
+	rr := update("user_token", `{"content":"First edit","version":1}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var updated domain.Message
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if updated.Version != 2 || updated.Content != "First edit" {
+		t.Errorf("Expected 'First edit' at version 2, got '%s' at version %d", updated.Content, updated.Version)
+	}
+
+	// Stale version is rejected
+	rr = update("user_token", `{"content":"Stale edit","version":1}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for stale version, got %d", rr.Code)
+	}
+
+	// Missing version is rejected
+	rr = update("user_token", `{"content":"No version"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without version, got %d", rr.Code)
+	}
+
+	// Only the author may edit
+	rr = update("admin_token", `{"content":"Admin edit","version":2}`)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-author, got %d", rr.Code)
+	}
+}
+
+func TestHandler_UpdateComment(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	usecase.comments[10] = &domain.Comment{ID: 10, MessageID: 1, UserID: 1, Username: "testuser", Content: "Original", ExpiresAt: time.Now().Add(time.Hour)}
+	usecase.comments[11] = &domain.Comment{ID: 11, MessageID: 1, UserID: 1, Username: "testuser", Content: "Old", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	update := func(token string, id int64, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/v1/comments/"+strconv.FormatInt(id, 10), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := update("user_token", 10, `{"content":"Edited"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var updated domain.Comment
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if updated.Content != "Edited" {
+		t.Errorf("Expected 'Edited', got '%s'", updated.Content)
+	}
+
+	if rr := update("admin_token", 10, `{"content":"Admin edit"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-author, got %d", rr.Code)
+	}
+	if rr := update("user_token", 11, `{"content":"Too late"}`); rr.Code != http.StatusGone {
+		t.Errorf("Expected status 410 for expired comment, got %d", rr.Code)
+	}
+	if rr := update("user_token", 10, `{"content":""}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for empty content, got %d", rr.Code)
+	}
+	if rr := update("user_token", 99, `{"content":"Missing"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing comment, got %d", rr.Code)
+	}
+}
+
+func TestHandler_GetUserMentions(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	usecase.mentions = append(usecase.mentions, &domain.Mention{
+		ID:              1,
+		SourceType:      domain.MentionSourceMessage,
+		SourceID:        5,
+		MentionedUserID: 1,
+	})
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/v1/users/1/mentions", "user_token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var response struct {
+		Mentions []*domain.Mention `json:"items"`
+		Total    int64             `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Total != 1 || len(response.Mentions) != 1 || response.Mentions[0].SourceID != 5 {
+		t.Errorf("Expected the single mention of user 1, got %+v", response)
+	}
+
+	if rr := get("/api/v1/users/2/mentions", "user_token"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another user's mentions, got %d", rr.Code)
+	}
+	if rr := get("/api/v1/users/1/mentions", "admin_token"); rr.Code != http.StatusOK {
+		t.Errorf("Expected admin to view any user's mentions, got %d", rr.Code)
+	}
+	if rr := get("/api/v1/users/1/mentions", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", rr.Code)
+	}
+}
+
+func TestHandler_GetMessagesDateRange(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	now := time.Now().UTC()
+	usecase.messages[1] = &domain.Message{ID: 1, Content: "Last week", CreatedAt: now.Add(-7 * 24 * time.Hour)}
+	usecase.messages[2] = &domain.Message{ID: 2, Content: "Yesterday", CreatedAt: now.Add(-24 * time.Hour)}
+	usecase.messages[3] = &domain.Message{ID: 3, Content: "Today", CreatedAt: now}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/messages?"+query, nil))
+		return rr
+	}
+
+	from := now.Add(-48 * time.Hour).Format(time.RFC3339)
+	to := now.Add(-time.Hour).Format(time.RFC3339)
+	rr := get("from=" + from + "&to=" + to)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Messages []*domain.Message `json:"items"`
+		Total    int64             `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Total != 1 || len(response.Messages) != 1 || response.Messages[0].ID != 2 {
+		t.Errorf("Expected only yesterday's message, got %+v (total %d)", response.Messages, response.Total)
+	}
+
+	for _, query := range []string{
+		"from=" + to + "&to=" + from,
+		"from=yesterday",
+		"from=" + from + "&sort=active",
+	} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandler_UserComments(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	usecase.comments[1] = &domain.Comment{ID: 1, MessageID: 1, UserID: 1, Content: "Live", ExpiresAt: time.Now().Add(time.Hour)}
+	usecase.comments[2] = &domain.Comment{ID: 2, MessageID: 1, UserID: 1, Content: "Expired", ExpiresAt: time.Now().Add(-time.Hour)}
+	usecase.comments[3] = &domain.Comment{ID: 3, MessageID: 1, UserID: 2, Content: "Someone else's", ExpiresAt: time.Now().Add(time.Hour)}
+
+	get := func(token string) (int, []*domain.Comment) {
+		req := httptest.NewRequest("GET", "/api/v1/users/1/comments", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		var response struct {
+			Comments []*domain.Comment `json:"items"`
+		}
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+		}
+		return rr.Code, response.Comments
+	}
+
+	if code, comments := get("user_token"); code != http.StatusOK || len(comments) != 1 || comments[0].ID != 1 {
+		t.Errorf("Expected only the live comment for a user, got %d: %+v", code, comments)
+	}
+	if code, comments := get("admin_token"); code != http.StatusOK || len(comments) != 2 {
+		t.Errorf("Expected admins to also see the expired comment, got %d: %+v", code, comments)
+	}
+}
+
+func TestHandler_MyMessages(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	var own []*domain.Message
+	for i := 0; i < 3; i++ {
+		message, err := usecase.CreateMessage(ctx, 1, "testuser", "Post "+strconv.Itoa(i))
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		own = append(own, message)
+	}
+	if _, err := usecase.CreateMessage(ctx, 2, "admin", "Someone else's post"); err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	if err := usecase.BanMessage(ctx, own[2].ID); err != nil {
+		t.Fatalf("Failed to ban test message: %v", err)
+	}
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/v1/me/messages?limit=2", "user_token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var response struct {
+		Messages []*domain.Message `json:"items"`
+		Total    int64             `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Total != 3 || len(response.Messages) != 2 {
+		t.Fatalf("Expected the first 2 of the user's 3 messages, got %d (total %d)", len(response.Messages), response.Total)
+	}
+	if response.Messages[0].ID != own[2].ID || !response.Messages[0].IsBanned {
+		t.Errorf("Expected the user's banned newest message first, got %+v", response.Messages[0])
+	}
+
+	rr = get("/api/v1/me/messages?limit=2&offset=2", "user_token")
+	response.Messages = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Messages) != 1 || response.Messages[0].ID != own[0].ID {
+		t.Errorf("Expected the user's oldest message on the second page, got %+v", response.Messages)
+	}
+
+	if rr := get("/api/v1/me/messages", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", rr.Code)
+	}
+}
+
+func TestHandler_SearchMessages(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	match, err := usecase.CreateMessage(ctx, 1, "testuser", "Anyone tried the new Gopher mascot?")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	if _, err := usecase.CreateMessage(ctx, 1, "testuser", "Unrelated"); err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	var response struct {
+		Results []*domain.SearchResult `json:"items"`
+		Total   int64                  `json:"total"`
+	}
+	rr := get("/api/v1/messages/search?q=gopher&highlight=true")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Total != 1 || len(response.Results) != 1 || response.Results[0].ID != match.ID {
+		t.Fatalf("Expected the single matching message, got %+v", response)
+	}
+	result := response.Results[0]
+	if len(result.MatchRanges) != 1 {
+		t.Fatalf("Expected one match range, got %v", result.MatchRanges)
+	}
+	if got := string([]rune(result.Snippet)[result.MatchRanges[0].Start:result.MatchRanges[0].End]); got != "Gopher" {
+		t.Errorf("Expected the range to cover Gopher, got %q", got)
+	}
+
+	rr = get("/api/v1/messages/search?q=gopher")
+	if strings.Contains(rr.Body.String(), "snippet") {
+		t.Errorf("Expected no highlighting unless requested, got %s", rr.Body.String())
+	}
+
+	if rr := get("/api/v1/messages/search?q=%20"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a blank query, got %d", rr.Code)
+	}
+}
+
+func TestHandler_RequiresJSONContentType(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Message")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	messagePath := "/api/v1/messages/" + strconv.FormatInt(message.ID, 10)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+	}{
+		{name: "Create message as form", method: "POST", path: "/api/v1/messages", contentType: "application/x-www-form-urlencoded", body: "content=hi"},
+		{name: "Create message without content type", method: "POST", path: "/api/v1/messages", body: `{"content":"hi"}`},
+		{name: "Create comment as text", method: "POST", path: messagePath + "/comments", contentType: "text/plain", body: "hi"},
+		{name: "Update message as text", method: "PUT", path: messagePath, contentType: "text/plain", body: "hi"},
+		{name: "Ban as form", method: "POST", path: "/api/v1/messages/ban", contentType: "application/x-www-form-urlencoded", body: "id=1"},
+		{name: "Unban as text", method: "POST", path: "/api/v1/messages/unban", contentType: "text/plain", body: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			req.Header.Set("Authorization", "Bearer admin_token")
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusUnsupportedMediaType {
+				t.Fatalf("Expected status 415, got %d", rr.Code)
+			}
+			var response map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Expected JSON error body: %v", err)
+			}
+			if response["error"] == "" {
+				t.Error("Expected error message in response")
+			}
+		})
+	}
+
+	// A charset parameter is still JSON
+	req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(`{"content":"hi"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer user_token")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 for JSON with charset, got %d", rr.Code)
+	}
+}
+
+func TestHandler_PinnedMessages(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Message")
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	messagePath := func(id int64) string {
+		return "/api/v1/messages/" + strconv.FormatInt(id, 10)
+	}
+
+	// Only admins can pin
+	if rr := do("POST", messagePath(ids[0])+"/pin", "user_token"); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for non-admin pin, got %d", rr.Code)
+	}
+
+	for _, id := range []int64{ids[2], ids[0]} {
+		if rr := do("POST", messagePath(id)+"/pin", "admin_token"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 pinning message %d, got %d", id, rr.Code)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rr := do("GET", "/api/v1/messages/pinned", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var response struct {
+		Messages []*domain.Message `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Messages) != 2 {
+		t.Fatalf("Expected 2 pinned messages, got %d", len(response.Messages))
+	}
+	if response.Messages[0].ID != ids[2] || response.Messages[1].ID != ids[0] {
+		t.Errorf("Expected pinned messages in pin order [%d %d], got [%d %d]",
+			ids[2], ids[0], response.Messages[0].ID, response.Messages[1].ID)
+	}
+
+	// Single-message GET exposes pin status
+	rr = do("GET", messagePath(ids[0]), "")
+	var message domain.Message
+	if err := json.Unmarshal(rr.Body.Bytes(), &message); err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	if !message.IsPinned || message.PinnedAt == nil {
+		t.Error("Expected single message GET to report pinned status")
+	}
+
+	// Unpinning removes it from the list and the pin status
+	if rr := do("DELETE", messagePath(ids[0])+"/pin", "admin_token"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 unpinning, got %d", rr.Code)
+	}
+	rr = do("GET", messagePath(ids[0])+"/pin-status", "")
+	var status map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to parse pin status: %v", err)
+	}
+	if status["is_pinned"] != false {
+		t.Errorf("Expected message to be unpinned, got %v", status["is_pinned"])
+	}
+}
+
+func TestHandler_LockMessage(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Announcement")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	messagePath := "/api/v1/messages/" + strconv.FormatInt(message.ID, 10)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Only admins can lock
+	if rr := do("POST", messagePath+"/lock", "user_token", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 for non-admin lock, got %d", rr.Code)
+	}
+	if rr := do("POST", messagePath+"/lock", "admin_token", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 locking, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := do("POST", messagePath+"/comments", "user_token", `{"content":"Too late"}`)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 commenting on a locked message, got %d", rr.Code)
+	}
+
+	if rr := do("POST", messagePath+"/unlock", "admin_token", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 unlocking, got %d", rr.Code)
+	}
+	rr = do("POST", messagePath+"/comments", "user_token", `{"content":"Back open"}`)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 commenting after unlock, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_HideMessage(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	hidden, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Second thoughts")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	banned, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Rule breaker")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	if err := usecase.BanMessage(context.Background(), banned.ID); err != nil {
+		t.Fatalf("Failed to ban test message: %v", err)
+	}
+	hiddenPath := "/api/v1/messages/" + strconv.FormatInt(hidden.ID, 10)
+	bannedPath := "/api/v1/messages/" + strconv.FormatInt(banned.ID, 10)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Only the author can hide
+	if rr := do("POST", hiddenPath+"/hide", "nameless_token"); rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 hiding someone else's message, got %d", rr.Code)
+	}
+	if rr := do("POST", hiddenPath+"/hide", "user_token"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 hiding, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := do("GET", "/api/v1/messages", "")
+	var list struct {
+		Messages []*domain.Message `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(list.Messages) != 0 {
+		t.Errorf("Expected hidden and banned messages to leave the list, got %d messages", len(list.Messages))
+	}
+
+	// A hidden message stays visible to its author, a banned one doesn't
+	if rr := do("GET", hiddenPath, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an anonymous hidden message read, got %d", rr.Code)
+	}
+	if rr := do("GET", hiddenPath, "nameless_token"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's hidden message, got %d", rr.Code)
+	}
+	if rr := do("GET", hiddenPath, "user_token"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for the author's hidden message, got %d", rr.Code)
+	}
+	if rr := do("GET", bannedPath, "user_token"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for the author's banned message, got %d", rr.Code)
+	}
+
+	if rr := do("POST", hiddenPath+"/unhide", "user_token"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 unhiding, got %d", rr.Code)
+	}
+	if rr := do("GET", hiddenPath, ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for an unhidden message, got %d", rr.Code)
+	}
+}
+
+func TestHandler_UnreadCount(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	var lastID int64
+	for i := 0; i < 3; i++ {
+		message, err := usecase.CreateMessage(context.Background(), 2, "admin", "Message")
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		lastID = message.ID
+	}
+
+	unreadCount := func() int64 {
+		req := httptest.NewRequest("GET", "/api/v1/messages/unread-count", nil)
+		req.Header.Set("Authorization", "Bearer user_token")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		var response map[string]int64
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response["unread"]
+	}
+
+	if count := unreadCount(); count != 3 {
+		t.Errorf("Expected 3 unread, got %d", count)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/messages/read", strings.NewReader(`{"message_id":`+strconv.FormatInt(lastID, 10)+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer user_token")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 marking read, got %d", rr.Code)
+	}
+
+	if count := unreadCount(); count != 0 {
+		t.Errorf("Expected 0 unread after marking read, got %d", count)
+	}
+
+	// Anonymous users can't track reads
+	req = httptest.NewRequest("GET", "/api/v1/messages/unread-count", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", rr.Code)
+	}
+}
+
+func TestHandler_CreateReply(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	quoted, err := usecase.CreateMessage(context.Background(), 2, "admin", "Original message")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer user_token")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := create(`{"content":"Reply","reply_to_message_id":` + strconv.FormatInt(quoted.ID, 10) + `}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var reply domain.Message
+	if err := json.Unmarshal(rr.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if reply.ReplyTo == nil || reply.ReplyTo.ID != quoted.ID || reply.ReplyTo.Content != "Original message" {
+		t.Errorf("Expected reply to embed quoted message %d, got %+v", quoted.ID, reply.ReplyTo)
+	}
+
+	rr = create(`{"content":"Reply","reply_to_message_id":999}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 replying to a missing message, got %d", rr.Code)
+	}
+}
+
+func TestHandler_RejectsInvalidUTF8(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Thread")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/messages", "/api/v1/messages/" + strconv.FormatInt(message.ID, 10) + "/comments"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader("{\"content\":\"Broken \xff\xfe bytes\"}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer user_token")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 posting invalid UTF-8 to %s, got %d: %s", path, rr.Code, rr.Body.String())
+		}
+	}
+
+	if messages, _, _ := usecase.GetMessages(context.Background(), 10, 0); len(messages) != 1 {
+		t.Errorf("Expected no new messages, got %d", len(messages))
+	}
+	if comments, _ := usecase.GetComments(context.Background(), message.ID, domain.SortAsc); len(comments) != 0 {
+		t.Errorf("Expected no comments, got %d", len(comments))
+	}
+}
+
+func TestHandler_CreateMessageValidationErrors(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+
+	body := `{"content":"` + strings.Repeat("a", 1001) + `"}`
+	req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer nameless_token")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp struct {
+		Errors []domain.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(resp.Errors) != 2 {
+		t.Fatalf("Expected 2 field errors, got %+v", resp.Errors)
+	}
+	if resp.Errors[0].Field != "content" || resp.Errors[1].Field != "username" {
+		t.Errorf("Expected content and username errors, got %+v", resp.Errors)
+	}
+}
+
+func TestHandler_StrictJSONRejectsUnknownFields(t *testing.T) {
+	post := func(cfg *config.Config, body string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		NewHandler(NewMockMessageUseCase(), ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+
+		req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer user_token")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	cfg := config.NewConfig()
+	cfg.StrictJSON = true
+
+	rr := post(cfg, `{"contnet":"hi"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `unknown field "contnet"`) {
+		t.Errorf("Expected error naming the unknown field, got %q", rr.Body.String())
+	}
+
+	// Extra metadata is ignored unless strict mode is on
+	cfg = config.NewConfig()
+	cfg.StrictJSON = false
+	if rr := post(cfg, `{"content":"hi","client":"web"}`); rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 outside strict mode, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_Presence(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/presence", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var presence struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &presence); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if presence.Count != 0 {
+		t.Errorf("Expected nobody online, got %d", presence.Count)
+	}
+}
+
+func TestHandler_Version(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+
+	getVersion := func() version.Info {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/version", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		var info version.Info
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return info
+	}
+
+	if info := getVersion(); info.Version != "dev" || info.Commit != "unknown" || info.BuildTime != "unknown" || info.GoVersion != runtime.Version() {
+		t.Errorf("Expected the defaults of a local build, got %+v", info)
+	}
+
+	// Simulate values injected with -ldflags -X
+	defer func(v, c, b string) { version.Version, version.Commit, version.BuildTime = v, c, b }(version.Version, version.Commit, version.BuildTime)
+	version.Version, version.Commit, version.BuildTime = "v1.2.3", "abc1234", "2024-05-01T12:00:00Z"
+	want := version.Info{Version: "v1.2.3", Commit: "abc1234", BuildTime: "2024-05-01T12:00:00Z", GoVersion: runtime.Version()}
+	if info := getVersion(); info != want {
+		t.Errorf("Expected %+v, got %+v", want, info)
+	}
+}
+
+func TestHandler_AuthUnavailable(t *testing.T) {
+	authClient := NewMockAuthClient()
+	mux := http.NewServeMux()
+	NewHandler(NewMockMessageUseCase(), ws.NewHub(), authClient, config.NewConfig()).RegisterRoutes(mux)
+
+	readyz := func() (int, map[string]string) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+		var body map[string]string
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to parse readiness response: %v", err)
+		}
+		return rr.Code, body
+	}
+
+	if code, body := readyz(); code != http.StatusOK || body["auth"] != "ok" {
+		t.Errorf("Expected ready with auth ok, got %d %v", code, body)
+	}
+
+	authClient.down = true
+	if code, body := readyz(); code != http.StatusServiceUnavailable || body["auth"] != "down" {
+		t.Errorf("Expected not ready with auth down, got %d %v", code, body)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(`{"content":"hi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer user_token")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 creating a message while auth is down, got %d", rr.Code)
+	}
+}
+
+func TestHandler_TokenCache(t *testing.T) {
+	authClient := NewMockAuthClient()
+	cfg := config.NewConfig()
+	cfg.TokenCacheTTL = time.Minute
+	handler := NewHandler(NewMockMessageUseCase(), ws.NewHub(), authClient, cfg)
+	now := time.Now()
+	handler.tokens.now = func() time.Time { return now }
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	unreadCount := func(token string) int {
+		req := httptest.NewRequest("GET", "/api/v1/messages/unread-count", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := unreadCount("user_token"); code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", code)
+		}
+	}
+	if authClient.validations != 1 {
+		t.Errorf("Expected the token to be validated once within the TTL, got %d", authClient.validations)
+	}
+
+	// Invalid tokens aren't cached
+	for i := 0; i < 2; i++ {
+		if code := unreadCount("bad_token"); code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401, got %d", code)
+		}
+	}
+	if authClient.validations != 3 {
+		t.Errorf("Expected invalid tokens to be validated every time, got %d validations", authClient.validations)
+	}
+
+	// Once the TTL passes the token is validated again, picking up a ban
+	authClient.users["user_token"] = &domain.User{ID: 1, Username: "testuser", Role: "user", IsBanned: true}
+	now = now.Add(time.Minute)
+	unreadCount("user_token")
+	unreadCount("user_token")
+	if authClient.validations != 5 {
+		t.Errorf("Expected an expired token and a banned user to be validated again, got %d validations", authClient.validations)
+	}
+
+	// Forgetting a user drops their cached tokens straight away
+	unreadCount("admin_token")
+	handler.ForgetUser(2)
+	unreadCount("admin_token")
+	if authClient.validations != 7 {
+		t.Errorf("Expected a forgotten user's token to be validated again, got %d validations", authClient.validations)
+	}
+}
+
+func TestHandler_GzipLargeResponses(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	for i := 0; i < 50; i++ {
+		if _, err := usecase.CreateMessage(context.Background(), 1, "testuser", "A message long enough to add up"); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/v1/messages?limit=50", "gzip, deflate")
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip-encoded list response, got %q", rr.Header().Get("Content-Encoding"))
+	}
+	if vary := strings.Join(rr.Header().Values("Vary"), ", "); !strings.Contains(vary, "Accept-Encoding") {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", vary)
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	var response struct {
+		Messages []*domain.Message `json:"items"`
+	}
+	if err := json.NewDecoder(zr).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode gzip body: %v", err)
+	}
+	if len(response.Messages) != 50 {
+		t.Errorf("Expected 50 messages, got %d", len(response.Messages))
+	}
+
+	// Clients that don't ask for gzip get plain JSON
+	if rr := get("/api/v1/messages?limit=50", ""); rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected uncompressed response without Accept-Encoding, got %q", rr.Header().Get("Content-Encoding"))
+	}
+
+	// Small responses stay uncompressed
+	rr = get("/api/v1/messages?limit=1", "gzip")
+	if rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected small response to stay uncompressed, got %q", rr.Header().Get("Content-Encoding"))
+	}
+	if rr.Code != http.StatusOK || !json.Valid(rr.Body.Bytes()) {
+		t.Errorf("Expected small response to be plain JSON, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandler_WebsocketHistory(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var ids []int64
+	for _, content := range []string{"First", "Banned", "Second"} {
+		message, err := usecase.CreateMessage(context.Background(), 1, "testuser", content)
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+	if err := usecase.BanMessage(context.Background(), ids[1]); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var frame struct {
+		Type string            `json:"type"`
+		Data []*domain.Message `json:"data"`
+	}
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("Failed to read history frame: %v", err)
+	}
+
+	if frame.Type != "history" {
+		t.Fatalf("Expected history frame, got %q", frame.Type)
+	}
+	if len(frame.Data) != 2 {
+		t.Fatalf("Expected 2 non-banned messages in history, got %d", len(frame.Data))
+	}
+	for _, message := range frame.Data {
+		if message.ID == ids[1] {
+			t.Error("Expected banned message to be excluded from history")
+		}
+	}
+}
+
+func TestHandler_WebsocketResume(t *testing.T) {
+	mux, _, hub := setupTestHandler(t)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	// frames reads n frames from conn; the server may batch several into one
+	// WebSocket message, separated by newlines
+	frames := func(conn *websocket.Conn, n int) []map[string]interface{} {
+		var out []map[string]interface{}
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for len(out) < n {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("Failed to read frame %d: %v", len(out)+1, err)
+			}
+			for _, line := range bytes.Split(data, []byte("\n")) {
+				var frame map[string]interface{}
+				if err := json.Unmarshal(line, &frame); err != nil {
+					t.Fatalf("Failed to parse frame %s: %v", line, err)
+				}
+				out = append(out, frame)
+			}
+		}
+		return out
+	}
+	waitForSeq := func(seq int64) {
+		deadline := time.Now().Add(2 * time.Second)
+		for hub.Seq() < seq {
+			if time.Now().After(deadline) {
+				t.Fatalf("Hub never reached seq %d", seq)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if history := frames(conn, 1)[0]; history["type"] != "history" || history["seq"] != float64(0) {
+		t.Fatalf("Expected a history frame at seq 0, got %v", history)
+	}
+	hub.BroadcastMessage(&domain.Message{ID: 1, Content: "Seen live"})
+	if live := frames(conn, 1)[0]; live["seq"] != float64(1) || live["content"] != "Seen live" {
+		t.Fatalf("Expected the live broadcast numbered 1, got %v", live)
+	}
+	conn.Close()
+
+	// Broadcasts while the client is away are kept for it
+	hub.BroadcastMessage(&domain.Message{ID: 2, Content: "Missed"})
+	hub.BroadcastMessage(&domain.Message{ID: 3, Content: "Also missed"})
+	waitForSeq(3)
+
+	conn, _, err = websocket.DefaultDialer.Dial(url+"?since_seq=1", nil)
+	if err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	defer conn.Close()
+	missed := frames(conn, 2)
+	if missed[0]["seq"] != float64(2) || missed[0]["content"] != "Missed" || missed[1]["seq"] != float64(3) {
+		t.Errorf("Expected broadcasts 2 and 3 replayed in order, got %v", missed)
+	}
+
+	// Then the live feed carries on from there
+	hub.BroadcastMessage(&domain.Message{ID: 4, Content: "Live again"})
+	if live := frames(conn, 1)[0]; live["seq"] != float64(4) {
+		t.Errorf("Expected the next live broadcast numbered 4, got %v", live)
+	}
+
+	// A sequence number the hub never handed out, e.g. from before a restart,
+	// asks the client to resync
+	stale, _, err := websocket.DefaultDialer.Dial(url+"?since_seq=99", nil)
+	if err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	defer stale.Close()
+	if frame := frames(stale, 1)[0]; frame["type"] != "resync" || frame["seq"] != float64(4) {
+		t.Errorf("Expected a resync frame at seq 4, got %v", frame)
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?since_seq=abc", nil); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid since_seq, got %v", err)
+	}
+}
+
+func TestHandler_WebsocketPing(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping","ts":1700000000123}`)); err != nil {
+		t.Fatalf("Failed to send ping: %v", err)
+	}
+
+	// Skip the history frame
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read pong: %v", err)
+		}
+		var frame struct {
+			Type string          `json:"type"`
+			TS   json.RawMessage `json:"ts"`
+		}
+		if err := json.Unmarshal(data, &frame); err != nil || frame.Type != "pong" {
+			continue
+		}
+		if string(frame.TS) != "1700000000123" {
+			t.Errorf("Expected the ping's ts echoed back, got %s", frame.TS)
+		}
+		return
+	}
+}
+
+func TestHandler_WebsocketOriginCheck(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dial := func(origin string) (*websocket.Conn, *http.Response, error) {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", header)
+	}
+
+	for _, origin := range []string{"http://localhost:8000", server.URL, ""} {
+		conn, _, err := dial(origin)
+		if err != nil {
+			t.Errorf("Expected origin %q to be allowed, got %v", origin, err)
+			continue
+		}
+		conn.Close()
+	}
+
+	conn, resp, err := dial("http://evil.example.com")
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected cross-origin upgrade to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a disallowed origin, got %v", resp)
+	}
+}
+
+func TestHandler_WebsocketConnectionLimit(t *testing.T) {
+	mux, _, hub := setupTestHandler(t)
+	hub.SetMaxConnsPerIP(2)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dial := func() (*websocket.Conn, *http.Response, error) {
+		return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	}
+
+	var conns []*websocket.Conn
+	for i := 0; i < 2; i++ {
+		conn, _, err := dial()
+		if err != nil {
+			t.Fatalf("Connection %d: expected to be within the limit, got %v", i+1, err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+
+	conn, resp, err := dial()
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected a connection beyond the limit to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 beyond the limit, got %v", resp)
+	}
+
+	// Closing a connection frees its slot once the server notices
+	conns[0].Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn, _, err := dial()
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a slot to free up after disconnecting, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandler_GetMessageWithComments(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	message, err := usecase.CreateMessage(ctx, 1, "testuser", "Detail page")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	for _, content := range []string{"First comment", "Second comment"} {
+		if _, err := usecase.CreateComment(ctx, message.ID, 2, "admin", content); err != nil {
+			t.Fatalf("Failed to create test comment: %v", err)
+		}
+	}
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/messages/"+strconv.FormatInt(message.ID, 10)+"?include=comments", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var detail struct {
+		ID       int64             `json:"id"`
+		Content  string            `json:"content"`
+		Comments []*domain.Comment `json:"comments"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if detail.ID != message.ID || detail.Content != "Detail page" {
+		t.Errorf("Expected message %d in response, got %+v", message.ID, detail)
+	}
+	if len(detail.Comments) != 2 {
+		t.Errorf("Expected 2 comments, got %d", len(detail.Comments))
+	}
+
+	// Banned messages are hidden from everyone but admins
+	if err := usecase.BanMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if rr := get("user_token"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for banned message, got %d", rr.Code)
+	}
+	if rr := get("admin_token"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for admin, got %d", rr.Code)
+	}
+}
+
+func TestHandler_GetMessageBySlug(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	// Create through the API, then follow the slug in the response
+	req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(`{"content": "Shareable links are nice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer user_token")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created domain.Message
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if expected := strconv.FormatInt(created.ID, 10) + "-shareable-links-are-nice"; created.Slug != expected {
+		t.Fatalf("Expected slug %q, got %q", expected, created.Slug)
+	}
+
+	get := func(slug, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/messages/slug/"+slug, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = get(created.Slug, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var found domain.Message
+	if err := json.Unmarshal(rr.Body.Bytes(), &found); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if found.ID != created.ID || found.Content != "Shareable links are nice" {
+		t.Errorf("Expected message %d, got %+v", created.ID, found)
+	}
+
+	if rr := get("999-nothing-here", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown slug, got %d", rr.Code)
+	}
+	if rr := get("", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a slug, got %d", rr.Code)
+	}
+
+	// Banned messages are hidden from everyone but admins
+	if err := usecase.BanMessage(ctx, created.ID); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if rr := get(created.Slug, "user_token"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for banned message, got %d", rr.Code)
+	}
+	if rr := get(created.Slug, "admin_token"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for admin, got %d", rr.Code)
+	}
+}
+
+func TestHandler_Upload(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.UploadDir = t.TempDir()
+	cfg.MaxUploadSize = 4096
+	mux := http.NewServeMux()
+	NewHandler(NewMockMessageUseCase(), ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+
+	upload := func(content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "upload.bin")
+		part.Write(content)
+		form.Close()
+
+		req := httptest.NewRequest("POST", "/api/v1/uploads", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer user_token")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+	t.Run("Allowed image", func(t *testing.T) {
+		rr := upload(png)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			URL         string `json:"url"`
+			ContentType string `json:"content_type"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if resp.ContentType != "image/png" || !strings.HasSuffix(resp.URL, ".png") {
+			t.Errorf("Expected a .png URL with type image/png, got %+v", resp)
+		}
+
+		// The returned URL serves the stored file
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", resp.URL, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 fetching upload, got %d", rr.Code)
+		}
+		if got, _ := io.ReadAll(rr.Body); !bytes.Equal(got, png) {
+			t.Error("Expected served file to match the upload")
+		}
+	})
+
+	t.Run("Rejected executable", func(t *testing.T) {
+		exe := append([]byte("MZ\x90\x00"), make([]byte, 64)...)
+		if rr := upload(exe); rr.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("Expected status 415, got %d", rr.Code)
+		}
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		if rr := upload(append(png, make([]byte, 8192)...)); rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status 413, got %d", rr.Code)
+		}
+	})
+}
+
+func TestHandler_Preview(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.PreviewsPerMinute = 1
+	mux := http.NewServeMux()
+	NewHandler(NewMockMessageUseCase(), ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+
+	preview := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/preview", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := preview(`{"content":"**Hello**"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var rendered domain.RenderedContent
+	if err := json.Unmarshal(rr.Body.Bytes(), &rendered); err != nil || rendered.Content != "**Hello**" {
+		t.Errorf("Expected the rendered content back, got %s (%v)", rr.Body.String(), err)
+	}
+
+	if rr := preview(`{"content":"Again"}`); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 over the preview limit, got %d", rr.Code)
+	}
+}
+
+func TestHandler_RateLimitsMessagesAndCommentsIndependently(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.MessagesPerMinute = 2
+	cfg.CommentsPerMinute = 3
+	usecase := NewMockMessageUseCase()
+	mux := http.NewServeMux()
+	NewHandler(usecase, ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Rate limited")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	post := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"content":"spam"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	commentsPath := "/api/v1/messages/" + strconv.FormatInt(message.ID, 10) + "/comments"
+
+	for i := 0; i < 2; i++ {
+		if rr := post("/api/v1/messages", "user_token"); rr.Code != http.StatusCreated {
+			t.Fatalf("Message %d: expected status 201, got %d", i+1, rr.Code)
+		}
+	}
+	rr := post("/api/v1/messages", "user_token")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 over the message limit, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on 429")
+	}
+
+	// Exhausting the message budget leaves the comment budget untouched
+	for i := 0; i < 3; i++ {
+		if rr := post(commentsPath, "user_token"); rr.Code != http.StatusCreated {
+			t.Fatalf("Comment %d: expected status 201, got %d", i+1, rr.Code)
+		}
+	}
+	if rr := post(commentsPath, "user_token"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 over the comment limit, got %d", rr.Code)
+	}
+
+	// Limits are per user
+	if rr := post(commentsPath, "admin_token"); rr.Code != http.StatusCreated {
+		t.Errorf("Expected another user's comment to be allowed, got %d", rr.Code)
+	}
+}
+
+// mockRateLimiter answers every Allow with its fields and records the keys asked about
+type mockRateLimiter struct {
+	allow      bool
+	retryAfter time.Duration
+	err        error
+	keys       []string
+}
+
+func (m *mockRateLimiter) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	m.keys = append(m.keys, key)
+	return m.allow, m.retryAfter, m.err
+}
+
+func TestHandler_SetRateLimiters(t *testing.T) {
+	cfg := config.NewConfig()
+	handler := NewHandler(NewMockMessageUseCase(), ws.NewHub(), NewMockAuthClient(), cfg)
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/messages", strings.NewReader(`{"content": "Limited"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer user_token")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	messages := &mockRateLimiter{retryAfter: 1500 * time.Millisecond}
+	handler.SetRateLimiters(messages, &mockRateLimiter{allow: true}, &mockRateLimiter{allow: true})
+	rr := post()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 when the limiter refuses, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After rounded up to 2, got %q", got)
+	}
+	if len(messages.keys) != 1 || messages.keys[0] != "user:1" {
+		t.Errorf("Expected the message limiter to be asked about user:1, got %v", messages.keys)
+	}
+
+	// A failing limiter, such as an unreachable Redis, lets requests through
+	messages.err = errors.New("connection refused")
+	if rr := post(); rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 when the limiter fails, got %d", rr.Code)
+	}
+}
+
+func TestHandler_GetCommentsOrder(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	message, err := usecase.CreateMessage(ctx, 1, "testuser", "Thread")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	for _, content := range []string{"first", "second"} {
+		if _, err := usecase.CreateComment(ctx, message.ID, 1, "testuser", content); err != nil {
+			t.Fatalf("Failed to create test comment: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query          string
+		expectedStatus int
+		expectedFirst  string
+	}{
+		{query: "", expectedStatus: http.StatusOK, expectedFirst: "first"},
+		{query: "?order=asc", expectedStatus: http.StatusOK, expectedFirst: "first"},
+		{query: "?order=desc", expectedStatus: http.StatusOK, expectedFirst: "second"},
+		{query: "?order=newest", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/v1/messages/"+strconv.FormatInt(message.ID, 10)+"/comments"+tt.query, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%q: expected status %d, got %d", tt.query, tt.expectedStatus, rr.Code)
+			continue
+		}
+		if tt.expectedStatus != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Comments []*domain.Comment `json:"items"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(resp.Comments) != 2 || resp.Comments[0].Content != tt.expectedFirst {
+			t.Errorf("%q: expected %q first, got %+v", tt.query, tt.expectedFirst, resp.Comments)
+		}
+	}
+}
+
+func TestHandler_ListsUsePagedResponse(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	message, err := usecase.CreateMessage(ctx, 1, "testuser", "Thread")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := usecase.CreateMessage(ctx, 1, "testuser", "More"); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		if _, err := usecase.CreateComment(ctx, message.ID, 1, "testuser", "Reply"); err != nil {
+			t.Fatalf("Failed to create test comment: %v", err)
+		}
+	}
+
+	get := func(path string) map[string]json.RawMessage {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer user_token")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, rr.Code, rr.Body.String())
+		}
+		var response map[string]json.RawMessage
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to parse response: %v", path, err)
+		}
+		return response
+	}
+
+	for _, path := range []string{
+		"/api/v1/messages",
+		"/api/v1/me/messages",
+		"/api/v1/users/1/comments",
+		"/api/v1/users/1/mentions",
+		"/api/v1/messages/search?q=More",
+		"/api/v1/messages/" + strconv.FormatInt(message.ID, 10) + "/comments",
+	} {
+		response := get(path)
+		for _, key := range []string{"items", "total", "limit", "offset"} {
+			if _, ok := response[key]; !ok {
+				t.Errorf("%s: expected %q in the response, got %v", path, key, response)
+			}
+		}
+		if items := string(response["items"]); !strings.HasPrefix(items, "[") {
+			t.Errorf("%s: expected items to be an array, got %s", path, items)
+		}
+	}
+
+	// next_cursor is only set while more results follow, and fetches them
+	var page PagedResponse[*domain.Message]
+	response := get("/api/v1/messages?limit=2")
+	if err := json.Unmarshal(response["next_cursor"], &page.NextCursor); err != nil || page.NextCursor == "" {
+		t.Fatalf("Expected a next_cursor with 3 messages and limit 2, got %v", response)
+	}
+	last := get("/api/v1/messages?limit=2&cursor=" + page.NextCursor)
+	var rest []*domain.Message
+	if err := json.Unmarshal(last["items"], &rest); err != nil {
+		t.Fatalf("Failed to parse items: %v", err)
+	}
+	if len(rest) != 1 {
+		t.Errorf("Expected the remaining message on the next page, got %d", len(rest))
+	}
+	if _, ok := last["next_cursor"]; ok {
+		t.Errorf("Expected no next_cursor on the last page, got %s", last["next_cursor"])
+	}
+}
+
+func TestHandler_LatestMessage(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/messages/latest", nil))
+		return rr
+	}
+
+	if rr := get(); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 with no messages, got %d", rr.Code)
+	}
+
+	var newest *domain.Message
+	for _, content := range []string{"Older", "Newer", "Newest"} {
+		message, err := usecase.CreateMessage(ctx, 1, "testuser", content)
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		newest = message
+	}
+
+	rr := get()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var latest domain.Message
+	if err := json.Unmarshal(rr.Body.Bytes(), &latest); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if latest.ID != newest.ID || latest.Content != "Newest" {
+		t.Errorf("Expected message %d, got %+v", newest.ID, latest)
+	}
+}
+
+func TestHandler_GetMessagesByIDs(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	var ids []int64
+	for _, content := range []string{"First", "Second"} {
+		message, err := usecase.CreateMessage(context.Background(), 1, "testuser", content)
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", fmt.Sprintf("/api/v1/messages?ids=%d,999,%d", ids[1], ids[0]), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Items []*domain.Message `json:"items"`
+		Total int64             `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Total != 2 || len(response.Items) != 2 || response.Items[0].ID != ids[1] || response.Items[1].ID != ids[0] {
+		t.Errorf("Expected messages [%d %d] of 2 in the paged envelope, got %s", ids[1], ids[0], rr.Body.String())
+	}
+}
+
+func TestHandler_GetMessagesTruncate(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	content := "Hello, world! This message is long enough to truncate."
+	if _, err := usecase.CreateMessage(context.Background(), 1, "testuser", content); err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+	list := func(target string) *domain.Message {
+		t.Helper()
+		rr := get(target)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Messages []*domain.Message `json:"items"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if len(response.Messages) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(response.Messages))
+		}
+		return response.Messages[0]
+	}
+
+	full := list("/api/v1/messages")
+	if full.Content != content || full.ContentLength != len(content) || full.Truncated {
+		t.Errorf("Expected full content with length %d, got %+v", len(content), full)
+	}
+
+	cut := list("/api/v1/messages?truncate=5")
+	if cut.Content != "Hello" || cut.ContentLength != len(content) || !cut.Truncated {
+		t.Errorf("Expected content cut to 5 characters with length %d, got %+v", len(content), cut)
+	}
+
+	// The cut shouldn't leak into later requests through the list cache
+	if again := list("/api/v1/messages"); again.Content != content {
+		t.Errorf("Expected full content again, got %q", again.Content)
+	}
+
+	for _, value := range []string{"-1", "abc"} {
+		if rr := get("/api/v1/messages?truncate=" + value); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for truncate=%s, got %d", value, rr.Code)
+		}
+	}
+}
+
+func TestHandler_TrendingMessages(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	quiet, err := usecase.CreateMessage(ctx, 1, "testuser", "Quiet")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	busy, err := usecase.CreateMessage(ctx, 1, "testuser", "Busy")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	for _, id := range []int64{quiet.ID, busy.ID, busy.ID} {
+		if _, err := usecase.CreateComment(ctx, id, 1, "testuser", "Reply"); err != nil {
+			t.Fatalf("Failed to create test comment: %v", err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/messages/trending", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page PagedResponse[*domain.Message]
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if page.Total != 2 || len(page.Items) != 2 || page.Items[0].ID != busy.ID {
+		t.Errorf("Expected the busier message first of 2, got %+v", page)
+	}
+}
+
+func TestHandler_MessageListLinkHeader(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	for i := 0; i < 6; i++ {
+		if _, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Message"); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	links := func(path string) map[string]string {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, rr.Code)
+		}
+		rels := make(map[string]string)
+		for _, link := range strings.Split(rr.Header().Get("Link"), ", ") {
+			var url, rel string
+			if _, err := fmt.Sscanf(link, "<%s rel=%q", &url, &rel); err != nil {
+				t.Fatalf("%s: malformed link %q: %v", path, link, err)
+			}
+			rels[rel] = strings.TrimSuffix(url, ">;")
+		}
+		return rels
+	}
+
+	// A middle page links both ways, keeping other parameters
+	got := links("/api/v1/messages?sort=active&limit=2&offset=2")
+	want := map[string]string{
+		"first": "/api/v1/messages?limit=2&offset=0&sort=active",
+		"prev":  "/api/v1/messages?limit=2&offset=0&sort=active",
+		"next":  "/api/v1/messages?limit=2&offset=4&sort=active",
+	}
+	for rel, url := range want {
+		if got[rel] != url {
+			t.Errorf("Expected rel=%q to be %s, got %q", rel, url, got[rel])
+		}
+	}
+
+	// The first page has no prev and the last no next
+	if _, ok := links("/api/v1/messages?limit=2")["prev"]; ok {
+		t.Error("Expected no prev link on the first page")
+	}
+	if last := links("/api/v1/messages?limit=2&cursor=4"); last["next"] != "" || last["prev"] != "/api/v1/messages?limit=2&offset=2" {
+		t.Errorf("Expected only first and prev links on the last page, got %v", last)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "not-a-cidr"})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		trusted    []*net.IPNet
+		want       string
+	}{
+		{name: "No proxy", remoteAddr: "203.0.113.7:5000", trusted: trusted, want: "203.0.113.7"},
+		{
+			name:       "Untrusted peer can't spoof X-Forwarded-For",
+			remoteAddr: "203.0.113.7:5000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			trusted:    trusted,
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Headers ignored without trusted proxies",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "Trusted proxy X-Forwarded-For",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			trusted:    trusted,
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Client-supplied hops before the first untrusted one are ignored",
+			remoteAddr: "10.0.0.2:5000",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.3"},
+			trusted:    trusted,
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Trusted single IP with X-Real-IP",
+			remoteAddr: "192.168.1.1:5000",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.2"},
+			trusted:    trusted,
+			want:       "198.51.100.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			if got := clientIP(req, tt.trusted); got != tt.want {
+				t.Errorf("Expected client IP %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandler_AdminRenameUser(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	message, err := usecase.CreateMessage(ctx, 1, "testuser", "First post")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	if _, err := usecase.CreateMessage(ctx, 1, "testuser", "Second post"); err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	if _, err := usecase.CreateComment(ctx, message.ID, 1, "testuser", "A comment"); err != nil {
+		t.Fatalf("Failed to create test comment: %v", err)
+	}
+	other, err := usecase.CreateMessage(ctx, 2, "admin", "Unrelated")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	rename := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/admin/users/1/rename", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := rename("user_token", `{"username":"renamed"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	if rr := rename("admin_token", `{"username":"  "}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid username, got %d", rr.Code)
+	}
+
+	rr := rename("admin_token", `{"username":"renamed"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Updated int64 `json:"updated"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Updated != 3 {
+		t.Errorf("Expected 3 updated posts, got %d", resp.Updated)
+	}
+
+	for _, msg := range usecase.messages {
+		if msg.UserID == 1 && msg.Username != "renamed" {
+			t.Errorf("Expected message %d to show the new username, got %q", msg.ID, msg.Username)
+		}
+	}
+	for _, comment := range usecase.comments {
+		if comment.UserID == 1 && comment.Username != "renamed" {
+			t.Errorf("Expected comment %d to show the new username, got %q", comment.ID, comment.Username)
+		}
+	}
+	if usecase.messages[other.ID].Username != "admin" {
+		t.Error("Expected other users' posts to be untouched")
+	}
+}
+
+func TestHandler_CORSPreflight(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+
+	tests := []struct {
+		path           string
+		expectedMethod string
+	}{
+		{path: "/api/v1/messages", expectedMethod: "GET, POST, OPTIONS"},
+		{path: "/api/v1/messages/1", expectedMethod: "GET, POST, PUT, DELETE, OPTIONS"},
+		{path: "/api/v1/comments/1", expectedMethod: "PUT, DELETE, OPTIONS"},
+		{path: "/api/v1/messages/search", expectedMethod: "GET, OPTIONS"},
+		{path: "/api/v1/messages/stream", expectedMethod: "GET, OPTIONS"},
+		{path: "/api/v1/preview", expectedMethod: "POST, OPTIONS"},
+		{path: "/api/v1/admin/readonly", expectedMethod: "GET, POST, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", tt.path, nil)
+			req.Header.Set("Origin", "http://localhost:8000")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+			header := rr.Header()
+			if got := header.Get("Access-Control-Allow-Methods"); got != tt.expectedMethod {
+				t.Errorf("Expected Access-Control-Allow-Methods %q, got %q", tt.expectedMethod, got)
+			}
+			if got := header.Get("Access-Control-Allow-Origin"); got != "http://localhost:8000" {
+				t.Errorf("Expected the origin to be allowed, got %q", got)
+			}
+			if header.Get("Access-Control-Allow-Headers") != "Content-Type, Authorization, X-Requested-With" ||
+				header.Get("Access-Control-Allow-Credentials") != "true" || header.Get("Access-Control-Max-Age") != "3600" {
+				t.Errorf("Expected the default CORS policy, got %v", header)
+			}
+		})
+	}
+}
+
+func TestHandler_CORSPolicyFromConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.CORSAllowedHeaders = []string{"Authorization"}
+	cfg.CORSAllowCredentials = false
+	cfg.CORSMaxAge = 0
+	mux := http.NewServeMux()
+	NewHandler(NewMockMessageUseCase(), ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/messages", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	header := rr.Header()
+	if got := header.Get("Access-Control-Allow-Headers"); got != "Authorization" {
+		t.Errorf("Expected the configured headers, got %q", got)
+	}
+	for _, name := range []string{"Access-Control-Allow-Credentials", "Access-Control-Max-Age", "Access-Control-Allow-Origin"} {
+		if got := header.Get(name); got != "" {
+			t.Errorf("Expected no %s, got %q", name, got)
+		}
+	}
+}
+
+func TestHandler_MethodNotAllowedSetsAllow(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+
+	tests := []struct {
+		path          string
+		method        string
+		expectedAllow string
+	}{
+		{path: "/api/v1/messages", method: http.MethodPatch, expectedAllow: "GET, POST, OPTIONS"},
+		{path: "/api/v1/messages/1", method: http.MethodPatch, expectedAllow: "GET, PUT, DELETE, OPTIONS"},
+		{path: "/api/v1/messages/1/comments", method: http.MethodDelete, expectedAllow: "GET, POST, OPTIONS"},
+		{path: "/api/v1/comments/1", method: http.MethodGet, expectedAllow: "PUT, DELETE, OPTIONS"},
+		{path: "/api/v1/messages/ban", method: http.MethodGet, expectedAllow: "POST, OPTIONS"},
+		{path: "/api/v1/messages/pinned", method: http.MethodPut, expectedAllow: "GET, OPTIONS"},
+		{path: "/api/v1/messages/unread-count", method: http.MethodPost, expectedAllow: "GET, OPTIONS"},
+		{path: "/api/v1/messages/1/pin", method: http.MethodPut, expectedAllow: "POST, DELETE, OPTIONS"},
+		{path: "/api/v1/messages/1/pin-status", method: http.MethodPost, expectedAllow: "GET, OPTIONS"},
+		{path: "/api/v1/users/1/mentions", method: http.MethodPost, expectedAllow: "GET, OPTIONS"},
+		{path: "/api/v1/admin/readonly", method: http.MethodPut, expectedAllow: "GET, POST, OPTIONS"},
+		{path: "/api/v1/uploads", method: http.MethodGet, expectedAllow: "POST, OPTIONS"},
+		{path: "/api/v1/uploads/image.png", method: http.MethodPost, expectedAllow: "GET, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("Expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+			}
+			if allow := rr.Header().Get("Allow"); allow != tt.expectedAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.expectedAllow, allow)
+			}
+		})
+	}
+}
+
+func TestHandler_MessageWithIDRejectsBadPaths(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+
+	for _, path := range []string{
+		"/api/v1/messages/abc",
+		"/api/v1/messages/abc/pin",
+		"/api/v1/messages/abc/lock",
+		"/api/v1/messages/abc/comments",
+		"/api/v1/messages/1/bogus",
+		"/api/v1/messages/1/comments/extra",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_AdminDeleteMissingReturnsNotFound(t *testing.T) {
+	mux, _, _ := setupTestHandler(t)
+
+	for _, path := range []string{
+		"/api/v1/messages/999",
+		"/api/v1/messages/999?action=delete",
+		"/api/v1/comments/999",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, path, nil)
+			req.Header.Set("Authorization", "Bearer admin_token")
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusNotFound, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_BulkImportComments(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Imported thread")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/admin/comments/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	comment := `{"message_id":` + strconv.FormatInt(message.ID, 10) + `,"user_id":5,"username":"olduser","content":"Hello"}`
+	body := `{"comments":[` + strings.TrimSuffix(strings.Repeat(comment+",", 100), ",") + `]}`
+
+	if rr := post("user_token", body); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+
+	rr := post("admin_token", body)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.IDs) != 100 {
+		t.Errorf("Expected 100 IDs, got %d", len(response.IDs))
+	}
+
+	rr = post("admin_token", `{"comments":[`+comment+`,{"message_id":1,"username":"olduser","content":""}]}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid comment, got %d", rr.Code)
+	}
+
+	if err := usecase.LockMessage(context.Background(), message.ID); err != nil {
+		t.Fatalf("Failed to lock message: %v", err)
+	}
+	if rr := post("admin_token", `{"comments":[`+comment+`]}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 importing onto a locked thread, got %d", rr.Code)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	handler := timeoutMiddleware(time.Second, func(w http.ResponseWriter, r *http.Request) {
+		deadline, hasDeadline = r.Context().Deadline()
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/messages", nil))
+
+	if !hasDeadline {
+		t.Fatal("Expected request context to have a deadline")
+	}
+	if remaining := time.Until(deadline); remaining > time.Second {
+		t.Errorf("Expected deadline within 1s, got %v", remaining)
+	}
+}
+
+func TestHandler_MessageTimeSeries(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	// Two messages yesterday, one today
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.Add(-24 * time.Hour)
+	for _, createdAt := range []time.Time{yesterday.Add(time.Hour), yesterday.Add(2 * time.Hour), today} {
+		message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Message")
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		message.CreatedAt = createdAt
+	}
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/admin/stats/timeseries"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get("?interval=day", "user_token"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	if rr := get("?interval=week", "admin_token"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown interval, got %d", rr.Code)
+	}
+
+	rr := get("?interval=day", "admin_token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Interval string              `json:"interval"`
+		Series   []domain.TimeBucket `json:"series"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Interval != "day" || len(resp.Series) != 2 {
+		t.Fatalf("Expected 2 daily buckets, got %+v", resp)
+	}
+	if !resp.Series[0].Bucket.Equal(yesterday) || resp.Series[0].Count != 2 || !resp.Series[1].Bucket.Equal(today) || resp.Series[1].Count != 1 {
+		t.Errorf("Expected 2 messages yesterday then 1 today, got %+v", resp.Series)
+	}
+}
+
+func TestHandler_RestoreMessage(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Deleted by mistake")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	if err := usecase.DeleteMessage(context.Background(), message.ID); err != nil {
+		t.Fatalf("Failed to delete test message: %v", err)
+	}
+	restorePath := "/api/v1/admin/messages/" + strconv.FormatInt(message.ID, 10) + "/restore"
+
+	restore := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", restorePath, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := restore("user_token"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	rr := restore("admin_token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var restored domain.Message
+	if err := json.Unmarshal(rr.Body.Bytes(), &restored); err != nil || restored.ID != message.ID {
+		t.Errorf("Expected the restored message, got %s", rr.Body.String())
+	}
+	if rr := restore("admin_token"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 restoring a message that isn't deleted, got %d", rr.Code)
+	}
+}
+
+func TestHandler_ModerationView(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Reported post")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := usecase.CreateComment(context.Background(), message.ID, 1, "testuser", "A comment"); err != nil {
+			t.Fatalf("Failed to create test comment: %v", err)
+		}
+	}
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	path := "/api/v1/admin/messages/" + strconv.FormatInt(message.ID, 10)
+
+	if rr := get(path, "user_token"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	rr := get(path, "admin_token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var view domain.MessageModerationView
+	if err := json.Unmarshal(rr.Body.Bytes(), &view); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if view.Message == nil || view.ID != message.ID || view.CommentCount != 2 {
+		t.Errorf("Expected message %d with 2 comments, got %s", message.ID, rr.Body.String())
+	}
+
+	if rr := get("/api/v1/admin/messages/999", "admin_token"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing message, got %d", rr.Code)
+	}
+	if rr := get(path+"/unknown", "admin_token"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown action, got %d", rr.Code)
+	}
+}
+
+func TestHandler_RecentComments(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	var messages []*domain.Message
+	for _, content := range []string{"First thread", "Second thread"} {
+		message, err := usecase.CreateMessage(context.Background(), 1, "testuser", content)
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		messages = append(messages, message)
+	}
+	var commentIDs []int64
+	for i := 0; i < 3; i++ {
+		comment, err := usecase.CreateComment(context.Background(), messages[i%2].ID, 1, "testuser", "A comment")
+		if err != nil {
+			t.Fatalf("Failed to create test comment: %v", err)
+		}
+		commentIDs = append(commentIDs, comment.ID)
+	}
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/admin/comments", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get("user_token"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	rr := get("admin_token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page struct {
+		Items []*domain.Comment `json:"items"`
+		Total int64             `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if page.Total != 3 || len(page.Items) != 3 {
+		t.Fatalf("Expected 3 comments, got %s", rr.Body.String())
+	}
+	for i, comment := range page.Items {
+		if want := commentIDs[2-i]; comment.ID != want {
+			t.Errorf("Position %d: expected comment %d, got %d", i, want, comment.ID)
+		}
+		if comment.Message == nil || comment.Message.ID != comment.MessageID || comment.Message.Content != messages[(2-i)%2].Content {
+			t.Errorf("Expected comment %d to carry its message, got %+v", comment.ID, comment.Message)
+		}
+	}
+}
+
+func TestHandler_SubscribeAndNotifications(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Watch this thread")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	subscribePath := "/api/v1/messages/" + strconv.FormatInt(message.ID, 10) + "/subscribe"
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("POST", subscribePath, "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", rr.Code)
+	}
+	if rr := do("POST", subscribePath, "user_token", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 subscribing, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", "/api/v1/messages/999/subscribe", "user_token", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 subscribing to a missing message, got %d", rr.Code)
+	}
+
+	commentsPath := "/api/v1/messages/" + strconv.FormatInt(message.ID, 10) + "/comments"
+	if rr := do("POST", commentsPath, "admin_token", `{"content": "A reply"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 commenting, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr := do("GET", "/api/v1/me/notifications", "user_token", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page struct {
+		Items []*domain.Notification `json:"items"`
+		Total int64                  `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if page.Total != 1 || len(page.Items) != 1 || page.Items[0].MessageID != message.ID {
+		t.Errorf("Expected one notification for message %d, got %s", message.ID, rr.Body.String())
+	}
+
+	if rr := do("DELETE", subscribePath, "user_token", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 unsubscribing, got %d", rr.Code)
+	}
+	if rr := do("POST", commentsPath, "admin_token", `{"content": "Another reply"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 commenting, got %d", rr.Code)
+	}
+	if err := json.Unmarshal(do("GET", "/api/v1/me/notifications", "user_token", "").Body.Bytes(), &page); err != nil || page.Total != 1 {
+		t.Errorf("Expected no new notifications after unsubscribing, got %d (%v)", page.Total, err)
+	}
+}
+
+func TestHandler_BanMatching(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	for _, content := range []string{"Visit spam.example.com now", "Legit post"} {
+		if _, err := usecase.CreateMessage(context.Background(), 1, "testuser", content); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	ban := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/admin/messages/ban-matching", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := ban("user_token", `{"pattern":"spam"}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	if rr := ban("admin_token", `{"pattern":"(unclosed"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid pattern, got %d", rr.Code)
+	}
+	if rr := ban("admin_token", `{"pattern":""}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty pattern, got %d", rr.Code)
+	}
+
+	rr := ban("admin_token", `{"pattern":"spam\\.example"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]int64
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp["banned"] != 1 {
+		t.Errorf("Expected 1 message banned, got %d", resp["banned"])
+	}
+}
+
+func TestHandler_UnbanMessagesBulk(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, content := range []string{"Banned", "Also banned"} {
+		message, err := usecase.CreateMessage(ctx, 1, "testuser", content)
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		if err := usecase.BanMessage(ctx, message.ID); err != nil {
+			t.Fatalf("Failed to ban test message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	unban := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/messages/unban-bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	body := fmt.Sprintf(`{"ids":[%d,404,%d]}`, ids[0], ids[1])
+	if rr := unban("user_token", body); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	if rr := unban("admin_token", `{"ids":[]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without ids, got %d", rr.Code)
+	}
+
+	rr := unban("admin_token", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Succeeded []int64 `json:"succeeded"`
+		Failed    []int64 `json:"failed"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if fmt.Sprint(resp.Succeeded) != fmt.Sprint(ids) || fmt.Sprint(resp.Failed) != "[404]" {
+		t.Errorf("Expected %v to succeed and 404 to fail, got %+v", ids, resp)
+	}
+	for _, id := range ids {
+		if usecase.messages[id].IsBanned {
+			t.Errorf("Expected message %d to be unbanned", id)
+		}
+	}
+}
+
+func TestHandler_ReadOnlyMode(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	message, err := usecase.CreateMessage(context.Background(), 1, "testuser", "Before maintenance")
+	if err != nil {
+		t.Fatalf("Failed to create test message: %v", err)
+	}
+	messagePath := "/api/v1/messages/" + strconv.FormatInt(message.ID, 10)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("POST", "/api/v1/admin/readonly", "user_token", `{"read_only":true}`); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-admin, got %d", rr.Code)
+	}
+	rr := do("POST", "/api/v1/admin/readonly", "admin_token", `{"read_only":true}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"read_only":true`) {
+		t.Fatalf("Expected read-only mode on, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	writes := []struct{ method, path, body string }{
+		{"POST", "/api/v1/messages", `{"content":"During maintenance"}`},
+		{"POST", messagePath + "/comments", `{"content":"During maintenance"}`},
+		{"POST", "/api/v1/messages/ban", fmt.Sprintf(`{"id":%d}`, message.ID)},
+		{"DELETE", messagePath, ""},
+	}
+	for _, w := range writes {
+		if rr := do(w.method, w.path, "admin_token", w.body); rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: expected status 503 in read-only mode, got %d", w.method, w.path, rr.Code)
+		}
+	}
+	for _, path := range []string{"/api/v1/messages", messagePath, messagePath + "/comments"} {
+		if rr := do("GET", path, "user_token", ""); rr.Code != http.StatusOK {
+			t.Errorf("GET %s: expected status 200 in read-only mode, got %d", path, rr.Code)
+		}
+	}
+	if _, exists := usecase.messages[message.ID]; !exists || usecase.messages[message.ID].IsBanned {
+		t.Error("Expected the message to be untouched in read-only mode")
+	}
+
+	if rr := do("POST", "/api/v1/admin/readonly", "admin_token", `{"read_only":false}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected read-only mode off, got %d", rr.Code)
+	}
+	if rr := do("POST", "/api/v1/messages", "user_token", `{"content":"After maintenance"}`); rr.Code != http.StatusCreated {
+		t.Errorf("Expected writes to work again, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
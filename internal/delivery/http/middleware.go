@@ -1,27 +1,113 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
 )
 
-// CORS middleware
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow requests from your frontend origin
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:8000")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "3600")
-
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+// requireJSON rejects requests whose body isn't declared as JSON with 415 Unsupported Media Type
+func requireJSON(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isJSONRequest(r) {
+			writeUnsupportedMediaType(w)
 			return
 		}
-
 		next.ServeHTTP(w, r)
+	}
+}
+
+// isJSONRequest reports whether the request's Content-Type is application/json
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// errInvalidBody is returned by decodeJSONBody for malformed request bodies
+var errInvalidBody = errors.New("Invalid request body")
+
+// errInvalidEncoding is returned by decodeJSONBody for bodies that aren't
+// valid UTF-8, which encoding/json would otherwise quietly repair
+var errInvalidEncoding = errors.New("Invalid request body: not valid UTF-8")
+
+// decodeJSONBody decodes the request body into v. In strict mode unknown
+// fields are rejected with an error naming the offending field.
+func decodeJSONBody(r *http.Request, v interface{}, strict bool) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return errInvalidBody
+	}
+	if !utf8.Valid(body) {
+		return errInvalidEncoding
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("Invalid request body: unknown field %s", field)
+		}
+		return errInvalidBody
+	}
+	return nil
+}
+
+// writeUnsupportedMediaType writes a 415 response with a JSON error body
+func writeUnsupportedMediaType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Content-Type must be application/json",
+	})
+}
+
+// writeValidationErrors writes a 400 response listing every field error when
+// err is a domain.ValidationErrors, and reports whether it did
+func writeValidationErrors(w http.ResponseWriter, err error) bool {
+	var verrs domain.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": verrs,
 	})
+	return true
+}
+
+// writeMethodNotAllowed writes a 405 response with an Allow header listing the
+// permitted methods. OPTIONS is always added, since every route answers
+// preflight requests.
+func writeMethodNotAllowed(w http.ResponseWriter, msg string, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(append(allowed[:len(allowed):len(allowed)], http.MethodOptions), ", "))
+	http.Error(w, msg, http.StatusMethodNotAllowed)
+}
+
+// timeoutMiddleware cancels the request context after timeout so that stuck
+// database calls are aborted instead of hanging the handler. A non-positive
+// timeout leaves the context untouched.
+func timeoutMiddleware(timeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	if timeout <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
 }
 
 // ... existing code ...
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// originAllowed reports whether origin is in allowed, where "*" allows any origin
+func originAllowed(origin string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setAllowOrigin sets Access-Control-Allow-Origin to the request's origin when
+// it is allowed. Credentials are allowed, so the origin is echoed instead of "*".
+func setAllowOrigin(w http.ResponseWriter, r *http.Request, allowed []string) {
+	w.Header().Add("Vary", "Origin")
+	if origin := r.Header.Get("Origin"); origin != "" && originAllowed(origin, allowed) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+}
+
+// checkOrigin decides whether a WebSocket handshake may be upgraded. Requests
+// without an Origin come from non-browser clients; browsers may connect from
+// this host or an allowed origin.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return originAllowed(origin, h.cfg.AllowedOrigins)
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// PagedResponse is the envelope every list endpoint returns. NextCursor is
+// set while more results follow and can be passed back as ?cursor= to fetch
+// the next page; it is the next offset, but clients should treat it as opaque.
+type PagedResponse[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	Limit      int64  `json:"limit"`
+	Offset     int64  `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Warning    string `json:"warning,omitempty"`
+}
+
+// newPagedResponse wraps one page of items, never encoding them as null
+func newPagedResponse[T any](items []T, total, limit, offset int64) PagedResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	page := PagedResponse[T]{Items: items, Total: total, Limit: limit, Offset: offset}
+	if next := offset + int64(len(items)); len(items) > 0 && next < total {
+		page.NextCursor = strconv.FormatInt(next, 10)
+	}
+	return page
+}
+
+// setPaginationLinks adds an RFC 5988 Link header with first, prev and next
+// pages of a list, so clients can page without reading the body. The links
+// keep the request's other query parameters and replace any cursor with an
+// explicit offset. There is no next link past maxOffset, since the offset
+// would be capped and the same page returned again.
+func setPaginationLinks(w http.ResponseWriter, r *http.Request, total, limit, offset, maxOffset int64) {
+	link := func(offset int64, rel string) string {
+		query := r.URL.Query()
+		query.Del("cursor")
+		query.Set("limit", strconv.FormatInt(limit, 10))
+		query.Set("offset", strconv.FormatInt(offset, 10))
+		return fmt.Sprintf("<%s?%s>; rel=%q", r.URL.Path, query.Encode(), rel)
+	}
+
+	links := []string{link(0, "first")}
+	if offset > 0 {
+		links = append(links, link(max(offset-limit, 0), "prev"))
+	}
+	if next := offset + limit; next < total && next <= maxOffset {
+		links = append(links, link(next, "next"))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// parsePagination reads limit and offset from the query string. A missing or
+// zero limit falls back to defaultLimit, values above the caps are clamped,
+// and malformed or negative values are rejected. A cursor from a previous
+// page's next_cursor can be given instead of offset.
+func parsePagination(r *http.Request, defaultLimit, maxLimit, maxOffset int64) (int64, int64, error) {
+	limit := defaultLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || l < 0 {
+			return 0, 0, errors.New("invalid limit parameter")
+		}
+		if l > 0 {
+			limit = l
+		}
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	offset := int64(0)
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		o, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || o < 0 {
+			return 0, 0, errors.New("invalid offset parameter")
+		}
+		offset = o
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		o, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || o < 0 {
+			return 0, 0, errors.New("invalid cursor parameter")
+		}
+		offset = o
+	}
+	if offset > maxOffset {
+		offset = maxOffset
+	}
+
+	return limit, offset, nil
+}
+
+// parseDateRange reads the RFC3339 from and to query parameters. Either may be
+// omitted, leaving that end of the range open as a zero time; ok reports
+// whether either was given. A range that ends before it starts is rejected.
+func parseDateRange(r *http.Request) (from, to time.Time, ok bool, err error) {
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := r.URL.Query().Get(p.name)
+		if value == "" {
+			continue
+		}
+		if *p.dst, err = time.Parse(time.RFC3339, value); err != nil {
+			return time.Time{}, time.Time{}, false, fmt.Errorf("invalid %s parameter: must be an RFC3339 timestamp", p.name)
+		}
+		ok = true
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return time.Time{}, time.Time{}, false, domain.ErrInvalidDateRange
+	}
+	return from, to, ok, nil
+}
+
+// deepPaginationWarning returns a warning for list responses whose total runs
+// past the deepest offset clients may request, or "" when every result is
+// reachable. Deep offsets make the database scan and discard every skipped row.
+func deepPaginationWarning(total, limit, maxOffset int64) string {
+	if total <= maxOffset+limit {
+		return ""
+	}
+	return fmt.Sprintf("only the first %d of %d results are reachable: offsets above %d are capped, narrow the query instead of paging deeper",
+		maxOffset+limit, total, maxOffset)
+}
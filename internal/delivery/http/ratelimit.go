@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimiter caps how many requests each key may make per window. Message
+// and comment creation use separate limiters so that one kind of traffic
+// never eats into the other's budget.
+type RateLimiter interface {
+	// Allow records a request for key and reports whether it is within the
+	// limit. When it isn't, the returned duration is how long until the key
+	// may retry.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+// SetRateLimiters replaces the in-memory limiters for message creation,
+// comment creation and previews, e.g. with ones shared across instances
+func (h *Handler) SetRateLimiters(messages, comments, previews RateLimiter) {
+	h.messageLimiter = messages
+	h.commentLimiter = comments
+	h.previewLimiter = previews
+}
+
+// rateLimit rejects requests over limiter's budget with 429 Too Many Requests
+// and a Retry-After header. Authenticated requests are keyed by user, others
+// by client IP, so it must run after authMiddleware to see the user. If the
+// limiter fails the request is let through, so an outage of a shared store
+// doesn't take writes down with it.
+func (h *Handler) rateLimit(limiter RateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, retryAfter, err := limiter.Allow(r.Context(), h.rateLimitKey(r))
+		if err != nil {
+			log.Printf("Rate limiter failed, allowing request: %v", err)
+		} else if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rateLimitKey identifies the caller: the authenticated user or the client IP
+func (h *Handler) rateLimitKey(r *http.Request) string {
+	if user, ok := getUserFromContext(r); ok {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+	return "ip:" + clientIP(r, h.trustedProxies)
+}
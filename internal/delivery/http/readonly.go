@@ -0,0 +1,63 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// readOnlyExempt are the non-GET routes that still work in read-only mode:
+// the switch itself, so admins can turn it back off, and previews, which
+// don't store anything
+var readOnlyExempt = map[string]bool{
+	"/api/v1/admin/readonly": true,
+	"/api/v1/preview":        true,
+}
+
+// readOnlyMiddleware rejects writes with 503 while the use case is read-only,
+// before they reach a handler. Reads (GET, HEAD and OPTIONS) always go through.
+func readOnlyMiddleware(useCase domain.MessageUseCase, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if useCase.IsReadOnly() && !readOnlyExempt[r.URL.Path] {
+				http.Error(w, "Service is in read-only mode for maintenance", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// handleReadOnly handles /api/v1/admin/readonly: GET reports whether the
+// service is in read-only mode and POST {"read_only": true} switches it
+// on or off (admin only)
+func (h *Handler) handleReadOnly(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.authAdminMiddleware(h.writeReadOnly)(w, r)
+	case http.MethodPost:
+		h.authAdminMiddleware(requireJSON(func(w http.ResponseWriter, r *http.Request) {
+			var req ReadOnlyRequest
+			if !decodeRequest(w, r, &req, h.cfg.StrictJSON) {
+				return
+			}
+
+			h.useCase.SetReadOnly(*req.ReadOnly)
+			user, _ := getUserFromContext(r)
+			log.Printf("Read-only mode set to %t by admin %d", *req.ReadOnly, user.ID)
+			h.writeReadOnly(w, r)
+		}))(w, r)
+	default:
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet, http.MethodPost)
+	}
+}
+
+// writeReadOnly writes the current read-only state as {"read_only": ...}
+func (h *Handler) writeReadOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"read_only": h.useCase.IsReadOnly()})
+}
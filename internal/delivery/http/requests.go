@@ -0,0 +1,235 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// request is a JSON request body that can check its own fields. Validate
+// returns domain.ValidationErrors listing every invalid field, or nil.
+type request interface {
+	Validate() error
+}
+
+// decodeRequest decodes the JSON body into req and validates it. On failure
+// it writes a 400 response, listing field errors as {"errors": [...]}, and
+// returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, req request, strict bool) bool {
+	if err := decodeJSONBody(r, req, strict); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := req.Validate(); err != nil {
+		if !writeValidationErrors(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return false
+	}
+	return true
+}
+
+// requireContent records an error if content is blank. Length limits are
+// configurable, so they're left to the usecase.
+func requireContent(errs *domain.ValidationErrors, content string) {
+	if strings.TrimSpace(content) == "" {
+		errs.Add("content", "content cannot be empty")
+	}
+}
+
+// CreateMessageRequest is the body of POST /api/v1/messages
+type CreateMessageRequest struct {
+	Content           string `json:"content"`
+	ReplyToMessageID  int64  `json:"reply_to_message_id"`
+	ExpiresInSeconds  int64  `json:"expires_in_seconds"`
+	CommentTTLSeconds int64  `json:"comment_ttl_seconds"`
+}
+
+// Validate implements request
+func (req *CreateMessageRequest) Validate() error {
+	var errs domain.ValidationErrors
+	requireContent(&errs, req.Content)
+	if req.ReplyToMessageID < 0 {
+		errs.Add("reply_to_message_id", "reply_to_message_id must be positive")
+	}
+	if req.ExpiresInSeconds < 0 {
+		errs.Add("expires_in_seconds", "expires_in_seconds must be positive")
+	}
+	if req.CommentTTLSeconds < 0 {
+		errs.Add("comment_ttl_seconds", "comment_ttl_seconds must be positive")
+	}
+	return errs.Err()
+}
+
+// PreviewRequest is the body of POST /api/v1/preview
+type PreviewRequest struct {
+	Content string `json:"content"`
+}
+
+// Validate implements request
+func (req *PreviewRequest) Validate() error {
+	var errs domain.ValidationErrors
+	requireContent(&errs, req.Content)
+	return errs.Err()
+}
+
+// UpdateMessageRequest is the body of PUT /api/v1/messages/{id}
+type UpdateMessageRequest struct {
+	Content string `json:"content"`
+	Version int64  `json:"version"`
+}
+
+// Validate implements request
+func (req *UpdateMessageRequest) Validate() error {
+	var errs domain.ValidationErrors
+	requireContent(&errs, req.Content)
+	if req.Version <= 0 {
+		errs.Add("version", "version is required")
+	}
+	return errs.Err()
+}
+
+// UpdateCommentRequest is the body of PUT /api/v1/comments/{id}
+type UpdateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// Validate implements request
+func (req *UpdateCommentRequest) Validate() error {
+	var errs domain.ValidationErrors
+	requireContent(&errs, req.Content)
+	return errs.Err()
+}
+
+// CreateCommentRequest is the body of POST /api/v1/messages/{id}/comments
+type CreateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// Validate implements request
+func (req *CreateCommentRequest) Validate() error {
+	var errs domain.ValidationErrors
+	requireContent(&errs, req.Content)
+	return errs.Err()
+}
+
+// MessageIDRequest is the body of the message ban and unban endpoints
+type MessageIDRequest struct {
+	ID int64 `json:"id"`
+}
+
+// Validate implements request
+func (req *MessageIDRequest) Validate() error {
+	var errs domain.ValidationErrors
+	if req.ID <= 0 {
+		errs.Add("id", "id is required")
+	}
+	return errs.Err()
+}
+
+// MessageIDsRequest is the body of POST /api/v1/messages/unban-bulk
+type MessageIDsRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// Validate implements request
+func (req *MessageIDsRequest) Validate() error {
+	var errs domain.ValidationErrors
+	switch {
+	case len(req.IDs) == 0:
+		errs.Add("ids", "at least one id is required")
+	case len(req.IDs) > maxBulkMessageIDs:
+		errs.Add("ids", fmt.Sprintf("at most %d ids can be given at once", maxBulkMessageIDs))
+	}
+	for _, id := range req.IDs {
+		if id <= 0 {
+			errs.Add("ids", "ids must be positive")
+			break
+		}
+	}
+	return errs.Err()
+}
+
+// ReadOnlyRequest is the body of POST /api/v1/admin/readonly
+type ReadOnlyRequest struct {
+	ReadOnly *bool `json:"read_only"`
+}
+
+// Validate implements request
+func (req *ReadOnlyRequest) Validate() error {
+	var errs domain.ValidationErrors
+	if req.ReadOnly == nil {
+		errs.Add("read_only", "read_only is required")
+	}
+	return errs.Err()
+}
+
+// MarkReadRequest is the body of POST /api/v1/messages/read
+type MarkReadRequest struct {
+	MessageID int64 `json:"message_id"`
+}
+
+// Validate implements request
+func (req *MarkReadRequest) Validate() error {
+	var errs domain.ValidationErrors
+	if req.MessageID <= 0 {
+		errs.Add("message_id", "message_id is required")
+	}
+	return errs.Err()
+}
+
+// RenameUserRequest is the body of POST /api/v1/admin/users/{id}/rename
+type RenameUserRequest struct {
+	Username string `json:"username"`
+}
+
+// Validate implements request
+func (req *RenameUserRequest) Validate() error {
+	var errs domain.ValidationErrors
+	if strings.TrimSpace(req.Username) == "" {
+		errs.Add("username", "username cannot be empty")
+	}
+	return errs.Err()
+}
+
+// BanMatchingRequest is the body of POST /api/v1/admin/messages/ban-matching
+type BanMatchingRequest struct {
+	Pattern string `json:"pattern"`
+}
+
+// Validate implements request. The pattern itself is checked by the usecase.
+func (req *BanMatchingRequest) Validate() error {
+	var errs domain.ValidationErrors
+	if strings.TrimSpace(req.Pattern) == "" {
+		errs.Add("pattern", "pattern cannot be empty")
+	}
+	return errs.Err()
+}
+
+// ImportCommentsRequest is the body of POST /api/v1/admin/comments/bulk
+type ImportCommentsRequest struct {
+	Comments []ImportedComment `json:"comments"`
+}
+
+// ImportedComment is a single comment in an ImportCommentsRequest
+type ImportedComment struct {
+	MessageID int64  `json:"message_id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Content   string `json:"content"`
+}
+
+// Validate implements request. The comments themselves are checked by the
+// usecase, which reports which one was invalid.
+func (req *ImportCommentsRequest) Validate() error {
+	var errs domain.ValidationErrors
+	switch {
+	case len(req.Comments) == 0:
+		errs.Add("comments", "at least one comment is required")
+	case len(req.Comments) > maxBulkComments:
+		errs.Add("comments", fmt.Sprintf("at most %d comments can be imported at once", maxBulkComments))
+	}
+	return errs.Err()
+}
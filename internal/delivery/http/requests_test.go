@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+func TestRequests_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    request
+		fields []string
+	}{
+		{name: "Valid message", req: &CreateMessageRequest{Content: "Hello", ReplyToMessageID: 1, ExpiresInSeconds: 60}},
+		{
+			name:   "Message with every field invalid",
+			req:    &CreateMessageRequest{Content: "  ", ReplyToMessageID: -1, ExpiresInSeconds: -1, CommentTTLSeconds: -1},
+			fields: []string{"content", "reply_to_message_id", "expires_in_seconds", "comment_ttl_seconds"},
+		},
+		{name: "Valid update", req: &UpdateMessageRequest{Content: "Edited", Version: 1}},
+		{name: "Update without version", req: &UpdateMessageRequest{Content: ""}, fields: []string{"content", "version"}},
+		{name: "Valid comment", req: &CreateCommentRequest{Content: "Nice"}},
+		{name: "Empty comment", req: &CreateCommentRequest{}, fields: []string{"content"}},
+		{name: "Valid message ID", req: &MessageIDRequest{ID: 3}},
+		{name: "Missing message ID", req: &MessageIDRequest{}, fields: []string{"id"}},
+		{name: "Valid mark read", req: &MarkReadRequest{MessageID: 3}},
+		{name: "Negative mark read", req: &MarkReadRequest{MessageID: -3}, fields: []string{"message_id"}},
+		{name: "Valid rename", req: &RenameUserRequest{Username: "renamed"}},
+		{name: "Blank rename", req: &RenameUserRequest{Username: " "}, fields: []string{"username"}},
+		{name: "Valid import", req: &ImportCommentsRequest{Comments: []ImportedComment{{MessageID: 1}}}},
+		{name: "Empty import", req: &ImportCommentsRequest{}, fields: []string{"comments"}},
+		{
+			name:   "Oversized import",
+			req:    &ImportCommentsRequest{Comments: make([]ImportedComment, maxBulkComments+1)},
+			fields: []string{"comments"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.fields == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var verrs domain.ValidationErrors
+			if !errors.As(err, &verrs) {
+				t.Fatalf("Expected ValidationErrors, got %v", err)
+			}
+			var fields []string
+			for _, e := range verrs {
+				fields = append(fields, e.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("Expected errors on %v, got %v", tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	decode := func(body string) (*httptest.ResponseRecorder, bool) {
+		rr := httptest.NewRecorder()
+		var req CreateCommentRequest
+		ok := decodeRequest(rr, httptest.NewRequest("POST", "/", strings.NewReader(body)), &req, true)
+		return rr, ok
+	}
+
+	if rr, ok := decode(`{"content":"Hello"}`); !ok || rr.Code != http.StatusOK {
+		t.Errorf("Expected a valid body to decode, got %d", rr.Code)
+	}
+
+	rr, ok := decode(`{"content":""}`)
+	if ok || rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"field":"content"`) {
+		t.Errorf("Expected a 400 listing the content field, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr, ok := decode(`{"content":`); ok || rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a 400 for a malformed body, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// stringIDsMiddleware rewrites integer IDs in JSON responses as strings, so
+// JavaScript clients don't lose precision on IDs above 2^53. With always set
+// every response is rewritten; otherwise only responses to clients that send
+// Accept: application/json; ids=string. It buffers the whole body, so it
+// must not wrap streaming endpoints.
+func stringIDsMiddleware(always bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !always {
+			w.Header().Add("Vary", "Accept")
+			if !wantsStringIDs(r) {
+				next(w, r)
+				return
+			}
+		}
+
+		sw := &stringIDsResponseWriter{ResponseWriter: w}
+		next(sw, r)
+		sw.Close()
+	}
+}
+
+// wantsStringIDs reports whether the client asked for string IDs with an
+// ids=string parameter on application/json in its Accept header
+func wantsStringIDs(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == "application/json" && params["ids"] == "string" {
+			return true
+		}
+	}
+	return false
+}
+
+// stringIDsResponseWriter holds back the response so that a JSON body can be
+// rewritten before it is sent
+type stringIDsResponseWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+// WriteHeader records the status code until the body is rewritten
+func (s *stringIDsResponseWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+}
+
+// Write buffers the body
+func (s *stringIDsResponseWriter) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+// Close rewrites a JSON body and sends the response. Bodies that aren't JSON,
+// or don't parse as it, are sent unchanged.
+func (s *stringIDsResponseWriter) Close() error {
+	body := s.buf.Bytes()
+	if mediaType, _, err := mime.ParseMediaType(s.Header().Get("Content-Type")); err == nil && mediaType == "application/json" {
+		if rewritten, err := stringifyIDs(body); err == nil {
+			body = rewritten
+			s.Header().Del("Content-Length")
+		}
+	}
+
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	s.ResponseWriter.WriteHeader(s.status)
+	_, err := s.ResponseWriter.Write(body)
+	return err
+}
+
+// stringifyIDs re-encodes a JSON document with every integer under an ID key
+// turned into a string
+func stringifyIDs(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(stringifyIDValues(v, false)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// stringifyIDValues turns integers in v into strings when they are IDs,
+// which isID reports for v itself and isIDKey for the fields of objects
+func stringifyIDValues(v interface{}, isID bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = stringifyIDValues(child, isIDKey(key))
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = stringifyIDValues(child, isID)
+		}
+	case json.Number:
+		if _, err := v.Int64(); isID && err == nil {
+			return v.String()
+		}
+	}
+	return v
+}
+
+// isIDKey reports whether a JSON field holds IDs, such as "id", "message_id" or "ids"
+func isIDKey(key string) bool {
+	return key == "id" || key == "ids" || strings.HasSuffix(key, "_id") || strings.HasSuffix(key, "_ids")
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/atmega-p471/forum-service/internal/config"
+	"github.com/atmega-p471/forum-service/internal/delivery/ws"
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+func TestHandler_StringIDs(t *testing.T) {
+	mux, usecase, _ := setupTestHandler(t)
+
+	// Past 2^53, where float64 can no longer represent every integer
+	const largeID int64 = 9007199254740993
+	usecase.messages[largeID] = &domain.Message{ID: largeID, UserID: largeID, Username: "testuser", Content: "Big", Version: 3}
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/messages/9007199254740993", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("application/json; ids=string")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var message struct {
+		ID      string `json:"id"`
+		UserID  string `json:"user_id"`
+		Version int64  `json:"version"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &message); err != nil {
+		t.Fatalf("Expected IDs as strings, got %s: %v", rr.Body.String(), err)
+	}
+	if message.ID != "9007199254740993" || message.UserID != "9007199254740993" || message.Version != 3 {
+		t.Errorf("Expected the large ID to round-trip as a string and other numbers untouched, got %+v", message)
+	}
+
+	if rr := get(""); !strings.Contains(rr.Body.String(), `"id":9007199254740993`) {
+		t.Errorf("Expected numeric IDs without the Accept parameter, got %s", rr.Body.String())
+	}
+}
+
+func TestHandler_StringIDsConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.JSONStringIDs = true
+	usecase := NewMockMessageUseCase()
+	mux := http.NewServeMux()
+	NewHandler(usecase, ws.NewHub(), NewMockAuthClient(), cfg).RegisterRoutes(mux)
+	usecase.messages[7] = &domain.Message{ID: 7, Username: "testuser", Content: "Hello"}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/messages?ids=7", nil))
+	if !strings.Contains(rr.Body.String(), `"id":"7"`) {
+		t.Errorf("Expected string IDs for every client when configured, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/url"
+	"path"
+
+	"github.com/atmega-p471/forum-service/internal/config"
+	"github.com/swaggo/swag"
+)
+
+// apiBasePath is the path prefix of the versioned REST API
+const apiBasePath = "/api/v1"
+
+// ConfigureSwagger points the generated API docs at the address clients
+// actually use: PUBLIC_URL when set (e.g. behind a reverse proxy),
+// otherwise the HTTP listen address.
+func ConfigureSwagger(spec *swag.Spec, cfg *config.Config) {
+	spec.Host = cfg.HTTPAddr
+	spec.BasePath = apiBasePath
+
+	if cfg.PublicURL == "" {
+		return
+	}
+
+	u, err := url.Parse(cfg.PublicURL)
+	if err != nil || u.Host == "" {
+		return
+	}
+
+	spec.Host = u.Host
+	spec.BasePath = path.Join("/", u.Path, apiBasePath)
+	if u.Scheme != "" {
+		spec.Schemes = []string{u.Scheme}
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/atmega-p471/forum-service/internal/config"
+	"github.com/swaggo/swag"
+)
+
+func TestConfigureSwagger_DocReflectsHost(t *testing.T) {
+	tests := []struct {
+		name             string
+		cfg              *config.Config
+		expectedHost     string
+		expectedBasePath string
+	}{
+		{
+			name:             "Listen address",
+			cfg:              &config.Config{HTTPAddr: "0.0.0.0:9000"},
+			expectedHost:     "0.0.0.0:9000",
+			expectedBasePath: "/api/v1",
+		},
+		{
+			name:             "Public URL behind a proxy",
+			cfg:              &config.Config{HTTPAddr: "localhost:8082", PublicURL: "https://forum.example.com/forum"},
+			expectedHost:     "forum.example.com",
+			expectedBasePath: "/forum/api/v1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Stand-in for the spec generated by swag init. It isn't registered
+			// with swag, whose registry is global and rejects a second run.
+			spec := &swag.Spec{
+				InfoInstanceName: "forum-test",
+				SwaggerTemplate:  `{"swagger": "2.0", "host": "{{.Host}}", "basePath": "{{.BasePath}}"}`,
+			}
+
+			ConfigureSwagger(spec, tt.cfg)
+
+			var doc struct {
+				Host     string `json:"host"`
+				BasePath string `json:"basePath"`
+			}
+			if err := json.Unmarshal([]byte(spec.ReadDoc()), &doc); err != nil {
+				t.Fatalf("Failed to parse doc: %v", err)
+			}
+			if doc.Host != tt.expectedHost {
+				t.Errorf("Expected host %s, got %s", tt.expectedHost, doc.Host)
+			}
+			if doc.BasePath != tt.expectedBasePath {
+				t.Errorf("Expected basePath %s, got %s", tt.expectedBasePath, doc.BasePath)
+			}
+		})
+	}
+}
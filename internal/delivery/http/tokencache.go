@@ -0,0 +1,92 @@
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// tokenCache remembers the user each recently validated token belongs to, so
+// authenticated requests don't each cost a call to the auth service. Entries
+// live for at most ttl, which bounds how long a logout or ban the forum isn't
+// told about goes unnoticed.
+type tokenCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]tokenEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// tokenEntry is a cached user and when it must be validated again
+type tokenEntry struct {
+	user    *domain.User
+	expires time.Time
+}
+
+// newTokenCache creates a cache keeping users for ttl. A non-positive ttl
+// disables caching.
+func newTokenCache(ttl time.Duration) *tokenCache {
+	return &tokenCache{
+		ttl:     ttl,
+		entries: make(map[string]tokenEntry),
+		now:     time.Now,
+	}
+}
+
+// get returns the user cached for token, if it hasn't expired
+func (c *tokenCache) get(token string) (*domain.User, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[token]
+	if !ok || !c.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.user, true
+}
+
+// put caches the user token belongs to. Banned users aren't cached, so they
+// are checked with the auth service on every request.
+func (c *tokenCache) put(token string, user *domain.User) {
+	if c.ttl <= 0 || user.IsBanned {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+	c.entries[token] = tokenEntry{user: user, expires: now.Add(c.ttl)}
+}
+
+// forgetUser drops every token cached for userID
+func (c *tokenCache) forgetUser(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for token, entry := range c.entries {
+		if entry.user.ID == userID {
+			delete(c.entries, token)
+		}
+	}
+}
+
+// sweep drops expired entries, at most once per ttl
+func (c *tokenCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	for token, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, token)
+		}
+	}
+	c.lastSweep = now
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// parseTruncate reads the optional truncate parameter of message lists: how
+// many characters of each message's content to return. Zero or missing
+// returns the content in full.
+func parseTruncate(r *http.Request) (int, error) {
+	truncateStr := r.URL.Query().Get("truncate")
+	if truncateStr == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(truncateStr)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid truncate parameter")
+	}
+	return n, nil
+}
+
+// truncateMessages returns copies of messages with their content length set
+// and their content cut to limit characters. The messages themselves may be
+// shared with a cache, so they're left alone.
+func truncateMessages(messages []*domain.Message, limit int) []*domain.Message {
+	truncated := make([]*domain.Message, len(messages))
+	for i, message := range messages {
+		truncated[i] = message.Truncate(limit)
+	}
+	return truncated
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// uploadsPath is the URL prefix uploaded files are served from
+const uploadsPath = "/api/v1/uploads/"
+
+// uploadExtensions maps the detected MIME type to the stored file's extension
+var uploadExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+// handleUpload handles POST /api/v1/uploads, storing a single file from the
+// multipart field "file" and returning the URL it can be attached by
+func (h *Handler) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodPost)
+		return
+	}
+	h.authMiddleware(h.storeUpload)(w, r)
+}
+
+// storeUpload validates the uploaded file's type against the allowlist and
+// writes it to the upload directory under a random name
+func (h *Handler) storeUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Multipart field 'file' is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Detect the type from the content; the client-supplied header can't be trusted
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	if !h.uploadAllowed(contentType) {
+		http.Error(w, "File type "+contentType+" is not allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	name, err := randomUploadName(uploadExtensions[contentType])
+	if err != nil {
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if err := os.MkdirAll(h.cfg.UploadDir, 0755); err != nil {
+		log.Printf("Error creating upload directory: %v", err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	path := filepath.Join(h.cfg.UploadDir, name)
+	dst, err := os.Create(path)
+	if err != nil {
+		log.Printf("Error creating upload %s: %v", path, err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if _, err := io.Copy(dst, io.MultiReader(bytes.NewReader(head[:n]), file)); err != nil {
+		dst.Close()
+		os.Remove(path)
+		log.Printf("Error writing upload %s: %v", path, err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path)
+		log.Printf("Error writing upload %s: %v", path, err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"url":          uploadsPath + name,
+		"content_type": contentType,
+	})
+}
+
+// uploadAllowed reports whether contentType is in the configured allowlist
+func (h *Handler) uploadAllowed(contentType string) bool {
+	for _, allowed := range h.cfg.UploadAllowedTypes {
+		if strings.EqualFold(allowed, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveUpload handles GET /api/v1/uploads/{name}
+func (h *Handler) serveUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Method not allowed", http.MethodGet)
+		return
+	}
+
+	// Stored names are flat, so anything with a path separator can't exist
+	name := strings.TrimPrefix(r.URL.Path, uploadsPath)
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, filepath.Join(h.cfg.UploadDir, name))
+}
+
+// randomUploadName generates an unguessable file name with the given extension
+func randomUploadName(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + ext, nil
+}
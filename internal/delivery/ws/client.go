@@ -26,14 +26,34 @@ var (
 	space   = []byte{' '}
 )
 
-// ServeWs handles websocket requests from the peer.
-func ServeWs(hub *Hub, w interface{}, r interface{}, c *websocket.Conn) {
-	client := &Client{
-		hub:  hub,
-		conn: c,
-		send: make(chan []byte, 256),
+// ServeWs handles websocket requests from the peer. A non-empty history frame
+// is written to the connection before the client joins the broadcast set.
+// The connection slot ip acquired with hub.AcquireConn is released once the
+// connection ends.
+func ServeWs(hub *Hub, c *websocket.Conn, ip string, history []byte) {
+	if len(history) > 0 {
+		c.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.WriteMessage(websocket.TextMessage, history); err != nil {
+			c.Close()
+			hub.ReleaseConn(ip)
+			return
+		}
 	}
-	client.hub.register <- client
+	serveClient(&Client{hub: hub, conn: c, send: make(chan []byte, 256), ip: ip})
+}
+
+// ResumeWs is ServeWs for a client reconnecting after receiving broadcast
+// since: it's first sent the message and comment broadcasts it missed, or a
+// resync frame if they're no longer buffered, and then the live feed.
+func ResumeWs(hub *Hub, c *websocket.Conn, ip string, since int64) {
+	// Make room for a full replay on top of the usual backlog
+	send := make(chan []byte, 256+hub.replay.capacity())
+	serveClient(&Client{hub: hub, conn: c, send: send, ip: ip, resume: true, since: since})
+}
+
+// serveClient registers client with its hub and starts pumping its connection
+func serveClient(client *Client) {
+	client.hub.Register(client)
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
@@ -44,8 +64,9 @@ func ServeWs(hub *Hub, w interface{}, r interface{}, c *websocket.Conn) {
 // readPump pumps messages from the websocket connection to the hub.
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.Unregister(c)
 		c.conn.Close()
+		c.hub.ReleaseConn(c.ip)
 	}()
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -61,9 +82,39 @@ func (c *Client) readPump() {
 			}
 			break
 		}
+		if frame, ok := parsePingFrame(message); ok {
+			c.hub.reply(c, frame.pong())
+			continue
+		}
+		if frame, ok := parseSeenFrame(message); ok {
+			c.markSeen(frame)
+			continue
+		}
+		if frame, ok := parseSubscriptionFrame(message); ok {
+			c.subs.apply(frame)
+			if frame.Subscribe != nil && frame.Subscribe.Presence {
+				// Start the subscriber off with the current count
+				c.hub.greetPresence(c)
+			}
+			continue
+		}
 		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		c.hub.broadcast <- message
+		c.hub.publish(event{data: message})
+	}
+}
+
+// markSeen passes a read receipt on to the hub, once per comment. Receipts
+// only count for threads the client is subscribed to.
+func (c *Client) markSeen(frame seenFrame) {
+	messageID := c.hub.receipts.thread(frame)
+	if c.seen[frame.CommentID] || !c.subs.follows(messageID) {
+		return
+	}
+	if c.seen == nil || len(c.seen) >= maxTrackedComments {
+		c.seen = make(map[int64]bool)
 	}
+	c.seen[frame.CommentID] = true
+	c.hub.markSeen(c, frame.CommentID, messageID)
 }
 
 // writePump pumps messages from the hub to the websocket connection.
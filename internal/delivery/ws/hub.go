@@ -2,64 +2,319 @@ package ws
 
 import (
 	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
 
 	"github.com/atmega-p471/forum-service/internal/domain"
 	"github.com/gorilla/websocket"
 )
 
+// Subscriber receives broadcasts from the hub, independent of transport
+type Subscriber interface {
+	// Send queues a message without blocking and reports whether it was accepted
+	Send(message []byte) bool
+	// Close is called by the hub once the subscriber has been removed
+	Close()
+}
+
 // Client represents a websocket client
 type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+	subs subscriptions
+	// ip is the connection slot the client holds in the hub
+	ip string
+	// resume is set for a client reconnecting after broadcast since, which
+	// the hub replays what it missed to as it registers
+	resume bool
+	since  int64
+	// seen holds the comments this client has sent read receipts for; only
+	// readPump touches it
+	seen map[int64]bool
+}
+
+// Send implements Subscriber
+func (c *Client) Send(message []byte) bool {
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close implements Subscriber
+func (c *Client) Close() {
+	close(c.send)
+}
+
+// wants reports whether the client's subscriptions include e
+func (c *Client) wants(e event) bool {
+	return c.subs.wants(e)
+}
+
+// channelSubscriber is a Subscriber backed by a buffered channel
+type channelSubscriber struct {
+	ch chan []byte
+}
+
+// Send implements Subscriber
+func (s *channelSubscriber) Send(message []byte) bool {
+	select {
+	case s.ch <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close implements Subscriber
+func (s *channelSubscriber) Close() {
+	close(s.ch)
+}
+
+// wants keeps channel subscribers on the message feed. Frames relayed from
+// WebSocket clients are left out, so a client can't pass one off as a message.
+func (s *channelSubscriber) wants(e event) bool {
+	return e.kind == eventMessage
+}
+
+// filteredSubscriber is a Subscriber that only receives some events
+type filteredSubscriber interface {
+	wants(e event) bool
+}
+
+// eventKind is what a broadcast is about
+type eventKind int
+
+const (
+	// eventOther is a broadcast that isn't about a single message, such as a client frame
+	eventOther eventKind = iota
+	// eventMessage is a new or changed message
+	eventMessage
+	// eventComment is a new comment
+	eventComment
+	// eventSeen is an updated read receipt count for a comment
+	eventSeen
+	// eventPresence is a change in the number of connected clients
+	eventPresence
+)
+
+// threadOnly reports whether events of kind k only go to thread subscribers
+func (k eventKind) threadOnly() bool {
+	return k == eventComment || k == eventSeen
+}
+
+// event is a broadcast payload along with what it's about, for filtering
+type event struct {
+	data      []byte
+	kind      eventKind
+	messageID int64
+	// from is the subscriber that caused the event, which doesn't receive it
+	from Subscriber
+	// to is the only subscriber that receives the event, if set
+	to Subscriber
+	// seq is the event's sequence number, if its kind is sequenced
+	seq int64
 }
 
-// Hub maintains the set of active clients and broadcasts messages to the clients
+// Hub maintains the set of active subscribers and broadcasts messages to them
 type Hub struct {
-	// Registered clients
-	clients map[*Client]bool
+	// Registered subscribers
+	clients map[Subscriber]bool
 
 	// Inbound messages from the clients
-	broadcast chan []byte
+	broadcast chan event
+
+	// Register requests from the subscribers
+	register chan Subscriber
+
+	// Unregister requests from subscribers
+	unregister chan Subscriber
+
+	// done is closed by Stop to shut down Run
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// connsMu guards conns, the number of open WebSocket connections per
+	// client IP, which is capped at maxConnsPerIP when that is positive
+	connsMu       sync.Mutex
+	conns         map[string]int
+	maxConnsPerIP int
+
+	// receipts holds the seen counts of comments
+	receipts receipts
 
-	// Register requests from the clients
-	register chan *Client
+	// online is the number of registered WebSocket clients
+	online atomic.Int64
 
-	// Unregister requests from clients
-	unregister chan *Client
+	// seq numbers message and comment broadcasts, and replay keeps the
+	// latest of them for clients that reconnect
+	seq    atomic.Int64
+	replay replayBuffer
 }
 
+// DefaultBroadcastBufferSize is the broadcast queue size used by NewHub
+const DefaultBroadcastBufferSize = 256
+
 // NewHub creates a new hub
 func NewHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+	return NewHubWithBuffer(DefaultBroadcastBufferSize)
+}
+
+// NewHubWithBuffer creates a new hub whose broadcast queue holds up to size
+// pending messages before further broadcasts are dropped
+func NewHubWithBuffer(size int) *Hub {
+	h := &Hub{
+		broadcast:  make(chan event, size),
+		register:   make(chan Subscriber),
+		unregister: make(chan Subscriber),
+		clients:    make(map[Subscriber]bool),
+		done:       make(chan struct{}),
+		conns:      make(map[string]int),
+	}
+	h.replay.setSize(DefaultReplaySize)
+	return h
+}
+
+// SetMaxConnsPerIP caps how many WebSocket connections one client IP may hold
+// open at once. Zero or less removes the cap.
+func (h *Hub) SetMaxConnsPerIP(max int) {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+	h.maxConnsPerIP = max
+}
+
+// AcquireConn reserves a connection slot for ip and reports whether it was
+// within the limit. Every successful call must be matched by ReleaseConn,
+// which ServeWs does when the connection ends.
+func (h *Hub) AcquireConn(ip string) bool {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+	if h.maxConnsPerIP > 0 && h.conns[ip] >= h.maxConnsPerIP {
+		return false
+	}
+	h.conns[ip]++
+	return true
+}
+
+// ReleaseConn frees a connection slot reserved by AcquireConn
+func (h *Hub) ReleaseConn(ip string) {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+	if h.conns[ip] <= 1 {
+		delete(h.conns, ip)
+		return
 	}
+	h.conns[ip]--
 }
 
-// Run starts the hub
+// Run starts the hub. It returns once Stop is called, after closing every
+// registered subscriber.
 func (h *Hub) Run() {
+	var announced int64
 	for {
 		select {
+		case <-h.done:
+			for client := range h.clients {
+				h.remove(client)
+			}
+			return
 		case client := <-h.register:
 			h.clients[client] = true
+			if c, ok := client.(*Client); ok {
+				h.online.Add(1)
+				if c.resume && !h.replayTo(c) {
+					h.remove(c)
+				}
+			}
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
-		case message := <-h.broadcast:
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+				h.remove(client)
 			}
+		case e := <-h.broadcast:
+			h.deliver(h.sequence(e))
 		}
+		announced = h.announcePresence(announced)
+	}
+}
+
+// deliver sends e to every subscriber that wants it, dropping any that can't
+// keep up. Only Run calls it.
+func (h *Hub) deliver(e event) {
+	if e.to != nil {
+		if e.kind == eventPresence {
+			e.data = newPresenceFrame(h.online.Load())
+		}
+		// The subscriber may have been removed since the reply was queued
+		if h.clients[e.to] && !e.to.Send(e.data) {
+			h.remove(e.to)
+		}
+		return
+	}
+	for client := range h.clients {
+		if client == e.from {
+			continue
+		}
+		if f, ok := client.(filteredSubscriber); ok && !f.wants(e) {
+			continue
+		}
+		if !client.Send(e.data) {
+			h.remove(client)
+		}
+	}
+}
+
+// remove drops a subscriber from the broadcast set and closes it
+func (h *Hub) remove(s Subscriber) {
+	delete(h.clients, s)
+	s.Close()
+	if _, ok := s.(*Client); ok {
+		h.online.Add(-1)
+	}
+}
+
+// Stop shuts down Run, disconnecting all subscribers. It is safe to call more than once.
+func (h *Hub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.done)
+	})
+}
+
+// Register adds a subscriber to the broadcast set. Once the hub is stopped
+// the subscriber is closed immediately instead.
+func (h *Hub) Register(s Subscriber) {
+	select {
+	case h.register <- s:
+	case <-h.done:
+		s.Close()
+	}
+}
+
+// Unregister removes a subscriber from the broadcast set and closes it
+func (h *Hub) Unregister(s Subscriber) {
+	select {
+	case h.unregister <- s:
+	case <-h.done:
+		// Run already closed every subscriber on its way out
+	}
+}
+
+// Subscribe registers a consumer outside the WebSocket path and returns a
+// channel receiving every broadcast message as JSON, plus a function that
+// unsubscribes it.
+// The channel is closed on unsubscribe or if the consumer falls too far behind.
+func (h *Hub) Subscribe() (<-chan []byte, func()) {
+	s := &channelSubscriber{ch: make(chan []byte, 256)}
+	h.Register(s)
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			h.Unregister(s)
+		})
 	}
 }
 
@@ -69,14 +324,43 @@ func (h *Hub) BroadcastMessage(message *domain.Message) {
 	if err != nil {
 		return
 	}
-	h.broadcast <- data
+	h.publish(event{data: data, kind: eventMessage, messageID: message.ID})
 }
 
-// BroadcastMessages broadcasts multiple messages to all connected clients
+// BroadcastMessages broadcasts multiple messages to all connected clients,
+// each as its own broadcast with its own sequence number
 func (h *Hub) BroadcastMessages(messages []*domain.Message) {
-	data, err := json.Marshal(messages)
+	for _, message := range messages {
+		h.BroadcastMessage(message)
+	}
+}
+
+// BroadcastComment sends a new comment as {"type": "comment", "data": {...}}
+// to WebSocket clients subscribed to its message's thread
+func (h *Hub) BroadcastComment(comment *domain.Comment) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "comment",
+		"data": comment,
+	})
 	if err != nil {
 		return
 	}
-	h.broadcast <- data
+	h.receipts.track(comment.ID, comment.MessageID)
+	h.publish(event{data: data, kind: eventComment, messageID: comment.MessageID})
+}
+
+// reply queues data for to alone. Replies go through Run like broadcasts,
+// so only the hub goroutine ever sends to a client or closes it.
+func (h *Hub) reply(to Subscriber, data []byte) {
+	h.publish(event{data: data, to: to})
+}
+
+// publish queues e for broadcast without blocking the caller. If the
+// queue is full the broadcast is dropped so request handling never stalls.
+func (h *Hub) publish(e event) {
+	select {
+	case h.broadcast <- e:
+	default:
+		log.Printf("Hub broadcast queue full, dropping broadcast of %d bytes", len(e.data))
+	}
 }
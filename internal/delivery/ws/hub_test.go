@@ -0,0 +1,623 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"github.com/gorilla/websocket"
+)
+
+// fakeSubscriber is a non-websocket Subscriber used for testing
+type fakeSubscriber struct {
+	received chan []byte
+	closed   chan struct{}
+}
+
+func newFakeSubscriber() *fakeSubscriber {
+	return &fakeSubscriber{
+		received: make(chan []byte, 16),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (f *fakeSubscriber) Send(message []byte) bool {
+	select {
+	case f.received <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *fakeSubscriber) Close() {
+	close(f.closed)
+}
+
+func TestHub_BroadcastToSubscriber(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	subscriber := newFakeSubscriber()
+	hub.Register(subscriber)
+
+	hub.BroadcastMessage(&domain.Message{ID: 42, Username: "testuser", Content: "Hello"})
+
+	select {
+	case data := <-subscriber.received:
+		var message domain.Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("Failed to decode broadcast: %v", err)
+		}
+		if message.ID != 42 || message.Content != "Hello" {
+			t.Errorf("Unexpected broadcast message: %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscriber did not receive broadcast")
+	}
+
+	hub.Unregister(subscriber)
+
+	select {
+	case <-subscriber.closed:
+	case <-time.After(time.Second):
+		t.Fatal("Subscriber was not closed on unregister")
+	}
+}
+
+func TestHub_Subscribe(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	updates, unsubscribe := hub.Subscribe()
+
+	hub.BroadcastMessage(&domain.Message{ID: 7, Content: "Streamed"})
+
+	select {
+	case data := <-updates:
+		if len(data) == 0 {
+			t.Error("Expected broadcast payload")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscription did not receive broadcast")
+	}
+
+	unsubscribe()
+	unsubscribe() // must be safe to call twice
+
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Error("Expected channel to be closed after unsubscribe")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Channel was not closed after unsubscribe")
+	}
+}
+
+func TestHub_SubscribeSkipsClientFrames(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	updates, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ServeWs(hub, conn, "", nil)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	// A frame shaped like a message, relayed back to the client once the hub
+	// has delivered it
+	forged := []byte(`{"id":1,"username":"admin","content":"Forged"}`)
+	if err := conn.WriteMessage(websocket.TextMessage, forged); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("Failed to read relayed frame: %v", err)
+	}
+
+	hub.BroadcastMessage(&domain.Message{ID: 9, Content: "Genuine"})
+
+	select {
+	case data := <-updates:
+		var message domain.Message
+		if err := json.Unmarshal(data, &message); err != nil || message.ID != 9 {
+			t.Errorf("Expected only the genuine message, got %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscription did not receive broadcast")
+	}
+}
+
+func TestHub_BroadcastDoesNotBlockWithoutConsumer(t *testing.T) {
+	// Run is deliberately not started, so nothing drains the queue
+	hub := NewHubWithBuffer(2)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			hub.BroadcastMessage(&domain.Message{ID: int64(i + 1), Content: "Hello"})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("BroadcastMessage blocked without a running hub")
+	}
+
+	if queued := len(hub.broadcast); queued != 2 {
+		t.Errorf("Expected 2 queued broadcasts, got %d", queued)
+	}
+}
+
+func TestClient_ReadPumpDoesNotBlockWithoutConsumer(t *testing.T) {
+	// Run is deliberately not started, so nothing drains the queue
+	hub := NewHubWithBuffer(1)
+	defer hub.Stop()
+
+	clients := make(chan *Client, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &Client{hub: hub, conn: conn, send: make(chan []byte, 16)}
+		clients <- client
+		go client.readPump()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	client := <-clients
+
+	for i := 0; i < 3; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	// A subscription after the overflow only applies if readPump kept going
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"subscribe":{"message_id":42}}`)); err != nil {
+		t.Fatalf("Failed to write subscription: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !client.subs.follows(42) {
+		if time.Now().After(deadline) {
+			t.Fatal("readPump blocked on a full broadcast queue")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if queued := len(hub.broadcast); queued != 1 {
+		t.Errorf("Expected 1 queued broadcast, got %d", queued)
+	}
+}
+
+func TestClient_RepliesAfterStop(t *testing.T) {
+	// Both frames are answered with a reply to the client alone, which is
+	// queued for Run, which has returned, rather than sent on the closed
+	// channel
+	tests := []struct {
+		name  string
+		frame string
+	}{
+		{name: "ping", frame: `{"type":"ping","ts":1}`},
+		{name: "presence subscription", frame: `{"subscribe":{"presence":true}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			go hub.Run()
+
+			clients := make(chan *Client, 1)
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				// writePump isn't started, so the connection stays open after Stop
+				client := &Client{hub: hub, conn: conn, send: make(chan []byte, 16)}
+				hub.Register(client)
+				clients <- client
+				go client.readPump()
+			}))
+			defer server.Close()
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+			client := <-clients
+
+			hub.Stop()
+			select {
+			case _, ok := <-client.send:
+				if ok {
+					t.Fatal("Expected the send channel to be closed")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Stop didn't close the client")
+			}
+
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(tt.frame)); err != nil {
+				t.Fatalf("Failed to write frame: %v", err)
+			}
+			deadline := time.Now().Add(time.Second)
+			for len(hub.broadcast) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("Expected the reply to be queued")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestHub_StopClosesSubscribers(t *testing.T) {
+	hub := NewHub()
+	stopped := make(chan struct{})
+	go func() {
+		hub.Run()
+		close(stopped)
+	}()
+
+	updates, unsubscribe := hub.Subscribe()
+
+	hub.Stop()
+	hub.Stop() // must be safe to call twice
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+	if _, ok := <-updates; ok {
+		t.Error("Expected subscriber channel to be closed on Stop")
+	}
+
+	// Late subscribers and unsubscribes must not block on a stopped hub
+	unsubscribe()
+	late, _ := hub.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("Expected subscriber registered after Stop to be closed")
+	}
+}
+
+func TestHub_ThreadSubscriptionFiltersComments(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ServeWs(hub, conn, "", nil)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	// readComments reads one frame and returns the comments in it; the client
+	// may batch several broadcasts into a frame, separated by newlines
+	readComments := func() []domain.Comment {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read frame: %v", err)
+		}
+		var comments []domain.Comment
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			var frame struct {
+				Type string         `json:"type"`
+				Data domain.Comment `json:"data"`
+			}
+			if err := json.Unmarshal(line, &frame); err != nil || frame.Type != "comment" {
+				t.Fatalf("Expected only comment events, got %s", line)
+			}
+			comments = append(comments, frame.Data)
+		}
+		return comments
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"subscribe":{"message_id":42}}`)); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// The subscription is applied asynchronously, so probe thread 42 until a
+	// comment arrives to show it took effect
+	probed := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			hub.BroadcastComment(&domain.Comment{ID: 1, MessageID: 42})
+			select {
+			case <-probed:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	readComments()
+	close(probed)
+
+	hub.BroadcastComment(&domain.Comment{ID: 100, MessageID: 7})
+	hub.BroadcastComment(&domain.Comment{ID: 200, MessageID: 42})
+	for done := false; !done; {
+		for _, comment := range readComments() {
+			if comment.MessageID != 42 {
+				t.Fatalf("Expected only comments on message 42, got comment %d on message %d", comment.ID, comment.MessageID)
+			}
+			done = done || comment.ID == 200
+		}
+	}
+}
+
+func TestHub_SeenReceiptsBroadcastToOtherViewers(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ServeWs(hub, conn, "", nil)
+	}))
+	defer server.Close()
+
+	// viewer is a client subscribed to thread 42 whose broadcasts, split out
+	// of batched frames, arrive on frames
+	type viewer struct {
+		conn   *websocket.Conn
+		frames chan []byte
+	}
+	connect := func() viewer {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		v := viewer{conn: conn, frames: make(chan []byte, 64)}
+		go func() {
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				for _, line := range bytes.Split(data, []byte("\n")) {
+					v.frames <- line
+				}
+			}
+		}()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"subscribe":{"message_id":42}}`)); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+		return v
+	}
+	// nextSeen returns the next read receipt count v receives, skipping comments
+	nextSeen := func(v viewer) seenCount {
+		for {
+			select {
+			case data := <-v.frames:
+				var frame struct {
+					Type string    `json:"type"`
+					Data seenCount `json:"data"`
+				}
+				if err := json.Unmarshal(data, &frame); err != nil {
+					t.Fatalf("Failed to decode frame %s: %v", data, err)
+				}
+				if frame.Type == "seen" {
+					return frame.Data
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Timed out waiting for a seen event")
+			}
+		}
+	}
+	// waitSubscribed broadcasts comment id on thread 42 until v receives it,
+	// since subscriptions are applied asynchronously
+	waitSubscribed := func(v viewer, id int64) {
+		for {
+			hub.BroadcastComment(&domain.Comment{ID: id, MessageID: 42})
+			select {
+			case data := <-v.frames:
+				var frame struct {
+					Data domain.Comment `json:"data"`
+				}
+				if json.Unmarshal(data, &frame) == nil && frame.Data.ID == id {
+					return
+				}
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	alice, bob := connect(), connect()
+	defer alice.conn.Close()
+	defer bob.conn.Close()
+	waitSubscribed(alice, 5)
+	waitSubscribed(bob, 5)
+
+	// Receipts sent twice by the same client count once
+	for i := 0; i < 2; i++ {
+		if err := alice.conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"seen","comment_id":5}`)); err != nil {
+			t.Fatalf("Failed to send seen: %v", err)
+		}
+	}
+	if got := nextSeen(bob); got != (seenCount{CommentID: 5, MessageID: 42, Count: 1}) {
+		t.Errorf("Expected comment 5 seen once, got %+v", got)
+	}
+
+	if err := bob.conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"seen","comment_id":5}`)); err != nil {
+		t.Fatalf("Failed to send seen: %v", err)
+	}
+	// Alice only hears about Bob's receipt, never her own
+	if got := nextSeen(alice); got.Count != 2 {
+		t.Errorf("Expected comment 5 seen twice, got %+v", got)
+	}
+	// Bob's own receipt isn't echoed, and Alice's duplicate never counted
+	for deadline := time.After(50 * time.Millisecond); ; {
+		select {
+		case data := <-bob.frames:
+			if bytes.Contains(data, []byte(`"type":"seen"`)) {
+				t.Errorf("Expected no further seen events for Bob, got %s", data)
+			}
+			continue
+		case <-deadline:
+		}
+		break
+	}
+}
+
+func TestHub_PresenceCountsConnectedClients(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ServeWs(hub, conn, "", nil)
+	}))
+	defer server.Close()
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		return conn
+	}
+	// nextCount returns the next presence count conn receives
+	nextCount := func(conn *websocket.Conn) int64 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read presence: %v", err)
+		}
+		var frame presenceFrame
+		if err := json.Unmarshal(data, &frame); err != nil || frame.Type != "presence" {
+			t.Fatalf("Expected a presence frame, got %s", data)
+		}
+		return frame.Count
+	}
+
+	watcher := dial()
+	defer watcher.Close()
+	if err := watcher.WriteMessage(websocket.TextMessage, []byte(`{"subscribe":{"presence":true}}`)); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if count := nextCount(watcher); count != 1 {
+		t.Errorf("Expected 1 client online on subscribing, got %d", count)
+	}
+
+	other := dial()
+	if count := nextCount(watcher); count != 2 {
+		t.Errorf("Expected 2 clients online after connect, got %d", count)
+	}
+	if online := hub.Online(); online != 2 {
+		t.Errorf("Expected Online to report 2, got %d", online)
+	}
+
+	other.Close()
+	if count := nextCount(watcher); count != 1 {
+		t.Errorf("Expected 1 client online after disconnect, got %d", count)
+	}
+	if online := hub.Online(); online != 1 {
+		t.Errorf("Expected Online to report 1, got %d", online)
+	}
+}
+
+func TestHub_ReplayKeepsLatestBroadcasts(t *testing.T) {
+	hub := NewHub()
+	hub.SetReplaySize(2)
+	go hub.Run()
+	defer hub.Stop()
+
+	since := make(chan int64, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ResumeWs(hub, conn, "", <-since)
+	}))
+	defer server.Close()
+
+	// resume connects as a client that last saw seq and returns its first frame
+	resume := func(seq int64) []byte {
+		since <- seq
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read replay: %v", err)
+		}
+		return data
+	}
+
+	subscriber := newFakeSubscriber()
+	hub.Register(subscriber)
+	for _, content := range []string{"One", "Two", "Three"} {
+		hub.BroadcastMessage(&domain.Message{Content: content})
+		<-subscriber.received
+	}
+
+	// Only the last two are kept, so a client that saw the first is caught up
+	lines := bytes.Split(resume(1), []byte("\n"))
+	if len(lines) != 2 || !bytes.HasPrefix(lines[0], []byte(`{"seq":2,`)) || !bytes.HasPrefix(lines[1], []byte(`{"seq":3,`)) {
+		t.Errorf("Expected broadcasts 2 and 3, got %q", lines)
+	}
+
+	// but one that saw none of them has a gap and must resync
+	var frame resyncFrame
+	if err := json.Unmarshal(resume(0), &frame); err != nil || frame.Type != "resync" || frame.Seq != 3 {
+		t.Errorf("Expected a resync frame at seq 3, got %+v (%v)", frame, err)
+	}
+}
@@ -0,0 +1,26 @@
+package ws
+
+import "encoding/json"
+
+// pingFrame is an application-level ping, {"type": "ping", "ts": ...}. The
+// server echoes it back as {"type": "pong"} with the same ts, so clients can
+// time the round trip through the whole stack rather than just the socket.
+type pingFrame struct {
+	Type string          `json:"type"`
+	TS   json.RawMessage `json:"ts,omitempty"`
+}
+
+// parsePingFrame reports whether data is a ping frame
+func parsePingFrame(data []byte) (pingFrame, bool) {
+	var frame pingFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return frame, false
+	}
+	return frame, frame.Type == "ping"
+}
+
+// pong returns the reply to a ping frame, carrying its ts back unchanged
+func (f pingFrame) pong() []byte {
+	data, _ := json.Marshal(pingFrame{Type: "pong", TS: f.TS})
+	return data
+}
@@ -0,0 +1,39 @@
+package ws
+
+import "encoding/json"
+
+// presenceFrame is sent to clients subscribed to presence, {"type": "presence", "count": 3}
+type presenceFrame struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// newPresenceFrame encodes the number of connected WebSocket clients
+func newPresenceFrame(count int64) []byte {
+	data, _ := json.Marshal(presenceFrame{Type: "presence", Count: count})
+	return data
+}
+
+// Online returns the number of connected WebSocket clients. Other
+// subscribers, such as SSE and gRPC streams, aren't counted.
+func (h *Hub) Online() int64 {
+	return h.online.Load()
+}
+
+// greetPresence queues the online count for c, which has just subscribed to
+// presence. Run fills in the count as it delivers the reply, so c can't get
+// an older count after a newer announcement.
+func (h *Hub) greetPresence(c *Client) {
+	h.publish(event{kind: eventPresence, to: c})
+}
+
+// announcePresence sends the online count to presence subscribers if it has
+// changed since announced, and returns the count last sent. Only Run calls it.
+func (h *Hub) announcePresence(announced int64) int64 {
+	// Delivering may drop slow clients, changing the count again
+	for n := h.online.Load(); n != announced; n = h.online.Load() {
+		announced = n
+		h.deliver(event{data: newPresenceFrame(n), kind: eventPresence})
+	}
+	return announced
+}
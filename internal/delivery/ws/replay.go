@@ -0,0 +1,148 @@
+package ws
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+)
+
+// DefaultReplaySize is how many sequenced broadcasts a hub keeps for
+// resuming clients unless SetReplaySize says otherwise
+const DefaultReplaySize = 256
+
+// resyncFrame tells a resuming client that broadcasts after its sequence
+// number are no longer buffered, {"type": "resync", "seq": 42}. It should
+// reload over the REST API and carry on from seq.
+type resyncFrame struct {
+	Type string `json:"type"`
+	Seq  int64  `json:"seq"`
+}
+
+// newResyncFrame encodes a resync frame for the hub's current sequence number
+func newResyncFrame(seq int64) []byte {
+	data, _ := json.Marshal(resyncFrame{Type: "resync", Seq: seq})
+	return data
+}
+
+// sequenced reports whether events of kind k are numbered and kept for replay
+func (k eventKind) sequenced() bool {
+	return k == eventMessage || k == eventComment
+}
+
+// withSeq adds "seq" as the first field of a JSON object payload
+func withSeq(data []byte, seq int64) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+	out := make([]byte, 0, len(data)+24)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendInt(out, seq, 10)
+	if data[1] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, data[1:]...)
+}
+
+// replayBuffer is a bounded ring of the latest sequenced events, oldest
+// first once it wraps
+type replayBuffer struct {
+	mu     sync.Mutex
+	events []event
+	// start is the index of the oldest event once the ring is full
+	start int
+	size  int
+}
+
+// setSize changes how many events are kept, dropping those already kept
+func (b *replayBuffer) setSize(size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events, b.start, b.size = nil, 0, size
+}
+
+// capacity returns how many events are kept at most
+func (b *replayBuffer) capacity() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.size, 0)
+}
+
+// add keeps e, evicting the oldest event once the buffer is full
+func (b *replayBuffer) add(e event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.size <= 0 {
+		return
+	}
+	if len(b.events) < b.size {
+		b.events = append(b.events, e)
+		return
+	}
+	b.events[b.start] = e
+	b.start = (b.start + 1) % b.size
+}
+
+// since returns the kept events numbered after seq, oldest first, and
+// whether they're all of them, i.e. none in between were evicted
+func (b *replayBuffer) since(seq, latest int64) ([]event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq == latest {
+		return nil, true
+	}
+	if seq > latest || len(b.events) == 0 {
+		// A sequence number from the future was handed out before a restart
+		return nil, false
+	}
+
+	var missed []event
+	for i := range b.events {
+		e := b.events[(b.start+i)%len(b.events)]
+		if e.seq > seq {
+			missed = append(missed, e)
+		}
+	}
+	return missed, len(missed) > 0 && missed[0].seq == seq+1
+}
+
+// SetReplaySize sets how many of the latest message and comment broadcasts
+// are kept for clients resuming with ResumeWs. Zero or less disables replay,
+// so resuming clients are always told to resync.
+func (h *Hub) SetReplaySize(size int) {
+	h.replay.setSize(size)
+}
+
+// Seq returns the sequence number of the latest message or comment
+// broadcast; zero if there hasn't been one since the hub started
+func (h *Hub) Seq() int64 {
+	return h.seq.Load()
+}
+
+// sequence numbers e and keeps it for replay if it's a kind that's replayed.
+// Only Run calls it, so numbers go out in the order events are delivered.
+func (h *Hub) sequence(e event) event {
+	if !e.kind.sequenced() {
+		return e
+	}
+	e.seq = h.seq.Add(1)
+	e.data = withSeq(e.data, e.seq)
+	h.replay.add(e)
+	return e
+}
+
+// replayTo sends c the buffered events it missed since c.since, as it would
+// have received them live, or a resync frame if some are gone. Only Run
+// calls it, so no live event can slip in between. It reports whether c kept up.
+func (h *Hub) replayTo(c *Client) bool {
+	missed, complete := h.replay.since(c.since, h.seq.Load())
+	if !complete {
+		return c.Send(newResyncFrame(h.seq.Load()))
+	}
+	for _, e := range missed {
+		if c.wants(e) && !c.Send(e.data) {
+			return false
+		}
+	}
+	return true
+}
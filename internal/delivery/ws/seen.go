@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// maxTrackedComments bounds the read receipt state the hub keeps in memory.
+// Receipts are ephemeral, so once the bound is hit they are simply forgotten
+// and counting starts over.
+const maxTrackedComments = 10000
+
+// seenFrame is a read receipt from a client, {"type": "seen", "comment_id": 5}.
+// message_id may be given for comments the hub hasn't broadcast itself, such
+// as ones posted before the server started.
+type seenFrame struct {
+	Type      string `json:"type"`
+	CommentID int64  `json:"comment_id"`
+	MessageID int64  `json:"message_id,omitempty"`
+}
+
+// parseSeenFrame reports whether data is a read receipt frame
+func parseSeenFrame(data []byte) (seenFrame, bool) {
+	var frame seenFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return frame, false
+	}
+	return frame, frame.Type == "seen" && frame.CommentID > 0
+}
+
+// seenCount is the payload broadcast to a thread when a comment's seen count changes
+type seenCount struct {
+	CommentID int64 `json:"comment_id"`
+	MessageID int64 `json:"message_id"`
+	Count     int   `json:"count"`
+}
+
+// receipts aggregates read receipts per comment. Each client is counted once
+// per comment; the client tracks what it has already reported.
+type receipts struct {
+	mu sync.Mutex
+	// threads maps comments the hub has broadcast to their message
+	threads map[int64]int64
+	counts  map[int64]int
+}
+
+// track remembers which message a broadcast comment belongs to
+func (r *receipts) track(commentID, messageID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.threads[commentID]; !ok && len(r.threads) >= maxTrackedComments {
+		r.threads = nil
+	}
+	if r.threads == nil {
+		r.threads = make(map[int64]int64)
+	}
+	r.threads[commentID] = messageID
+}
+
+// thread returns the message a comment belongs to, falling back to the
+// client's claim for comments the hub hasn't seen broadcast
+func (r *receipts) thread(frame seenFrame) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if messageID, ok := r.threads[frame.CommentID]; ok {
+		return messageID
+	}
+	return frame.MessageID
+}
+
+// add counts one more viewer of a comment and returns the new total
+func (r *receipts) add(commentID int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.counts[commentID]; !ok && len(r.counts) >= maxTrackedComments {
+		r.counts = nil
+	}
+	if r.counts == nil {
+		r.counts = make(map[int64]int)
+	}
+	r.counts[commentID]++
+	return r.counts[commentID]
+}
+
+// markSeen records that from has seen a comment on messageID's thread and
+// sends {"type": "seen", "data": {...}} with the new count to the thread's
+// other subscribers
+func (h *Hub) markSeen(from Subscriber, commentID, messageID int64) {
+	count := h.receipts.add(commentID)
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "seen",
+		"data": seenCount{CommentID: commentID, MessageID: messageID, Count: count},
+	})
+	if err != nil {
+		return
+	}
+	h.publish(event{data: data, kind: eventSeen, messageID: messageID, from: from})
+}
@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// maxThreadSubscriptions caps how many threads one client can follow
+const maxThreadSubscriptions = 50
+
+// subscriptionFrame is a client frame changing what it receives, e.g.
+// {"subscribe": {"message_id": 42}} or {"unsubscribe": {"feed": true}}
+type subscriptionFrame struct {
+	Subscribe   *subscriptionTarget `json:"subscribe"`
+	Unsubscribe *subscriptionTarget `json:"unsubscribe"`
+}
+
+// subscriptionTarget is the global feed, a single message's thread, the
+// online count, or a combination
+type subscriptionTarget struct {
+	Feed      bool  `json:"feed"`
+	MessageID int64 `json:"message_id"`
+	Presence  bool  `json:"presence"`
+}
+
+// presenceOnly reports whether t leaves the feed and threads alone
+func (t *subscriptionTarget) presenceOnly() bool {
+	return t == nil || (t.Presence && !t.Feed && t.MessageID == 0)
+}
+
+// parseSubscriptionFrame reports whether data is a subscription frame
+func parseSubscriptionFrame(data []byte) (subscriptionFrame, bool) {
+	var frame subscriptionFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return frame, false
+	}
+	return frame, frame.Subscribe != nil || frame.Unsubscribe != nil
+}
+
+// subscriptions tracks what a client receives. A client that has never sent a
+// subscription frame gets the whole message feed, as before subscriptions
+// existed, but no comment or read receipt events. Once it subscribes, it gets
+// message events only if it follows the feed or that message's thread, and
+// comment and read receipt events only for followed threads. Presence is
+// separate: subscribing to it alone leaves the rest as it was.
+type subscriptions struct {
+	mu       sync.Mutex
+	filtered bool
+	feed     bool
+	threads  map[int64]bool
+	presence bool
+}
+
+// apply updates the subscriptions from a client frame
+func (s *subscriptions) apply(frame subscriptionFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !frame.Subscribe.presenceOnly() || !frame.Unsubscribe.presenceOnly() {
+		s.filtered = true
+	}
+	if t := frame.Subscribe; t != nil {
+		if t.Feed {
+			s.feed = true
+		}
+		if t.Presence {
+			s.presence = true
+		}
+		if t.MessageID > 0 && len(s.threads) < maxThreadSubscriptions {
+			if s.threads == nil {
+				s.threads = make(map[int64]bool)
+			}
+			s.threads[t.MessageID] = true
+		}
+	}
+	if t := frame.Unsubscribe; t != nil {
+		if t.Feed {
+			s.feed = false
+		}
+		if t.Presence {
+			s.presence = false
+		}
+		delete(s.threads, t.MessageID)
+	}
+}
+
+// wants reports whether e matches the subscriptions
+func (s *subscriptions) wants(e event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.kind == eventPresence {
+		return s.presence
+	}
+	if !s.filtered {
+		return !e.kind.threadOnly()
+	}
+	switch e.kind {
+	case eventComment, eventSeen:
+		return s.threads[e.messageID]
+	case eventMessage:
+		return s.feed || s.threads[e.messageID]
+	default:
+		return s.feed
+	}
+}
+
+// follows reports whether the client is subscribed to a message's thread
+func (s *subscriptions) follows(messageID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.threads[messageID]
+}
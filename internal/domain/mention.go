@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"regexp"
+	"time"
+)
+
+// Mention source types
+const (
+	MentionSourceMessage = "message"
+	MentionSourceComment = "comment"
+)
+
+// Mention records that a user was @mentioned in a message or comment
+type Mention struct {
+	ID              int64     `json:"id"`
+	SourceType      string    `json:"source_type"`
+	SourceID        int64     `json:"source_id"`
+	MentionedUserID int64     `json:"mentioned_user_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// mentionPattern matches @username when the @ isn't part of a word (e.g. an email address)
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_]{1,32})`)
+
+// ExtractMentions returns the distinct usernames @mentioned in content,
+// in order of first appearance
+func ExtractMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := match[1]
+		if !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
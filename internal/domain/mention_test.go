@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "No mentions",
+			content:  "Just a regular message",
+			expected: nil,
+		},
+		{
+			name:     "Single mention",
+			content:  "@alice hello",
+			expected: []string{"alice"},
+		},
+		{
+			name:     "Multiple mentions with punctuation",
+			content:  "Thanks @alice, @bob_2 and (@carol)!",
+			expected: []string{"alice", "bob_2", "carol"},
+		},
+		{
+			name:     "Duplicate mentions are collapsed",
+			content:  "@alice @bob @alice again @bob",
+			expected: []string{"alice", "bob"},
+		},
+		{
+			name:     "Email addresses are not mentions",
+			content:  "Mail me at user@example.com",
+			expected: nil,
+		},
+		{
+			name:     "Bare at sign",
+			content:  "Meet @ noon",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ExtractMentions(tt.content)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
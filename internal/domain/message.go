@@ -1,44 +1,362 @@
 package domain
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
-// Message represents a message entity
+// MaxPinnedMessages caps how many messages can be pinned at once
+const MaxPinnedMessages = 5
+
+// Default content length limits
+const (
+	// DefaultMaxMessageLength caps a message's content, in bytes
+	DefaultMaxMessageLength = 1000
+	// DefaultMaxCommentLength caps a comment's content, in bytes
+	DefaultMaxCommentLength = 500
+	// DefaultMaxUsernameLength caps the username shown on a post, in characters
+	DefaultMaxUsernameLength = 50
+)
+
+// Limits are the content length limits enforced by validation. A zero field
+// falls back to its default.
+type Limits struct {
+	MaxMessageLength  int
+	MaxCommentLength  int
+	MaxUsernameLength int
+}
+
+// DefaultLimits returns the default content length limits
+func DefaultLimits() Limits {
+	return Limits{
+		MaxMessageLength:  DefaultMaxMessageLength,
+		MaxCommentLength:  DefaultMaxCommentLength,
+		MaxUsernameLength: DefaultMaxUsernameLength,
+	}
+}
+
+// withDefaults fills unset limits with their defaults
+func (l Limits) withDefaults() Limits {
+	if l.MaxMessageLength <= 0 {
+		l.MaxMessageLength = DefaultMaxMessageLength
+	}
+	if l.MaxCommentLength <= 0 {
+		l.MaxCommentLength = DefaultMaxCommentLength
+	}
+	if l.MaxUsernameLength <= 0 {
+		l.MaxUsernameLength = DefaultMaxUsernameLength
+	}
+	return l
+}
+
+var (
+	// ErrMessageNotFound is returned when a message does not exist
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrCommentNotFound is returned when a comment does not exist
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrVersionConflict is returned when an edit was based on a stale version of a message
+	ErrVersionConflict = errors.New("message has been modified since it was read")
+	// ErrNotMessageAuthor is returned when a user tries to edit someone else's message
+	ErrNotMessageAuthor = errors.New("only the author can edit this message")
+	// ErrNotCommentAuthor is returned when a user tries to edit someone else's comment
+	ErrNotCommentAuthor = errors.New("only the author can edit this comment")
+	// ErrCommentExpired is returned when editing a comment whose lifetime has ended
+	ErrCommentExpired = errors.New("comment has expired")
+	// ErrTooManyPinned is returned when pinning would exceed MaxPinnedMessages
+	ErrTooManyPinned = errors.New("too many pinned messages")
+	// ErrInvalidReplyTarget is returned when replying to a missing or banned message
+	ErrInvalidReplyTarget = errors.New("reply target does not exist")
+	// ErrThreadLocked is returned when commenting on a locked message
+	ErrThreadLocked = errors.New("thread is locked")
+	// ErrInvalidCommentTTL is returned when a message's comment TTL is negative or above the maximum
+	ErrInvalidCommentTTL = errors.New("invalid comment TTL")
+	// ErrAuthUnavailable is returned when the auth service cannot be reached
+	ErrAuthUnavailable = errors.New("auth service unavailable")
+	// ErrInvalidComment is returned when an imported comment is missing required fields
+	ErrInvalidComment = errors.New("invalid comment")
+	// ErrInvalidDateRange is returned when a date range ends before it starts
+	ErrInvalidDateRange = errors.New("invalid date range: from must not be after to")
+	// ErrAuthRequired is returned when an anonymous caller posts where the
+	// operator requires authentication
+	ErrAuthRequired = errors.New("authentication required")
+	// ErrDuplicateContent is returned when a user reposts the same content
+	// within the duplicate window
+	ErrDuplicateContent = errors.New("duplicate content: you posted this message recently")
+	// ErrReadOnly is returned by writes while the service is in read-only mode
+	ErrReadOnly = errors.New("service is in read-only mode for maintenance")
+)
+
+// Message represents a message entity. Anonymous messages have no UserID,
+// and leave user_id out of their JSON.
 type Message struct {
 	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
+	UserID    int64     `json:"user_id,omitempty"`
 	Username  string    `json:"username"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	IsBanned  bool      `json:"is_banned"`
+	// ContentHTML is a sanitized HTML rendering of Content, set in markdown content mode
+	ContentHTML string `json:"content_html,omitempty"`
+	// LastActivityAt is bumped whenever the message receives a comment, unless
+	// the message is older than the bump window
+	LastActivityAt time.Time `json:"last_activity_at"`
+	// Version starts at 1 and is incremented on every edit
+	Version int64 `json:"version"`
+	// IsPinned and PinnedAt are set while an admin has the message pinned
+	IsPinned bool       `json:"is_pinned"`
+	PinnedAt *time.Time `json:"pinned_at,omitempty"`
+	// IsLocked is set while an admin has closed the message to new comments
+	IsLocked bool `json:"is_locked"`
+	// IsHidden is set while the author has hidden the message. Unlike a ban
+	// it's the author's choice, and the author can still see the message.
+	IsHidden bool `json:"is_hidden"`
+	// Slug identifies the message in shareable URLs. It's set when the
+	// message is created and doesn't change when the content is edited.
+	Slug string `json:"slug,omitempty"`
+	// ReplyToMessageID is set when the message quotes another message
+	ReplyToMessageID *int64            `json:"reply_to_message_id,omitempty"`
+	ReplyTo          *MessageReference `json:"reply_to,omitempty"`
+	// ExpiresAt is set for ephemeral messages; nil means the message is permanent
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// CommentTTLSeconds overrides how long comments on this message live; zero uses the default
+	CommentTTLSeconds int64 `json:"comment_ttl_seconds,omitempty"`
+	// CommentCount and ParticipantCount summarize the non-expired comments,
+	// counting comments and distinct commenters. Set on listed messages.
+	CommentCount     int64 `json:"comment_count"`
+	ParticipantCount int64 `json:"participant_count"`
+	// ContentLength is the length of the full Content in characters, and
+	// Truncated is set when Content has been cut short. Set on listed messages.
+	ContentLength int  `json:"content_length,omitempty"`
+	Truncated     bool `json:"truncated,omitempty"`
+}
+
+// IsExpired checks if an ephemeral message has expired
+func (m *Message) IsExpired() bool {
+	return m.ExpiresAt != nil && time.Now().After(*m.ExpiresAt)
+}
+
+// Truncate returns a copy of m reporting its ContentLength. If limit is
+// positive and the content is longer, the copy keeps only the first limit
+// characters of it and is marked Truncated; it has no ContentHTML then, as
+// HTML cut short could be malformed.
+func (m *Message) Truncate(limit int) *Message {
+	truncated := *m
+	truncated.ContentLength = utf8.RuneCountInString(m.Content)
+	if limit > 0 && truncated.ContentLength > limit {
+		truncated.Content = string([]rune(m.Content)[:limit])
+		truncated.ContentHTML = ""
+		truncated.Truncated = true
+	}
+	return &truncated
+}
+
+// CommentLifetime returns how long comments on the message live, or fallback
+// if the message has no comment TTL of its own
+func (m *Message) CommentLifetime(fallback time.Duration) time.Duration {
+	if m.CommentTTLSeconds > 0 {
+		return time.Duration(m.CommentTTLSeconds) * time.Second
+	}
+	return fallback
+}
+
+// replyPreviewLength is how many characters of a quoted message are shown in a reply
+const replyPreviewLength = 100
+
+// MessageReference is a brief summary of a quoted message
+type MessageReference struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Content  string `json:"content"`
+}
+
+// NewMessageReference summarizes a message for embedding in replies,
+// truncating long content
+func NewMessageReference(m *Message) *MessageReference {
+	content := []rune(m.Content)
+	preview := m.Content
+	if len(content) > replyPreviewLength {
+		preview = string(content[:replyPreviewLength]) + "..."
+	}
+	return &MessageReference{
+		ID:       m.ID,
+		Username: m.Username,
+		Content:  preview,
+	}
+}
+
+// SortOrder is the direction a list is ordered in
+type SortOrder string
+
+// Sort orders; the zero value sorts ascending
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// ParseSortOrder parses an "asc" or "desc" query value, defaulting to ascending
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch SortOrder(s) {
+	case "", SortAsc:
+		return SortAsc, nil
+	case SortDesc:
+		return SortDesc, nil
+	}
+	return "", fmt.Errorf("invalid order %q: must be asc or desc", s)
+}
+
+// RenderedContent is message content as it would be stored, along with its
+// HTML rendering when markdown is enabled
+type RenderedContent struct {
+	Content     string `json:"content"`
+	ContentHTML string `json:"content_html"`
+}
+
+// MessageOptions holds optional settings for creating a message
+type MessageOptions struct {
+	// ReplyToMessageID quotes an existing message when non-zero
+	ReplyToMessageID int64
+	// ExpiresIn makes the message ephemeral when positive
+	ExpiresIn time.Duration
+	// CommentTTL sets how long comments on the message live when positive
+	CommentTTL time.Duration
 }
 
-// Validate validates the message
-func (m *Message) Validate() error {
-	if strings.TrimSpace(m.Content) == "" {
-		return errors.New("content cannot be empty")
+// FieldError describes a single invalid field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors collects every field that failed validation
+type ValidationErrors []FieldError
+
+// Error implements the error interface
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, e := range v {
+		messages[i] = e.Error()
 	}
-	if strings.TrimSpace(m.Username) == "" {
-		return errors.New("username cannot be empty")
+	return strings.Join(messages, "; ")
+}
+
+// Add records a field error
+func (v *ValidationErrors) Add(field, message string) {
+	*v = append(*v, FieldError{Field: field, Message: message})
+}
+
+// Err returns v as an error, or nil if nothing failed
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
 	}
-	if len(m.Content) > 1000 {
-		return errors.New("content too long")
+	return v
+}
+
+// validateUsername checks that a username is present, printable and not too long,
+// so anonymous posters can't impersonate others with formatting tricks
+func validateUsername(errs *ValidationErrors, username string, maxLength int) {
+	switch {
+	case strings.TrimSpace(username) == "":
+		errs.Add("username", "username cannot be empty")
+	case strings.IndexFunc(username, unicode.IsControl) >= 0:
+		errs.Add("username", "username cannot contain control characters")
+	case utf8.RuneCountInString(username) > maxLength:
+		errs.Add("username", "username too long")
 	}
-	return nil
 }
 
+// ValidateUsername checks a username on its own against limits, reporting
+// problems as ValidationErrors
+func ValidateUsername(username string, limits Limits) error {
+	var errs ValidationErrors
+	validateUsername(&errs, username, limits.withDefaults().MaxUsernameLength)
+	return errs.Err()
+}
+
+// invalidEncoding is reported for content that isn't valid UTF-8, which
+// couldn't be encoded back as JSON intact
+const invalidEncoding = "content must be valid UTF-8"
+
+// ValidateContentEncoding checks that content is valid UTF-8, reporting
+// problems as ValidationErrors
+func ValidateContentEncoding(content string) error {
+	if utf8.ValidString(content) {
+		return nil
+	}
+	return ValidationErrors{{Field: "content", Message: invalidEncoding}}
+}
+
+// ValidateMessageContent checks message content on its own against limits,
+// reporting problems as ValidationErrors
+func ValidateMessageContent(content string, limits Limits) error {
+	var errs ValidationErrors
+	validateMessageContent(&errs, content, limits.withDefaults().MaxMessageLength)
+	return errs.Err()
+}
+
+// validateMessageContent records an error if content is blank, isn't valid
+// UTF-8 or is longer than maxLength
+func validateMessageContent(errs *ValidationErrors, content string, maxLength int) {
+	switch {
+	case strings.TrimSpace(content) == "":
+		errs.Add("content", "content cannot be empty")
+	case !utf8.ValidString(content):
+		errs.Add("content", invalidEncoding)
+	case len(content) > maxLength:
+		errs.Add("content", "content too long")
+	}
+}
+
+// Validate validates the message against limits, reporting every invalid
+// field as ValidationErrors
+func (m *Message) Validate(limits Limits) error {
+	limits = limits.withDefaults()
+	var errs ValidationErrors
+	validateMessageContent(&errs, m.Content, limits.MaxMessageLength)
+	validateUsername(&errs, m.Username, limits.MaxUsernameLength)
+	return errs.Err()
+}
+
+// CommentNeverExpires is the expiry given to comments when comment expiry is turned off
+var CommentNeverExpires = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
 // Comment represents a comment entity
 type Comment struct {
 	ID        int64     `json:"id"`
 	MessageID int64     `json:"message_id"`
-	UserID    int64     `json:"user_id"`
+	UserID    int64     `json:"user_id,omitempty"`
 	Username  string    `json:"username"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
+	// Message summarizes the commented message in site-wide comment lists
+	Message *MessageReference `json:"message,omitempty"`
+}
+
+// MessageDetail is a message together with its non-expired comments
+type MessageDetail struct {
+	*Message
+	Comments []*Comment `json:"comments"`
+}
+
+// MessageModerationView is what a moderator sees when reviewing a message:
+// the message with its banned, hidden, locked and pinned flags and comment
+// count, plus its author as the auth service knows them. Author is nil for
+// anonymous messages or when the auth service couldn't be asked.
+type MessageModerationView struct {
+	*Message
+	Author *User `json:"author,omitempty"`
 }
 
 // IsExpired checks if the comment has expired
@@ -46,51 +364,154 @@ func (c *Comment) IsExpired() bool {
 	return time.Now().After(c.ExpiresAt)
 }
 
-// Validate validates the comment
-func (c *Comment) Validate() error {
-	if strings.TrimSpace(c.Content) == "" {
-		return errors.New("content cannot be empty")
-	}
-	if strings.TrimSpace(c.Username) == "" {
-		return errors.New("username cannot be empty")
+// MarshalJSON leaves expires_at out for comments that never expire, rather
+// than showing clients the CommentNeverExpires sentinel
+func (c Comment) MarshalJSON() ([]byte, error) {
+	type comment Comment
+	out := struct {
+		comment
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}{comment: comment(c)}
+	if !c.ExpiresAt.IsZero() && !c.ExpiresAt.Equal(CommentNeverExpires) {
+		out.ExpiresAt = &c.ExpiresAt
 	}
-	if len(c.Content) > 500 {
-		return errors.New("comment too long")
+	return json.Marshal(out)
+}
+
+// Validate validates the comment against limits, reporting every invalid
+// field as ValidationErrors
+func (c *Comment) Validate(limits Limits) error {
+	limits = limits.withDefaults()
+	var errs ValidationErrors
+	switch {
+	case strings.TrimSpace(c.Content) == "":
+		errs.Add("content", "content cannot be empty")
+	case !utf8.ValidString(c.Content):
+		errs.Add("content", invalidEncoding)
+	case len(c.Content) > limits.MaxCommentLength:
+		errs.Add("content", "comment too long")
 	}
+	validateUsername(&errs, c.Username, limits.MaxUsernameLength)
 	if c.MessageID <= 0 {
-		return errors.New("invalid message ID")
+		errs.Add("message_id", "invalid message ID")
 	}
-	return nil
+	return errs.Err()
 }
 
 // MessageRepository defines the repository interface for Message
 type MessageRepository interface {
-	GetByID(id int64) (*Message, error)
-	List(limit, offset int64) ([]*Message, int64, error)
-	GetAllMessages() ([]*Message, error)
-	Create(message *Message) (int64, error)
-	Ban(id int64) error
-	Unban(id int64) error
-	Delete(id int64) error
-	CreateComment(comment *Comment) (int64, error)
-	GetComments(messageID int64) ([]*Comment, error)
-	GetCommentByID(id int64) (*Comment, error)
-	DeleteComment(id int64) error
-	DeleteExpiredComments() error
+	GetByID(ctx context.Context, id int64) (*Message, error)
+	GetByIDs(ctx context.Context, ids []int64) ([]*Message, error)
+	GetBySlug(ctx context.Context, slug string) (*Message, error)
+	List(ctx context.Context, limit, offset int64) ([]*Message, int64, error)
+	ListByActivity(ctx context.Context, limit, offset int64) ([]*Message, int64, error)
+	Latest(ctx context.Context) (*Message, error)
+	ListTrending(ctx context.Context, since time.Time, limit, offset int64) ([]*Message, int64, error)
+	Search(ctx context.Context, query string, limit, offset int64) ([]*Message, int64, error)
+	ListCreatedBetween(ctx context.Context, from, to time.Time, limit, offset int64) ([]*Message, int64, error)
+	GetAllMessages(ctx context.Context) ([]*Message, error)
+	Create(ctx context.Context, message *Message) (int64, error)
+	HasRecentDuplicate(ctx context.Context, userID int64, content string, since time.Time) (bool, error)
+	Update(ctx context.Context, id int64, content, contentHTML string, expectedVersion int64) (int64, error)
+	Ban(ctx context.Context, id int64) error
+	Unban(ctx context.Context, id int64) error
+	ListByUser(ctx context.Context, userID int64) ([]*Message, error)
+	ListByUserPage(ctx context.Context, userID, limit, offset int64) ([]*Message, int64, error)
+	BanMessagesByUser(ctx context.Context, userID int64) (int64, error)
+	BanByIDs(ctx context.Context, ids []int64) (int64, error)
+	UnbanByIDs(ctx context.Context, ids []int64) ([]int64, error)
+	UnbanMessagesByUser(ctx context.Context, userID int64) (int64, error)
+	Pin(ctx context.Context, id int64) error
+	Unpin(ctx context.Context, id int64) error
+	Lock(ctx context.Context, id int64) error
+	Unlock(ctx context.Context, id int64) error
+	Hide(ctx context.Context, id int64) error
+	Unhide(ctx context.Context, id int64) error
+	ListPinned(ctx context.Context) ([]*Message, error)
+	Delete(ctx context.Context, id int64) error
+	Trash(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64, deletedSince time.Time) error
+	PurgeTrash(ctx context.Context, t time.Time) (int64, error)
+	PurgeBanned(ctx context.Context) (int64, error)
+	CountByInterval(ctx context.Context, interval StatsInterval) ([]TimeBucket, error)
+	CreateComment(ctx context.Context, comment *Comment) (int64, error)
+	CreateCommentWithoutBump(ctx context.Context, comment *Comment) (int64, error)
+	CreateComments(ctx context.Context, comments []*Comment, bumped []int64) ([]int64, error)
+	GetComments(ctx context.Context, messageID int64, order SortOrder, includeExpired bool) ([]*Comment, error)
+	GetCommentByID(ctx context.Context, id int64) (*Comment, error)
+	ListCommentsByUser(ctx context.Context, userID, limit, offset int64, includeExpired bool) ([]*Comment, int64, error)
+	ListRecentComments(ctx context.Context, limit, offset int64, includeExpired bool) ([]*Comment, int64, error)
+	UpdateComment(ctx context.Context, id int64, content string, expiresAt time.Time) error
+	DeleteComment(ctx context.Context, id int64) error
+	DeleteExpiredComments(ctx context.Context) error
+	UpdateUsername(ctx context.Context, userID int64, newUsername string) (int64, error)
+	DeleteMessagesOlderThan(ctx context.Context, t time.Time) (int64, error)
+	DeleteExpiredMessages(ctx context.Context) (int64, error)
+	FindUserIDsByUsernames(ctx context.Context, usernames []string) (map[string][]int64, error)
+	CreateMentions(ctx context.Context, mentions []*Mention) error
+	ListMentions(ctx context.Context, userID, limit, offset int64) ([]*Mention, int64, error)
+	MarkRead(ctx context.Context, userID, messageID int64) error
+	CountUnread(ctx context.Context, userID int64) (int64, error)
+	Subscribe(ctx context.Context, userID, messageID int64) error
+	Unsubscribe(ctx context.Context, userID, messageID int64) error
+	CreateCommentNotifications(ctx context.Context, comment *Comment) (int64, error)
+	ListNotifications(ctx context.Context, userID, limit, offset int64) ([]*Notification, int64, error)
 }
 
 // MessageUseCase defines the usecase interface for Message
 type MessageUseCase interface {
-	GetMessages(limit, offset int64) ([]*Message, int64, error)
-	GetAllMessages() ([]*Message, error)
-	CreateMessage(userID int64, username, content string) (*Message, error)
-	BanMessage(id int64) error
-	UnbanMessage(id int64) error
-	GetByID(id int64) (*Message, error)
-	CreateComment(messageID, userID int64, username, content string) (*Comment, error)
-	GetComments(messageID int64) ([]*Comment, error)
-	DeleteMessage(id int64) error
-	DeleteComment(id int64) error
+	GetMessages(ctx context.Context, limit, offset int64) ([]*Message, int64, error)
+	GetActiveMessages(ctx context.Context, limit, offset int64) ([]*Message, int64, error)
+	GetLatestMessage(ctx context.Context) (*Message, error)
+	GetTrendingMessages(ctx context.Context, limit, offset int64) ([]*Message, int64, error)
+	GetMessagesBetween(ctx context.Context, from, to time.Time, limit, offset int64) ([]*Message, int64, error)
+	GetAllMessages(ctx context.Context) ([]*Message, error)
+	PreviewContent(ctx context.Context, content string) (*RenderedContent, error)
+	CreateMessage(ctx context.Context, userID int64, username, content string) (*Message, error)
+	CreateMessageWithOptions(ctx context.Context, userID int64, username, content string, opts MessageOptions) (*Message, error)
+	UpdateMessage(ctx context.Context, id, userID int64, content string, expectedVersion int64) (*Message, error)
+	BanMessage(ctx context.Context, id int64) error
+	UnbanMessage(ctx context.Context, id int64) error
+	BanUserContent(ctx context.Context, userID int64) (int64, error)
+	UnbanUserContent(ctx context.Context, userID int64) (int64, error)
+	PinMessage(ctx context.Context, id int64) error
+	UnpinMessage(ctx context.Context, id int64) error
+	LockMessage(ctx context.Context, id int64) error
+	UnlockMessage(ctx context.Context, id int64) error
+	HideMessage(ctx context.Context, id, userID int64) error
+	UnhideMessage(ctx context.Context, id, userID int64) error
+	GetPinnedMessages(ctx context.Context) ([]*Message, error)
+	GetByID(ctx context.Context, id int64) (*Message, error)
+	GetBySlug(ctx context.Context, slug string) (*Message, error)
+	GetMessageWithComments(ctx context.Context, id int64) (*MessageDetail, error)
+	GetModerationView(ctx context.Context, id int64) (*MessageModerationView, error)
+	GetMessagesByIDs(ctx context.Context, ids []int64, includeBanned bool) ([]*Message, error)
+	GetMessagesByUser(ctx context.Context, userID, limit, offset int64) ([]*Message, int64, error)
+	SearchMessages(ctx context.Context, query string, limit, offset int64, highlight bool) ([]*SearchResult, int64, error)
+	CreateComment(ctx context.Context, messageID, userID int64, username, content string) (*Comment, error)
+	UpdateComment(ctx context.Context, id, userID int64, content string) (*Comment, error)
+	ImportComments(ctx context.Context, comments []*Comment) ([]int64, error)
+	GetComments(ctx context.Context, messageID int64, order SortOrder) ([]*Comment, error)
+	GetCommentsByUser(ctx context.Context, userID, limit, offset int64, includeExpired bool) ([]*Comment, int64, error)
+	GetRecentComments(ctx context.Context, limit, offset int64) ([]*Comment, int64, error)
+	DeleteMessage(ctx context.Context, id int64) error
+	RestoreMessage(ctx context.Context, id int64) (*Message, error)
+	PurgeBannedMessages(ctx context.Context) (int64, error)
+	BanMatchingMessages(ctx context.Context, pattern string) (int64, error)
+	BanMessages(ctx context.Context, ids []int64) (banned, failed []int64, err error)
+	UnbanMessages(ctx context.Context, ids []int64) (unbanned, failed []int64, err error)
+	GetMessageTimeSeries(ctx context.Context, interval StatsInterval) ([]TimeBucket, error)
+	DeleteComment(ctx context.Context, id int64) error
+	SyncUsername(ctx context.Context, userID int64, username string) (int64, error)
+	RenameUser(ctx context.Context, userID int64, username string) (int64, error)
+	GetUserMentions(ctx context.Context, userID, limit, offset int64) ([]*Mention, int64, error)
+	MarkRead(ctx context.Context, userID, messageID int64) error
+	CountUnread(ctx context.Context, userID int64) (int64, error)
+	SubscribeToMessage(ctx context.Context, messageID, userID int64) error
+	UnsubscribeFromMessage(ctx context.Context, messageID, userID int64) error
+	GetNotifications(ctx context.Context, userID, limit, offset int64) ([]*Notification, int64, error)
+	IsReadOnly() bool
+	SetReadOnly(readOnly bool)
 }
 
 // User represents a minimal user structure for forum service
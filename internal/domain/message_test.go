@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -47,6 +50,41 @@ func TestMessage_IsExpired(t *testing.T) {
 	}
 }
 
+func TestMessage_Truncate(t *testing.T) {
+	message := &Message{ID: 1, Content: "Привет, world", ContentHTML: "<p>Привет, world</p>"}
+
+	tests := []struct {
+		name            string
+		limit           int
+		expectedContent string
+		truncated       bool
+	}{
+		{name: "No limit", limit: 0, expectedContent: "Привет, world"},
+		{name: "Limit above the length", limit: 100, expectedContent: "Привет, world"},
+		{name: "Limit equal to the length", limit: 13, expectedContent: "Привет, world"},
+		{name: "Limit counts characters, not bytes", limit: 6, expectedContent: "Привет", truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := message.Truncate(tt.limit)
+			if got.Content != tt.expectedContent || got.Truncated != tt.truncated {
+				t.Errorf("Expected content %q truncated=%v, got %q truncated=%v", tt.expectedContent, tt.truncated, got.Content, got.Truncated)
+			}
+			if got.ContentLength != 13 {
+				t.Errorf("Expected the full length 13, got %d", got.ContentLength)
+			}
+			if (got.ContentHTML == "") != tt.truncated {
+				t.Errorf("Expected content_html only on untruncated copies, got %q", got.ContentHTML)
+			}
+		})
+	}
+
+	if message.Content != "Привет, world" || message.ContentLength != 0 {
+		t.Errorf("Expected the original message to be left alone, got %+v", message)
+	}
+}
+
 func TestComment_IsExpired(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -91,6 +129,47 @@ func TestComment_IsExpired(t *testing.T) {
 	}
 }
 
+func TestJSON_OmitsUnsetOptionalFields(t *testing.T) {
+	keys := func(v interface{}) map[string]json.RawMessage {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to marshal %T: %v", v, err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("Failed to unmarshal %s: %v", data, err)
+		}
+		return fields
+	}
+
+	anonymous := keys(&Message{ID: 1, Username: "anonymous", Content: "Hi", CreatedAt: time.Now()})
+	for _, key := range []string{"user_id", "expires_at", "pinned_at", "reply_to_message_id", "reply_to", "content_html", "comment_ttl_seconds"} {
+		if value, ok := anonymous[key]; ok {
+			t.Errorf("Expected %q to be omitted from an anonymous message, got %s", key, value)
+		}
+	}
+	if _, ok := anonymous["username"]; !ok {
+		t.Error("Expected username to be kept")
+	}
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	comment := keys(Comment{ID: 1, MessageID: 1, UserID: 2, Content: "Hi", ExpiresAt: expiresAt})
+	var got time.Time
+	if err := json.Unmarshal(comment["expires_at"], &got); err != nil || !got.Equal(expiresAt) {
+		t.Errorf("Expected expires_at %v on an expiring comment, got %s", expiresAt, comment["expires_at"])
+	}
+	if string(comment["user_id"]) != "2" {
+		t.Errorf("Expected user_id 2, got %s", comment["user_id"])
+	}
+
+	forever := keys(&Comment{ID: 2, MessageID: 1, Content: "Hi", ExpiresAt: CommentNeverExpires})
+	for _, key := range []string{"expires_at", "user_id"} {
+		if value, ok := forever[key]; ok {
+			t.Errorf("Expected %q to be omitted from an anonymous comment that never expires, got %s", key, value)
+		}
+	}
+}
+
 func TestMessage_Validate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -133,14 +212,85 @@ func TestMessage_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Invalid UTF-8",
+			message: &Message{
+				UserID:   1,
+				Username: "testuser",
+				Content:  "Broken \xff\xfe bytes",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.message.Validate()
+			err := tt.message.Validate(DefaultLimits())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Message.Validate() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestMessage_ValidateReportsAllErrors(t *testing.T) {
+	message := &Message{
+		UserID:   1,
+		Username: "",
+		Content:  strings.Repeat("a", 1001),
+	}
+
+	err := message.Validate(DefaultLimits())
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	if len(verrs) != 2 {
+		t.Fatalf("Expected 2 errors, got %d: %v", len(verrs), verrs)
+	}
+	if verrs[0].Field != "content" || verrs[1].Field != "username" {
+		t.Errorf("Expected content and username errors, got %v", verrs)
+	}
+}
+
+func TestComment_ValidateReportsAllErrors(t *testing.T) {
+	comment := &Comment{
+		Username: "",
+		Content:  strings.Repeat("a", 501),
+	}
+
+	var verrs ValidationErrors
+	if !errors.As(comment.Validate(DefaultLimits()), &verrs) || len(verrs) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", verrs)
+	}
+}
+
+func TestMessage_ValidateUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantErr  bool
+	}{
+		{name: "Plain username", username: "testuser", wantErr: false},
+		{name: "Unicode username", username: "пользователь", wantErr: false},
+		{name: "Username with newline", username: "testuser\nadmin", wantErr: true},
+		{name: "Username with escape sequence", username: "test\x1b[31muser", wantErr: true},
+		{name: "Over-long username", username: strings.Repeat("a", DefaultMaxUsernameLength+1), wantErr: true},
+		{name: "Username at max length", username: strings.Repeat("я", DefaultMaxUsernameLength), wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &Message{UserID: 0, Username: tt.username, Content: "Valid content"}
+			err := message.Validate(DefaultLimits())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Message.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var verrs ValidationErrors
+			if tt.wantErr && (!errors.As(err, &verrs) || verrs[0].Field != "username") {
+				t.Errorf("Expected a username field error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// Notification tells a user about a new comment on a message they subscribed to
+type Notification struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	MessageID int64     `json:"message_id"`
+	CommentID int64     `json:"comment_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxPatternLength caps the length, in characters, of a content pattern
+const MaxPatternLength = 200
+
+// maxPatternInsts caps the size of a compiled content pattern. Go regexps run
+// in linear time, but a small pattern with nested counted repetition can
+// still compile to a program big enough to make every match slow.
+const maxPatternInsts = 2000
+
+// ErrInvalidPattern is returned when a content pattern is empty, too long,
+// too complex or not a valid regular expression
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// CompilePattern validates and compiles a regular expression used to match
+// message content
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("%w: pattern is required", ErrInvalidPattern)
+	}
+	if utf8.RuneCountInString(pattern) > MaxPatternLength {
+		return nil, fmt.Errorf("%w: pattern longer than %d characters", ErrInvalidPattern, MaxPatternLength)
+	}
+
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	if len(prog.Inst) > maxPatternInsts {
+		return nil, fmt.Errorf("%w: pattern too complex", ErrInvalidPattern)
+	}
+
+	return regexp.Compile(pattern)
+}
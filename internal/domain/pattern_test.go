@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{"Substring", "spam.example.com", false},
+		{"Alternation", `(?i)buy (now|today)`, false},
+		{"Empty", "  ", true},
+		{"Invalid syntax", "(unclosed", true},
+		{"Too long", strings.Repeat("a", MaxPatternLength+1), true},
+		{"Too complex", "((a{100}){100})", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompilePattern(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompilePattern(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPattern) {
+				t.Errorf("Expected ErrInvalidPattern, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxSearchQueryLength caps the length, in characters, of a search query
+const MaxSearchQueryLength = 100
+
+// snippetContext is how many characters a snippet keeps on each side of the first match
+const snippetContext = 40
+
+// snippetEllipsis marks where a snippet was cut from its content
+const snippetEllipsis = "…"
+
+// ErrInvalidSearchQuery is returned when a search query is empty or too long
+var ErrInvalidSearchQuery = errors.New("search query must be between 1 and 100 characters")
+
+// MatchRange is the half-open character range [Start, End) of a match in a snippet.
+// Offsets count Unicode code points, not bytes.
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SearchResult is a message matching a search, optionally with an excerpt
+// around the match and where the query occurs in it, for highlighting
+type SearchResult struct {
+	*Message
+	Snippet     string       `json:"snippet,omitempty"`
+	MatchRanges []MatchRange `json:"match_ranges,omitempty"`
+}
+
+// NormalizeSearchQuery trims a search query and checks its length
+func NormalizeSearchQuery(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || utf8.RuneCountInString(query) > MaxSearchQueryLength {
+		return "", ErrInvalidSearchQuery
+	}
+	return query, nil
+}
+
+// Highlight returns an excerpt of content around the first case-insensitive
+// occurrence of query, along with the ranges of every occurrence within it.
+// When content doesn't contain query the excerpt is its beginning.
+func Highlight(content, query string) (string, []MatchRange) {
+	text := []rune(content)
+	matches := findMatches(foldRunes(text), foldRunes([]rune(query)))
+	queryLen := utf8.RuneCountInString(query)
+
+	start, end := 0, len(text)
+	if len(matches) > 0 {
+		start = max(0, matches[0]-snippetContext)
+		end = min(len(text), matches[0]+queryLen+snippetContext)
+	} else {
+		end = min(len(text), 2*snippetContext)
+	}
+
+	var snippet strings.Builder
+	offset := -start
+	if start > 0 {
+		snippet.WriteString(snippetEllipsis)
+		offset += utf8.RuneCountInString(snippetEllipsis)
+	}
+	snippet.WriteString(string(text[start:end]))
+	if end < len(text) {
+		snippet.WriteString(snippetEllipsis)
+	}
+
+	var ranges []MatchRange
+	for _, m := range matches {
+		if m >= start && m+queryLen <= end {
+			ranges = append(ranges, MatchRange{Start: m + offset, End: m + queryLen + offset})
+		}
+	}
+	return snippet.String(), ranges
+}
+
+// MatchOffsets returns the character offset of each non-overlapping
+// case-insensitive occurrence of query in content
+func MatchOffsets(content, query string) []int {
+	return findMatches(foldRunes([]rune(content)), foldRunes([]rune(query)))
+}
+
+// foldRunes lowercases each rune, keeping positions aligned with the input
+func foldRunes(runes []rune) []rune {
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = unicode.ToLower(r)
+	}
+	return folded
+}
+
+// findMatches returns the start of each non-overlapping occurrence of query in text
+func findMatches(text, query []rune) []int {
+	if len(query) == 0 {
+		return nil
+	}
+	var matches []int
+	for i := 0; i+len(query) <= len(text); {
+		if string(text[i:i+len(query)]) == string(query) {
+			matches = append(matches, i)
+			i += len(query)
+			continue
+		}
+		i++
+	}
+	return matches
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		matches int
+	}{
+		{name: "Short content", content: "Hello World", query: "world", matches: 1},
+		{name: "Repeated term", content: "go go gadget go", query: "Go", matches: 3},
+		{name: "Cyrillic term", content: "Привет, мир! Мир прекрасен", query: "мир", matches: 2},
+		{
+			name:    "Match deep in long content",
+			content: strings.Repeat("lorem ipsum ", 20) + "needle" + strings.Repeat(" dolor sit", 20),
+			query:   "needle",
+			matches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet, ranges := Highlight(tt.content, tt.query)
+			if !strings.Contains(strings.ToLower(snippet), strings.ToLower(tt.query)) {
+				t.Fatalf("Expected snippet %q to contain %q", snippet, tt.query)
+			}
+			if len(ranges) != tt.matches {
+				t.Fatalf("Expected %d match ranges, got %v", tt.matches, ranges)
+			}
+			runes := []rune(snippet)
+			for _, r := range ranges {
+				if matched := string(runes[r.Start:r.End]); !strings.EqualFold(matched, tt.query) {
+					t.Errorf("Expected range %v to cover %q, got %q", r, tt.query, matched)
+				}
+			}
+		})
+	}
+}
+
+func TestHighlight_TrimsLongContent(t *testing.T) {
+	content := strings.Repeat("a", 200) + "needle" + strings.Repeat("b", 200)
+
+	snippet, ranges := Highlight(content, "needle")
+	if !strings.HasPrefix(snippet, snippetEllipsis) || !strings.HasSuffix(snippet, snippetEllipsis) {
+		t.Errorf("Expected snippet cut on both sides, got %q", snippet)
+	}
+	if len([]rune(snippet)) >= len(content) {
+		t.Errorf("Expected snippet shorter than content, got %d characters", len([]rune(snippet)))
+	}
+	if len(ranges) != 1 || ranges[0].Start != snippetContext+1 {
+		t.Errorf("Expected one match after the context and ellipsis, got %v", ranges)
+	}
+}
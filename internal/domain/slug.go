@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Slug length limits
+const (
+	// slugMaxWords caps how many words of the content go into a slug
+	slugMaxWords = 6
+	// slugMaxLength caps the part of a slug after the ID, in characters
+	slugMaxLength = 50
+)
+
+// MessageSlug builds the URL slug of a message from its ID and the first few
+// words of its content, e.g. "42-first-few-words". Leading with the ID keeps
+// slugs unique even when two messages start with the same words, and content
+// without any letters or digits gets just the ID.
+func MessageSlug(id int64, content string) string {
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	b.WriteString(strconv.FormatInt(id, 10))
+	length := 0
+	for i, word := range words {
+		n := len([]rune(word))
+		if i == slugMaxWords || length+n > slugMaxLength {
+			break
+		}
+		b.WriteByte('-')
+		b.WriteString(word)
+		length += n + 1
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "testing"
+
+func TestMessageSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       int64
+		content  string
+		expected string
+	}{
+		{
+			name:     "First few words",
+			id:       42,
+			content:  "First few words",
+			expected: "42-first-few-words",
+		},
+		{
+			name:     "Punctuation and spacing are collapsed",
+			id:       7,
+			content:  "  Hello, world!  What's   new? ",
+			expected: "7-hello-world-what-s-new",
+		},
+		{
+			name:     "Word count is capped",
+			id:       1,
+			content:  "one two three four five six seven eight",
+			expected: "1-one-two-three-four-five-six",
+		},
+		{
+			name:     "Long words are left out rather than cut",
+			id:       3,
+			content:  "short averyveryveryveryveryveryveryveryveryveryverylongword",
+			expected: "3-short",
+		},
+		{
+			name:     "Non-ASCII letters are kept",
+			id:       5,
+			content:  "Привет мир",
+			expected: "5-привет-мир",
+		},
+		{
+			name:     "No words",
+			id:       9,
+			content:  "!!! ???",
+			expected: "9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MessageSlug(tt.id, tt.content); got != tt.expected {
+				t.Errorf("MessageSlug(%d, %q) = %q, want %q", tt.id, tt.content, got, tt.expected)
+			}
+		})
+	}
+}
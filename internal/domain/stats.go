@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatsInterval is the width of the buckets a time series is counted in
+type StatsInterval string
+
+// Stats intervals; the zero value counts by day
+const (
+	IntervalHour StatsInterval = "hour"
+	IntervalDay  StatsInterval = "day"
+)
+
+// ParseStatsInterval parses an "hour" or "day" query value, defaulting to day
+func ParseStatsInterval(s string) (StatsInterval, error) {
+	switch StatsInterval(s) {
+	case "", IntervalDay:
+		return IntervalDay, nil
+	case IntervalHour:
+		return IntervalHour, nil
+	}
+	return "", fmt.Errorf("invalid interval %q: must be hour or day", s)
+}
+
+// TimeBucket is the number of messages created in the interval starting at Bucket
+type TimeBucket struct {
+	Bucket time.Time `json:"bucket"`
+	Count  int64     `json:"count"`
+}
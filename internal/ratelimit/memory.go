@@ -0,0 +1,78 @@
+// Package ratelimit counts requests per key in fixed one-minute windows,
+// either in process memory or in Redis so that every instance of the service
+// shares one budget.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// window is how long each budget lasts
+const window = time.Minute
+
+// Memory allows each key at most limit requests per window, counted in this
+// process only. Running several instances multiplies the effective limit.
+type Memory struct {
+	mu        sync.Mutex
+	limit     int64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// bucket counts a key's requests in the window starting at start
+type bucket struct {
+	start time.Time
+	count int64
+}
+
+// NewMemory creates a limiter allowing limit requests per minute per key.
+// A non-positive limit disables limiting.
+func NewMemory(limit int64) *Memory {
+	return &Memory{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow records a request for key and reports whether it is within the limit.
+// When it isn't, the returned duration is how long until the key may retry.
+// It never fails.
+func (l *Memory) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	if l.limit <= 0 {
+		return true, 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || now.Sub(b.start) >= window {
+		l.buckets[key] = &bucket{start: now, count: 1}
+		return true, 0, nil
+	}
+	if b.count >= l.limit {
+		return false, b.start.Add(window).Sub(now), nil
+	}
+	b.count++
+	return true, 0, nil
+}
+
+// sweep drops buckets whose window has ended, at most once per window
+func (l *Memory) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < window {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.start) >= window {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory_WindowResets(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	limiter := NewMemory(1)
+	limiter.now = func() time.Time { return now }
+
+	if ok, _, _ := limiter.Allow(ctx, "ip:10.0.0.1"); !ok {
+		t.Fatal("Expected first request to be allowed")
+	}
+	ok, retryAfter, _ := limiter.Allow(ctx, "ip:10.0.0.1")
+	if ok || retryAfter != time.Minute {
+		t.Fatalf("Expected second request rejected with a 1m retry, got %v %v", ok, retryAfter)
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _, _ := limiter.Allow(ctx, "ip:10.0.0.1"); !ok {
+		t.Error("Expected request to be allowed once the window has passed")
+	}
+}
+
+func TestMemory_KeysAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewMemory(2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := limiter.Allow(ctx, "user:1"); !ok {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	if ok, _, _ := limiter.Allow(ctx, "user:1"); ok {
+		t.Error("Expected the third request to be rejected")
+	}
+	if ok, _, _ := limiter.Allow(ctx, "user:2"); !ok {
+		t.Error("Expected another key to have its own budget")
+	}
+}
+
+func TestMemory_ZeroLimitDisables(t *testing.T) {
+	limiter := NewMemory(0)
+	for i := 0; i < 100; i++ {
+		if ok, _, err := limiter.Allow(context.Background(), "user:1"); !ok || err != nil {
+			t.Fatalf("Expected every request to be allowed, got %v %v", ok, err)
+		}
+	}
+}
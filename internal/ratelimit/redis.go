@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix namespaces the service's counters in a shared Redis
+const keyPrefix = "forum:ratelimit:"
+
+// allowScript counts a request against KEYS[1], starting a window of ARGV[1]
+// milliseconds on the first one, and returns the count and the milliseconds
+// left in the window. Running it as a script keeps the two steps atomic, so a
+// crash between them can't leave a counter that never expires.
+var allowScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {count, ttl}
+`)
+
+// Redis allows each key at most limit requests per window, counted in Redis
+// and so shared by every instance pointed at the same server
+type Redis struct {
+	client redis.Scripter
+	name   string
+	limit  int64
+}
+
+// NewRedis creates a limiter allowing limit requests per minute per key,
+// counted in client. Limiters sharing a server need distinct names to keep
+// separate budgets. A non-positive limit disables limiting.
+func NewRedis(client redis.Scripter, name string, limit int64) *Redis {
+	return &Redis{client: client, name: name, limit: limit}
+}
+
+// Allow records a request for key and reports whether it is within the limit.
+// When it isn't, the returned duration is how long until the key may retry.
+func (l *Redis) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	if l.limit <= 0 {
+		return true, 0, nil
+	}
+
+	result, err := allowScript.Run(ctx, l.client, []string{keyPrefix + l.name + ":" + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if count, ttl := result[0], result[1]; count > l.limit {
+		return false, time.Duration(ttl) * time.Millisecond, nil
+	}
+	return true, 0, nil
+}
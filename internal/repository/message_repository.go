@@ -1,86 +1,174 @@
 package repository
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/atmega-p471/forum-service/internal/domain"
 )
 
+// timestampLayout is a fixed-width RFC3339 layout with nanosecond precision.
+// Unlike time.RFC3339Nano it keeps trailing zeros, so stored values sort
+// lexically in the same order as the instants they represent.
+const timestampLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
 // MessageRepository is a message repository
 type MessageRepository struct {
-	db *sql.DB
+	db *timedDB
+	// stmts holds the prepared statements for hot queries, keyed by query text
+	stmts map[string]*sql.Stmt
 }
 
-// NewMessageRepository creates a new message repository
+// NewMessageRepository creates a new message repository. The schema must
+// already exist so that hot queries can be prepared.
 func NewMessageRepository(db *sql.DB) domain.MessageRepository {
+	return newMessageRepository(newTimedDB(db))
+}
+
+// newMessageRepository creates a message repository whose queries are timed by db
+func newMessageRepository(db *timedDB) *MessageRepository {
+	stmts := make(map[string]*sql.Stmt, len(preparedQueries))
+	for _, query := range preparedQueries {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			// Queries that can't be prepared fall back to running ad hoc
+			log.Printf("Failed to prepare statement %q: %v", query, err)
+			continue
+		}
+		stmts[query] = stmt
+	}
+
 	return &MessageRepository{
-		db: db,
+		db:    db,
+		stmts: stmts,
 	}
 }
 
-// GetByID gets a message by ID
-func (r MessageRepository) GetByID(id int64) (*domain.Message, error) {
-	var message domain.Message
-	var createdAt string
-
-	err := r.db.QueryRow("SELECT id, user_id, username, content, created_at, is_banned FROM messages WHERE id = ?", id).
-		Scan(&message.ID, &message.UserID, &message.Username, &message.Content, &createdAt, &message.IsBanned)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("message not found")
+// Close releases the repository's prepared statements
+func (r MessageRepository) Close() error {
+	var errs []error
+	for query, stmt := range r.stmts {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
 		}
-		return nil, err
+		delete(r.stmts, query)
 	}
+	return errors.Join(errs...)
+}
 
-	message.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-	return &message, nil
+// messageColumns lists the columns read by scanMessage, in order
+const messageColumns = "id, user_id, username, content, created_at, is_banned, last_activity_at, version, pinned_at, reply_to_message_id, expires_at, content_html, is_locked, comment_ttl_seconds, is_hidden, slug"
+
+// notExpired filters out ephemeral messages past their expiry; it takes the current time as its argument
+const notExpired = "(expires_at IS NULL OR expires_at > ?)"
+
+// notModerated filters out banned and hidden messages
+const notModerated = "is_banned = 0 AND is_hidden = 0"
+
+// visibleMessages filters the feed down to unbanned, unhidden, unexpired messages; it takes the current time as its argument
+const visibleMessages = notModerated + " AND " + notExpired
+
+// Hot queries, prepared once when the repository is created
+const (
+	getByIDQuery        = "SELECT " + messageColumns + " FROM messages WHERE id = ?"
+	getBySlugQuery      = "SELECT " + messageColumns + " FROM messages WHERE slug = ?"
+	countMessagesQuery  = "SELECT COUNT(*) FROM messages WHERE " + visibleMessages
+	listQuery           = "SELECT " + messageColumns + " FROM messages WHERE " + visibleMessages + " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	listByActivityQuery = "SELECT " + messageColumns + " FROM messages WHERE " + visibleMessages + " ORDER BY last_activity_at DESC, id DESC LIMIT ? OFFSET ?"
+	latestQuery         = "SELECT " + messageColumns + " FROM messages WHERE " + visibleMessages + " ORDER BY created_at DESC, id DESC LIMIT 1"
+	insertCommentQuery  = "INSERT INTO comments (message_id, user_id, username, content, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)"
+	touchMessageQuery   = "UPDATE messages SET last_activity_at = ? WHERE id = ?"
+)
+
+var preparedQueries = []string{getByIDQuery, getBySlugQuery, countMessagesQuery, listQuery, listByActivityQuery, latestQuery, insertCommentQuery, touchMessageQuery}
+
+// queryContext runs query using its prepared statement if there is one
+func (r MessageRepository) queryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if stmt, ok := r.stmts[query]; ok {
+		defer r.db.observe(query, time.Now())
+		return stmt.QueryContext(ctx, args...)
+	}
+	return r.db.QueryContext(ctx, query, args...)
 }
 
-// List gets a list of messages
-func (r MessageRepository) List(limit, offset int64) ([]*domain.Message, int64, error) {
-	// First, get the total count
-	var total int64
-	err := r.db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&total)
-	if err != nil {
-		return nil, 0, err
+// queryRowContext runs query using its prepared statement if there is one
+func (r MessageRepository) queryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if stmt, ok := r.stmts[query]; ok {
+		defer r.db.observe(query, time.Now())
+		return stmt.QueryRowContext(ctx, args...)
 	}
+	return r.db.QueryRowContext(ctx, query, args...)
+}
 
-	// Then, get the messages
-	rows, err := r.db.Query("SELECT id, user_id, username, content, created_at, is_banned FROM messages ORDER BY created_at DESC LIMIT ? OFFSET ?", limit, offset)
-	if err != nil {
-		return nil, 0, err
+// execTx runs query inside tx using its prepared statement if there is one
+func (r MessageRepository) execTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	defer r.db.observe(query, time.Now())
+	if stmt, ok := r.stmts[query]; ok {
+		return tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
 	}
-	defer rows.Close()
+	return tx.ExecContext(ctx, query, args...)
+}
 
-	var messages []*domain.Message
-	for rows.Next() {
-		var message domain.Message
-		var createdAt string
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMessage scans a row selected with messageColumns into a message
+func scanMessage(row rowScanner) (*domain.Message, error) {
+	var message domain.Message
+	var createdAt, lastActivityAt string
+	var pinnedAt sql.NullString
+	var replyTo sql.NullInt64
+	var expiresAt sql.NullString
+
+	err := row.Scan(&message.ID, &message.UserID, &message.Username, &message.Content, &createdAt, &message.IsBanned, &lastActivityAt, &message.Version, &pinnedAt, &replyTo, &expiresAt, &message.ContentHTML, &message.IsLocked, &message.CommentTTLSeconds, &message.IsHidden, &message.Slug)
+	if err != nil {
+		return nil, err
+	}
 
-		err := rows.Scan(&message.ID, &message.UserID, &message.Username, &message.Content, &createdAt, &message.IsBanned)
+	if expiresAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, expiresAt.String)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
+		message.ExpiresAt = &t
+	}
+
+	if replyTo.Valid {
+		message.ReplyToMessageID = &replyTo.Int64
+	}
 
-		message.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
+	if pinnedAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, pinnedAt.String)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
-		messages = append(messages, &message)
+		message.IsPinned = true
+		message.PinnedAt = &t
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, 0, err
+	message.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, err
 	}
-
-	return messages, total, nil
+	message.LastActivityAt, err = time.Parse(time.RFC3339Nano, lastActivityAt)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
 }
 
-// GetAllMessages gets all messages (admin only)
-func (r MessageRepository) GetAllMessages() ([]*domain.Message, error) {
-	rows, err := r.db.Query("SELECT id, user_id, username, content, created_at, is_banned FROM messages ORDER BY created_at DESC")
+// queryMessages runs a query selecting messageColumns and scans all rows
+func (r MessageRepository) queryMessages(ctx context.Context, query string, args ...interface{}) ([]*domain.Message, error) {
+	rows, err := r.queryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,156 +176,1383 @@ func (r MessageRepository) GetAllMessages() ([]*domain.Message, error) {
 
 	var messages []*domain.Message
 	for rows.Next() {
-		var message domain.Message
-		var createdAt string
-
-		err := rows.Scan(&message.ID, &message.UserID, &message.Username, &message.Content, &createdAt, &message.IsBanned)
-		if err != nil {
-			return nil, err
-		}
-
-		message.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
+		message, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
-		messages = append(messages, &message)
+		messages = append(messages, message)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
+	if err := r.attachReplyReferences(ctx, messages); err != nil {
+		return nil, err
+	}
+	if err := r.attachCommentStats(ctx, messages); err != nil {
+		return nil, err
+	}
+
 	return messages, nil
 }
 
-// Create creates a new message
-func (r MessageRepository) Create(message *domain.Message) (int64, error) {
-	message.CreatedAt = time.Now().UTC()
-	res, err := r.db.Exec("INSERT INTO messages (user_id, username, content, created_at, is_banned) VALUES (?, ?, ?, ?, ?)",
-		message.UserID, message.Username, message.Content, message.CreatedAt.Format(time.RFC3339), message.IsBanned)
+// attachCommentStats fills in CommentCount and ParticipantCount, counting
+// only non-expired comments, with one grouped query for the whole page
+func (r MessageRepository) attachCommentStats(ctx context.Context, messages []*domain.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(messages)+1)
+	args = append(args, time.Now().UTC().Format(timestampLayout))
+	byID := make(map[int64]*domain.Message, len(messages))
+	for _, message := range messages {
+		args = append(args, message.ID)
+		byID[message.ID] = message
+	}
+
+	rows, err := r.queryContext(ctx, "SELECT message_id, COUNT(*), COUNT(DISTINCT user_id) FROM comments WHERE expires_at > ? AND message_id IN ("+placeholders(len(messages))+") GROUP BY message_id", args...)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return res.LastInsertId()
-}
+	defer rows.Close()
 
-// Ban bans a message
-func (r MessageRepository) Ban(id int64) error {
-	_, err := r.db.Exec("UPDATE messages SET is_banned = 1 WHERE id = ?", id)
-	return err
+	for rows.Next() {
+		var messageID, comments, participants int64
+		if err := rows.Scan(&messageID, &comments, &participants); err != nil {
+			return err
+		}
+		if message, ok := byID[messageID]; ok {
+			message.CommentCount = comments
+			message.ParticipantCount = participants
+		}
+	}
+	return rows.Err()
 }
 
-// Unban unbans a message
-func (r MessageRepository) Unban(id int64) error {
-	_, err := r.db.Exec("UPDATE messages SET is_banned = 0 WHERE id = ?", id)
-	return err
+// attachReplyReferences fills in ReplyTo for messages that quote another
+// message. Quoted messages that have since been banned are left out.
+func (r MessageRepository) attachReplyReferences(ctx context.Context, messages []*domain.Message) error {
+	var ids []interface{}
+	for _, message := range messages {
+		if message.ReplyToMessageID != nil {
+			ids = append(ids, *message.ReplyToMessageID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, username, content FROM messages WHERE "+notModerated+" AND id IN ("+placeholders(len(ids))+")", ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	references := make(map[int64]*domain.MessageReference)
+	for rows.Next() {
+		var quoted domain.Message
+		if err := rows.Scan(&quoted.ID, &quoted.Username, &quoted.Content); err != nil {
+			return err
+		}
+		references[quoted.ID] = domain.NewMessageReference(&quoted)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if message.ReplyToMessageID != nil {
+			message.ReplyTo = references[*message.ReplyToMessageID]
+		}
+	}
+	return nil
 }
 
-// CreateComment creates a new comment
-func (r MessageRepository) CreateComment(comment *domain.Comment) (int64, error) {
-	// First check if the message exists
-	_, err := r.GetByID(comment.MessageID)
+// GetByID gets a message by ID
+func (r MessageRepository) GetByID(ctx context.Context, id int64) (*domain.Message, error) {
+	message, err := scanMessage(r.queryRowContext(ctx, getByIDQuery, id))
 	if err != nil {
-		return 0, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("message %d: %w", id, domain.ErrMessageNotFound)
+		}
+		return nil, err
 	}
 
-	comment.CreatedAt = time.Now().UTC()
-	comment.ExpiresAt = comment.CreatedAt.Add(5 * time.Minute) // Comments expire after 5 minutes
+	if err := r.attachReplyReferences(ctx, []*domain.Message{message}); err != nil {
+		return nil, err
+	}
 
-	res, err := r.db.Exec("INSERT INTO comments (message_id, user_id, username, content, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
-		comment.MessageID, comment.UserID, comment.Username, comment.Content,
-		comment.CreatedAt.Format(time.RFC3339), comment.ExpiresAt.Format(time.RFC3339))
+	return message, nil
+}
+
+// GetBySlug gets a message by its slug
+func (r MessageRepository) GetBySlug(ctx context.Context, slug string) (*domain.Message, error) {
+	message, err := scanMessage(r.queryRowContext(ctx, getBySlugQuery, slug))
 	if err != nil {
-		return 0, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("message %q: %w", slug, domain.ErrMessageNotFound)
+		}
+		return nil, err
+	}
+
+	if err := r.attachReplyReferences(ctx, []*domain.Message{message}); err != nil {
+		return nil, err
 	}
-	return res.LastInsertId()
+
+	return message, nil
 }
 
-// GetComments gets all comments for a message (excluding expired ones)
-func (r MessageRepository) GetComments(messageID int64) ([]*domain.Comment, error) {
-	// First check if the message exists
-	_, err := r.GetByID(messageID)
+// Latest gets the newest message in the feed. It returns
+// domain.ErrMessageNotFound when the feed is empty.
+func (r MessageRepository) Latest(ctx context.Context) (*domain.Message, error) {
+	message, err := scanMessage(r.queryRowContext(ctx, latestQuery, time.Now().UTC().Format(timestampLayout)))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("latest message: %w", domain.ErrMessageNotFound)
+		}
 		return nil, err
 	}
+	return message, nil
+}
 
-	// Only get comments that haven't expired yet
-	now := time.Now().UTC()
-	rows, err := r.db.Query("SELECT id, message_id, user_id, username, content, created_at, expires_at FROM comments WHERE message_id = ? AND expires_at > ? ORDER BY created_at ASC", messageID, now.Format(time.RFC3339))
+// GetByIDs gets messages by their IDs, preserving the order of ids.
+// IDs that don't exist are skipped.
+func (r MessageRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Message, error) {
+	if len(ids) == 0 {
+		return []*domain.Message{}, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	rows, err := r.queryMessages(ctx, "SELECT "+messageColumns+" FROM messages WHERE id IN ("+placeholders(len(ids))+")", args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var comments []*domain.Comment
-	for rows.Next() {
-		var comment domain.Comment
-		var createdAt, expiresAt string
+	found := make(map[int64]*domain.Message, len(rows))
+	for _, message := range rows {
+		found[message.ID] = message
+	}
 
-		err := rows.Scan(&comment.ID, &comment.MessageID, &comment.UserID, &comment.Username, &comment.Content, &createdAt, &expiresAt)
-		if err != nil {
-			return nil, err
+	messages := make([]*domain.Message, 0, len(found))
+	for _, id := range ids {
+		if message, ok := found[id]; ok {
+			messages = append(messages, message)
 		}
+	}
 
-		comment.CreatedAt, err = time.Parse(time.RFC3339, createdAt)
-		if err != nil {
-			return nil, err
-		}
-		comment.ExpiresAt, err = time.Parse(time.RFC3339, expiresAt)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, &comment)
+	return messages, nil
+}
+
+// List gets a list of unbanned messages, newest first
+func (r MessageRepository) List(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	return r.list(ctx, listQuery, limit, offset)
+}
+
+// ListByActivity gets a list of unbanned messages, most recently active first
+func (r MessageRepository) ListByActivity(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	return r.list(ctx, listByActivityQuery, limit, offset)
+}
+
+// list runs a paged query over visible messages and returns the page along with the total count
+func (r MessageRepository) list(ctx context.Context, query string, limit, offset int64) ([]*domain.Message, int64, error) {
+	now := time.Now().UTC().Format(timestampLayout)
+
+	// First, get the total count
+	var total int64
+	err := r.queryRowContext(ctx, countMessagesQuery, now).Scan(&total)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	// Then, get the messages
+	messages, err := r.queryMessages(ctx, query, now, limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return comments, nil
+	return messages, total, nil
 }
 
-// Delete deletes a message completely (admin only)
-func (r MessageRepository) Delete(id int64) error {
-	// First delete all comments for this message
-	_, err := r.db.Exec("DELETE FROM comments WHERE message_id = ?", id)
+// ListTrending gets a page of visible messages with comments posted since
+// since, most such comments first, along with the total count
+func (r MessageRepository) ListTrending(ctx context.Context, since time.Time, limit, offset int64) ([]*domain.Message, int64, error) {
+	now := time.Now().UTC().Format(timestampLayout)
+	recent := "(SELECT message_id, COUNT(*) AS recent_comments FROM comments WHERE created_at >= ? GROUP BY message_id) recent" +
+		" JOIN messages ON messages.id = recent.message_id WHERE " + visibleMessages
+	cutoff := since.UTC().Format(timestampLayout)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+recent, cutoff, now).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	messages, err := r.queryMessages(ctx, "SELECT "+messageColumns+" FROM "+recent+
+		" ORDER BY recent.recent_comments DESC, last_activity_at DESC, id DESC LIMIT ? OFFSET ?", cutoff, now, limit, offset)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	// Then delete the message
-	_, err = r.db.Exec("DELETE FROM messages WHERE id = ?", id)
-	return err
+	return messages, total, nil
 }
 
-// GetCommentByID gets a comment by ID
-func (r MessageRepository) GetCommentByID(id int64) (*domain.Comment, error) {
-	var comment domain.Comment
-	var createdAt, expiresAt string
+// likeEscaper escapes LIKE wildcards so search queries match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
 
-	err := r.db.QueryRow("SELECT id, message_id, user_id, username, content, created_at, expires_at FROM comments WHERE id = ?", id).
-		Scan(&comment.ID, &comment.MessageID, &comment.UserID, &comment.Username, &comment.Content, &createdAt, &expiresAt)
+// Search gets a page of visible messages whose content contains query,
+// ignoring ASCII case, newest first, along with the total count
+func (r MessageRepository) Search(ctx context.Context, query string, limit, offset int64) ([]*domain.Message, int64, error) {
+	now := time.Now().UTC().Format(timestampLayout)
+	pattern := "%" + likeEscaper.Replace(query) + "%"
+
+	var total int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages WHERE "+visibleMessages+` AND content LIKE ? ESCAPE '\'`,
+		now, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	messages, err := r.queryMessages(ctx, "SELECT "+messageColumns+" FROM messages WHERE "+visibleMessages+
+		` AND content LIKE ? ESCAPE '\' ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`, now, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+// ListCreatedBetween gets a page of visible messages created between from
+// and to inclusive, newest first, along with the total count. A zero from or
+// to leaves that end of the range open.
+func (r MessageRepository) ListCreatedBetween(ctx context.Context, from, to time.Time, limit, offset int64) ([]*domain.Message, int64, error) {
+	where := visibleMessages
+	args := []interface{}{time.Now().UTC().Format(timestampLayout)}
+	if !from.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, from.UTC().Format(timestampLayout))
+	}
+	if !to.IsZero() {
+		where += " AND created_at <= ?"
+		args = append(args, to.UTC().Format(timestampLayout))
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	messages, err := r.queryMessages(ctx, "SELECT "+messageColumns+" FROM messages WHERE "+where+
+		" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+// bucketFormats are the strftime formats that truncate created_at to the
+// start of each stats interval, as RFC3339
+var bucketFormats = map[domain.StatsInterval]string{
+	domain.IntervalHour: "%Y-%m-%dT%H:00:00Z",
+	domain.IntervalDay:  "%Y-%m-%dT00:00:00Z",
+}
+
+// CountByInterval counts every message ever created, banned or not, in
+// buckets of interval, oldest first. Intervals without messages are left out.
+func (r MessageRepository) CountByInterval(ctx context.Context, interval domain.StatsInterval) ([]domain.TimeBucket, error) {
+	format, ok := bucketFormats[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT strftime(?, created_at) AS bucket, COUNT(*) FROM messages GROUP BY bucket ORDER BY bucket", format)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("comment not found")
-		}
 		return nil, err
 	}
+	defer rows.Close()
 
-	comment.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-	comment.ExpiresAt, _ = time.Parse(time.RFC3339, expiresAt)
-	return &comment, nil
+	buckets := []domain.TimeBucket{}
+	for rows.Next() {
+		var bucket string
+		var count int64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		start, err := time.Parse(time.RFC3339, bucket)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, domain.TimeBucket{Bucket: start, Count: count})
+	}
+	return buckets, rows.Err()
 }
 
-// DeleteComment deletes a comment completely (admin only)
-func (r MessageRepository) DeleteComment(id int64) error {
-	_, err := r.db.Exec("DELETE FROM comments WHERE id = ?", id)
-	return err
+// GetAllMessages gets all messages (admin only)
+func (r MessageRepository) GetAllMessages(ctx context.Context) ([]*domain.Message, error) {
+	return r.queryMessages(ctx, "SELECT "+messageColumns+" FROM messages ORDER BY created_at DESC, id DESC")
 }
 
-// DeleteExpiredComments deletes all expired comments
-func (r MessageRepository) DeleteExpiredComments() error {
-	now := time.Now().UTC()
-	_, err := r.db.Exec("DELETE FROM comments WHERE expires_at <= ?", now.Format(time.RFC3339))
-	return err
+// Create creates a new message. The repository is the only place CreatedAt
+// is set, always in UTC.
+func (r MessageRepository) Create(ctx context.Context, message *domain.Message) (int64, error) {
+	message.CreatedAt = time.Now().UTC()
+	message.LastActivityAt = message.CreatedAt
+	message.Version = 1
+
+	var expiresAt interface{}
+	if message.ExpiresAt != nil {
+		expiresAt = message.ExpiresAt.UTC().Format(timestampLayout)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO messages (user_id, username, content, created_at, is_banned, last_activity_at, version, reply_to_message_id, expires_at, content_html, comment_ttl_seconds, content_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		message.UserID, message.Username, message.Content, message.CreatedAt.Format(timestampLayout), message.IsBanned,
+		message.LastActivityAt.Format(timestampLayout), message.Version, message.ReplyToMessageID, expiresAt, message.ContentHTML, message.CommentTTLSeconds, contentHash(message.Content))
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	// The slug leads with the ID, so it can only be built once the row exists
+	slug := domain.MessageSlug(id, message.Content)
+	if _, err := tx.ExecContext(ctx, "UPDATE messages SET slug = ? WHERE id = ?", slug, id); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	message.Slug = slug
+	return id, nil
+}
+
+// contentHash is the indexed digest of a message's content used to find duplicates
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasRecentDuplicate reports whether userID has posted a message with exactly
+// content at or after since
+func (r MessageRepository) HasRecentDuplicate(ctx context.Context, userID int64, content string, since time.Time) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM messages WHERE user_id = ? AND content_hash = ? AND created_at >= ? AND content = ?)",
+		userID, contentHash(content), since.UTC().Format(timestampLayout), content).Scan(&exists)
+	return exists, err
+}
+
+// Update replaces a message's content if its current version matches
+// expectedVersion, and returns the new version. It returns
+// domain.ErrVersionConflict if the message was edited in the meantime.
+func (r MessageRepository) Update(ctx context.Context, id int64, content, contentHTML string, expectedVersion int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE messages SET content = ?, content_html = ?, content_hash = ?, version = version + 1 WHERE id = ? AND version = ?",
+		content, contentHTML, contentHash(content), id, expectedVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		// Distinguish a missing message from a stale version
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return 0, err
+		}
+		return 0, domain.ErrVersionConflict
+	}
+
+	return expectedVersion + 1, nil
+}
+
+// Ban bans a message
+func (r MessageRepository) Ban(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET is_banned = 1 WHERE id = ?", id)
+	return err
+}
+
+// Unban unbans a message
+func (r MessageRepository) Unban(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET is_banned = 0 WHERE id = ?", id)
+	return err
+}
+
+// Pin pins a message. Pinning an already pinned message keeps its original pin time.
+func (r MessageRepository) Pin(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET pinned_at = ? WHERE id = ? AND pinned_at IS NULL",
+		time.Now().UTC().Format(timestampLayout), id)
+	return err
+}
+
+// Unpin unpins a message
+func (r MessageRepository) Unpin(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET pinned_at = NULL WHERE id = ?", id)
+	return err
+}
+
+// Lock closes a message to new comments
+func (r MessageRepository) Lock(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET is_locked = 1 WHERE id = ?", id)
+	return err
+}
+
+// Unlock reopens a message to new comments
+func (r MessageRepository) Unlock(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET is_locked = 0 WHERE id = ?", id)
+	return err
+}
+
+// Hide hides a message at its author's request
+func (r MessageRepository) Hide(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET is_hidden = 1 WHERE id = ?", id)
+	return err
+}
+
+// Unhide makes a hidden message visible again
+func (r MessageRepository) Unhide(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE messages SET is_hidden = 0 WHERE id = ?", id)
+	return err
+}
+
+// ListByUser gets all of a user's messages, including banned ones, newest first
+func (r MessageRepository) ListByUser(ctx context.Context, userID int64) ([]*domain.Message, error) {
+	return r.queryMessages(ctx, "SELECT "+messageColumns+" FROM messages WHERE user_id = ? ORDER BY created_at DESC, id DESC", userID)
+}
+
+// ListByUserPage gets a page of a user's unexpired messages, including banned
+// ones, newest first, along with the total count
+func (r MessageRepository) ListByUserPage(ctx context.Context, userID, limit, offset int64) ([]*domain.Message, int64, error) {
+	now := time.Now().UTC().Format(timestampLayout)
+
+	var total int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages WHERE user_id = ? AND "+notExpired, userID, now).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	messages, err := r.queryMessages(ctx, "SELECT "+messageColumns+" FROM messages WHERE user_id = ? AND "+notExpired+
+		" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", userID, now, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+// BanMessagesByUser bans all of a user's messages and returns how many changed
+func (r MessageRepository) BanMessagesByUser(ctx context.Context, userID int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE messages SET is_banned = 1 WHERE user_id = ? AND is_banned = 0", userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// BanByIDs bans the given messages and returns how many weren't banned already
+func (r MessageRepository) BanByIDs(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	res, err := r.db.ExecContext(ctx, "UPDATE messages SET is_banned = 1 WHERE is_banned = 0 AND id IN ("+placeholders(len(ids))+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UnbanByIDs unbans the given messages in one transaction and returns the
+// IDs that exist, in the order given. Missing IDs are skipped.
+func (r MessageRepository) UnbanByIDs(ctx context.Context, ids []int64) ([]int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found []int64
+	for _, id := range ids {
+		res, err := tx.ExecContext(ctx, "UPDATE messages SET is_banned = 0 WHERE id = ?", id)
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			found = append(found, id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// UnbanMessagesByUser unbans all of a user's messages and returns how many changed
+func (r MessageRepository) UnbanMessagesByUser(ctx context.Context, userID int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE messages SET is_banned = 0 WHERE user_id = ? AND is_banned = 1", userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListPinned gets all pinned, non-banned, unexpired messages in the order they were pinned
+func (r MessageRepository) ListPinned(ctx context.Context) ([]*domain.Message, error) {
+	return r.queryMessages(ctx, "SELECT "+messageColumns+" FROM messages WHERE pinned_at IS NOT NULL AND "+visibleMessages+" ORDER BY pinned_at ASC, id ASC",
+		time.Now().UTC().Format(timestampLayout))
+}
+
+// CreateComment creates a new comment and bumps the message's last activity.
+// A zero ExpiresAt is filled in from the message's comment TTL.
+func (r MessageRepository) CreateComment(ctx context.Context, comment *domain.Comment) (int64, error) {
+	return r.createComment(ctx, comment, true)
+}
+
+// CreateCommentWithoutBump creates a new comment like CreateComment but leaves
+// the message's last activity alone, so old threads don't rise in sort=active
+func (r MessageRepository) CreateCommentWithoutBump(ctx context.Context, comment *domain.Comment) (int64, error) {
+	return r.createComment(ctx, comment, false)
+}
+
+// createComment inserts a comment, bumping its message's last activity if bump is set
+func (r MessageRepository) createComment(ctx context.Context, comment *domain.Comment, bump bool) (int64, error) {
+	// First check if the message exists and is open for comments
+	message, err := r.GetByID(ctx, comment.MessageID)
+	if err != nil {
+		return 0, err
+	}
+	if message.IsLocked {
+		return 0, domain.ErrThreadLocked
+	}
+
+	comment.CreatedAt = time.Now().UTC()
+	if comment.ExpiresAt.IsZero() {
+		// Comments expire after the message's comment TTL, or 5 minutes by default
+		comment.ExpiresAt = comment.CreatedAt.Add(message.CommentLifetime(5 * time.Minute))
+	}
+	comment.ExpiresAt = comment.ExpiresAt.UTC()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := r.execTx(ctx, tx, insertCommentQuery,
+		comment.MessageID, comment.UserID, comment.Username, comment.Content,
+		comment.CreatedAt.Format(timestampLayout), comment.ExpiresAt.Format(timestampLayout))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if bump {
+		_, err = r.execTx(ctx, tx, touchMessageQuery, comment.CreatedAt.Format(timestampLayout), comment.MessageID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// maxCommentsPerInsert keeps multi-row inserts well under SQLite's bound parameter limit
+const maxCommentsPerInsert = 100
+
+// CreateComments inserts a batch of comments in a single transaction and
+// returns their IDs in input order, bumping the last activity of the bumped
+// messages. Nothing is written if any comment references a missing or locked
+// message or an insert fails. Comments without an expiry get their message's
+// comment TTL, as in CreateComment.
+func (r MessageRepository) CreateComments(ctx context.Context, comments []*domain.Comment, bumped []int64) ([]int64, error) {
+	if len(comments) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Every referenced message must exist
+	var messageIDs []interface{}
+	seen := make(map[int64]bool)
+	for _, comment := range comments {
+		if !seen[comment.MessageID] {
+			seen[comment.MessageID] = true
+			messageIDs = append(messageIDs, comment.MessageID)
+		}
+	}
+
+	found, err := tx.QueryContext(ctx, "SELECT id, is_locked, comment_ttl_seconds FROM messages WHERE id IN ("+placeholders(len(messageIDs))+")", messageIDs...)
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[int64]*domain.Message, len(messageIDs))
+	for found.Next() {
+		message := &domain.Message{}
+		if err := found.Scan(&message.ID, &message.IsLocked, &message.CommentTTLSeconds); err != nil {
+			found.Close()
+			return nil, err
+		}
+		targets[message.ID] = message
+	}
+	found.Close()
+	if err := found.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) != len(messageIDs) {
+		return nil, fmt.Errorf("comment target: %w", domain.ErrMessageNotFound)
+	}
+	for _, message := range targets {
+		if message.IsLocked {
+			return nil, fmt.Errorf("comment target: %w", domain.ErrThreadLocked)
+		}
+	}
+
+	now := time.Now().UTC()
+	ids := make([]int64, 0, len(comments))
+	for start := 0; start < len(comments); start += maxCommentsPerInsert {
+		end := start + maxCommentsPerInsert
+		if end > len(comments) {
+			end = len(comments)
+		}
+		batch := comments[start:end]
+
+		rows := make([]string, 0, len(batch))
+		args := make([]interface{}, 0, len(batch)*6)
+		for _, comment := range batch {
+			comment.CreatedAt = now
+			if comment.ExpiresAt.IsZero() {
+				// Comments expire after the message's comment TTL, or 5 minutes by default
+				comment.ExpiresAt = now.Add(targets[comment.MessageID].CommentLifetime(5 * time.Minute))
+			}
+			rows = append(rows, "(?, ?, ?, ?, ?, ?)")
+			args = append(args, comment.MessageID, comment.UserID, comment.Username, comment.Content,
+				comment.CreatedAt.Format(timestampLayout), comment.ExpiresAt.Format(timestampLayout))
+		}
+
+		res, err := tx.ExecContext(ctx, "INSERT INTO comments (message_id, user_id, username, content, created_at, expires_at) VALUES "+strings.Join(rows, ", "), args...)
+		if err != nil {
+			return nil, err
+		}
+
+		// A single INSERT assigns consecutive rowids, ending at the last insert ID
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		for i, comment := range batch {
+			comment.ID = lastID - int64(len(batch)-1-i)
+			ids = append(ids, comment.ID)
+		}
+	}
+
+	if len(bumped) > 0 {
+		args := []interface{}{now.Format(timestampLayout)}
+		for _, id := range bumped {
+			args = append(args, id)
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE messages SET last_activity_at = ? WHERE id IN ("+placeholders(len(bumped))+")", args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetComments gets all comments for a message, oldest first unless order is
+// domain.SortDesc. Expired comments are only included with includeExpired.
+func (r MessageRepository) GetComments(ctx context.Context, messageID int64, order domain.SortOrder, includeExpired bool) ([]*domain.Comment, error) {
+	// First check if the message exists
+	_, err := r.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	where := "message_id = ?"
+	args := []interface{}{messageID}
+	if !includeExpired {
+		where += " AND expires_at > ?"
+		args = append(args, time.Now().UTC().Format(timestampLayout))
+	}
+	orderBy := "created_at ASC, id ASC"
+	if order == domain.SortDesc {
+		orderBy = "created_at DESC, id DESC"
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT id, message_id, user_id, username, content, created_at, expires_at FROM comments WHERE "+where+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []*domain.Comment
+	for rows.Next() {
+		var comment domain.Comment
+		var createdAt, expiresAt string
+
+		err := rows.Scan(&comment.ID, &comment.MessageID, &comment.UserID, &comment.Username, &comment.Content, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, err
+		}
+
+		comment.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		comment.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// ListCommentsByUser gets a page of a user's comments, newest first, along
+// with the total count. Expired comments that haven't been cleaned up yet are
+// only included with includeExpired.
+func (r MessageRepository) ListCommentsByUser(ctx context.Context, userID, limit, offset int64, includeExpired bool) ([]*domain.Comment, int64, error) {
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	if !includeExpired {
+		where += " AND expires_at > ?"
+		args = append(args, time.Now().UTC().Format(timestampLayout))
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM comments WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, message_id, user_id, username, content, created_at, expires_at FROM comments WHERE "+where+
+		" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []*domain.Comment{}
+	for rows.Next() {
+		var comment domain.Comment
+		var createdAt, expiresAt string
+
+		err := rows.Scan(&comment.ID, &comment.MessageID, &comment.UserID, &comment.Username, &comment.Content, &createdAt, &expiresAt)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		comment.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		comment.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		comments = append(comments, &comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return comments, total, nil
+}
+
+// ListRecentComments gets the newest comments across all messages, each with
+// a summary of the message it is on, along with the total count
+func (r MessageRepository) ListRecentComments(ctx context.Context, limit, offset int64, includeExpired bool) ([]*domain.Comment, int64, error) {
+	from := " FROM comments c JOIN messages m ON m.id = c.message_id"
+	var args []interface{}
+	if !includeExpired {
+		from += " WHERE c.expires_at > ?"
+		args = append(args, time.Now().UTC().Format(timestampLayout))
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT c.id, c.message_id, c.user_id, c.username, c.content, c.created_at, c.expires_at, m.username, m.content"+
+		from+" ORDER BY c.created_at DESC, c.id DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	comments := []*domain.Comment{}
+	for rows.Next() {
+		var comment domain.Comment
+		var createdAt, expiresAt string
+		message := domain.Message{}
+
+		err := rows.Scan(&comment.ID, &comment.MessageID, &comment.UserID, &comment.Username, &comment.Content, &createdAt, &expiresAt,
+			&message.Username, &message.Content)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		comment.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		comment.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		message.ID = comment.MessageID
+		comment.Message = domain.NewMessageReference(&message)
+		comments = append(comments, &comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return comments, total, nil
+}
+
+// Delete deletes a message completely (admin only)
+func (r MessageRepository) Delete(ctx context.Context, id int64) error {
+	// First delete all comments for this message
+	_, err := r.db.ExecContext(ctx, "DELETE FROM comments WHERE message_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	// Then delete the message
+	_, err = r.db.ExecContext(ctx, "DELETE FROM messages WHERE id = ?", id)
+	return err
+}
+
+// GetCommentByID gets a comment by ID
+func (r MessageRepository) GetCommentByID(ctx context.Context, id int64) (*domain.Comment, error) {
+	var comment domain.Comment
+	var createdAt, expiresAt string
+
+	err := r.db.QueryRowContext(ctx, "SELECT id, message_id, user_id, username, content, created_at, expires_at FROM comments WHERE id = ?", id).
+		Scan(&comment.ID, &comment.MessageID, &comment.UserID, &comment.Username, &comment.Content, &createdAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("comment %d: %w", id, domain.ErrCommentNotFound)
+		}
+		return nil, err
+	}
+
+	comment.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	comment.ExpiresAt, _ = time.Parse(time.RFC3339Nano, expiresAt)
+	return &comment, nil
+}
+
+// UpdateComment replaces a comment's content and expiry, returning
+// ErrCommentNotFound if there was no such comment
+func (r MessageRepository) UpdateComment(ctx context.Context, id int64, content string, expiresAt time.Time) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE comments SET content = ?, expires_at = ? WHERE id = ?",
+		content, expiresAt.UTC().Format(timestampLayout), id)
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("comment %d: %w", id, domain.ErrCommentNotFound)
+	}
+	return nil
+}
+
+// DeleteComment deletes a comment completely (admin only), returning
+// ErrCommentNotFound if there was no such comment
+func (r MessageRepository) DeleteComment(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM comments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("comment %d: %w", id, domain.ErrCommentNotFound)
+	}
+	return nil
+}
+
+// DeleteExpiredComments deletes all expired comments
+func (r MessageRepository) DeleteExpiredComments(ctx context.Context) error {
+	now := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx, "DELETE FROM comments WHERE expires_at <= ?", now.Format(timestampLayout))
+	return err
+}
+
+// UpdateUsername rewrites the denormalized username on all of a user's
+// messages and comments and returns the number of rows updated
+func (r MessageRepository) UpdateUsername(ctx context.Context, userID int64, newUsername string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var updated int64
+	for _, query := range []string{
+		"UPDATE messages SET username = ? WHERE user_id = ?",
+		"UPDATE comments SET username = ? WHERE user_id = ?",
+	} {
+		res, err := tx.ExecContext(ctx, query, newUsername, userID)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		updated += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// DeleteExpiredMessages deletes all expired ephemeral messages along with
+// their comments and returns the number of messages deleted
+func (r MessageRepository) DeleteExpiredMessages(ctx context.Context) (int64, error) {
+	now := time.Now().UTC().Format(timestampLayout)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE message_id IN (SELECT id FROM messages WHERE expires_at <= ?)", now)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// PurgeBanned permanently deletes all banned messages, along with their
+// comments, and returns the number of messages deleted
+func (r MessageRepository) PurgeBanned(ctx context.Context) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE message_id IN (SELECT id FROM messages WHERE is_banned = 1)")
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE is_banned = 1")
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// trashedMessageColumns are the message columns kept in the trash: those read
+// by scanMessage plus the content hash duplicate detection relies on
+const trashedMessageColumns = messageColumns + ", content_hash"
+
+// trashedCommentColumns are the comment columns kept in the trash
+const trashedCommentColumns = "id, message_id, user_id, username, content, created_at, expires_at"
+
+// Trash moves a message and its comments into the trash, where Restore can
+// bring them back until PurgeTrash removes them for good. Every column in
+// trashedMessageColumns must also exist in deleted_messages.
+func (r MessageRepository) Trash(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO deleted_messages ("+trashedMessageColumns+", deleted_at) SELECT "+trashedMessageColumns+", ? FROM messages WHERE id = ?",
+		time.Now().UTC().Format(timestampLayout), id)
+	if err != nil {
+		return err
+	}
+	if moved, err := res.RowsAffected(); err != nil {
+		return err
+	} else if moved == 0 {
+		return fmt.Errorf("message %d: %w", id, domain.ErrMessageNotFound)
+	}
+
+	for _, statement := range []string{
+		"INSERT INTO deleted_comments (" + trashedCommentColumns + ") SELECT " + trashedCommentColumns + " FROM comments WHERE message_id = ?",
+		"DELETE FROM comments WHERE message_id = ?",
+		"DELETE FROM messages WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, statement, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Restore moves a message trashed at or after deletedSince, and its comments,
+// back out of the trash. Messages trashed earlier are reported as not found.
+func (r MessageRepository) Restore(ctx context.Context, id int64, deletedSince time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO messages ("+trashedMessageColumns+") SELECT "+trashedMessageColumns+" FROM deleted_messages WHERE id = ? AND deleted_at >= ?",
+		id, deletedSince.UTC().Format(timestampLayout))
+	if err != nil {
+		return err
+	}
+	if restored, err := res.RowsAffected(); err != nil {
+		return err
+	} else if restored == 0 {
+		return fmt.Errorf("deleted message %d: %w", id, domain.ErrMessageNotFound)
+	}
+
+	for _, statement := range []string{
+		"INSERT INTO comments (" + trashedCommentColumns + ") SELECT " + trashedCommentColumns + " FROM deleted_comments WHERE message_id = ?",
+		"DELETE FROM deleted_comments WHERE message_id = ?",
+		"DELETE FROM deleted_messages WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, statement, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PurgeTrash permanently deletes messages trashed before t, along with their
+// comments, and returns the number of messages deleted
+func (r MessageRepository) PurgeTrash(ctx context.Context, t time.Time) (int64, error) {
+	cutoff := t.UTC().Format(timestampLayout)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM deleted_comments WHERE message_id IN (SELECT id FROM deleted_messages WHERE deleted_at < ?)", cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM deleted_messages WHERE deleted_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// DeleteMessagesOlderThan deletes all messages created before t, along with
+// their comments, and returns the number of messages deleted
+func (r MessageRepository) DeleteMessagesOlderThan(ctx context.Context, t time.Time) (int64, error) {
+	cutoff := t.UTC().Format(timestampLayout)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM comments WHERE message_id IN (SELECT id FROM messages WHERE created_at < ?)", cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM messages WHERE created_at < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// placeholders returns a comma-separated list of n SQL placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// FindUserIDsByUsernames looks up which user IDs have posted under each of
+// the given usernames. A username can map to several IDs if it was reused
+// after a rename, so callers should confirm against the auth service.
+func (r MessageRepository) FindUserIDsByUsernames(ctx context.Context, usernames []string) (map[string][]int64, error) {
+	result := make(map[string][]int64)
+	if len(usernames) == 0 {
+		return result, nil
+	}
+
+	args := make([]interface{}, 0, 2*len(usernames))
+	for _, username := range usernames {
+		args = append(args, username)
+	}
+	args = append(args, args...)
+
+	in := placeholders(len(usernames))
+	rows, err := r.db.QueryContext(ctx, "SELECT username, user_id FROM messages WHERE user_id != 0 AND username IN ("+in+")"+
+		" UNION SELECT username, user_id FROM comments WHERE user_id != 0 AND username IN ("+in+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var username string
+		var userID int64
+		if err := rows.Scan(&username, &userID); err != nil {
+			return nil, err
+		}
+		result[username] = append(result[username], userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CreateMentions stores mentions, ignoring any already recorded for the same source and user
+func (r MessageRepository) CreateMentions(ctx context.Context, mentions []*domain.Mention) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, mention := range mentions {
+		if mention.CreatedAt.IsZero() {
+			mention.CreatedAt = time.Now().UTC()
+		}
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO mentions (source_type, source_id, mentioned_user_id, created_at) VALUES (?, ?, ?, ?)",
+			mention.SourceType, mention.SourceID, mention.MentionedUserID, mention.CreatedAt.Format(timestampLayout))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// visibleMentionsFilter restricts mentions to sources that still exist and aren't banned or hidden
+const visibleMentionsFilter = `mentioned_user_id = ? AND (
+	(source_type = 'message' AND source_id IN (SELECT id FROM messages WHERE ` + notModerated + `)) OR
+	(source_type = 'comment' AND source_id IN (SELECT id FROM comments)))`
+
+// ListMentions gets the mentions of a user, newest first, along with the total count
+func (r MessageRepository) ListMentions(ctx context.Context, userID, limit, offset int64) ([]*domain.Mention, int64, error) {
+	var total int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mentions WHERE "+visibleMentionsFilter, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, source_type, source_id, mentioned_user_id, created_at FROM mentions WHERE "+
+		visibleMentionsFilter+" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var mentions []*domain.Mention
+	for rows.Next() {
+		var mention domain.Mention
+		var createdAt string
+
+		err := rows.Scan(&mention.ID, &mention.SourceType, &mention.SourceID, &mention.MentionedUserID, &createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		mention.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		mentions = append(mentions, &mention)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return mentions, total, nil
+}
+
+// MarkRead records that a user has read every message up to and including
+// messageID. The read marker never moves backwards.
+func (r MessageRepository) MarkRead(ctx context.Context, userID, messageID int64) error {
+	_, err := r.db.ExecContext(ctx, `INSERT INTO message_reads (user_id, last_read_message_id, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			last_read_message_id = MAX(last_read_message_id, excluded.last_read_message_id),
+			updated_at = excluded.updated_at`,
+		userID, messageID, time.Now().UTC().Format(timestampLayout))
+	return err
+}
+
+// CountUnread counts visible messages by other users posted after the user's read marker
+func (r MessageRepository) CountUnread(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages
+		WHERE `+notModerated+` AND user_id != ?
+		AND id > COALESCE((SELECT last_read_message_id FROM message_reads WHERE user_id = ?), 0)`,
+		userID, userID).Scan(&count)
+	return count, err
+}
+
+// Subscribe subscribes a user to new comments on a message. Subscribing twice is a no-op.
+func (r MessageRepository) Subscribe(ctx context.Context, userID, messageID int64) error {
+	_, err := r.db.ExecContext(ctx, "INSERT OR IGNORE INTO subscriptions (user_id, message_id, created_at) VALUES (?, ?, ?)",
+		userID, messageID, time.Now().UTC().Format(timestampLayout))
+	return err
+}
+
+// Unsubscribe removes a user's subscription to a message, if any
+func (r MessageRepository) Unsubscribe(ctx context.Context, userID, messageID int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM subscriptions WHERE user_id = ? AND message_id = ?", userID, messageID)
+	return err
+}
+
+// CreateCommentNotifications notifies every subscriber of the comment's
+// message except its author, and returns how many were notified
+func (r MessageRepository) CreateCommentNotifications(ctx context.Context, comment *domain.Comment) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `INSERT INTO notifications (user_id, message_id, comment_id, created_at)
+		SELECT user_id, message_id, ?, ? FROM subscriptions WHERE message_id = ? AND user_id != ?`,
+		comment.ID, time.Now().UTC().Format(timestampLayout), comment.MessageID, comment.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// visibleNotificationsFilter restricts notifications to comments that still
+// exist on messages that aren't banned or hidden
+const visibleNotificationsFilter = `user_id = ? AND comment_id IN (SELECT id FROM comments)
+	AND message_id IN (SELECT id FROM messages WHERE ` + notModerated + `)`
+
+// ListNotifications gets a user's notifications, newest first, along with the total count
+func (r MessageRepository) ListNotifications(ctx context.Context, userID, limit, offset int64) ([]*domain.Notification, int64, error) {
+	var total int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE "+visibleNotificationsFilter, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, user_id, message_id, comment_id, created_at FROM notifications WHERE "+
+		visibleNotificationsFilter+" ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?", userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var notifications []*domain.Notification
+	for rows.Next() {
+		var notification domain.Notification
+		var createdAt string
+
+		err := rows.Scan(&notification.ID, &notification.UserID, &notification.MessageID, &notification.CommentID, &createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		notification.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, &notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
 }
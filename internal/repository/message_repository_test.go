@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,7 +42,7 @@ func TestMessageRepository_Create(t *testing.T) {
 		IsBanned:  false,
 	}
 
-	id, err := repo.Create(message)
+	id, err := repo.Create(context.Background(), message)
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
@@ -48,7 +52,7 @@ func TestMessageRepository_Create(t *testing.T) {
 	}
 
 	// Verify message was created
-	created, err := repo.GetByID(id)
+	created, err := repo.GetByID(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Failed to get created message: %v", err)
 	}
@@ -68,7 +72,7 @@ func TestMessageRepository_GetByID(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	// Test non-existent message
-	_, err := repo.GetByID(999)
+	_, err := repo.GetByID(context.Background(), 999)
 	if err == nil {
 		t.Error("Expected error for non-existent message")
 	}
@@ -82,12 +86,12 @@ func TestMessageRepository_GetByID(t *testing.T) {
 		IsBanned:  false,
 	}
 
-	id, err := repo.Create(message)
+	id, err := repo.Create(context.Background(), message)
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
 
-	retrieved, err := repo.GetByID(id)
+	retrieved, err := repo.GetByID(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Failed to get message by ID: %v", err)
 	}
@@ -112,14 +116,14 @@ func TestMessageRepository_List(t *testing.T) {
 			CreatedAt: time.Now().Add(time.Duration(i) * time.Minute),
 			IsBanned:  false,
 		}
-		_, err := repo.Create(message)
+		_, err := repo.Create(context.Background(), message)
 		if err != nil {
 			t.Fatalf("Failed to create test message: %v", err)
 		}
 	}
 
 	// Test list with limit and offset
-	messages, total, err := repo.List(3, 0)
+	messages, total, err := repo.List(context.Background(), 3, 0)
 	if err != nil {
 		t.Fatalf("Failed to list messages: %v", err)
 	}
@@ -133,7 +137,7 @@ func TestMessageRepository_List(t *testing.T) {
 	}
 
 	// Test with offset
-	messages, _, err = repo.List(3, 3)
+	messages, _, err = repo.List(context.Background(), 3, 3)
 	if err != nil {
 		t.Fatalf("Failed to list messages with offset: %v", err)
 	}
@@ -158,19 +162,19 @@ func TestMessageRepository_Ban(t *testing.T) {
 		IsBanned:  false,
 	}
 
-	id, err := repo.Create(message)
+	id, err := repo.Create(context.Background(), message)
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
 
 	// Ban the message
-	err = repo.Ban(id)
+	err = repo.Ban(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Failed to ban message: %v", err)
 	}
 
 	// Verify message is banned
-	banned, err := repo.GetByID(id)
+	banned, err := repo.GetByID(context.Background(), id)
 	if err != nil {
 		t.Fatalf("Failed to get banned message: %v", err)
 	}
@@ -195,7 +199,7 @@ func TestMessageRepository_CreateComment(t *testing.T) {
 		IsBanned:  false,
 	}
 
-	messageID, err := repo.Create(message)
+	messageID, err := repo.Create(context.Background(), message)
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
@@ -210,7 +214,7 @@ func TestMessageRepository_CreateComment(t *testing.T) {
 		ExpiresAt: time.Now().Add(24 * time.Hour),
 	}
 
-	commentID, err := repo.CreateComment(comment)
+	commentID, err := repo.CreateComment(context.Background(), comment)
 	if err != nil {
 		t.Fatalf("Failed to create comment: %v", err)
 	}
@@ -220,7 +224,7 @@ func TestMessageRepository_CreateComment(t *testing.T) {
 	}
 
 	// Verify comment was created
-	comments, err := repo.GetComments(messageID)
+	comments, err := repo.GetComments(context.Background(), messageID, domain.SortAsc, false)
 	if err != nil {
 		t.Fatalf("Failed to get comments: %v", err)
 	}
@@ -233,3 +237,1816 @@ func TestMessageRepository_CreateComment(t *testing.T) {
 		t.Errorf("Expected comment content %s, got %s", comment.Content, comments[0].Content)
 	}
 }
+
+func TestMessageRepository_ListStableOrder(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	// Create messages in a tight loop so several share the same second
+	for i := 0; i < 10; i++ {
+		message := &domain.Message{
+			UserID:   1,
+			Username: "testuser",
+			Content:  "Burst message",
+		}
+		if _, err := repo.Create(context.Background(), message); err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+	}
+
+	messages, _, err := repo.List(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+
+	if len(messages) != 10 {
+		t.Fatalf("Expected 10 messages, got %d", len(messages))
+	}
+
+	for i := 1; i < len(messages); i++ {
+		prev, cur := messages[i-1], messages[i]
+		if cur.CreatedAt.After(prev.CreatedAt) {
+			t.Errorf("Message %d created after message %d but listed below it", cur.ID, prev.ID)
+		}
+		if cur.CreatedAt.Equal(prev.CreatedAt) && cur.ID > prev.ID {
+			t.Errorf("Messages with equal timestamps not ordered by ID: %d before %d", prev.ID, cur.ID)
+		}
+	}
+
+	// Paging through the same data must not repeat or skip messages
+	seen := make(map[int64]bool)
+	for offset := int64(0); offset < 10; offset += 3 {
+		page, _, err := repo.List(context.Background(), 3, offset)
+		if err != nil {
+			t.Fatalf("Failed to list page at offset %d: %v", offset, err)
+		}
+		for _, m := range page {
+			if seen[m.ID] {
+				t.Errorf("Message %d returned on more than one page", m.ID)
+			}
+			seen[m.ID] = true
+		}
+	}
+	if len(seen) != 10 {
+		t.Errorf("Expected 10 distinct messages across pages, got %d", len(seen))
+	}
+}
+
+func TestMessageRepository_GetByIDs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		id, err := repo.Create(context.Background(), &domain.Message{
+			UserID:   1,
+			Username: "testuser",
+			Content:  "Test message " + string(rune(i+'1')),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Mix existing IDs with missing ones, out of insertion order
+	requested := []int64{ids[2], 999, ids[0], 1000, ids[1]}
+	messages, err := repo.GetByIDs(context.Background(), requested)
+	if err != nil {
+		t.Fatalf("Failed to get messages by IDs: %v", err)
+	}
+
+	expected := []int64{ids[2], ids[0], ids[1]}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d", len(expected), len(messages))
+	}
+	for i, id := range expected {
+		if messages[i].ID != id {
+			t.Errorf("Expected message %d at position %d, got %d", id, i, messages[i].ID)
+		}
+	}
+
+	// Only missing IDs yields an empty result, not an error
+	messages, err = repo.GetByIDs(context.Background(), []int64{998, 999})
+	if err != nil {
+		t.Fatalf("Failed to get missing messages: %v", err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("Expected 0 messages, got %d", len(messages))
+	}
+}
+
+func TestMessageRepository_Latest(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	if _, err := repo.Latest(ctx); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound for an empty feed, got %v", err)
+	}
+
+	var ids []int64
+	for _, content := range []string{"First", "Second", "Third"} {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: content})
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	latest, err := repo.Latest(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get latest message: %v", err)
+	}
+	if latest.ID != ids[2] || latest.Content != "Third" {
+		t.Errorf("Expected the newest message %d, got %+v", ids[2], latest)
+	}
+
+	// A banned head falls back to the newest visible message
+	if err := repo.Ban(ctx, ids[2]); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if latest, err := repo.Latest(ctx); err != nil || latest.ID != ids[1] {
+		t.Errorf("Expected message %d once the newest is banned, got %+v (%v)", ids[1], latest, err)
+	}
+}
+
+func TestMessageRepository_ListByActivity(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	oldID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Old message"})
+	if err != nil {
+		t.Fatalf("Failed to create old message: %v", err)
+	}
+	newID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "New message"})
+	if err != nil {
+		t.Fatalf("Failed to create new message: %v", err)
+	}
+
+	// Without any comments, activity order matches creation order
+	messages, _, err := repo.ListByActivity(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages by activity: %v", err)
+	}
+	if len(messages) != 2 || messages[0].ID != newID {
+		t.Fatalf("Expected newest message first before any comments")
+	}
+
+	// Commenting on the old message bumps it to the top
+	_, err = repo.CreateComment(context.Background(), &domain.Comment{
+		MessageID: oldID,
+		UserID:    2,
+		Username:  "commenter",
+		Content:   "Bump",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	messages, _, err = repo.ListByActivity(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages by activity: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].ID != oldID {
+		t.Errorf("Expected commented message %d first, got %d", oldID, messages[0].ID)
+	}
+	if !messages[0].LastActivityAt.After(messages[0].CreatedAt) {
+		t.Error("Expected last activity to be after creation for the commented message")
+	}
+
+	// A comment that doesn't bump leaves the activity order alone
+	_, err = repo.CreateCommentWithoutBump(context.Background(), &domain.Comment{
+		MessageID: newID,
+		UserID:    2,
+		Username:  "commenter",
+		Content:   "No bump",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	messages, _, err = repo.ListByActivity(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages by activity: %v", err)
+	}
+	if messages[0].ID != oldID {
+		t.Errorf("Expected message %d to stay first after a non-bumping comment, got %d", oldID, messages[0].ID)
+	}
+
+	// Default ordering is unaffected
+	messages, _, err = repo.List(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if messages[0].ID != newID {
+		t.Errorf("Expected newest message %d first in default order, got %d", newID, messages[0].ID)
+	}
+}
+
+func TestMessageRepository_UpdateUsername(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	var messageIDs []int64
+	for i := 0; i < 3; i++ {
+		id, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "oldname", Content: "Test message"})
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		messageIDs = append(messageIDs, id)
+	}
+
+	otherID, err := repo.Create(context.Background(), &domain.Message{UserID: 2, Username: "other", Content: "Someone else's message"})
+	if err != nil {
+		t.Fatalf("Failed to create other message: %v", err)
+	}
+
+	// One comment by the renamed user, one by someone else
+	for _, c := range []*domain.Comment{
+		{MessageID: otherID, UserID: 1, Username: "oldname", Content: "Reply"},
+		{MessageID: messageIDs[0], UserID: 2, Username: "other", Content: "Reply"},
+	} {
+		if _, err := repo.CreateComment(context.Background(), c); err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+	}
+
+	updated, err := repo.UpdateUsername(context.Background(), 1, "newname")
+	if err != nil {
+		t.Fatalf("Failed to update username: %v", err)
+	}
+	if updated != 4 {
+		t.Errorf("Expected 4 rows updated, got %d", updated)
+	}
+
+	for _, id := range messageIDs {
+		message, err := repo.GetByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Failed to get message: %v", err)
+		}
+		if message.Username != "newname" {
+			t.Errorf("Expected message %d username 'newname', got '%s'", id, message.Username)
+		}
+	}
+
+	other, err := repo.GetByID(context.Background(), otherID)
+	if err != nil {
+		t.Fatalf("Failed to get other message: %v", err)
+	}
+	if other.Username != "other" {
+		t.Errorf("Expected other user's message untouched, got '%s'", other.Username)
+	}
+
+	comments, err := repo.GetComments(context.Background(), otherID, domain.SortAsc, false)
+	if err != nil {
+		t.Fatalf("Failed to get comments: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Username != "newname" {
+		t.Errorf("Expected renamed user's comment to carry the new username")
+	}
+
+	comments, err = repo.GetComments(context.Background(), messageIDs[0], domain.SortAsc, false)
+	if err != nil {
+		t.Fatalf("Failed to get comments: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Username != "other" {
+		t.Errorf("Expected other user's comment untouched")
+	}
+}
+
+func TestMessageRepository_DeleteMessagesOlderThan(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	oldID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Old message"})
+	if err != nil {
+		t.Fatalf("Failed to create old message: %v", err)
+	}
+	newID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "New message"})
+	if err != nil {
+		t.Fatalf("Failed to create new message: %v", err)
+	}
+	if _, err := repo.CreateComment(context.Background(), &domain.Comment{MessageID: oldID, UserID: 2, Username: "commenter", Content: "Old reply"}); err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	// Backdate the old message past the cutoff
+	old := time.Now().UTC().Add(-48 * time.Hour).Format(timestampLayout)
+	if _, err := db.Exec("UPDATE messages SET created_at = ? WHERE id = ?", old, oldID); err != nil {
+		t.Fatalf("Failed to backdate message: %v", err)
+	}
+
+	deleted, err := repo.DeleteMessagesOlderThan(context.Background(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to delete old messages: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 message deleted, got %d", deleted)
+	}
+
+	if _, err := repo.GetByID(context.Background(), oldID); err == nil {
+		t.Error("Expected old message to be deleted")
+	}
+	if _, err := repo.GetByID(context.Background(), newID); err != nil {
+		t.Errorf("Expected new message to remain: %v", err)
+	}
+
+	var comments int
+	if err := db.QueryRow("SELECT COUNT(*) FROM comments WHERE message_id = ?", oldID).Scan(&comments); err != nil {
+		t.Fatalf("Failed to count comments: %v", err)
+	}
+	if comments != 0 {
+		t.Errorf("Expected old message's comments to be deleted, found %d", comments)
+	}
+}
+
+func TestMessageRepository_UpdateVersion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	id, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Original"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	version, err := repo.Update(context.Background(), id, "First edit", "", 1)
+	if err != nil {
+		t.Fatalf("Failed to update message at current version: %v", err)
+	}
+	if version != 2 {
+		t.Errorf("Expected version 2, got %d", version)
+	}
+
+	// A second writer still holding version 1 must not clobber the first edit
+	_, err = repo.Update(context.Background(), id, "Stale edit", "", 1)
+	if !errors.Is(err, domain.ErrVersionConflict) {
+		t.Fatalf("Expected ErrVersionConflict for stale version, got %v", err)
+	}
+
+	message, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if message.Content != "First edit" || message.Version != 2 {
+		t.Errorf("Expected 'First edit' at version 2, got '%s' at version %d", message.Content, message.Version)
+	}
+
+	// Editing a missing message is not reported as a conflict
+	_, err = repo.Update(context.Background(), 999, "Nothing", "", 1)
+	if err == nil || errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("Expected not-found error for missing message, got %v", err)
+	}
+}
+
+func TestMessageRepository_ListRecentComments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	var messageIDs []int64
+	for _, content := range []string{"First thread", "Second thread"} {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "author", Content: content})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		messageIDs = append(messageIDs, id)
+	}
+
+	// Comments alternate between the two messages, each newer than the last
+	now := time.Now()
+	var commentIDs []int64
+	for i, content := range []string{"oldest", "older", "newer", "newest"} {
+		id, err := repo.CreateComment(ctx, &domain.Comment{
+			MessageID: messageIDs[i%2], UserID: 2, Username: "commenter", Content: content, ExpiresAt: now.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+		// CreateComment stamps comments with the current time
+		at := now.Add(time.Duration(i-4) * time.Minute).UTC().Format(timestampLayout)
+		if _, err := db.Exec("UPDATE comments SET created_at = ? WHERE id = ?", at, id); err != nil {
+			t.Fatalf("Failed to backdate comment: %v", err)
+		}
+		commentIDs = append(commentIDs, id)
+	}
+	if _, err := repo.CreateComment(ctx, &domain.Comment{
+		MessageID: messageIDs[0], UserID: 2, Username: "commenter", Content: "expired", ExpiresAt: now.Add(-time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	comments, total, err := repo.ListRecentComments(ctx, 3, 0, false)
+	if err != nil {
+		t.Fatalf("ListRecentComments failed: %v", err)
+	}
+	if total != 4 || len(comments) != 3 {
+		t.Fatalf("Expected 3 of 4 comments, got %d of %d", len(comments), total)
+	}
+	for i, comment := range comments {
+		want := commentIDs[len(commentIDs)-1-i]
+		if comment.ID != want {
+			t.Errorf("Position %d: expected comment %d, got %d", i, want, comment.ID)
+		}
+		if comment.Message == nil || comment.Message.ID != comment.MessageID {
+			t.Fatalf("Expected comment %d to carry its message, got %+v", comment.ID, comment.Message)
+		}
+	}
+	if comments[0].Message.Content != "Second thread" || comments[1].Message.Content != "First thread" {
+		t.Errorf("Expected each comment's own message content, got %q and %q", comments[0].Message.Content, comments[1].Message.Content)
+	}
+
+	if _, total, _ := repo.ListRecentComments(ctx, 10, 0, true); total != 5 {
+		t.Errorf("Expected 5 comments including expired, got %d", total)
+	}
+}
+
+func TestMessageRepository_Notifications(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	messageID, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "author", Content: "Watch this thread"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	// Subscribing twice keeps a single subscription
+	for _, userID := range []int64{1, 2, 2, 3} {
+		if err := repo.Subscribe(ctx, userID, messageID); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+	}
+	if err := repo.Unsubscribe(ctx, 3, messageID); err != nil {
+		t.Fatalf("Failed to unsubscribe: %v", err)
+	}
+
+	comment := &domain.Comment{MessageID: messageID, UserID: 2, Username: "commenter", Content: "First", ExpiresAt: time.Now().Add(time.Hour)}
+	if comment.ID, err = repo.CreateComment(ctx, comment); err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	notified, err := repo.CreateCommentNotifications(ctx, comment)
+	if err != nil {
+		t.Fatalf("Failed to create notifications: %v", err)
+	}
+	// The commenter isn't notified of their own comment, nor the unsubscribed user
+	if notified != 1 {
+		t.Errorf("Expected 1 subscriber notified, got %d", notified)
+	}
+
+	notifications, total, err := repo.ListNotifications(ctx, 1, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list notifications: %v", err)
+	}
+	if total != 1 || len(notifications) != 1 {
+		t.Fatalf("Expected 1 notification, got %d (total %d)", len(notifications), total)
+	}
+	if n := notifications[0]; n.UserID != 1 || n.MessageID != messageID || n.CommentID != comment.ID || n.CreatedAt.IsZero() {
+		t.Errorf("Unexpected notification %+v", n)
+	}
+	for _, userID := range []int64{2, 3} {
+		if _, total, _ := repo.ListNotifications(ctx, userID, 10, 0); total != 0 {
+			t.Errorf("Expected no notifications for user %d, got %d", userID, total)
+		}
+	}
+
+	// Notifications of comments on banned messages are no longer listed
+	if err := repo.Ban(ctx, messageID); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if _, total, _ := repo.ListNotifications(ctx, 1, 10, 0); total != 0 {
+		t.Errorf("Expected notifications on a banned message to be hidden, got %d", total)
+	}
+}
+
+func TestMessageRepository_Mentions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	// alice has posted before, so her username resolves locally
+	if _, err := repo.Create(context.Background(), &domain.Message{UserID: 7, Username: "alice", Content: "Hi all"}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	sourceID, err := repo.Create(context.Background(), &domain.Message{UserID: 8, Username: "bob", Content: "@alice @alice look"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	candidates, err := repo.FindUserIDsByUsernames(context.Background(), []string{"alice", "nobody"})
+	if err != nil {
+		t.Fatalf("Failed to find users by username: %v", err)
+	}
+	if len(candidates["alice"]) != 1 || candidates["alice"][0] != 7 {
+		t.Errorf("Expected alice to resolve to user 7, got %v", candidates["alice"])
+	}
+	if _, ok := candidates["nobody"]; ok {
+		t.Error("Expected unknown username not to resolve")
+	}
+
+	// Recording the same mention twice keeps a single row
+	mention := func() *domain.Mention {
+		return &domain.Mention{SourceType: domain.MentionSourceMessage, SourceID: sourceID, MentionedUserID: 7}
+	}
+	if err := repo.CreateMentions(context.Background(), []*domain.Mention{mention(), mention()}); err != nil {
+		t.Fatalf("Failed to create mentions: %v", err)
+	}
+
+	mentions, total, err := repo.ListMentions(context.Background(), 7, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list mentions: %v", err)
+	}
+	if total != 1 || len(mentions) != 1 {
+		t.Fatalf("Expected 1 mention, got %d (total %d)", len(mentions), total)
+	}
+	if mentions[0].SourceType != domain.MentionSourceMessage || mentions[0].SourceID != sourceID {
+		t.Errorf("Unexpected mention source %s %d", mentions[0].SourceType, mentions[0].SourceID)
+	}
+
+	// Mentions in banned messages are hidden
+	if err := repo.Ban(context.Background(), sourceID); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	_, total, err = repo.ListMentions(context.Background(), 7, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list mentions: %v", err)
+	}
+	if total != 0 {
+		t.Errorf("Expected mentions in banned message to be hidden, got %d", total)
+	}
+}
+
+func TestMessageRepository_ListPinned(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		id, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Message"})
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	for _, id := range []int64{ids[1], ids[0], ids[2]} {
+		if err := repo.Pin(context.Background(), id); err != nil {
+			t.Fatalf("Failed to pin message %d: %v", id, err)
+		}
+	}
+	if err := repo.Unpin(context.Background(), ids[0]); err != nil {
+		t.Fatalf("Failed to unpin message: %v", err)
+	}
+	if err := repo.Ban(context.Background(), ids[2]); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+
+	pinned, err := repo.ListPinned(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list pinned messages: %v", err)
+	}
+	if len(pinned) != 1 || pinned[0].ID != ids[1] {
+		t.Fatalf("Expected only message %d pinned, got %d messages", ids[1], len(pinned))
+	}
+	if !pinned[0].IsPinned || pinned[0].PinnedAt == nil {
+		t.Error("Expected pinned message to report its pin time")
+	}
+
+	unpinned, err := repo.GetByID(context.Background(), ids[0])
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if unpinned.IsPinned || unpinned.PinnedAt != nil {
+		t.Error("Expected unpinned message to have no pin time")
+	}
+}
+
+func TestMessageRepository_UnreadTracking(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	create := func(userID int64) int64 {
+		id, err := repo.Create(context.Background(), &domain.Message{UserID: userID, Username: "user", Content: "Message"})
+		if err != nil {
+			t.Fatalf("Failed to create test message: %v", err)
+		}
+		return id
+	}
+	countUnread := func(userID int64) int64 {
+		count, err := repo.CountUnread(context.Background(), userID)
+		if err != nil {
+			t.Fatalf("Failed to count unread: %v", err)
+		}
+		return count
+	}
+
+	create(2)
+	second := create(2)
+	create(1) // the reader's own message never counts as unread
+
+	if count := countUnread(1); count != 2 {
+		t.Errorf("Expected 2 unread before marking, got %d", count)
+	}
+
+	if err := repo.MarkRead(context.Background(), 1, second); err != nil {
+		t.Fatalf("Failed to mark read: %v", err)
+	}
+	if count := countUnread(1); count != 0 {
+		t.Errorf("Expected 0 unread after marking, got %d", count)
+	}
+
+	// New messages arrive after the read marker
+	create(2)
+	create(3)
+	if count := countUnread(1); count != 2 {
+		t.Errorf("Expected 2 unread after new messages, got %d", count)
+	}
+
+	// Marking an older message doesn't move the marker backwards
+	if err := repo.MarkRead(context.Background(), 1, 1); err != nil {
+		t.Fatalf("Failed to mark read: %v", err)
+	}
+	if count := countUnread(1); count != 2 {
+		t.Errorf("Expected read marker not to move backwards, got %d unread", count)
+	}
+
+	// Other users are tracked independently
+	if count := countUnread(3); count != 4 {
+		t.Errorf("Expected 4 unread for a user who never read anything, got %d", count)
+	}
+}
+
+func TestMessageRepository_ReplyReference(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	longContent := strings.Repeat("a", 150)
+	quotedID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "alice", Content: longContent})
+	if err != nil {
+		t.Fatalf("Failed to create quoted message: %v", err)
+	}
+	replyID, err := repo.Create(context.Background(), &domain.Message{UserID: 2, Username: "bob", Content: "Agreed", ReplyToMessageID: &quotedID})
+	if err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+
+	reply, err := repo.GetByID(context.Background(), replyID)
+	if err != nil {
+		t.Fatalf("Failed to get reply: %v", err)
+	}
+	if reply.ReplyToMessageID == nil || *reply.ReplyToMessageID != quotedID {
+		t.Fatalf("Expected reply to reference message %d", quotedID)
+	}
+	if reply.ReplyTo == nil || reply.ReplyTo.ID != quotedID || reply.ReplyTo.Username != "alice" {
+		t.Fatalf("Expected embedded reference to message %d by alice, got %+v", quotedID, reply.ReplyTo)
+	}
+	if len(reply.ReplyTo.Content) >= len(longContent) {
+		t.Errorf("Expected quoted content to be truncated, got %d characters", len(reply.ReplyTo.Content))
+	}
+
+	// List responses embed the reference too
+	messages, _, err := repo.List(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if messages[0].ID != replyID || messages[0].ReplyTo == nil {
+		t.Error("Expected listed reply to embed its reference")
+	}
+	if messages[1].ReplyTo != nil {
+		t.Error("Expected non-reply to have no reference")
+	}
+}
+
+func TestMessageRepository_ExpiredMessages(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	expiredID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Gone", ExpiresAt: &past})
+	if err != nil {
+		t.Fatalf("Failed to create expired message: %v", err)
+	}
+	ephemeralID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Soon gone", ExpiresAt: &future})
+	if err != nil {
+		t.Fatalf("Failed to create ephemeral message: %v", err)
+	}
+	permanentID, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Forever"})
+	if err != nil {
+		t.Fatalf("Failed to create permanent message: %v", err)
+	}
+
+	messages, total, err := repo.List(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if total != 2 || len(messages) != 2 {
+		t.Fatalf("Expected 2 unexpired messages, got %d (total %d)", len(messages), total)
+	}
+	for _, m := range messages {
+		if m.ID == expiredID {
+			t.Error("Expected expired message to be excluded from the list")
+		}
+	}
+
+	// Admins can still fetch it directly until it's purged
+	expired, err := repo.GetByID(context.Background(), expiredID)
+	if err != nil {
+		t.Fatalf("Failed to get expired message: %v", err)
+	}
+	if !expired.IsExpired() {
+		t.Error("Expected message to report itself expired")
+	}
+
+	deleted, err := repo.DeleteExpiredMessages(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to delete expired messages: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 expired message deleted, got %d", deleted)
+	}
+	if _, err := repo.GetByID(context.Background(), expiredID); err == nil {
+		t.Error("Expected expired message to be purged")
+	}
+	for _, id := range []int64{ephemeralID, permanentID} {
+		if _, err := repo.GetByID(context.Background(), id); err != nil {
+			t.Errorf("Expected message %d to remain: %v", id, err)
+		}
+	}
+}
+
+func TestMessageRepository_UpdateComment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	messageID, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Message", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	commentID, err := repo.CreateComment(ctx, &domain.Comment{
+		MessageID: messageID,
+		UserID:    1,
+		Username:  "testuser",
+		Content:   "Original",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	expiresAt := time.Now().Add(2 * time.Hour).UTC()
+	if err := repo.UpdateComment(ctx, commentID, "Edited", expiresAt); err != nil {
+		t.Fatalf("Failed to update comment: %v", err)
+	}
+	comment, err := repo.GetCommentByID(ctx, commentID)
+	if err != nil {
+		t.Fatalf("Failed to get comment: %v", err)
+	}
+	if comment.Content != "Edited" || !comment.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected 'Edited' expiring at %v, got '%s' expiring at %v", expiresAt, comment.Content, comment.ExpiresAt)
+	}
+
+	if err := repo.UpdateComment(ctx, commentID+100, "Missing", expiresAt); !errors.Is(err, domain.ErrCommentNotFound) {
+		t.Errorf("Expected ErrCommentNotFound, got %v", err)
+	}
+}
+
+func TestMessageRepository_HasRecentDuplicate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	before := time.Now().Add(-time.Second)
+	if _, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Same again"}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  int64
+		content string
+		since   time.Time
+		want    bool
+	}{
+		{name: "Same user and content", userID: 1, content: "Same again", since: before, want: true},
+		{name: "Different user", userID: 2, content: "Same again", since: before},
+		{name: "Different content", userID: 1, content: "Same again!", since: before},
+		{name: "Outside the window", userID: 1, content: "Same again", since: time.Now().Add(time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.HasRecentDuplicate(ctx, tt.userID, tt.content, tt.since)
+			if err != nil {
+				t.Fatalf("HasRecentDuplicate failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMessageRepository_ListTrending(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	create := func(content string, comments int, at time.Time) int64 {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: content, CreatedAt: at})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		for i := 0; i < comments; i++ {
+			if _, err := repo.CreateComment(ctx, &domain.Comment{
+				MessageID: id,
+				UserID:    2,
+				Username:  "commenter",
+				Content:   "Reply",
+				ExpiresAt: now.Add(time.Hour),
+			}); err != nil {
+				t.Fatalf("Failed to create comment: %v", err)
+			}
+		}
+		// CreateComment stamps comments with the current time
+		if _, err := db.Exec("UPDATE comments SET created_at = ? WHERE message_id = ?", at.UTC().Format(timestampLayout), id); err != nil {
+			t.Fatalf("Failed to backdate comments: %v", err)
+		}
+		return id
+	}
+
+	old := create("Busy last week", 5, now.Add(-7*24*time.Hour))
+	hot := create("Busy today", 3, now.Add(-time.Hour))
+	warm := create("Quieter today", 1, now.Add(-2*time.Hour))
+	create("No comments", 0, now)
+
+	messages, total, err := repo.ListTrending(ctx, now.Add(-24*time.Hour), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list trending messages: %v", err)
+	}
+	if total != 2 || len(messages) != 2 {
+		t.Fatalf("Expected the 2 messages commented on today, got %d of %d", len(messages), total)
+	}
+	if messages[0].ID != hot || messages[1].ID != warm {
+		t.Errorf("Expected %d then %d, got %d then %d", hot, warm, messages[0].ID, messages[1].ID)
+	}
+
+	// A wider window lets the old, busier message back in at the top
+	messages, _, err = repo.ListTrending(ctx, now.Add(-30*24*time.Hour), 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to list trending messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != old {
+		t.Errorf("Expected message %d first over a month, got %v", old, messages)
+	}
+}
+
+func TestMessageRepository_CreateComments(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	first, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "First"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	second, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Second"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	comments := make([]*domain.Comment, 100)
+	for i := range comments {
+		messageID := first
+		if i%2 == 1 {
+			messageID = second
+		}
+		comments[i] = &domain.Comment{MessageID: messageID, UserID: 2, Username: "importer", Content: fmt.Sprintf("Comment %d", i)}
+	}
+
+	ids, err := repo.CreateComments(context.Background(), comments, []int64{first, second})
+	if err != nil {
+		t.Fatalf("Failed to import comments: %v", err)
+	}
+	if len(ids) != 100 {
+		t.Fatalf("Expected 100 IDs, got %d", len(ids))
+	}
+	for i, id := range ids {
+		comment, err := repo.GetCommentByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Failed to get comment %d: %v", id, err)
+		}
+		if comment.Content != fmt.Sprintf("Comment %d", i) || comment.MessageID != comments[i].MessageID {
+			t.Errorf("ID %d at position %d points to %q on message %d", id, i, comment.Content, comment.MessageID)
+		}
+	}
+
+	// A batch referencing a missing message is rolled back entirely
+	_, err = repo.CreateComments(context.Background(), []*domain.Comment{
+		{MessageID: first, UserID: 2, Username: "importer", Content: "Valid"},
+		{MessageID: 999, UserID: 2, Username: "importer", Content: "Orphan"},
+	}, []int64{first})
+	if err == nil {
+		t.Fatal("Expected error importing a comment for a missing message")
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM comments").Scan(&total); err != nil {
+		t.Fatalf("Failed to count comments: %v", err)
+	}
+	if total != 100 {
+		t.Errorf("Expected failed batch to be rolled back leaving 100 comments, got %d", total)
+	}
+}
+
+func TestMessageRepository_CancelledContext(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+
+	if _, err := repo.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Hello"}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := repo.List(ctx, 10, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected List to abort with context.Canceled, got %v", err)
+	}
+	if _, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Too late"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Create to abort with context.Canceled, got %v", err)
+	}
+}
+
+func TestRepository_Close(t *testing.T) {
+	db := setupTestDB(t)
+
+	repo := NewRepository(db)
+	if _, err := repo.Message.Create(context.Background(), &domain.Message{UserID: 1, Username: "testuser", Content: "Hello"}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Failed to close repository: %v", err)
+	}
+	if err := db.Ping(); err == nil {
+		t.Error("Expected database to be closed")
+	}
+}
+
+// benchmarkGetByID fetches the same message repeatedly through repo
+func benchmarkGetByID(b *testing.B, newRepo func(db *sql.DB) domain.MessageRepository) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		b.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	if err := InitSchema(db); err != nil {
+		b.Fatalf("Failed to initialize test schema: %v", err)
+	}
+
+	repo := newRepo(db)
+	ctx := context.Background()
+	id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Benchmark message"})
+	if err != nil {
+		b.Fatalf("Failed to create message: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetByID(ctx, id); err != nil {
+			b.Fatalf("Failed to get message: %v", err)
+		}
+	}
+}
+
+func BenchmarkGetByID_Prepared(b *testing.B) {
+	benchmarkGetByID(b, NewMessageRepository)
+}
+
+func BenchmarkGetByID_AdHoc(b *testing.B) {
+	benchmarkGetByID(b, func(db *sql.DB) domain.MessageRepository {
+		return &MessageRepository{db: newTimedDB(db)}
+	})
+}
+
+func TestMessageRepository_BanMessagesByUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	for _, m := range []*domain.Message{
+		{UserID: 1, Username: "spammer", Content: "Spam 1"},
+		{UserID: 1, Username: "spammer", Content: "Spam 2"},
+		{UserID: 1, Username: "spammer", Content: "Spam 3"},
+		{UserID: 2, Username: "other", Content: "Legit"},
+	} {
+		if _, err := repo.Create(ctx, m); err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+	}
+
+	banned, err := repo.BanMessagesByUser(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to ban user's messages: %v", err)
+	}
+	if banned != 3 {
+		t.Errorf("Expected 3 messages banned, got %d", banned)
+	}
+
+	messages, total, err := repo.List(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if total != 1 || len(messages) != 1 || messages[0].UserID != 2 {
+		t.Fatalf("Expected only the other user's message in the list, got %d (total %d)", len(messages), total)
+	}
+
+	userMessages, err := repo.ListByUser(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to list user's messages: %v", err)
+	}
+	for _, m := range userMessages {
+		if !m.IsBanned {
+			t.Errorf("Expected message %d to be banned", m.ID)
+		}
+	}
+
+	page, total, err := repo.ListByUserPage(ctx, 1, 2, 1)
+	if err != nil {
+		t.Fatalf("Failed to page user's messages: %v", err)
+	}
+	if total != 3 || len(page) != 2 || page[0].ID != userMessages[1].ID || !page[0].IsBanned {
+		t.Errorf("Expected the second and third of 3 banned messages, got %d (total %d)", len(page), total)
+	}
+
+	unbanned, err := repo.UnbanMessagesByUser(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to unban user's messages: %v", err)
+	}
+	if unbanned != 3 {
+		t.Errorf("Expected 3 messages unbanned, got %d", unbanned)
+	}
+
+	_, total, err = repo.List(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("Expected all 4 messages after unban, got %d", total)
+	}
+}
+
+func TestMessageRepository_BanByIDs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: fmt.Sprintf("Message %d", i)})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := repo.Ban(ctx, ids[0]); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+
+	banned, err := repo.BanByIDs(ctx, []int64{ids[0], ids[1], 999})
+	if err != nil {
+		t.Fatalf("Failed to ban messages: %v", err)
+	}
+	if banned != 1 {
+		t.Errorf("Expected only the unbanned message to count, got %d", banned)
+	}
+	if _, total, _ := repo.List(ctx, 10, 0); total != 1 {
+		t.Errorf("Expected 1 visible message left, got %d", total)
+	}
+}
+
+func TestMessageRepository_UnbanByIDs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	var ids []int64
+	for i := 0; i < 2; i++ {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: fmt.Sprintf("Message %d", i)})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := repo.BanByIDs(ctx, ids); err != nil {
+		t.Fatalf("Failed to ban messages: %v", err)
+	}
+
+	found, err := repo.UnbanByIDs(ctx, []int64{ids[1], 999, ids[0]})
+	if err != nil {
+		t.Fatalf("Failed to unban messages: %v", err)
+	}
+	if fmt.Sprint(found) != fmt.Sprint([]int64{ids[1], ids[0]}) {
+		t.Errorf("Expected the existing IDs in the order given, got %v", found)
+	}
+	if _, total, _ := repo.List(ctx, 10, 0); total != 2 {
+		t.Errorf("Expected both messages visible again, got %d", total)
+	}
+}
+
+func TestMessageRepository_NotFoundErrors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	_, err := repo.GetByID(ctx, 999)
+	if !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+
+	_, err = repo.GetCommentByID(ctx, 999)
+	if !errors.Is(err, domain.ErrCommentNotFound) {
+		t.Errorf("Expected ErrCommentNotFound, got %v", err)
+	}
+
+	err = repo.DeleteComment(ctx, 999)
+	if !errors.Is(err, domain.ErrCommentNotFound) {
+		t.Errorf("Expected ErrCommentNotFound deleting a missing comment, got %v", err)
+	}
+
+	_, err = repo.CreateComments(ctx, []*domain.Comment{
+		{MessageID: 999, UserID: 1, Username: "testuser", Content: "Orphan"},
+	}, nil)
+	if !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound importing onto a missing message, got %v", err)
+	}
+}
+
+func TestMessageRepository_Lock(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Thread"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	if err := repo.Lock(ctx, id); err != nil {
+		t.Fatalf("Failed to lock message: %v", err)
+	}
+	message, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if !message.IsLocked {
+		t.Error("Expected message to be locked")
+	}
+
+	_, err = repo.CreateComment(ctx, &domain.Comment{MessageID: id, UserID: 2, Username: "commenter", Content: "Blocked"})
+	if !errors.Is(err, domain.ErrThreadLocked) {
+		t.Errorf("Expected ErrThreadLocked, got %v", err)
+	}
+	_, err = repo.CreateComments(ctx, []*domain.Comment{{MessageID: id, UserID: 2, Username: "importer", Content: "Blocked"}}, nil)
+	if !errors.Is(err, domain.ErrThreadLocked) {
+		t.Errorf("Expected ErrThreadLocked importing onto a locked thread, got %v", err)
+	}
+
+	if err := repo.Unlock(ctx, id); err != nil {
+		t.Fatalf("Failed to unlock message: %v", err)
+	}
+	if _, err := repo.CreateComment(ctx, &domain.Comment{MessageID: id, UserID: 2, Username: "commenter", Content: "Allowed"}); err != nil {
+		t.Errorf("Expected comment after unlock to succeed, got %v", err)
+	}
+}
+
+func TestMessageRepository_Hide(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, content := range []string{"Visible", "Hidden", "Banned"} {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: content})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := repo.Hide(ctx, ids[1]); err != nil {
+		t.Fatalf("Failed to hide message: %v", err)
+	}
+	if err := repo.Ban(ctx, ids[2]); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+
+	messages, total, err := repo.List(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if total != 1 || len(messages) != 1 || messages[0].ID != ids[0] {
+		t.Errorf("Expected only the visible message in the feed, got %d messages", total)
+	}
+
+	// The author still sees hidden and banned messages, told apart by their flags
+	own, _, err := repo.ListByUserPage(ctx, 1, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list user messages: %v", err)
+	}
+	if len(own) != 3 {
+		t.Fatalf("Expected the author to see all 3 messages, got %d", len(own))
+	}
+	for _, message := range own {
+		if message.IsHidden != (message.ID == ids[1]) || message.IsBanned != (message.ID == ids[2]) {
+			t.Errorf("Unexpected flags on message %d: hidden=%t banned=%t", message.ID, message.IsHidden, message.IsBanned)
+		}
+	}
+
+	if err := repo.Unhide(ctx, ids[1]); err != nil {
+		t.Fatalf("Failed to unhide message: %v", err)
+	}
+	if _, total, _ := repo.List(ctx, 10, 0); total != 2 {
+		t.Errorf("Expected the unhidden message back in the feed, got %d messages", total)
+	}
+}
+
+func TestMessageRepository_CommentTTL(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Announcement", CommentTTLSeconds: 3600})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	message, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if message.CommentTTLSeconds != 3600 {
+		t.Errorf("Expected comment TTL 3600s, got %d", message.CommentTTLSeconds)
+	}
+
+	comment := &domain.Comment{MessageID: id, UserID: 1, Username: "testuser", Content: "Comment"}
+	if _, err := repo.CreateComment(ctx, comment); err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	if lifetime := comment.ExpiresAt.Sub(comment.CreatedAt); lifetime != time.Hour {
+		t.Errorf("Expected comment to live for the message's 1h TTL, got %s", lifetime)
+	}
+
+	imported := &domain.Comment{MessageID: id, UserID: 1, Username: "importer", Content: "Imported"}
+	if _, err := repo.CreateComments(ctx, []*domain.Comment{imported}, nil); err != nil {
+		t.Fatalf("Failed to import comment: %v", err)
+	}
+	if lifetime := imported.ExpiresAt.Sub(imported.CreatedAt); lifetime != time.Hour {
+		t.Errorf("Expected imported comment to live for the message's 1h TTL, got %s", lifetime)
+	}
+}
+
+func TestMessageRepository_TimestampsAreUTC(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+	zone := time.FixedZone("UTC+5", 5*60*60)
+
+	// Caller-supplied timestamps in another zone must not leak through
+	expiresAt := time.Now().In(zone).Add(time.Hour)
+	message := &domain.Message{
+		UserID:    1,
+		Username:  "testuser",
+		Content:   "Timestamps",
+		CreatedAt: time.Now().In(zone),
+		ExpiresAt: &expiresAt,
+	}
+	id, err := repo.Create(ctx, message)
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	stored, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get message: %v", err)
+	}
+	if message.CreatedAt.Location() != time.UTC || stored.CreatedAt.Location() != time.UTC {
+		t.Errorf("Expected UTC timestamps, got returned %s and stored %s", message.CreatedAt.Location(), stored.CreatedAt.Location())
+	}
+	if !stored.CreatedAt.Equal(message.CreatedAt) {
+		t.Errorf("Expected stored CreatedAt %s to equal returned %s", stored.CreatedAt, message.CreatedAt)
+	}
+	if stored.ExpiresAt == nil || !stored.ExpiresAt.Equal(expiresAt) || stored.ExpiresAt.Location() != time.UTC {
+		t.Errorf("Expected ExpiresAt %s stored in UTC, got %v", expiresAt.UTC(), stored.ExpiresAt)
+	}
+
+	comment := &domain.Comment{MessageID: id, UserID: 1, Username: "testuser", Content: "Comment", ExpiresAt: time.Now().In(zone).Add(time.Minute)}
+	if _, err := repo.CreateComment(ctx, comment); err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	comments, err := repo.GetComments(ctx, id, domain.SortAsc, false)
+	if err != nil || len(comments) != 1 {
+		t.Fatalf("Expected 1 comment, got %d (err: %v)", len(comments), err)
+	}
+	if comment.CreatedAt.Location() != time.UTC || comment.ExpiresAt.Location() != time.UTC {
+		t.Error("Expected returned comment timestamps in UTC")
+	}
+	if !comments[0].CreatedAt.Equal(comment.CreatedAt) || !comments[0].ExpiresAt.Equal(comment.ExpiresAt) {
+		t.Errorf("Expected stored comment timestamps to equal returned ones, got %+v and %+v", comments[0], comment)
+	}
+}
+
+func TestMessageRepository_CommentOrder(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Thread"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	var commentIDs []int64
+	for _, content := range []string{"first", "second", "third"} {
+		commentID, err := repo.CreateComment(ctx, &domain.Comment{MessageID: id, UserID: 1, Username: "testuser", Content: content})
+		if err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+		commentIDs = append(commentIDs, commentID)
+	}
+
+	tests := []struct {
+		order domain.SortOrder
+		want  []int64
+	}{
+		{order: "", want: commentIDs},
+		{order: domain.SortAsc, want: commentIDs},
+		{order: domain.SortDesc, want: []int64{commentIDs[2], commentIDs[1], commentIDs[0]}},
+	}
+	for _, tt := range tests {
+		comments, err := repo.GetComments(ctx, id, tt.order, false)
+		if err != nil {
+			t.Fatalf("Failed to get comments in order %q: %v", tt.order, err)
+		}
+		var got []int64
+		for _, comment := range comments {
+			got = append(got, comment.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Order %q: expected comment IDs %v, got %v", tt.order, tt.want, got)
+		}
+	}
+}
+
+func TestMessageRepository_CommentStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	busy, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "author", Content: "Busy thread"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	quiet, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "author", Content: "Quiet thread"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	comments := []*domain.Comment{
+		{MessageID: busy, UserID: 2, Username: "alice", Content: "One"},
+		{MessageID: busy, UserID: 2, Username: "alice", Content: "Two"},
+		{MessageID: busy, UserID: 3, Username: "bob", Content: "Three"},
+		// Expired comments don't count
+		{MessageID: busy, UserID: 4, Username: "carol", Content: "Gone", ExpiresAt: time.Now().Add(-time.Minute)},
+	}
+	for _, comment := range comments {
+		if _, err := repo.CreateComment(ctx, comment); err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+	}
+
+	messages, _, err := repo.List(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	stats := make(map[int64][2]int64)
+	for _, message := range messages {
+		stats[message.ID] = [2]int64{message.CommentCount, message.ParticipantCount}
+	}
+	if got := stats[busy]; got != [2]int64{3, 2} {
+		t.Errorf("Expected 3 comments from 2 participants, got %d comments from %d", got[0], got[1])
+	}
+	if got := stats[quiet]; got != [2]int64{0, 0} {
+		t.Errorf("Expected no comments on the quiet thread, got %v", got)
+	}
+}
+
+func TestMessageRepository_PurgeBanned(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, content := range []string{"Spam", "More spam", "Legit"} {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: content})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids[:2] {
+		if err := repo.Ban(ctx, id); err != nil {
+			t.Fatalf("Failed to ban message: %v", err)
+		}
+	}
+	commentID, err := repo.CreateComment(ctx, &domain.Comment{MessageID: ids[0], UserID: 2, Username: "commenter", Content: "Reply to spam"})
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	deleted, err := repo.PurgeBanned(ctx)
+	if err != nil {
+		t.Fatalf("Failed to purge banned messages: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 messages purged, got %d", deleted)
+	}
+
+	for _, id := range ids[:2] {
+		if _, err := repo.GetByID(ctx, id); !errors.Is(err, domain.ErrMessageNotFound) {
+			t.Errorf("Expected banned message %d to be purged, got %v", id, err)
+		}
+	}
+	if _, err := repo.GetByID(ctx, ids[2]); err != nil {
+		t.Errorf("Expected unbanned message to remain, got %v", err)
+	}
+	if _, err := repo.GetCommentByID(ctx, commentID); !errors.Is(err, domain.ErrCommentNotFound) {
+		t.Errorf("Expected comment on purged message to be deleted, got %v", err)
+	}
+}
+
+func TestMessageRepository_Search(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	ids := make(map[string]int64)
+	for _, content := range []string{"Release notes for Go", "GOLANG meetup", "100% uptime", "1000 uptime", "Banned golang spam"} {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "author", Content: content})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids[content] = id
+	}
+	if err := repo.Ban(ctx, ids["Banned golang spam"]); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+
+	messages, total, err := repo.Search(ctx, "go", 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if total != 2 || len(messages) != 1 || messages[0].ID != ids["GOLANG meetup"] {
+		t.Errorf("Expected the newest of 2 case-insensitive matches, got %d (total %d)", len(messages), total)
+	}
+
+	// Wildcards in the query match literally
+	messages, total, err = repo.Search(ctx, "0%", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if total != 1 || len(messages) != 1 || messages[0].ID != ids["100% uptime"] {
+		t.Errorf("Expected only the literal %% match, got %d (total %d)", len(messages), total)
+	}
+}
+
+func TestMessageRepository_ListCommentsByUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Thread"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	comments := []*domain.Comment{
+		{MessageID: id, UserID: 2, Username: "commenter", Content: "first", ExpiresAt: time.Now().Add(time.Hour)},
+		{MessageID: id, UserID: 2, Username: "commenter", Content: "second", ExpiresAt: time.Now().Add(time.Hour)},
+		{MessageID: id, UserID: 2, Username: "commenter", Content: "expired", ExpiresAt: time.Now().Add(-time.Hour)},
+		{MessageID: id, UserID: 2, Username: "commenter", Content: "third", ExpiresAt: time.Now().Add(time.Hour)},
+		{MessageID: id, UserID: 3, Username: "other", Content: "not theirs", ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	var ids []int64
+	for _, comment := range comments {
+		commentID, err := repo.CreateComment(ctx, comment)
+		if err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+		ids = append(ids, commentID)
+	}
+
+	tests := []struct {
+		name           string
+		limit, offset  int64
+		includeExpired bool
+		want           []int64
+		total          int64
+	}{
+		{name: "First page", limit: 2, want: []int64{ids[3], ids[1]}, total: 3},
+		{name: "Second page", limit: 2, offset: 2, want: []int64{ids[0]}, total: 3},
+		{name: "Past the end", limit: 2, offset: 4, want: nil, total: 3},
+		{name: "Including expired", limit: 10, includeExpired: true, want: []int64{ids[3], ids[2], ids[1], ids[0]}, total: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, total, err := repo.ListCommentsByUser(ctx, 2, tt.limit, tt.offset, tt.includeExpired)
+			if err != nil {
+				t.Fatalf("Failed to list comments: %v", err)
+			}
+			var got []int64
+			for _, comment := range page {
+				got = append(got, comment.ID)
+			}
+			if total != tt.total || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected comments %v (total %d), got %v (total %d)", tt.want, tt.total, got, total)
+			}
+		})
+	}
+}
+
+func TestMessageRepository_ListCreatedBetween(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	// One message a day, from four days ago to yesterday
+	base := time.Now().UTC().Truncate(time.Hour).Add(-96 * time.Hour)
+	var ids []int64
+	for day := 0; day < 4; day++ {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: fmt.Sprintf("Day %d", day)})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		createdAt := base.Add(time.Duration(day) * 24 * time.Hour).Format(timestampLayout)
+		if _, err := db.Exec("UPDATE messages SET created_at = ? WHERE id = ?", createdAt, id); err != nil {
+			t.Fatalf("Failed to backdate message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := repo.Ban(ctx, ids[2]); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+
+	day := func(n int) time.Time { return base.Add(time.Duration(n) * 24 * time.Hour) }
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []int64
+	}{
+		{name: "Inclusive bounds", from: day(1), to: day(3), want: []int64{ids[3], ids[1]}},
+		{name: "Open start", to: day(1), want: []int64{ids[1], ids[0]}},
+		{name: "Open end", from: day(3), want: []int64{ids[3]}},
+		{name: "Empty window", from: day(1).Add(time.Hour), to: day(2).Add(time.Hour), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, total, err := repo.ListCreatedBetween(ctx, tt.from, tt.to, 10, 0)
+			if err != nil {
+				t.Fatalf("Failed to list messages: %v", err)
+			}
+			var got []int64
+			for _, message := range messages {
+				got = append(got, message.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("Expected messages %v, got %v (total %d)", tt.want, got, total)
+			}
+		})
+	}
+}
+
+func TestMessageRepository_CountByInterval(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	// Two messages on March 1st an hour apart, none on the 2nd, one on the 3rd
+	for _, createdAt := range []string{"2024-03-03T08:15:00Z", "2024-03-01T09:30:00Z", "2024-03-01T10:45:00Z"} {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Message at " + createdAt})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		at, _ := time.Parse(time.RFC3339, createdAt)
+		if _, err := db.Exec("UPDATE messages SET created_at = ? WHERE id = ?", at.Format(timestampLayout), id); err != nil {
+			t.Fatalf("Failed to backdate message: %v", err)
+		}
+	}
+
+	tests := []struct {
+		interval domain.StatsInterval
+		want     []string
+	}{
+		{domain.IntervalDay, []string{"2024-03-01T00:00:00Z=2", "2024-03-03T00:00:00Z=1"}},
+		{domain.IntervalHour, []string{"2024-03-01T09:00:00Z=1", "2024-03-01T10:00:00Z=1", "2024-03-03T08:00:00Z=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.interval), func(t *testing.T) {
+			buckets, err := repo.CountByInterval(ctx, tt.interval)
+			if err != nil {
+				t.Fatalf("Failed to count messages: %v", err)
+			}
+			var got []string
+			for _, bucket := range buckets {
+				got = append(got, fmt.Sprintf("%s=%d", bucket.Bucket.Format(time.RFC3339), bucket.Count))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := repo.CountByInterval(ctx, "week"); err == nil {
+		t.Error("Expected an error for an unsupported interval")
+	}
+}
+
+func TestMessageRepository_GetBySlug(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	// Messages with the same opening words still get distinct slugs
+	first := &domain.Message{UserID: 1, Username: "testuser", Content: "Hello world, again"}
+	second := &domain.Message{UserID: 2, Username: "other", Content: "Hello world, again"}
+	var firstID int64
+	for _, message := range []*domain.Message{first, second} {
+		id, err := repo.Create(ctx, message)
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		if firstID == 0 {
+			firstID = id
+		}
+		if expected := domain.MessageSlug(id, message.Content); message.Slug != expected {
+			t.Errorf("Expected slug %q, got %q", expected, message.Slug)
+		}
+	}
+	if first.Slug == second.Slug {
+		t.Fatalf("Expected distinct slugs, both got %q", first.Slug)
+	}
+
+	for _, message := range []*domain.Message{first, second} {
+		found, err := repo.GetBySlug(ctx, message.Slug)
+		if err != nil {
+			t.Fatalf("Failed to get message by slug %q: %v", message.Slug, err)
+		}
+		if found.Username != message.Username || found.Slug != message.Slug {
+			t.Errorf("Expected %q to resolve to %s's message, got %+v", message.Slug, message.Username, found)
+		}
+	}
+
+	// Editing doesn't change the slug, so shared links keep working
+	if _, err := repo.Update(ctx, firstID, "Something else entirely", "", 1); err != nil {
+		t.Fatalf("Failed to update message: %v", err)
+	}
+	if found, err := repo.GetBySlug(ctx, first.Slug); err != nil || found.Content != "Something else entirely" {
+		t.Errorf("Expected the old slug to resolve to the edited message, got %+v (%v)", found, err)
+	}
+
+	if _, err := repo.GetBySlug(ctx, "999-missing"); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound for an unknown slug, got %v", err)
+	}
+}
+
+func TestMessageRepository_TrashAndRestore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+
+	id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Regrettable"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	commentID, err := repo.CreateComment(ctx, &domain.Comment{MessageID: id, UserID: 2, Username: "commenter", Content: "Reply", ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	// Delete then restore brings back the message and its comments
+	if err := repo.Trash(ctx, id); err != nil {
+		t.Fatalf("Failed to trash message: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, id); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected a trashed message to be gone, got %v", err)
+	}
+	if err := repo.Trash(ctx, id); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected trashing twice to fail with ErrMessageNotFound, got %v", err)
+	}
+	if err := repo.Restore(ctx, id, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("Failed to restore message: %v", err)
+	}
+	message, err := repo.GetByID(ctx, id)
+	if err != nil || message.Content != "Regrettable" {
+		t.Fatalf("Expected the restored message, got %+v (%v)", message, err)
+	}
+	if duplicate, err := repo.HasRecentDuplicate(ctx, 1, "Regrettable", time.Now().Add(-time.Hour)); err != nil || !duplicate {
+		t.Errorf("Expected the restored message to still count as a duplicate, got %v (%v)", duplicate, err)
+	}
+	comments, err := repo.GetComments(ctx, id, domain.SortAsc, false)
+	if err != nil || len(comments) != 1 || comments[0].ID != commentID {
+		t.Errorf("Expected comment %d restored, got %+v (%v)", commentID, comments, err)
+	}
+
+	// Past the retention it can't be restored, and the purge removes it for good
+	if err := repo.Trash(ctx, id); err != nil {
+		t.Fatalf("Failed to trash message: %v", err)
+	}
+	if err := repo.Restore(ctx, id, time.Now().Add(time.Hour)); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound restoring past the retention, got %v", err)
+	}
+	purged, err := repo.PurgeTrash(ctx, time.Now().Add(time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("Expected 1 message purged, got %d (%v)", purged, err)
+	}
+	if err := repo.Restore(ctx, id, time.Time{}); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected a purged message to be gone for good, got %v", err)
+	}
+	var leftover int
+	if err := db.QueryRow("SELECT COUNT(*) FROM deleted_comments").Scan(&leftover); err != nil || leftover != 0 {
+		t.Errorf("Expected purged comments to be gone, got %d (%v)", leftover, err)
+	}
+}
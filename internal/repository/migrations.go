@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// migration is one versioned step of the schema. Databases created before
+// migrations were tracked may already contain a step's changes, so every step
+// must be safe to apply to them.
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in the order it is applied. Append new
+// steps with the next version; never edit or reorder applied ones.
+var migrations = []migration{
+	{1, "create messages and comments", execAll(`
+		CREATE TABLE IF NOT EXISTS messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			is_banned BOOLEAN NOT NULL DEFAULT 0
+		)`, `
+		CREATE TABLE IF NOT EXISTS comments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_message_id ON comments(message_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_expires_at ON comments(expires_at)`,
+	)},
+	{2, "track message activity", steps(
+		addColumn("messages", "last_activity_at", "TIMESTAMP NOT NULL DEFAULT ''"),
+		execAll(
+			`UPDATE messages SET last_activity_at = created_at WHERE last_activity_at = ''`,
+			`CREATE INDEX IF NOT EXISTS idx_messages_last_activity_at ON messages(last_activity_at DESC)`,
+		),
+	)},
+	{3, "add message versions", addColumn("messages", "version", "INTEGER NOT NULL DEFAULT 1")},
+	{4, "add pinned messages", addColumn("messages", "pinned_at", "TIMESTAMP")},
+	{5, "add message replies", addColumn("messages", "reply_to_message_id", "INTEGER REFERENCES messages(id) ON DELETE SET NULL")},
+	{6, "add ephemeral messages", steps(
+		addColumn("messages", "expires_at", "TIMESTAMP"),
+		execAll(`CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at)`),
+	)},
+	{7, "add rendered message content", addColumn("messages", "content_html", "TEXT NOT NULL DEFAULT ''")},
+	{8, "add locked threads", addColumn("messages", "is_locked", "BOOLEAN NOT NULL DEFAULT 0")},
+	{9, "add per-message comment TTL", addColumn("messages", "comment_ttl_seconds", "INTEGER NOT NULL DEFAULT 0")},
+	{10, "create mentions", execAll(`
+		CREATE TABLE IF NOT EXISTS mentions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source_type TEXT NOT NULL,
+			source_id INTEGER NOT NULL,
+			mentioned_user_id INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			UNIQUE (source_type, source_id, mentioned_user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_mentions_user_created_at ON mentions(mentioned_user_id, created_at DESC)`,
+	)},
+	{11, "create message reads", execAll(`
+		CREATE TABLE IF NOT EXISTS message_reads (
+			user_id INTEGER PRIMARY KEY,
+			last_read_message_id INTEGER NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+	)},
+	{12, "index comments by user", execAll(`CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments(user_id)`)},
+	{13, "add hidden messages", addColumn("messages", "is_hidden", "BOOLEAN NOT NULL DEFAULT 0")},
+	{14, "create message trash", execAll(`
+		CREATE TABLE IF NOT EXISTS deleted_messages (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			is_banned BOOLEAN NOT NULL DEFAULT 0,
+			last_activity_at TIMESTAMP NOT NULL DEFAULT '',
+			version INTEGER NOT NULL DEFAULT 1,
+			pinned_at TIMESTAMP,
+			reply_to_message_id INTEGER,
+			expires_at TIMESTAMP,
+			content_html TEXT NOT NULL DEFAULT '',
+			is_locked BOOLEAN NOT NULL DEFAULT 0,
+			comment_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+			is_hidden BOOLEAN NOT NULL DEFAULT 0,
+			deleted_at TIMESTAMP NOT NULL
+		)`, `
+		CREATE TABLE IF NOT EXISTS deleted_comments (
+			id INTEGER PRIMARY KEY,
+			message_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_deleted_messages_deleted_at ON deleted_messages(deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_deleted_comments_message_id ON deleted_comments(message_id)`,
+	)},
+	// Existing messages keep an empty hash; the duplicate window is short, so
+	// they only go unmatched briefly after the upgrade
+	{15, "add message content hashes", steps(
+		addColumn("messages", "content_hash", "TEXT NOT NULL DEFAULT ''"),
+		execAll(`CREATE INDEX IF NOT EXISTS idx_messages_user_content_hash ON messages(user_id, content_hash, created_at)`),
+	)},
+	{16, "create subscriptions and notifications", execAll(`
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			user_id INTEGER NOT NULL,
+			message_id INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, message_id)
+		)`, `
+		CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			message_id INTEGER NOT NULL,
+			comment_id INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_subscriptions_message_id ON subscriptions(message_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user_created_at ON notifications(user_id, created_at DESC)`,
+	)},
+	{17, "add message slugs", steps(
+		addColumn("messages", "slug", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("deleted_messages", "slug", "TEXT NOT NULL DEFAULT ''"),
+		backfillSlugs("messages"),
+		backfillSlugs("deleted_messages"),
+		execAll(`CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_slug ON messages(slug) WHERE slug != ''`),
+	)},
+	// Messages already in the trash come back with an empty hash, as messages
+	// did after version 15
+	{18, "keep content hashes of deleted messages", addColumn("deleted_messages", "content_hash", "TEXT NOT NULL DEFAULT ''")},
+}
+
+// migrate applies every migration not yet recorded in schema_migrations, each
+// in its own transaction, in version order
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[int]bool)
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs m and records it as applied in one transaction
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.apply(tx); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
+		m.version, m.description, time.Now().UTC().Format(timestampLayout))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execAll returns a step that runs each statement in turn
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// steps returns a step that runs each of fns in turn
+func steps(fns ...func(tx *sql.Tx) error) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, fn := range fns {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// backfillSlugs returns a step that gives every message in table without a
+// slug the one it would have been created with
+func backfillSlugs(table string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		rows, err := tx.Query(fmt.Sprintf("SELECT id, content FROM %s WHERE slug = ''", table))
+		if err != nil {
+			return err
+		}
+		slugs := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return err
+			}
+			slugs[id] = domain.MessageSlug(id, content)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for id, slug := range slugs {
+			if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET slug = ? WHERE id = ?", table), slug, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumn returns a step that adds column to table unless it already exists
+func addColumn(table, column, definition string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		exists, err := columnExists(tx, table, column)
+		if err != nil || exists {
+			return err
+		}
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+		return err
+	}
+}
+
+// columnExists reports whether table has a column with the given name
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+func TestInitSchema_MigratesOldSchema(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// The schema as it was before any columns were added
+	for _, statement := range []string{
+		`CREATE TABLE messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			is_banned BOOLEAN NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE comments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			message_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+		)`,
+		`INSERT INTO messages (user_id, username, content, created_at) VALUES (1, 'olduser', 'Old message', '2024-01-01T00:00:00.000000000Z')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to create old schema: %v", err)
+		}
+	}
+
+	if err := InitSchema(db); err != nil {
+		t.Fatalf("Failed to migrate old schema: %v", err)
+	}
+
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatalf("Failed to count applied migrations: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Expected %d applied migrations, got %d", len(migrations), applied)
+	}
+
+	repo := NewMessageRepository(db)
+	message, err := repo.GetByID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Failed to read migrated message: %v", err)
+	}
+	if message.Content != "Old message" || message.Version != 1 || !message.LastActivityAt.Equal(message.CreatedAt) || message.Slug != "1-old-message" {
+		t.Errorf("Expected the old message with defaults filled in, got %+v", message)
+	}
+	if _, err := repo.CreateComment(context.Background(), &domain.Comment{MessageID: 1, UserID: 2, Username: "commenter", Content: "New comment"}); err != nil {
+		t.Errorf("Expected the migrated schema to accept new comments, got %v", err)
+	}
+
+	// Running again finds nothing to do
+	if err := InitSchema(db); err != nil {
+		t.Fatalf("Failed to re-run migrations: %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatalf("Failed to count applied migrations: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Expected re-running to apply nothing, got %d migrations", applied)
+	}
+}
+
+func TestInitSchema_AdoptsUntrackedSchema(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// A database from before migrations were tracked already has every column
+	if _, err := db.Exec("DROP TABLE schema_migrations"); err != nil {
+		t.Fatalf("Failed to drop migration history: %v", err)
+	}
+	if err := InitSchema(db); err != nil {
+		t.Fatalf("Expected migrations to skip existing columns, got %v", err)
+	}
+}
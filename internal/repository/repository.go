@@ -3,25 +3,51 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"io"
 	"os"
+	"time"
 
 	"github.com/atmega-p471/forum-service/internal/domain"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog"
 )
 
 // Repository encapsulates all repositories
 type Repository struct {
 	Message domain.MessageRepository
+
+	db *timedDB
 }
 
 // NewRepository creates a new repository
 func NewRepository(db *sql.DB) *Repository {
+	timed := newTimedDB(db)
 	return &Repository{
-		Message: NewMessageRepository(db),
+		Message: newMessageRepository(timed),
+		db:      timed,
+	}
+}
+
+// LogSlowQueries sets where and above which duration slow queries are logged.
+// A non-positive threshold disables the log. It must be called before the
+// repository is used.
+func (r *Repository) LogSlowQueries(logger zerolog.Logger, threshold time.Duration) {
+	r.db.logger = logger
+	r.db.threshold = threshold
+}
+
+// Close releases the repositories' resources and closes the database
+func (r *Repository) Close() error {
+	var errs []error
+	if closer, ok := r.Message.(io.Closer); ok {
+		errs = append(errs, closer.Close())
 	}
+	errs = append(errs, r.db.Close())
+	return errors.Join(errs...)
 }
 
-// InitSchema initializes the database schema
+// InitSchema initializes the database schema, applying any migrations that
+// haven't run against it yet
 func InitSchema(db *sql.DB) error {
 	// Enable foreign key support
 	_, err := db.Exec("PRAGMA foreign_keys = ON")
@@ -34,49 +60,8 @@ func InitSchema(db *sql.DB) error {
 		return err
 	}
 
-	// Create messages table (only if it doesn't exist)
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			username TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL,
-			is_banned BOOLEAN NOT NULL DEFAULT 0
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create comments table (only if it doesn't exist)
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS comments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			message_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			username TEXT NOT NULL,
-			content TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL,
-			expires_at TIMESTAMP NOT NULL,
-			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create indexes for better performance
-	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at DESC)`)
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_comments_message_id ON comments(message_id)`)
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_comments_expires_at ON comments(expires_at)`)
-	if err != nil {
+	// Bring the schema up to date
+	if err := migrate(db); err != nil {
 		return err
 	}
 
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultSlowQueryThreshold is how long a query may take before it is logged as slow
+const DefaultSlowQueryThreshold = 100 * time.Millisecond
+
+// maxLoggedQueryLength truncates the SQL included in slow query warnings
+const maxLoggedQueryLength = 200
+
+// querier is the part of *sql.DB whose calls are timed
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// timedDB is a *sql.DB whose ExecContext, QueryContext and QueryRowContext log
+// a warning when they take longer than threshold
+type timedDB struct {
+	*sql.DB
+	// next runs the timed calls; it is the DB itself outside tests
+	next      querier
+	threshold time.Duration
+	logger    zerolog.Logger
+}
+
+// newTimedDB wraps db, logging queries slower than DefaultSlowQueryThreshold
+// to the global zerolog logger
+func newTimedDB(db *sql.DB) *timedDB {
+	return &timedDB{
+		DB:        db,
+		next:      db,
+		threshold: DefaultSlowQueryThreshold,
+		logger:    log.Logger,
+	}
+}
+
+// ExecContext runs an ExecContext on the underlying DB and times it
+func (t *timedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer t.observe(query, time.Now())
+	return t.next.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs a QueryContext on the underlying DB and times it
+func (t *timedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer t.observe(query, time.Now())
+	return t.next.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a QueryRowContext on the underlying DB and times it
+func (t *timedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer t.observe(query, time.Now())
+	return t.next.QueryRowContext(ctx, query, args...)
+}
+
+// observe logs query if it has run for longer than the threshold since start.
+// A non-positive threshold disables logging.
+func (t *timedDB) observe(query string, start time.Time) {
+	elapsed := time.Since(start)
+	if t.threshold <= 0 || elapsed < t.threshold {
+		return
+	}
+
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedQueryLength {
+		query = query[:maxLoggedQueryLength] + "..."
+	}
+	t.logger.Warn().
+		Str("operation", operationName()).
+		Dur("elapsed", elapsed).
+		Dur("threshold", t.threshold).
+		Str("query", query).
+		Msg("Slow database query")
+}
+
+// operationName returns the exported repository method that issued the
+// query being observed, such as "List", or "unknown"
+func operationName() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		name := frame.Function[strings.LastIndex(frame.Function, ".")+1:]
+		if strings.Contains(frame.Function, "Repository") && name != "" && unicode.IsUpper(rune(name[0])) {
+			return name
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
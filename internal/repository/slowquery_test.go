@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// slowDB delays every ExecContext before running it on the real database
+type slowDB struct {
+	*sql.DB
+	delay time.Duration
+}
+
+func (s slowDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	time.Sleep(s.delay)
+	return s.DB.ExecContext(ctx, query, args...)
+}
+
+func TestTimedDB_LogsSlowQueries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	var logs bytes.Buffer
+	timed := newTimedDB(db)
+	timed.next = slowDB{DB: db, delay: 20 * time.Millisecond}
+	timed.logger = zerolog.New(&logs)
+	repo := newMessageRepository(timed)
+	ctx := context.Background()
+
+	timed.threshold = time.Second
+	if err := repo.Ban(ctx, 1); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("Expected no log for a query under the threshold, got %s", logs.String())
+	}
+
+	timed.threshold = 10 * time.Millisecond
+	if err := repo.Ban(ctx, 1); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	out := logs.String()
+	for _, want := range []string{`"level":"warn"`, `"operation":"Ban"`, `"query":"UPDATE messages SET is_banned = 1 WHERE id = ?"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected slow query log to contain %s, got %s", want, out)
+		}
+	}
+
+	logs.Reset()
+	timed.threshold = 0
+	if err := repo.Ban(ctx, 1); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected a zero threshold to disable the log, got %s", logs.String())
+	}
+}
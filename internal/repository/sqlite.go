@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBusyTimeout is how long a connection waits for another writer's lock
+// before giving up with "database is locked"
+const DefaultBusyTimeout = 5 * time.Second
+
+// DefaultJournalMode lets readers and a writer use the database at the same time
+const DefaultJournalMode = "WAL"
+
+// DSN returns the SQLite data source name for the database at path. Every
+// connection waits up to busyTimeout for locks held by other connections,
+// and uses journalMode when it isn't empty. Transactions take the write lock
+// when they begin, so two of them can't both read and then deadlock trying
+// to write, which the busy timeout can't resolve.
+func DSN(path string, busyTimeout time.Duration, journalMode string) string {
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	params.Set("_txlock", "immediate")
+	if journalMode != "" {
+		params.Set("_journal_mode", journalMode)
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + params.Encode()
+}
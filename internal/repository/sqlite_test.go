@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+func TestDSN_ConcurrentWrites(t *testing.T) {
+	db, err := sql.Open("sqlite3", DSN(filepath.Join(t.TempDir(), "forum.db"), DefaultBusyTimeout, DefaultJournalMode))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := InitSchema(db); err != nil {
+		t.Fatalf("Failed to initialize schema: %v", err)
+	}
+
+	var journalMode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Errorf("Expected WAL journal mode, got %q (%v)", journalMode, err)
+	}
+
+	repo := NewMessageRepository(db)
+	ctx := context.Background()
+	parent, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Thread"})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	const writers, writes = 20, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*writes*2)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				content := fmt.Sprintf("Writer %d message %d", w, i)
+				if _, err := repo.Create(ctx, &domain.Message{UserID: int64(w + 1), Username: "writer", Content: content}); err != nil {
+					errs <- err
+				}
+				if _, err := repo.CreateComment(ctx, &domain.Comment{MessageID: parent, UserID: int64(w + 1), Username: "writer", Content: content}); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Concurrent write failed: %v", err)
+	}
+	if _, total, err := repo.List(ctx, 1, 0); err != nil || total != writers*writes+1 {
+		t.Errorf("Expected %d messages, got %d (%v)", writers*writes+1, total, err)
+	}
+}
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/atmega-p471/forum-service/internal/config"
+	"github.com/atmega-p471/forum-service/internal/domain"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Content modes control how message content is processed before it's stored
+const (
+	// ContentModePlain stores content exactly as submitted
+	ContentModePlain = config.ContentModePlain
+	// ContentModeSanitized strips HTML tags and escapes the rest, so the stored
+	// content is safe to render as HTML
+	ContentModeSanitized = config.ContentModeSanitized
+	// ContentModeMarkdown keeps the raw content and stores a safe HTML
+	// rendering of its markdown alongside it as content_html
+	ContentModeMarkdown = config.ContentModeMarkdown
+)
+
+var (
+	// stripPolicy removes every HTML element
+	stripPolicy = bluemonday.StrictPolicy()
+	// markdownPolicy allows the elements markdown can produce while dropping
+	// scripts, event handlers and unsafe URLs
+	markdownPolicy = bluemonday.UGCPolicy()
+	// markdownRenderer renders CommonMark without passing through raw HTML
+	markdownRenderer = goldmark.New()
+)
+
+// validateContentMode checks that mode is one of the supported content modes
+func validateContentMode(mode string) error {
+	switch mode {
+	case ContentModePlain, ContentModeSanitized, ContentModeMarkdown:
+		return nil
+	}
+	return fmt.Errorf("unknown content mode %q", mode)
+}
+
+// prepareContent rejects content that isn't valid UTF-8 and, when nfc is set,
+// normalizes it to NFC so visually identical text is stored identically
+func prepareContent(content string, nfc bool) (string, error) {
+	if err := domain.ValidateContentEncoding(content); err != nil {
+		return "", err
+	}
+	if nfc {
+		return norm.NFC.String(content), nil
+	}
+	return content, nil
+}
+
+// renderContent applies mode to content, returning the content to store and,
+// for markdown, its rendered HTML
+func renderContent(mode, content string) (string, string, error) {
+	switch mode {
+	case ContentModeSanitized:
+		sanitized := strings.TrimSpace(stripPolicy.Sanitize(content))
+		if sanitized == "" {
+			return "", "", ErrMessageEmpty
+		}
+		return sanitized, "", nil
+	case ContentModeMarkdown:
+		var buf bytes.Buffer
+		if err := markdownRenderer.Convert([]byte(content), &buf); err != nil {
+			return "", "", err
+		}
+		return content, markdownPolicy.Sanitize(buf.String()), nil
+	default:
+		return content, "", nil
+	}
+}
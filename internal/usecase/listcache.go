@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// listCache coalesces concurrent identical message list queries into a single
+// repository call, and keeps first pages for ttl so a burst of clients loading
+// the front page share one query. A nil listCache passes every call through.
+type listCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	// gen is bumped by invalidate so results fetched before a write aren't cached
+	gen   uint64
+	calls map[listKey]*listCall
+	pages map[listKey]*listCall
+}
+
+// listKey identifies a list query
+type listKey struct {
+	active        bool
+	limit, offset int64
+}
+
+// listCall is an in-flight or completed list query
+type listCall struct {
+	done     chan struct{}
+	messages []*domain.Message
+	total    int64
+	err      error
+	expires  time.Time
+}
+
+// newListCache creates a list cache keeping first pages for ttl. A
+// non-positive ttl only coalesces concurrent queries.
+func newListCache(ttl time.Duration) *listCache {
+	return &listCache{
+		ttl:   ttl,
+		calls: make(map[listKey]*listCall),
+		pages: make(map[listKey]*listCall),
+	}
+}
+
+// setTTL changes how long first pages are cached
+func (c *listCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.pages = make(map[listKey]*listCall)
+}
+
+// get returns the list for key from the cache, from an identical query
+// already in flight, or by calling fetch
+func (c *listCache) get(ctx context.Context, key listKey, fetch func(context.Context) ([]*domain.Message, int64, error)) ([]*domain.Message, int64, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+
+	for {
+		c.mu.Lock()
+		if page, ok := c.pages[key]; ok && time.Now().Before(page.expires) {
+			c.mu.Unlock()
+			return page.messages, page.total, nil
+		}
+		if call, ok := c.calls[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, 0, ctx.Err()
+			}
+			// The query ran on the first caller's context; if that caller went
+			// away, try again on ours rather than failing with their error
+			if isContextError(call.err) && ctx.Err() == nil {
+				continue
+			}
+			return call.messages, call.total, call.err
+		}
+
+		call := &listCall{done: make(chan struct{})}
+		c.calls[key] = call
+		gen := c.gen
+		c.mu.Unlock()
+
+		call.messages, call.total, call.err = fetch(ctx)
+
+		c.mu.Lock()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+		if call.err == nil && key.offset == 0 && c.ttl > 0 && gen == c.gen {
+			call.expires = time.Now().Add(c.ttl)
+			c.pages[key] = call
+		}
+		c.mu.Unlock()
+		close(call.done)
+
+		return call.messages, call.total, call.err
+	}
+}
+
+// invalidate drops cached pages after a write that changes message lists.
+// Queries already in flight still complete, but their results aren't cached.
+func (c *listCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.calls = make(map[listKey]*listCall)
+	c.pages = make(map[listKey]*listCall)
+}
+
+// isContextError reports whether err is a context cancellation or timeout
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
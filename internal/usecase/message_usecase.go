@@ -1,47 +1,216 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
-	"github.com/atmega-p471/forum-service/internal/delivery/grpc/client"
+	"github.com/atmega-p471/forum-service/internal/config"
 	"github.com/atmega-p471/forum-service/internal/domain"
 )
 
 var (
-	ErrMessageNotFound = errors.New("message not found")
+	ErrMessageNotFound = domain.ErrMessageNotFound
 	ErrUserBanned      = errors.New("user is banned")
 	ErrMessageTooLong  = errors.New("message is too long")
 	ErrMessageEmpty    = errors.New("message cannot be empty")
 	ErrInternalError   = errors.New("internal error")
 )
 
+// maxMentionsPerPost caps how many @mentions in one post are resolved
+const maxMentionsPerPost = 10
+
 // MessageUseCase implements domain.MessageUseCase
 type MessageUseCase struct {
-	repo       domain.MessageRepository
-	authClient *client.AuthClient
-	hub        Hub
+	repo          domain.MessageRepository
+	authClient    AuthClient
+	hub           Hub
+	contentMode   string
+	commentTTL    time.Duration
+	maxCommentTTL time.Duration
+	// commentsExpire is false when comments are kept forever
+	commentsExpire bool
+	// resetCommentExpiryOnEdit gives an edited comment a fresh lifetime
+	resetCommentExpiryOnEdit bool
+	// normalizeContent is set to store content in Unicode NFC
+	normalizeContent bool
+	// requireAuthForMessages and requireAuthForComments reject anonymous
+	// (user ID 0) posts of that kind
+	requireAuthForMessages bool
+	requireAuthForComments bool
+	bumpWindow             time.Duration
+	// duplicateWindow is how long a user can't repost identical content; zero allows it
+	duplicateWindow time.Duration
+	// trendingWindow is how far back comments count towards trending
+	trendingWindow time.Duration
+	// trashRetention is how long deleted messages can be restored; zero deletes them outright
+	trashRetention time.Duration
+	bannedWords    wordFilter
+	limits         domain.Limits
+	lists          *listCache
+	// userBanned is called with each user whose content is banned
+	userBanned []func(userID int64)
+	// readOnly is set while writes are blocked for maintenance
+	readOnly atomic.Bool
+
+	// done is closed by StopSchedulers to end the background jobs
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// AuthClient defines the auth service calls the usecase depends on
+type AuthClient interface {
+	GetUser(id int64) (*domain.User, error)
 }
 
 // Hub defines a minimal interface for the WebSocket hub
 type Hub interface {
 	BroadcastMessage(*domain.Message)
+	BroadcastComment(*domain.Comment)
 }
 
 // NewMessageUseCase creates a new message usecase
-func NewMessageUseCase(repo domain.MessageRepository, authClient *client.AuthClient, hub Hub) domain.MessageUseCase {
+func NewMessageUseCase(repo domain.MessageRepository, authClient AuthClient, hub Hub) domain.MessageUseCase {
 	return &MessageUseCase{
-		repo:       repo,
-		authClient: authClient,
-		hub:        hub,
+		repo:           repo,
+		authClient:     authClient,
+		hub:            hub,
+		contentMode:    ContentModePlain,
+		commentTTL:     config.DefaultCommentTTL,
+		maxCommentTTL:  config.DefaultMaxCommentTTL,
+		commentsExpire: true,
+		trendingWindow: config.DefaultTrendingWindow,
+		limits:         domain.DefaultLimits(),
+		lists:          newListCache(0),
+		done:           make(chan struct{}),
+	}
+}
+
+// SetContentMode sets how message content is processed before it's stored:
+// ContentModePlain, ContentModeSanitized or ContentModeMarkdown
+func (u *MessageUseCase) SetContentMode(mode string) error {
+	if err := validateContentMode(mode); err != nil {
+		return err
+	}
+	u.contentMode = mode
+	return nil
+}
+
+// SetCommentTTL sets how long comments live on messages without their own
+// comment TTL, and the largest comment TTL a message may set
+func (u *MessageUseCase) SetCommentTTL(ttl, maxTTL time.Duration) error {
+	if ttl <= 0 || maxTTL < ttl {
+		return fmt.Errorf("%w: default %s, max %s", domain.ErrInvalidCommentTTL, ttl, maxTTL)
+	}
+	u.commentTTL = ttl
+	u.maxCommentTTL = maxTTL
+	return nil
+}
+
+// SetCommentsExpire turns comment expiry on or off. With expiry off new
+// comments never expire, expired comments that haven't been cleaned up yet
+// are listed again, and cleanup leaves comments alone.
+func (u *MessageUseCase) SetCommentsExpire(expire bool) {
+	u.commentsExpire = expire
+}
+
+// SetResetCommentExpiryOnEdit makes edited comments start a new lifetime,
+// as if they had just been posted, instead of keeping their original expiry
+func (u *MessageUseCase) SetResetCommentExpiryOnEdit(reset bool) {
+	u.resetCommentExpiryOnEdit = reset
+}
+
+// SetNormalizeContent turns NFC normalization of new and edited content on or off
+func (u *MessageUseCase) SetNormalizeContent(normalize bool) {
+	u.normalizeContent = normalize
+}
+
+// SetRequireAuth sets whether anonymous callers may create messages and
+// comments. Either can be closed to anonymous posts while the other stays open.
+func (u *MessageUseCase) SetRequireAuth(messages, comments bool) {
+	u.requireAuthForMessages = messages
+	u.requireAuthForComments = comments
+}
+
+// SetBumpWindow stops comments on messages older than window from bumping
+// their last activity. Zero lets every comment bump.
+func (u *MessageUseCase) SetBumpWindow(window time.Duration) {
+	u.bumpWindow = window
+}
+
+// SetDuplicateWindow rejects messages whose content exactly matches one the
+// same user posted within window. Zero turns the check off.
+func (u *MessageUseCase) SetDuplicateWindow(window time.Duration) {
+	u.duplicateWindow = window
+}
+
+// OnUserBanned registers fn to be called with a user's ID when their content
+// is banned, e.g. to drop anything cached about the user
+func (u *MessageUseCase) OnUserBanned(fn func(userID int64)) {
+	u.userBanned = append(u.userBanned, fn)
+}
+
+// SetTrendingWindow sets how far back comments count towards a message trending
+func (u *MessageUseCase) SetTrendingWindow(window time.Duration) {
+	u.trendingWindow = window
+}
+
+// SetTrashRetention keeps deleted messages restorable for retention before
+// they are purged. Zero deletes messages permanently straight away.
+func (u *MessageUseCase) SetTrashRetention(retention time.Duration) {
+	u.trashRetention = retention
+}
+
+// SetBannedWords rejects messages and comments whose content or username
+// contains any of words, matched as whole words ignoring case
+func (u *MessageUseCase) SetBannedWords(words []string) {
+	u.bannedWords = newWordFilter(words)
+}
+
+// SetLimits sets the content and username length limits posts are validated against
+func (u *MessageUseCase) SetLimits(limits domain.Limits) {
+	u.limits = limits
+}
+
+// SetListCacheTTL sets how long first pages of message lists are served from
+// memory. Concurrent identical list queries are coalesced regardless; a
+// non-positive ttl disables only the cache.
+func (u *MessageUseCase) SetListCacheTTL(ttl time.Duration) {
+	u.lists.setTTL(ttl)
+}
+
+// IsReadOnly reports whether writes are blocked for maintenance
+func (u *MessageUseCase) IsReadOnly() bool {
+	return u.readOnly.Load()
+}
+
+// SetReadOnly blocks or allows writes. While blocked, every create, update,
+// ban and delete fails with domain.ErrReadOnly and the cleanup and retention
+// schedulers skip their runs; reads are unaffected.
+func (u *MessageUseCase) SetReadOnly(readOnly bool) {
+	u.readOnly.Store(readOnly)
+}
+
+// checkWritable returns domain.ErrReadOnly while writes are blocked
+func (u *MessageUseCase) checkWritable() error {
+	if u.readOnly.Load() {
+		return domain.ErrReadOnly
 	}
+	return nil
 }
 
 // GetMessages gets a list of messages
-func (u *MessageUseCase) GetMessages(limit, offset int64) ([]*domain.Message, int64, error) {
+func (u *MessageUseCase) GetMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
 	log.Printf("Getting messages with limit: %d, offset: %d", limit, offset)
-	messages, total, err := u.repo.List(limit, offset)
+	messages, total, err := u.lists.get(ctx, listKey{limit: limit, offset: offset}, func(ctx context.Context) ([]*domain.Message, int64, error) {
+		return u.repo.List(ctx, limit, offset)
+	})
 	if err != nil {
 		log.Printf("Error getting messages from repository: %v", err)
 		return nil, 0, err
@@ -50,10 +219,40 @@ func (u *MessageUseCase) GetMessages(limit, offset int64) ([]*domain.Message, in
 	return messages, total, nil
 }
 
+// GetActiveMessages gets a list of messages ordered by most recent activity
+func (u *MessageUseCase) GetActiveMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	log.Printf("Getting active messages with limit: %d, offset: %d", limit, offset)
+	messages, total, err := u.lists.get(ctx, listKey{active: true, limit: limit, offset: offset}, func(ctx context.Context) ([]*domain.Message, int64, error) {
+		return u.repo.ListByActivity(ctx, limit, offset)
+	})
+	if err != nil {
+		log.Printf("Error getting active messages from repository: %v", err)
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// GetLatestMessage gets the newest message in the feed, so clients can
+// cheaply check whether anything has been posted since they last looked
+func (u *MessageUseCase) GetLatestMessage(ctx context.Context) (*domain.Message, error) {
+	return u.repo.Latest(ctx)
+}
+
+// GetTrendingMessages gets visible messages ordered by how many comments they
+// got within the trending window
+func (u *MessageUseCase) GetTrendingMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	messages, total, err := u.repo.ListTrending(ctx, time.Now().Add(-u.trendingWindow), limit, offset)
+	if err != nil {
+		log.Printf("Error getting trending messages from repository: %v", err)
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
 // GetAllMessages gets all messages (admin only)
-func (u *MessageUseCase) GetAllMessages() ([]*domain.Message, error) {
+func (u *MessageUseCase) GetAllMessages(ctx context.Context) ([]*domain.Message, error) {
 	log.Printf("Getting all messages for admin")
-	messages, err := u.repo.GetAllMessages()
+	messages, err := u.repo.GetAllMessages(ctx)
 	if err != nil {
 		log.Printf("Error getting all messages from repository: %v", err)
 		return nil, err
@@ -62,15 +261,59 @@ func (u *MessageUseCase) GetAllMessages() ([]*domain.Message, error) {
 	return messages, nil
 }
 
+// PreviewContent renders content the way CreateMessage would store it,
+// without storing anything
+func (u *MessageUseCase) PreviewContent(ctx context.Context, content string) (*domain.RenderedContent, error) {
+	content, err := prepareContent(content, u.normalizeContent)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, contentHTML, err := renderContent(u.contentMode, content)
+	if err != nil && !errors.Is(err, ErrMessageEmpty) {
+		return nil, err
+	}
+
+	// Content that sanitizes to nothing is reported like any other empty content
+	if err := domain.ValidateMessageContent(rendered, u.limits); err != nil {
+		return nil, err
+	}
+	return &domain.RenderedContent{Content: rendered, ContentHTML: contentHTML}, nil
+}
+
 // CreateMessage creates a new message
-func (u *MessageUseCase) CreateMessage(userID int64, username, content string) (*domain.Message, error) {
+func (u *MessageUseCase) CreateMessage(ctx context.Context, userID int64, username, content string) (*domain.Message, error) {
+	return u.CreateMessageWithOptions(ctx, userID, username, content, domain.MessageOptions{})
+}
+
+// CreateMessageWithOptions creates a new message, optionally as a reply to another message
+func (u *MessageUseCase) CreateMessageWithOptions(ctx context.Context, userID int64, username, content string, opts domain.MessageOptions) (*domain.Message, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, err
+	}
+
 	log.Printf("Creating message for user %d (%s)", userID, username)
 
+	if userID == 0 && u.requireAuthForMessages {
+		return nil, domain.ErrAuthRequired
+	}
 	if content == "" {
 		log.Printf("Empty content provided")
 		return nil, errors.New("content is required")
 	}
 
+	content, err := prepareContent(content, u.normalizeContent)
+	if err != nil {
+		log.Printf("Invalid message content: %v", err)
+		return nil, err
+	}
+
+	content, contentHTML, err := renderContent(u.contentMode, content)
+	if err != nil {
+		log.Printf("Error rendering message content: %v", err)
+		return nil, err
+	}
+
 	// Skip auth validation for anonymous users (ID=0)
 	if userID != 0 {
 		// Validate user ID
@@ -89,15 +332,49 @@ func (u *MessageUseCase) CreateMessage(userID int64, username, content string) (
 
 	// Create message
 	message := &domain.Message{
-		UserID:    userID,
-		Username:  username,
-		Content:   content,
-		CreatedAt: time.Now().UTC(),
-		IsBanned:  false,
+		UserID:      userID,
+		Username:    username,
+		Content:     content,
+		ContentHTML: contentHTML,
+		IsBanned:    false,
+	}
+	if err := message.Validate(u.limits); err != nil {
+		log.Printf("Invalid message: %v", err)
+		return nil, err
+	}
+	if err := u.bannedWords.check(username, content); err != nil {
+		log.Printf("Rejected message from user %d: %v", userID, err)
+		return nil, err
+	}
+	if err := u.checkDuplicate(ctx, userID, content); err != nil {
+		return nil, err
+	}
+
+	if opts.ExpiresIn < 0 {
+		return nil, errors.New("expiry must be positive")
+	}
+	if err := validateCommentTTL(opts.CommentTTL, u.maxCommentTTL); err != nil {
+		return nil, err
+	}
+	message.CommentTTLSeconds = int64(opts.CommentTTL / time.Second)
+	if opts.ExpiresIn > 0 {
+		expiresAt := time.Now().UTC().Add(opts.ExpiresIn)
+		message.ExpiresAt = &expiresAt
+	}
+
+	// Resolve the quoted message, which must exist and not be banned
+	if opts.ReplyToMessageID != 0 {
+		quoted, err := u.repo.GetByID(ctx, opts.ReplyToMessageID)
+		if err != nil || quoted.IsBanned || quoted.IsHidden || quoted.IsExpired() {
+			log.Printf("Invalid reply target %d", opts.ReplyToMessageID)
+			return nil, domain.ErrInvalidReplyTarget
+		}
+		message.ReplyToMessageID = &quoted.ID
+		message.ReplyTo = domain.NewMessageReference(quoted)
 	}
 
 	// Save message
-	messageID, err := u.repo.Create(message)
+	messageID, err := u.repo.Create(ctx, message)
 	if err != nil {
 		log.Printf("Error creating message in repository: %v", err)
 		return nil, err
@@ -107,25 +384,94 @@ func (u *MessageUseCase) CreateMessage(userID int64, username, content string) (
 	message.ID = messageID
 	log.Printf("Successfully created message with ID: %d", messageID)
 
+	u.recordMentions(ctx, domain.MentionSourceMessage, messageID, content)
+
 	// Broadcast message
-	u.hub.BroadcastMessage(message)
+	u.publish(message)
 
 	return message, nil
 }
 
+// checkDuplicate returns ErrDuplicateContent if userID posted content within
+// the duplicate window. Anonymous posts share user ID 0, so they aren't checked.
+func (u *MessageUseCase) checkDuplicate(ctx context.Context, userID int64, content string) error {
+	if u.duplicateWindow <= 0 || userID == 0 {
+		return nil
+	}
+	duplicate, err := u.repo.HasRecentDuplicate(ctx, userID, content, time.Now().Add(-u.duplicateWindow))
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		log.Printf("Rejected duplicate message from user %d", userID)
+		return domain.ErrDuplicateContent
+	}
+	return nil
+}
+
+// UpdateMessage edits the content of a message owned by userID. The edit is
+// rejected with domain.ErrVersionConflict unless expectedVersion is current.
+func (u *MessageUseCase) UpdateMessage(ctx context.Context, id, userID int64, content string, expectedVersion int64) (*domain.Message, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Updating message %d for user %d (version %d)", id, userID, expectedVersion)
+
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if message.UserID != userID {
+		log.Printf("User %d is not the author of message %d", userID, id)
+		return nil, domain.ErrNotMessageAuthor
+	}
+
+	content, err = prepareContent(content, u.normalizeContent)
+	if err != nil {
+		return nil, err
+	}
+
+	edited := *message
+	edited.Content, edited.ContentHTML, err = renderContent(u.contentMode, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := edited.Validate(u.limits); err != nil {
+		return nil, err
+	}
+
+	version, err := u.repo.Update(ctx, id, edited.Content, edited.ContentHTML, expectedVersion)
+	if err != nil {
+		log.Printf("Error updating message %d in repository: %v", id, err)
+		return nil, err
+	}
+	edited.Version = version
+	log.Printf("Successfully updated message %d to version %d", id, version)
+
+	// Broadcast updated message
+	u.publish(&edited)
+
+	return &edited, nil
+}
+
 // BanMessage bans a message
-func (u *MessageUseCase) BanMessage(id int64) error {
+func (u *MessageUseCase) BanMessage(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
 	// Check if message exists
-	message, err := u.repo.GetByID(id)
+	message, err := u.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	if message == nil {
-		return errors.New("message not found")
+		return domain.ErrMessageNotFound
 	}
 
 	// Ban message
-	err = u.repo.Ban(id)
+	err = u.repo.Ban(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -134,24 +480,28 @@ func (u *MessageUseCase) BanMessage(id int64) error {
 	message.IsBanned = true
 
 	// Broadcast updated message
-	u.hub.BroadcastMessage(message)
+	u.publish(message)
 
 	return nil
 }
 
 // UnbanMessage unbans a message
-func (u *MessageUseCase) UnbanMessage(id int64) error {
+func (u *MessageUseCase) UnbanMessage(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
 	// Check if message exists
-	message, err := u.repo.GetByID(id)
+	message, err := u.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	if message == nil {
-		return errors.New("message not found")
+		return domain.ErrMessageNotFound
 	}
 
 	// Unban message
-	err = u.repo.Unban(id)
+	err = u.repo.Unban(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -160,124 +510,1072 @@ func (u *MessageUseCase) UnbanMessage(id int64) error {
 	message.IsBanned = false
 
 	// Broadcast updated message
-	u.hub.BroadcastMessage(message)
+	u.publish(message)
 
 	return nil
 }
 
-func (u *MessageUseCase) GetByID(id int64) (*domain.Message, error) {
-	message, err := u.repo.GetByID(id)
+// BanMessages bans the given messages together and broadcasts each one.
+// IDs that don't exist are returned as failed; the rest are banned even if
+// some were banned already.
+func (u *MessageUseCase) BanMessages(ctx context.Context, ids []int64) ([]int64, []int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, nil, err
+	}
+
+	messages, err := u.repo.GetByIDs(ctx, ids)
 	if err != nil {
-		return nil, err
+		log.Printf("Error loading messages %v to ban: %v", ids, err)
+		return nil, nil, err
 	}
 
-	if message == nil {
-		return nil, ErrMessageNotFound
+	found := make(map[int64]bool, len(messages))
+	var banned []int64
+	for _, message := range messages {
+		found[message.ID] = true
+		banned = append(banned, message.ID)
+	}
+	var failed []int64
+	for _, id := range ids {
+		if !found[id] {
+			failed = append(failed, id)
+		}
 	}
 
-	return message, nil
+	if _, err := u.repo.BanByIDs(ctx, banned); err != nil {
+		log.Printf("Error banning messages %v: %v", banned, err)
+		return nil, nil, err
+	}
+
+	// Broadcast updated messages
+	for _, message := range messages {
+		message.IsBanned = true
+		u.publish(message)
+	}
+
+	return banned, failed, nil
 }
 
-// CreateComment creates a new comment
-func (u *MessageUseCase) CreateComment(messageID, userID int64, username, content string) (*domain.Comment, error) {
-	if content == "" {
-		return nil, errors.New("content is required")
+// UnbanMessages unbans the given messages together and broadcasts each one.
+// IDs that don't exist are returned as failed; the rest are unbanned even if
+// some weren't banned to begin with.
+func (u *MessageUseCase) UnbanMessages(ctx context.Context, ids []int64) ([]int64, []int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, nil, err
 	}
 
-	// Skip auth validation for anonymous users (ID=0)
-	if userID != 0 {
-		// Validate user ID
-		user, err := u.authClient.GetUser(userID)
-		if err != nil {
-			return nil, err
-		}
+	unbanned, err := u.repo.UnbanByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Error unbanning messages %v: %v", ids, err)
+		return nil, nil, err
+	}
 
-		// Check if user is banned
-		if user.IsBanned {
-			return nil, errors.New("user is banned")
+	found := make(map[int64]bool, len(unbanned))
+	for _, id := range unbanned {
+		found[id] = true
+	}
+	var failed []int64
+	for _, id := range ids {
+		if !found[id] {
+			failed = append(failed, id)
 		}
 	}
 
-	// Create comment
-	comment := &domain.Comment{
-		MessageID: messageID,
-		UserID:    userID,
-		Username:  username,
-		Content:   content,
-		CreatedAt: time.Now(),
+	// Broadcast updated messages
+	messages, err := u.repo.GetByIDs(ctx, unbanned)
+	if err != nil {
+		log.Printf("Error loading unbanned messages for broadcast: %v", err)
+	}
+	for _, message := range messages {
+		u.publish(message)
 	}
 
-	// Save comment
-	commentID, err := u.repo.CreateComment(comment)
+	return unbanned, failed, nil
+}
+
+// BanUserContent bans all of a user's messages, e.g. when the auth service
+// bans the user, and broadcasts each one that was hidden
+func (u *MessageUseCase) BanUserContent(ctx context.Context, userID int64) (int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return 0, err
+	}
+	return u.setUserContentBanned(ctx, userID, true)
+}
+
+// UnbanUserContent unbans all of a user's messages and broadcasts each one that was restored
+func (u *MessageUseCase) UnbanUserContent(ctx context.Context, userID int64) (int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return 0, err
+	}
+	return u.setUserContentBanned(ctx, userID, false)
+}
+
+// setUserContentBanned bans or unbans all of a user's messages and broadcasts the changes
+func (u *MessageUseCase) setUserContentBanned(ctx context.Context, userID int64, banned bool) (int64, error) {
+	messages, err := u.repo.ListByUser(ctx, userID)
 	if err != nil {
-		return nil, err
+		log.Printf("Error listing messages for user %d: %v", userID, err)
+		return 0, err
 	}
 
-	// Set comment ID
-	comment.ID = commentID
+	var updated int64
+	if banned {
+		updated, err = u.repo.BanMessagesByUser(ctx, userID)
+	} else {
+		updated, err = u.repo.UnbanMessagesByUser(ctx, userID)
+	}
+	if err != nil {
+		log.Printf("Error setting banned=%t on messages for user %d: %v", banned, userID, err)
+		return 0, err
+	}
+	log.Printf("Set banned=%t on %d messages for user %d", banned, updated, userID)
+	if banned {
+		for _, fn := range u.userBanned {
+			fn(userID)
+		}
+	}
 
-	return comment, nil
+	// Broadcast updated messages
+	for _, message := range messages {
+		if message.IsBanned != banned {
+			message.IsBanned = banned
+			u.publish(message)
+		}
+	}
+
+	return updated, nil
 }
 
-// GetComments gets all comments for a message
-func (u *MessageUseCase) GetComments(messageID int64) ([]*domain.Comment, error) {
-	return u.repo.GetComments(messageID)
+// BanMatchingMessages bans every message whose content matches pattern, a
+// regular expression checked with domain.CompilePattern, and returns how many
+// were banned (admin only). Matching stops with the context's error once it
+// is cancelled, before anything is banned.
+func (u *MessageUseCase) BanMatchingMessages(ctx context.Context, pattern string) (int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	re, err := domain.CompilePattern(pattern)
+	if err != nil {
+		return 0, err
+	}
+
+	messages, err := u.repo.GetAllMessages(ctx)
+	if err != nil {
+		log.Printf("Error listing messages to match %q: %v", pattern, err)
+		return 0, err
+	}
+
+	var matched []*domain.Message
+	var ids []int64
+	for _, message := range messages {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if !message.IsBanned && re.MatchString(message.Content) {
+			matched = append(matched, message)
+			ids = append(ids, message.ID)
+		}
+	}
+
+	banned, err := u.repo.BanByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Error banning messages matching %q: %v", pattern, err)
+		return 0, err
+	}
+	log.Printf("Banned %d messages matching %q", banned, pattern)
+
+	// Broadcast updated messages
+	for _, message := range matched {
+		message.IsBanned = true
+		u.publish(message)
+	}
+
+	return banned, nil
 }
 
-// DeleteMessage deletes a message completely (admin only)
-func (u *MessageUseCase) DeleteMessage(id int64) error {
-	// Check if message exists
-	message, err := u.repo.GetByID(id)
+// PinMessage pins a message so it shows in the pinned list (admin only)
+func (u *MessageUseCase) PinMessage(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	message, err := u.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
-	if message == nil {
-		return errors.New("message not found")
+	if message.IsPinned {
+		return nil
 	}
 
-	// Delete message
-	err = u.repo.Delete(id)
+	pinned, err := u.repo.ListPinned(ctx)
 	if err != nil {
 		return err
 	}
+	if len(pinned) >= domain.MaxPinnedMessages {
+		log.Printf("Cannot pin message %d: %d messages already pinned", id, len(pinned))
+		return domain.ErrTooManyPinned
+	}
+
+	if err := u.repo.Pin(ctx, id); err != nil {
+		log.Printf("Error pinning message %d: %v", id, err)
+		return err
+	}
+
+	// Broadcast updated message
+	if message, err = u.repo.GetByID(ctx, id); err == nil {
+		u.publish(message)
+	}
 
 	return nil
 }
 
-// DeleteComment deletes a comment completely (admin only)
-func (u *MessageUseCase) DeleteComment(id int64) error {
-	// Check if comment exists
-	comment, err := u.repo.GetCommentByID(id)
-	if err != nil {
+// LockMessage closes a message to new comments (admin only)
+func (u *MessageUseCase) LockMessage(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
 		return err
 	}
-	if comment == nil {
-		return errors.New("comment not found")
+	return u.setLocked(ctx, id, true)
+}
+
+// UnlockMessage reopens a message to new comments (admin only)
+func (u *MessageUseCase) UnlockMessage(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
 	}
+	return u.setLocked(ctx, id, false)
+}
 
-	// Delete comment
-	err = u.repo.DeleteComment(id)
+// setLocked locks or unlocks a message and broadcasts the change
+func (u *MessageUseCase) setLocked(ctx context.Context, id int64, locked bool) error {
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		err = u.repo.Lock(ctx, id)
+	} else {
+		err = u.repo.Unlock(ctx, id)
+	}
 	if err != nil {
+		log.Printf("Error setting locked=%t on message %d: %v", locked, id, err)
 		return err
 	}
 
+	// Broadcast updated message
+	message.IsLocked = locked
+	u.publish(message)
+
 	return nil
 }
 
-// CleanupExpiredComments removes all expired comments from the database
-func (u *MessageUseCase) CleanupExpiredComments() error {
-	log.Printf("Cleaning up expired comments...")
-	err := u.repo.DeleteExpiredComments()
-	if err != nil {
-		log.Printf("Error cleaning up expired comments: %v", err)
+// HideMessage hides a message from everyone but its author and admins. Only
+// the author may hide it; unlike a ban it can be undone with UnhideMessage.
+func (u *MessageUseCase) HideMessage(ctx context.Context, id, userID int64) error {
+	if err := u.checkWritable(); err != nil {
 		return err
 	}
-	log.Printf("Successfully cleaned up expired comments")
-	return nil
+	return u.setHidden(ctx, id, userID, true)
 }
 
-// StartCleanupScheduler starts a background goroutine that periodically cleans up expired comments
-func (u *MessageUseCase) StartCleanupScheduler() {
+// UnhideMessage makes a message its author hid visible again
+func (u *MessageUseCase) UnhideMessage(ctx context.Context, id, userID int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+	return u.setHidden(ctx, id, userID, false)
+}
+
+// setHidden hides or unhides a message owned by userID and broadcasts the change
+func (u *MessageUseCase) setHidden(ctx context.Context, id, userID int64, hidden bool) error {
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if message.UserID != userID {
+		log.Printf("User %d is not the author of message %d", userID, id)
+		return domain.ErrNotMessageAuthor
+	}
+
+	if hidden {
+		err = u.repo.Hide(ctx, id)
+	} else {
+		err = u.repo.Unhide(ctx, id)
+	}
+	if err != nil {
+		log.Printf("Error setting hidden=%t on message %d: %v", hidden, id, err)
+		return err
+	}
+
+	// Broadcast updated message
+	message.IsHidden = hidden
+	u.publish(message)
+
+	return nil
+}
+
+// UnpinMessage unpins a message (admin only)
+func (u *MessageUseCase) UnpinMessage(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := u.repo.Unpin(ctx, id); err != nil {
+		log.Printf("Error unpinning message %d: %v", id, err)
+		return err
+	}
+
+	// Broadcast updated message
+	message.IsPinned = false
+	message.PinnedAt = nil
+	u.publish(message)
+
+	return nil
+}
+
+// GetPinnedMessages gets the pinned messages in the order they were pinned
+func (u *MessageUseCase) GetPinnedMessages(ctx context.Context) ([]*domain.Message, error) {
+	return u.repo.ListPinned(ctx)
+}
+
+func (u *MessageUseCase) GetByID(ctx context.Context, id int64) (*domain.Message, error) {
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if message == nil {
+		return nil, ErrMessageNotFound
+	}
+
+	return message, nil
+}
+
+// GetBySlug gets a message by its slug
+func (u *MessageUseCase) GetBySlug(ctx context.Context, slug string) (*domain.Message, error) {
+	return u.repo.GetBySlug(ctx, slug)
+}
+
+// GetMessagesByIDs gets several messages at once in the order requested.
+// Missing IDs are skipped, and banned and hidden messages are skipped unless includeBanned is set.
+func (u *MessageUseCase) GetMessagesByIDs(ctx context.Context, ids []int64, includeBanned bool) ([]*domain.Message, error) {
+	messages, err := u.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Error getting messages by IDs from repository: %v", err)
+		return nil, err
+	}
+
+	if includeBanned {
+		return messages, nil
+	}
+
+	visible := make([]*domain.Message, 0, len(messages))
+	for _, message := range messages {
+		if !message.IsBanned && !message.IsHidden && !message.IsExpired() {
+			visible = append(visible, message)
+		}
+	}
+	return visible, nil
+}
+
+// CreateComment creates a new comment
+func (u *MessageUseCase) CreateComment(ctx context.Context, messageID, userID int64, username, content string) (*domain.Comment, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	if userID == 0 && u.requireAuthForComments {
+		return nil, domain.ErrAuthRequired
+	}
+	if content == "" {
+		return nil, errors.New("content is required")
+	}
+	content, err := prepareContent(content, u.normalizeContent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip auth validation for anonymous users (ID=0)
+	if userID != 0 {
+		// Validate user ID
+		user, err := u.authClient.GetUser(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Check if user is banned
+		if user.IsBanned {
+			return nil, errors.New("user is banned")
+		}
+	}
+
+	message, err := u.repo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create comment
+	comment := &domain.Comment{
+		MessageID: messageID,
+		UserID:    userID,
+		Username:  username,
+		Content:   content,
+		ExpiresAt: u.commentExpiry(message),
+	}
+	if err := comment.Validate(u.limits); err != nil {
+		return nil, err
+	}
+	if err := u.bannedWords.check(username, content); err != nil {
+		return nil, err
+	}
+
+	// Save comment
+	createComment := u.repo.CreateComment
+	if u.bumpWindow > 0 && time.Since(message.CreatedAt) > u.bumpWindow {
+		createComment = u.repo.CreateCommentWithoutBump
+	}
+	commentID, err := createComment(ctx, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set comment ID
+	comment.ID = commentID
+	u.lists.invalidate()
+	u.hub.BroadcastComment(comment)
+
+	u.recordMentions(ctx, domain.MentionSourceComment, commentID, content)
+	u.notifySubscribers(ctx, comment)
+
+	return comment, nil
+}
+
+// UpdateComment edits a comment's content. Only the author can edit, and only
+// while the comment has not expired.
+func (u *MessageUseCase) UpdateComment(ctx context.Context, id, userID int64, content string) (*domain.Comment, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Updating comment %d for user %d", id, userID)
+
+	comment, err := u.repo.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != userID {
+		log.Printf("User %d is not the author of comment %d", userID, id)
+		return nil, domain.ErrNotCommentAuthor
+	}
+	if u.commentsExpire && comment.IsExpired() {
+		return nil, domain.ErrCommentExpired
+	}
+
+	content, err = prepareContent(content, u.normalizeContent)
+	if err != nil {
+		return nil, err
+	}
+
+	edited := *comment
+	edited.Content = content
+	if err := edited.Validate(u.limits); err != nil {
+		return nil, err
+	}
+	if err := u.bannedWords.check(edited.Username, content); err != nil {
+		return nil, err
+	}
+	if u.resetCommentExpiryOnEdit {
+		message, err := u.repo.GetByID(ctx, comment.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		edited.ExpiresAt = u.commentExpiry(message)
+	}
+
+	if err := u.repo.UpdateComment(ctx, id, edited.Content, edited.ExpiresAt); err != nil {
+		log.Printf("Error updating comment %d in repository: %v", id, err)
+		return nil, err
+	}
+	u.lists.invalidate()
+	u.hub.BroadcastComment(&edited)
+
+	return &edited, nil
+}
+
+// ImportComments validates and stores a batch of comments atomically,
+// returning their IDs in input order. Used for migrating data from another forum.
+func (u *MessageUseCase) ImportComments(ctx context.Context, comments []*domain.Comment) ([]int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	// Each comment gets the same checks as one created through CreateComment
+	messages := make(map[int64]*domain.Message)
+	var bumped []int64
+	for i, comment := range comments {
+		if err := validateImportedComment(comment); err != nil {
+			return nil, fmt.Errorf("comment %d: %w", i, err)
+		}
+		content, err := prepareContent(comment.Content, u.normalizeContent)
+		if err != nil {
+			return nil, fmt.Errorf("comment %d: %w: %v", i, domain.ErrInvalidComment, err)
+		}
+		comment.Content = content
+		if err := comment.Validate(u.limits); err != nil {
+			return nil, fmt.Errorf("comment %d: %w: %v", i, domain.ErrInvalidComment, err)
+		}
+		if err := u.bannedWords.check(comment.Username, comment.Content); err != nil {
+			return nil, fmt.Errorf("comment %d: %w: %v", i, domain.ErrInvalidComment, err)
+		}
+
+		message, ok := messages[comment.MessageID]
+		if !ok {
+			message, err = u.repo.GetByID(ctx, comment.MessageID)
+			if err != nil {
+				return nil, fmt.Errorf("comment %d: %w", i, err)
+			}
+			messages[comment.MessageID] = message
+			if u.bumpWindow <= 0 || time.Since(message.CreatedAt) <= u.bumpWindow {
+				bumped = append(bumped, message.ID)
+			}
+		}
+		if message.IsLocked {
+			return nil, fmt.Errorf("comment %d: %w", i, domain.ErrThreadLocked)
+		}
+		comment.ExpiresAt = u.commentExpiry(message)
+	}
+
+	ids, err := u.repo.CreateComments(ctx, comments, bumped)
+	if err != nil {
+		log.Printf("Error importing %d comments: %v", len(comments), err)
+		return nil, err
+	}
+
+	u.lists.invalidate()
+	log.Printf("Imported %d comments", len(ids))
+	return ids, nil
+}
+
+// commentExpiry returns when a new comment on message expires
+func (u *MessageUseCase) commentExpiry(message *domain.Message) time.Time {
+	if !u.commentsExpire {
+		return domain.CommentNeverExpires
+	}
+	return time.Now().UTC().Add(message.CommentLifetime(u.commentTTL))
+}
+
+// validateCommentTTL checks a message's comment TTL is not negative and at most maxTTL
+func validateCommentTTL(ttl, maxTTL time.Duration) error {
+	if ttl < 0 || ttl > maxTTL {
+		return fmt.Errorf("%w: must be between 0 and %s", domain.ErrInvalidCommentTTL, maxTTL)
+	}
+	return nil
+}
+
+// validateImportedComment checks that a comment has everything needed to be stored
+func validateImportedComment(comment *domain.Comment) error {
+	switch {
+	case comment == nil:
+		return domain.ErrInvalidComment
+	case comment.MessageID <= 0:
+		return fmt.Errorf("%w: message_id is required", domain.ErrInvalidComment)
+	case strings.TrimSpace(comment.Username) == "":
+		return fmt.Errorf("%w: username is required", domain.ErrInvalidComment)
+	case strings.TrimSpace(comment.Content) == "":
+		return fmt.Errorf("%w: content is required", domain.ErrInvalidComment)
+	case !utf8.ValidString(comment.Content):
+		return fmt.Errorf("%w: content must be valid UTF-8", domain.ErrInvalidComment)
+	}
+	return nil
+}
+
+// recordMentions stores the @mentions in a new message or comment that resolve
+// to real users. Failures are logged rather than failing the post.
+func (u *MessageUseCase) recordMentions(ctx context.Context, sourceType string, sourceID int64, content string) {
+	usernames := domain.ExtractMentions(content)
+	if len(usernames) == 0 || u.authClient == nil {
+		return
+	}
+	if len(usernames) > maxMentionsPerPost {
+		usernames = usernames[:maxMentionsPerPost]
+	}
+
+	candidates, err := u.repo.FindUserIDsByUsernames(ctx, usernames)
+	if err != nil {
+		log.Printf("Error resolving mentions for %s %d: %v", sourceType, sourceID, err)
+		return
+	}
+
+	var mentions []*domain.Mention
+	for _, username := range usernames {
+		// Confirm with the auth service, since stored usernames may be stale
+		for _, userID := range candidates[username] {
+			user, err := u.authClient.GetUser(userID)
+			if err != nil || user.Username != username {
+				continue
+			}
+			mentions = append(mentions, &domain.Mention{
+				SourceType:      sourceType,
+				SourceID:        sourceID,
+				MentionedUserID: userID,
+			})
+			break
+		}
+	}
+
+	if len(mentions) == 0 {
+		return
+	}
+	if err := u.repo.CreateMentions(ctx, mentions); err != nil {
+		log.Printf("Error saving mentions for %s %d: %v", sourceType, sourceID, err)
+		return
+	}
+	log.Printf("Recorded %d mentions for %s %d", len(mentions), sourceType, sourceID)
+}
+
+// GetMessagesByUser gets a page of a user's own messages, newest first,
+// including banned ones so they can see moderation actions
+func (u *MessageUseCase) GetMessagesByUser(ctx context.Context, userID, limit, offset int64) ([]*domain.Message, int64, error) {
+	messages, total, err := u.repo.ListByUserPage(ctx, userID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting messages for user %d: %v", userID, err)
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// GetMessagesBetween gets a page of visible messages created between from and
+// to inclusive, newest first. A zero from or to leaves that end open.
+func (u *MessageUseCase) GetMessagesBetween(ctx context.Context, from, to time.Time, limit, offset int64) ([]*domain.Message, int64, error) {
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return nil, 0, domain.ErrInvalidDateRange
+	}
+
+	messages, total, err := u.repo.ListCreatedBetween(ctx, from, to, limit, offset)
+	if err != nil {
+		log.Printf("Error getting messages between %s and %s: %v", from, to, err)
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// GetCommentsByUser gets a page of a user's comments, newest first. Expired
+// comments are only included with includeExpired, which is meant for admins.
+func (u *MessageUseCase) GetCommentsByUser(ctx context.Context, userID, limit, offset int64, includeExpired bool) ([]*domain.Comment, int64, error) {
+	comments, total, err := u.repo.ListCommentsByUser(ctx, userID, limit, offset, includeExpired)
+	if err != nil {
+		log.Printf("Error getting comments for user %d: %v", userID, err)
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// GetRecentComments gets a page of the newest comments across all messages,
+// each with a summary of the message it is on (admin only)
+func (u *MessageUseCase) GetRecentComments(ctx context.Context, limit, offset int64) ([]*domain.Comment, int64, error) {
+	comments, total, err := u.repo.ListRecentComments(ctx, limit, offset, !u.commentsExpire)
+	if err != nil {
+		log.Printf("Error getting recent comments: %v", err)
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// SearchMessages finds visible messages containing query, best match first by
+// searchScore. With highlight set each result carries a snippet and the match
+// positions in it.
+func (u *MessageUseCase) SearchMessages(ctx context.Context, query string, limit, offset int64, highlight bool) ([]*domain.SearchResult, int64, error) {
+	query, err := domain.NormalizeSearchQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Rank the newest matches by relevance and recency, then page through them
+	messages, total, err := u.repo.Search(ctx, query, maxRankedSearchResults, 0)
+	if err != nil {
+		log.Printf("Error searching messages for %q: %v", query, err)
+		return nil, 0, err
+	}
+	rankSearchResults(messages, query, time.Now())
+	// Matches past the cap aren't ranked, so they can't be paged to either
+	ranked := int64(len(messages))
+	total = min(total, ranked)
+	messages = messages[min(offset, ranked):min(offset+limit, ranked)]
+
+	results := make([]*domain.SearchResult, len(messages))
+	for i, message := range messages {
+		results[i] = &domain.SearchResult{Message: message}
+		if highlight {
+			results[i].Snippet, results[i].MatchRanges = domain.Highlight(message.Content, query)
+		}
+	}
+	return results, total, nil
+}
+
+// notifySubscribers records a notification of a new comment for everyone
+// subscribed to its message. Failures are logged rather than failing the
+// comment, which has already been saved.
+func (u *MessageUseCase) notifySubscribers(ctx context.Context, comment *domain.Comment) {
+	notified, err := u.repo.CreateCommentNotifications(ctx, comment)
+	if err != nil {
+		log.Printf("Error notifying subscribers of comment %d: %v", comment.ID, err)
+		return
+	}
+	if notified > 0 {
+		log.Printf("Notified %d subscribers of comment %d on message %d", notified, comment.ID, comment.MessageID)
+	}
+}
+
+// SubscribeToMessage subscribes a user to new comments on a message. Banned
+// and expired messages can't be subscribed to, nor hidden ones except by
+// their author.
+func (u *MessageUseCase) SubscribeToMessage(ctx context.Context, messageID, userID int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	message, err := u.repo.GetByID(ctx, messageID)
+	if err != nil {
+		return err
+	}
+	if message.IsBanned || message.IsExpired() || message.IsHidden && message.UserID != userID {
+		return ErrMessageNotFound
+	}
+
+	if err := u.repo.Subscribe(ctx, userID, messageID); err != nil {
+		log.Printf("Error subscribing user %d to message %d: %v", userID, messageID, err)
+		return err
+	}
+	return nil
+}
+
+// UnsubscribeFromMessage stops notifying a user of new comments on a message
+func (u *MessageUseCase) UnsubscribeFromMessage(ctx context.Context, messageID, userID int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	if err := u.repo.Unsubscribe(ctx, userID, messageID); err != nil {
+		log.Printf("Error unsubscribing user %d from message %d: %v", userID, messageID, err)
+		return err
+	}
+	return nil
+}
+
+// GetNotifications gets a user's notifications of new comments on messages
+// they subscribed to, newest first
+func (u *MessageUseCase) GetNotifications(ctx context.Context, userID, limit, offset int64) ([]*domain.Notification, int64, error) {
+	notifications, total, err := u.repo.ListNotifications(ctx, userID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting notifications for user %d: %v", userID, err)
+		return nil, 0, err
+	}
+	return notifications, total, nil
+}
+
+// GetUserMentions gets the messages and comments a user was mentioned in, newest first
+func (u *MessageUseCase) GetUserMentions(ctx context.Context, userID, limit, offset int64) ([]*domain.Mention, int64, error) {
+	mentions, total, err := u.repo.ListMentions(ctx, userID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting mentions for user %d: %v", userID, err)
+		return nil, 0, err
+	}
+	return mentions, total, nil
+}
+
+// MarkRead marks every message up to messageID as read for a user.
+// Anonymous users (ID=0) are not tracked.
+func (u *MessageUseCase) MarkRead(ctx context.Context, userID, messageID int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	if userID == 0 {
+		return nil
+	}
+	if _, err := u.repo.GetByID(ctx, messageID); err != nil {
+		return err
+	}
+	return u.repo.MarkRead(ctx, userID, messageID)
+}
+
+// CountUnread counts messages a user hasn't read yet. Anonymous users always have none.
+func (u *MessageUseCase) CountUnread(ctx context.Context, userID int64) (int64, error) {
+	if userID == 0 {
+		return 0, nil
+	}
+	return u.repo.CountUnread(ctx, userID)
+}
+
+// GetMessageWithComments gets a message and its non-expired comments in one call
+func (u *MessageUseCase) GetMessageWithComments(ctx context.Context, id int64) (*domain.MessageDetail, error) {
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := u.repo.GetComments(ctx, id, domain.SortAsc, !u.commentsExpire)
+	if err != nil {
+		log.Printf("Error getting comments for message %d: %v", id, err)
+		return nil, err
+	}
+
+	return &domain.MessageDetail{Message: message, Comments: comments}, nil
+}
+
+// GetModerationView gathers everything a moderator needs to review a message,
+// including banned and hidden ones. The author's account is looked up in the
+// auth service; if that fails the view is still returned, without it.
+func (u *MessageUseCase) GetModerationView(ctx context.Context, id int64) (*domain.MessageModerationView, error) {
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return nil, ErrMessageNotFound
+	}
+
+	comments, err := u.repo.GetComments(ctx, id, domain.SortAsc, !u.commentsExpire)
+	if err != nil {
+		log.Printf("Error getting comments for message %d: %v", id, err)
+		return nil, err
+	}
+	participants := make(map[int64]bool)
+	for _, comment := range comments {
+		participants[comment.UserID] = true
+	}
+	message.CommentCount = int64(len(comments))
+	message.ParticipantCount = int64(len(participants))
+
+	view := &domain.MessageModerationView{Message: message}
+	if message.UserID != 0 {
+		author, err := u.authClient.GetUser(message.UserID)
+		if err != nil {
+			log.Printf("Error getting author %d of message %d: %v", message.UserID, id, err)
+		} else {
+			view.Author = author
+		}
+	}
+	return view, nil
+}
+
+// GetComments gets all comments for a message in the given order
+func (u *MessageUseCase) GetComments(ctx context.Context, messageID int64, order domain.SortOrder) ([]*domain.Comment, error) {
+	return u.repo.GetComments(ctx, messageID, order, !u.commentsExpire)
+}
+
+// DeleteMessage deletes a message completely (admin only)
+func (u *MessageUseCase) DeleteMessage(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	// Check if message exists
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if message == nil {
+		return domain.ErrMessageNotFound
+	}
+
+	// Move the message to the trash, or delete it outright without one
+	if u.trashRetention > 0 {
+		err = u.repo.Trash(ctx, id)
+	} else {
+		err = u.repo.Delete(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	u.lists.invalidate()
+
+	return nil
+}
+
+// RestoreMessage brings a deleted message and its comments back from the
+// trash, as long as it was deleted within the trash retention (admin only)
+func (u *MessageUseCase) RestoreMessage(ctx context.Context, id int64) (*domain.Message, error) {
+	if err := u.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	if err := u.repo.Restore(ctx, id, time.Now().UTC().Add(-u.trashRetention)); err != nil {
+		log.Printf("Error restoring message %d: %v", id, err)
+		return nil, err
+	}
+
+	message, err := u.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	u.publish(message)
+
+	log.Printf("Restored message %d", id)
+	return message, nil
+}
+
+// PurgeTrash permanently deletes messages that have been in the trash longer
+// than the trash retention
+func (u *MessageUseCase) PurgeTrash(ctx context.Context) error {
+	deleted, err := u.repo.PurgeTrash(ctx, time.Now().UTC().Add(-u.trashRetention))
+	if err != nil {
+		log.Printf("Error purging deleted messages: %v", err)
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Permanently deleted %d messages from the trash", deleted)
+	}
+	return nil
+}
+
+// PurgeBannedMessages permanently deletes all banned messages and their comments (admin only)
+func (u *MessageUseCase) PurgeBannedMessages(ctx context.Context) (int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	deleted, err := u.repo.PurgeBanned(ctx)
+	if err != nil {
+		log.Printf("Error purging banned messages: %v", err)
+		return 0, err
+	}
+	u.lists.invalidate()
+	log.Printf("Purged %d banned messages", deleted)
+	return deleted, nil
+}
+
+// GetMessageTimeSeries counts messages created per interval, oldest first (admin only)
+func (u *MessageUseCase) GetMessageTimeSeries(ctx context.Context, interval domain.StatsInterval) ([]domain.TimeBucket, error) {
+	buckets, err := u.repo.CountByInterval(ctx, interval)
+	if err != nil {
+		log.Printf("Error counting messages by %s: %v", interval, err)
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// DeleteComment deletes a comment completely (admin only)
+func (u *MessageUseCase) DeleteComment(ctx context.Context, id int64) error {
+	if err := u.checkWritable(); err != nil {
+		return err
+	}
+
+	// Check if comment exists
+	comment, err := u.repo.GetCommentByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if comment == nil {
+		return domain.ErrCommentNotFound
+	}
+
+	// Delete comment
+	err = u.repo.DeleteComment(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.lists.invalidate()
+
+	return nil
+}
+
+// SyncUsername propagates a user's new username to all of their messages and comments
+func (u *MessageUseCase) SyncUsername(ctx context.Context, userID int64, username string) (int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	if userID <= 0 {
+		return 0, errors.New("invalid user ID")
+	}
+	if strings.TrimSpace(username) == "" {
+		return 0, errors.New("username cannot be empty")
+	}
+
+	log.Printf("Syncing username for user %d to %s", userID, username)
+	updated, err := u.repo.UpdateUsername(ctx, userID, username)
+	if err != nil {
+		log.Printf("Error updating username in repository: %v", err)
+		return 0, err
+	}
+	u.lists.invalidate()
+	log.Printf("Updated username on %d messages and comments", updated)
+	return updated, nil
+}
+
+// RenameUser replaces a user's username on all of their messages and comments
+// on a moderator's behalf. Unlike SyncUsername the new name must pass the same
+// checks as a posted username.
+func (u *MessageUseCase) RenameUser(ctx context.Context, userID int64, username string) (int64, error) {
+	if err := u.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	if userID <= 0 {
+		return 0, errors.New("invalid user ID")
+	}
+	if err := domain.ValidateUsername(username, u.limits); err != nil {
+		return 0, err
+	}
+	if err := u.bannedWords.check(username, ""); err != nil {
+		return 0, err
+	}
+
+	log.Printf("Renaming user %d to %s", userID, username)
+	updated, err := u.repo.UpdateUsername(ctx, userID, username)
+	if err != nil {
+		log.Printf("Error updating username in repository: %v", err)
+		return 0, err
+	}
+	u.lists.invalidate()
+	log.Printf("Renamed user on %d messages and comments", updated)
+	return updated, nil
+}
+
+// publish invalidates cached message lists and broadcasts a new or changed message
+func (u *MessageUseCase) publish(message *domain.Message) {
+	u.lists.invalidate()
+	u.hub.BroadcastMessage(message)
+}
+
+// CleanupExpiredComments removes all expired comments from the database
+func (u *MessageUseCase) CleanupExpiredComments(ctx context.Context) error {
+	if !u.commentsExpire {
+		return nil
+	}
+	log.Printf("Cleaning up expired comments...")
+	err := u.repo.DeleteExpiredComments(ctx)
+	if err != nil {
+		log.Printf("Error cleaning up expired comments: %v", err)
+		return err
+	}
+	log.Printf("Successfully cleaned up expired comments")
+	return nil
+}
+
+// CleanupExpiredMessages removes all expired ephemeral messages from the database
+func (u *MessageUseCase) CleanupExpiredMessages(ctx context.Context) error {
+	deleted, err := u.repo.DeleteExpiredMessages(ctx)
+	if err != nil {
+		log.Printf("Error cleaning up expired messages: %v", err)
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Successfully cleaned up %d expired messages", deleted)
+	}
+	return nil
+}
+
+// StartCleanupScheduler starts a background goroutine that periodically cleans up expired comments and messages
+// and purges the trash, skipping runs while the service is read-only
+func (u *MessageUseCase) StartCleanupScheduler() {
 	go func() {
 		ticker := time.NewTicker(1 * time.Minute) // Check every minute
 		defer ticker.Stop()
@@ -286,11 +1584,73 @@ func (u *MessageUseCase) StartCleanupScheduler() {
 
 		for {
 			select {
+			case <-u.done:
+				return
 			case <-ticker.C:
-				if err := u.CleanupExpiredComments(); err != nil {
+				if u.readOnly.Load() {
+					continue
+				}
+				if err := u.CleanupExpiredComments(context.Background()); err != nil {
 					log.Printf("Failed to cleanup expired comments: %v", err)
 				}
+				if err := u.CleanupExpiredMessages(context.Background()); err != nil {
+					log.Printf("Failed to cleanup expired messages: %v", err)
+				}
+				if err := u.PurgeTrash(context.Background()); err != nil {
+					log.Printf("Failed to purge deleted messages: %v", err)
+				}
 			}
 		}
 	}()
 }
+
+// CleanupOldMessages deletes messages (and their comments) older than the retention period
+func (u *MessageUseCase) CleanupOldMessages(ctx context.Context, retention time.Duration) error {
+	cutoff := time.Now().UTC().Add(-retention)
+	log.Printf("Deleting messages created before %s...", cutoff.Format(time.RFC3339))
+	deleted, err := u.repo.DeleteMessagesOlderThan(ctx, cutoff)
+	if err != nil {
+		log.Printf("Error deleting old messages: %v", err)
+		return err
+	}
+	log.Printf("Successfully deleted %d old messages", deleted)
+	return nil
+}
+
+// StartRetentionScheduler starts a background goroutine that periodically deletes
+// messages older than retention, skipping runs while the service is read-only.
+// A zero or negative retention disables it.
+func (u *MessageUseCase) StartRetentionScheduler(retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour) // Check every hour
+		defer ticker.Stop()
+
+		log.Printf("Started message retention scheduler (retention %s, checking every hour)", retention)
+
+		for {
+			select {
+			case <-u.done:
+				return
+			case <-ticker.C:
+				if u.readOnly.Load() {
+					continue
+				}
+				if err := u.CleanupOldMessages(context.Background(), retention); err != nil {
+					log.Printf("Failed to cleanup old messages: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// StopSchedulers stops the cleanup and retention schedulers. It is safe to
+// call more than once.
+func (u *MessageUseCase) StopSchedulers() {
+	u.stopOnce.Do(func() {
+		close(u.done)
+	})
+}
@@ -1,10 +1,17 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/atmega-p471/forum-service/internal/config"
 	"github.com/atmega-p471/forum-service/internal/domain"
 )
 
@@ -17,6 +24,7 @@ type AuthClientInterface interface {
 // MockHub implements Hub interface for testing
 type MockHub struct {
 	broadcastedMessages []*domain.Message
+	broadcastedComments []*domain.Comment
 }
 
 func NewMockHub() *MockHub {
@@ -29,34 +37,69 @@ func (m *MockHub) BroadcastMessage(message *domain.Message) {
 	m.broadcastedMessages = append(m.broadcastedMessages, message)
 }
 
+func (m *MockHub) BroadcastComment(comment *domain.Comment) {
+	m.broadcastedComments = append(m.broadcastedComments, comment)
+}
+
 // MockMessageRepository implements domain.MessageRepository for testing
 type MockMessageRepository struct {
 	messages map[int64]*domain.Message
 	comments map[int64]*domain.Comment
+	mentions []*domain.Mention
+	lastRead map[int64]int64
+	trash    map[int64]*domain.Message
+	trashed  map[int64]time.Time
 	nextID   int64
+
+	subscriptions map[int64]map[int64]bool
+	notifications []*domain.Notification
 }
 
 func NewMockMessageRepository() *MockMessageRepository {
 	return &MockMessageRepository{
 		messages: make(map[int64]*domain.Message),
 		comments: make(map[int64]*domain.Comment),
+		lastRead: make(map[int64]int64),
+		trash:    make(map[int64]*domain.Message),
+		trashed:  make(map[int64]time.Time),
 		nextID:   1,
+
+		subscriptions: make(map[int64]map[int64]bool),
 	}
 }
 
-func (m *MockMessageRepository) GetByID(id int64) (*domain.Message, error) {
+func (m *MockMessageRepository) GetByID(ctx context.Context, id int64) (*domain.Message, error) {
 	if msg, exists := m.messages[id]; exists {
 		return msg, nil
 	}
-	return nil, errors.New("message not found")
+	return nil, domain.ErrMessageNotFound
+}
+
+func (m *MockMessageRepository) GetBySlug(ctx context.Context, slug string) (*domain.Message, error) {
+	for _, msg := range m.messages {
+		if msg.Slug == slug {
+			return msg, nil
+		}
+	}
+	return nil, domain.ErrMessageNotFound
+}
+
+func (m *MockMessageRepository) GetByIDs(ctx context.Context, ids []int64) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for _, id := range ids {
+		if msg, exists := m.messages[id]; exists {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
 }
 
-func (m *MockMessageRepository) List(limit, offset int64) ([]*domain.Message, int64, error) {
+func (m *MockMessageRepository) List(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
 	var messages []*domain.Message
 	var count int64
 
 	for _, msg := range m.messages {
-		if !msg.IsBanned {
+		if !msg.IsBanned && !msg.IsHidden {
 			count++
 			if count > offset && int64(len(messages)) < limit {
 				messages = append(messages, msg)
@@ -67,7 +110,67 @@ func (m *MockMessageRepository) List(limit, offset int64) ([]*domain.Message, in
 	return messages, count, nil
 }
 
-func (m *MockMessageRepository) GetAllMessages() ([]*domain.Message, error) {
+func (m *MockMessageRepository) Search(ctx context.Context, query string, limit, offset int64) ([]*domain.Message, int64, error) {
+	var messages []*domain.Message
+	for _, msg := range m.messages {
+		if !msg.IsBanned && !msg.IsHidden && strings.Contains(strings.ToLower(msg.Content), strings.ToLower(query)) {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID > messages[j].ID })
+
+	total := int64(len(messages))
+	if offset >= total {
+		return nil, total, nil
+	}
+	return messages[offset:min(offset+limit, total)], total, nil
+}
+
+func (m *MockMessageRepository) ListByActivity(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	return m.List(ctx, limit, offset)
+}
+
+func (m *MockMessageRepository) Latest(ctx context.Context) (*domain.Message, error) {
+	var latest *domain.Message
+	for _, msg := range m.messages {
+		if !msg.IsBanned && !msg.IsHidden && (latest == nil || msg.ID > latest.ID) {
+			latest = msg
+		}
+	}
+	if latest == nil {
+		return nil, domain.ErrMessageNotFound
+	}
+	return latest, nil
+}
+
+func (m *MockMessageRepository) ListTrending(ctx context.Context, since time.Time, limit, offset int64) ([]*domain.Message, int64, error) {
+	recent := make(map[int64]int)
+	for _, comment := range m.comments {
+		if !comment.CreatedAt.Before(since) {
+			recent[comment.MessageID]++
+		}
+	}
+	var messages []*domain.Message
+	for id := range recent {
+		if msg, exists := m.messages[id]; exists && !msg.IsBanned && !msg.IsHidden {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if recent[messages[i].ID] != recent[messages[j].ID] {
+			return recent[messages[i].ID] > recent[messages[j].ID]
+		}
+		return messages[i].ID > messages[j].ID
+	})
+
+	total := int64(len(messages))
+	if offset >= total {
+		return nil, total, nil
+	}
+	return messages[offset:min(offset+limit, total)], total, nil
+}
+
+func (m *MockMessageRepository) GetAllMessages(ctx context.Context) ([]*domain.Message, error) {
 	var messages []*domain.Message
 	for _, msg := range m.messages {
 		messages = append(messages, msg)
@@ -75,74 +178,345 @@ func (m *MockMessageRepository) GetAllMessages() ([]*domain.Message, error) {
 	return messages, nil
 }
 
-func (m *MockMessageRepository) Create(message *domain.Message) (int64, error) {
+func (m *MockMessageRepository) HasRecentDuplicate(ctx context.Context, userID int64, content string, since time.Time) (bool, error) {
+	for _, msg := range m.messages {
+		if msg.UserID == userID && msg.Content == content && !msg.CreatedAt.Before(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MockMessageRepository) Create(ctx context.Context, message *domain.Message) (int64, error) {
 	id := m.nextID
 	m.nextID++
 	message.ID = id
-	message.CreatedAt = time.Now()
+	message.CreatedAt = time.Now().UTC()
+	message.Version = 1
+	message.Slug = domain.MessageSlug(id, message.Content)
 	m.messages[id] = message
 	return id, nil
 }
 
-func (m *MockMessageRepository) Ban(id int64) error {
+func (m *MockMessageRepository) Update(ctx context.Context, id int64, content, contentHTML string, expectedVersion int64) (int64, error) {
+	msg, exists := m.messages[id]
+	if !exists {
+		return 0, domain.ErrMessageNotFound
+	}
+	if msg.Version != expectedVersion {
+		return 0, domain.ErrVersionConflict
+	}
+	msg.Content = content
+	msg.ContentHTML = contentHTML
+	msg.Version++
+	return msg.Version, nil
+}
+
+func (m *MockMessageRepository) Ban(ctx context.Context, id int64) error {
 	if msg, exists := m.messages[id]; exists {
 		msg.IsBanned = true
 		return nil
 	}
-	return errors.New("message not found")
+	return domain.ErrMessageNotFound
 }
 
-func (m *MockMessageRepository) Unban(id int64) error {
+func (m *MockMessageRepository) Unban(ctx context.Context, id int64) error {
 	if msg, exists := m.messages[id]; exists {
 		msg.IsBanned = false
 		return nil
 	}
-	return errors.New("message not found")
+	return domain.ErrMessageNotFound
+}
+
+func (m *MockMessageRepository) ListByUserPage(ctx context.Context, userID, limit, offset int64) ([]*domain.Message, int64, error) {
+	messages, _ := m.ListByUser(ctx, userID)
+	total := int64(len(messages))
+	if offset >= total {
+		return nil, total, nil
+	}
+	return messages[offset:min(offset+limit, total)], total, nil
+}
+
+func (m *MockMessageRepository) ListCreatedBetween(ctx context.Context, from, to time.Time, limit, offset int64) ([]*domain.Message, int64, error) {
+	var messages []*domain.Message
+	for _, msg := range m.messages {
+		if !msg.IsBanned && !msg.IsHidden && (from.IsZero() || !msg.CreatedAt.Before(from)) && (to.IsZero() || !msg.CreatedAt.After(to)) {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID > messages[j].ID })
+
+	total := int64(len(messages))
+	if offset >= total {
+		return nil, total, nil
+	}
+	return messages[offset:min(offset+limit, total)], total, nil
+}
+
+func (m *MockMessageRepository) ListByUser(ctx context.Context, userID int64) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for _, msg := range m.messages {
+		if msg.UserID == userID {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
+
+func (m *MockMessageRepository) BanMessagesByUser(ctx context.Context, userID int64) (int64, error) {
+	return m.setBannedByUser(userID, true), nil
+}
+
+func (m *MockMessageRepository) UnbanMessagesByUser(ctx context.Context, userID int64) (int64, error) {
+	return m.setBannedByUser(userID, false), nil
+}
+
+func (m *MockMessageRepository) setBannedByUser(userID int64, banned bool) int64 {
+	var updated int64
+	for _, msg := range m.messages {
+		if msg.UserID == userID && msg.IsBanned != banned {
+			msg.IsBanned = banned
+			updated++
+		}
+	}
+	return updated
+}
+
+func (m *MockMessageRepository) Pin(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	if !msg.IsPinned {
+		now := time.Now()
+		msg.IsPinned = true
+		msg.PinnedAt = &now
+	}
+	return nil
+}
+
+func (m *MockMessageRepository) Unpin(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsPinned = false
+	msg.PinnedAt = nil
+	return nil
+}
+
+func (m *MockMessageRepository) Lock(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsLocked = true
+	return nil
+}
+
+func (m *MockMessageRepository) Unlock(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsLocked = false
+	return nil
+}
+
+func (m *MockMessageRepository) Hide(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsHidden = true
+	return nil
+}
+
+func (m *MockMessageRepository) Unhide(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	msg.IsHidden = false
+	return nil
+}
+
+func (m *MockMessageRepository) ListPinned(ctx context.Context) ([]*domain.Message, error) {
+	var messages []*domain.Message
+	for _, msg := range m.messages {
+		if msg.IsPinned && !msg.IsBanned && !msg.IsHidden {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].PinnedAt.Before(*messages[j].PinnedAt)
+	})
+	return messages, nil
 }
 
-func (m *MockMessageRepository) Delete(id int64) error {
+func (m *MockMessageRepository) Delete(ctx context.Context, id int64) error {
 	if _, exists := m.messages[id]; exists {
 		delete(m.messages, id)
 		return nil
 	}
-	return errors.New("message not found")
+	return domain.ErrMessageNotFound
+}
+
+func (m *MockMessageRepository) Trash(ctx context.Context, id int64) error {
+	msg, exists := m.messages[id]
+	if !exists {
+		return domain.ErrMessageNotFound
+	}
+	delete(m.messages, id)
+	m.trash[id] = msg
+	m.trashed[id] = time.Now().UTC()
+	return nil
+}
+
+func (m *MockMessageRepository) Restore(ctx context.Context, id int64, deletedSince time.Time) error {
+	msg, exists := m.trash[id]
+	if !exists || m.trashed[id].Before(deletedSince) {
+		return domain.ErrMessageNotFound
+	}
+	delete(m.trash, id)
+	m.messages[id] = msg
+	return nil
+}
+
+func (m *MockMessageRepository) PurgeTrash(ctx context.Context, t time.Time) (int64, error) {
+	var deleted int64
+	for id, trashedAt := range m.trashed {
+		if trashedAt.Before(t) {
+			delete(m.trash, id)
+			delete(m.trashed, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MockMessageRepository) CreateComment(ctx context.Context, comment *domain.Comment) (int64, error) {
+	if msg, exists := m.messages[comment.MessageID]; exists && msg.IsLocked {
+		return 0, domain.ErrThreadLocked
+	}
+	id, err := m.CreateCommentWithoutBump(ctx, comment)
+	if err != nil {
+		return 0, err
+	}
+	if msg, exists := m.messages[comment.MessageID]; exists {
+		msg.LastActivityAt = comment.CreatedAt
+	}
+	return id, nil
 }
 
-func (m *MockMessageRepository) CreateComment(comment *domain.Comment) (int64, error) {
+func (m *MockMessageRepository) CreateCommentWithoutBump(ctx context.Context, comment *domain.Comment) (int64, error) {
+	if msg, exists := m.messages[comment.MessageID]; exists && msg.IsLocked {
+		return 0, domain.ErrThreadLocked
+	}
 	id := m.nextID
 	m.nextID++
 	comment.ID = id
-	comment.CreatedAt = time.Now()
+	comment.CreatedAt = time.Now().UTC()
 	m.comments[id] = comment
 	return id, nil
 }
 
-func (m *MockMessageRepository) GetComments(messageID int64) ([]*domain.Comment, error) {
+func (m *MockMessageRepository) CreateComments(ctx context.Context, comments []*domain.Comment, bumped []int64) ([]int64, error) {
+	for _, comment := range comments {
+		msg, exists := m.messages[comment.MessageID]
+		if !exists {
+			return nil, domain.ErrMessageNotFound
+		}
+		if msg.IsLocked {
+			return nil, domain.ErrThreadLocked
+		}
+	}
+
+	ids := make([]int64, 0, len(comments))
+	for _, comment := range comments {
+		id, _ := m.CreateCommentWithoutBump(ctx, comment)
+		ids = append(ids, id)
+	}
+	now := time.Now().UTC()
+	for _, id := range bumped {
+		if msg, exists := m.messages[id]; exists {
+			msg.LastActivityAt = now
+		}
+	}
+	return ids, nil
+}
+
+func (m *MockMessageRepository) GetComments(ctx context.Context, messageID int64, order domain.SortOrder, includeExpired bool) ([]*domain.Comment, error) {
 	var comments []*domain.Comment
 	for _, comment := range m.comments {
-		if comment.MessageID == messageID && !comment.IsExpired() {
+		if comment.MessageID == messageID && (includeExpired || !comment.IsExpired()) {
 			comments = append(comments, comment)
 		}
 	}
 	return comments, nil
 }
 
-func (m *MockMessageRepository) GetCommentByID(id int64) (*domain.Comment, error) {
+func (m *MockMessageRepository) GetCommentByID(ctx context.Context, id int64) (*domain.Comment, error) {
 	if comment, exists := m.comments[id]; exists {
 		return comment, nil
 	}
-	return nil, errors.New("comment not found")
+	return nil, domain.ErrCommentNotFound
+}
+
+func (m *MockMessageRepository) ListCommentsByUser(ctx context.Context, userID, limit, offset int64, includeExpired bool) ([]*domain.Comment, int64, error) {
+	var comments []*domain.Comment
+	for _, comment := range m.comments {
+		if comment.UserID == userID && (includeExpired || comment.ExpiresAt.After(time.Now())) {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID > comments[j].ID })
+
+	total := int64(len(comments))
+	if offset >= total {
+		return nil, total, nil
+	}
+	return comments[offset:min(offset+limit, total)], total, nil
+}
+
+func (m *MockMessageRepository) ListRecentComments(ctx context.Context, limit, offset int64, includeExpired bool) ([]*domain.Comment, int64, error) {
+	var comments []*domain.Comment
+	for _, comment := range m.comments {
+		msg, exists := m.messages[comment.MessageID]
+		if exists && (includeExpired || comment.ExpiresAt.After(time.Now())) {
+			listed := *comment
+			listed.Message = domain.NewMessageReference(msg)
+			comments = append(comments, &listed)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID > comments[j].ID })
+
+	total := int64(len(comments))
+	if offset >= total {
+		return nil, total, nil
+	}
+	return comments[offset:min(offset+limit, total)], total, nil
+}
+
+func (m *MockMessageRepository) UpdateComment(ctx context.Context, id int64, content string, expiresAt time.Time) error {
+	comment, exists := m.comments[id]
+	if !exists {
+		return domain.ErrCommentNotFound
+	}
+	comment.Content = content
+	comment.ExpiresAt = expiresAt
+	return nil
 }
 
-func (m *MockMessageRepository) DeleteComment(id int64) error {
+func (m *MockMessageRepository) DeleteComment(ctx context.Context, id int64) error {
 	if _, exists := m.comments[id]; exists {
 		delete(m.comments, id)
 		return nil
 	}
-	return errors.New("comment not found")
+	return domain.ErrCommentNotFound
 }
 
-func (m *MockMessageRepository) DeleteExpiredComments() error {
+func (m *MockMessageRepository) DeleteExpiredComments(ctx context.Context) error {
 	for id, comment := range m.comments {
 		if comment.IsExpired() {
 			delete(m.comments, id)
@@ -151,6 +525,185 @@ func (m *MockMessageRepository) DeleteExpiredComments() error {
 	return nil
 }
 
+func (m *MockMessageRepository) UpdateUsername(ctx context.Context, userID int64, newUsername string) (int64, error) {
+	var updated int64
+	for _, msg := range m.messages {
+		if msg.UserID == userID {
+			msg.Username = newUsername
+			updated++
+		}
+	}
+	for _, comment := range m.comments {
+		if comment.UserID == userID {
+			comment.Username = newUsername
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (m *MockMessageRepository) DeleteMessagesOlderThan(ctx context.Context, t time.Time) (int64, error) {
+	var deleted int64
+	for id, msg := range m.messages {
+		if msg.CreatedAt.Before(t) {
+			delete(m.messages, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MockMessageRepository) FindUserIDsByUsernames(ctx context.Context, usernames []string) (map[string][]int64, error) {
+	result := make(map[string][]int64)
+	for _, username := range usernames {
+		for _, msg := range m.messages {
+			if msg.UserID != 0 && msg.Username == username {
+				result[username] = append(result[username], msg.UserID)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *MockMessageRepository) CreateMentions(ctx context.Context, mentions []*domain.Mention) error {
+	m.mentions = append(m.mentions, mentions...)
+	return nil
+}
+
+func (m *MockMessageRepository) ListMentions(ctx context.Context, userID, limit, offset int64) ([]*domain.Mention, int64, error) {
+	var mentions []*domain.Mention
+	for _, mention := range m.mentions {
+		if mention.MentionedUserID == userID {
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions, int64(len(mentions)), nil
+}
+
+func (m *MockMessageRepository) MarkRead(ctx context.Context, userID, messageID int64) error {
+	if messageID > m.lastRead[userID] {
+		m.lastRead[userID] = messageID
+	}
+	return nil
+}
+
+func (m *MockMessageRepository) CountUnread(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	for id, msg := range m.messages {
+		if id > m.lastRead[userID] && msg.UserID != userID && !msg.IsBanned && !msg.IsHidden {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MockMessageRepository) Subscribe(ctx context.Context, userID, messageID int64) error {
+	if m.subscriptions[messageID] == nil {
+		m.subscriptions[messageID] = make(map[int64]bool)
+	}
+	m.subscriptions[messageID][userID] = true
+	return nil
+}
+
+func (m *MockMessageRepository) Unsubscribe(ctx context.Context, userID, messageID int64) error {
+	delete(m.subscriptions[messageID], userID)
+	return nil
+}
+
+func (m *MockMessageRepository) CreateCommentNotifications(ctx context.Context, comment *domain.Comment) (int64, error) {
+	var notified int64
+	for userID := range m.subscriptions[comment.MessageID] {
+		if userID == comment.UserID {
+			continue
+		}
+		m.notifications = append(m.notifications, &domain.Notification{
+			ID:        int64(len(m.notifications) + 1),
+			UserID:    userID,
+			MessageID: comment.MessageID,
+			CommentID: comment.ID,
+			CreatedAt: time.Now(),
+		})
+		notified++
+	}
+	return notified, nil
+}
+
+func (m *MockMessageRepository) ListNotifications(ctx context.Context, userID, limit, offset int64) ([]*domain.Notification, int64, error) {
+	var notifications []*domain.Notification
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		if m.notifications[i].UserID == userID {
+			notifications = append(notifications, m.notifications[i])
+		}
+	}
+	total := int64(len(notifications))
+	start := min(offset, total)
+	end := min(start+limit, total)
+	return notifications[start:end], total, nil
+}
+
+func (m *MockMessageRepository) DeleteExpiredMessages(ctx context.Context) (int64, error) {
+	var deleted int64
+	for id, msg := range m.messages {
+		if msg.IsExpired() {
+			delete(m.messages, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MockMessageRepository) CountByInterval(ctx context.Context, interval domain.StatsInterval) ([]domain.TimeBucket, error) {
+	counts := make(map[time.Time]int64)
+	for _, msg := range m.messages {
+		bucket := msg.CreatedAt.UTC().Truncate(time.Hour)
+		if interval == domain.IntervalDay {
+			bucket = time.Date(bucket.Year(), bucket.Month(), bucket.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		counts[bucket]++
+	}
+
+	buckets := []domain.TimeBucket{}
+	for bucket, count := range counts {
+		buckets = append(buckets, domain.TimeBucket{Bucket: bucket, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Bucket.Before(buckets[j].Bucket) })
+	return buckets, nil
+}
+
+func (m *MockMessageRepository) BanByIDs(ctx context.Context, ids []int64) (int64, error) {
+	var banned int64
+	for _, id := range ids {
+		if msg, exists := m.messages[id]; exists && !msg.IsBanned {
+			msg.IsBanned = true
+			banned++
+		}
+	}
+	return banned, nil
+}
+
+func (m *MockMessageRepository) UnbanByIDs(ctx context.Context, ids []int64) ([]int64, error) {
+	var found []int64
+	for _, id := range ids {
+		if msg, exists := m.messages[id]; exists {
+			msg.IsBanned = false
+			found = append(found, id)
+		}
+	}
+	return found, nil
+}
+
+func (m *MockMessageRepository) PurgeBanned(ctx context.Context) (int64, error) {
+	var deleted int64
+	for id, msg := range m.messages {
+		if msg.IsBanned {
+			delete(m.messages, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 // MockAuthClient implements AuthClientInterface for testing
 type MockAuthClient struct {
 	users map[int64]*domain.User
@@ -195,7 +748,7 @@ func NewTestMessageUseCase(repo domain.MessageRepository, authClient AuthClientI
 	}
 }
 
-func (u *TestMessageUseCase) CreateMessage(userID int64, username, content string) (*domain.Message, error) {
+func (u *TestMessageUseCase) CreateMessage(ctx context.Context, userID int64, username, content string) (*domain.Message, error) {
 	if content == "" {
 		return nil, errors.New("content is required")
 	}
@@ -224,7 +777,7 @@ func (u *TestMessageUseCase) CreateMessage(userID int64, username, content strin
 	}
 
 	// Save message
-	messageID, err := u.repo.Create(message)
+	messageID, err := u.repo.Create(ctx, message)
 	if err != nil {
 		return nil, err
 	}
@@ -238,20 +791,20 @@ func (u *TestMessageUseCase) CreateMessage(userID int64, username, content strin
 	return message, nil
 }
 
-func (u *TestMessageUseCase) GetMessages(limit, offset int64) ([]*domain.Message, int64, error) {
-	return u.repo.List(limit, offset)
+func (u *TestMessageUseCase) GetMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	return u.repo.List(ctx, limit, offset)
 }
 
-func (u *TestMessageUseCase) BanMessage(id int64) error {
-	message, err := u.repo.GetByID(id)
+func (u *TestMessageUseCase) BanMessage(ctx context.Context, id int64) error {
+	message, err := u.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	if message == nil {
-		return errors.New("message not found")
+		return domain.ErrMessageNotFound
 	}
 
-	err = u.repo.Ban(id)
+	err = u.repo.Ban(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -262,11 +815,11 @@ func (u *TestMessageUseCase) BanMessage(id int64) error {
 	return nil
 }
 
-func (u *TestMessageUseCase) GetByID(id int64) (*domain.Message, error) {
-	return u.repo.GetByID(id)
+func (u *TestMessageUseCase) GetByID(ctx context.Context, id int64) (*domain.Message, error) {
+	return u.repo.GetByID(ctx, id)
 }
 
-func (u *TestMessageUseCase) CreateComment(messageID, userID int64, username, content string) (*domain.Comment, error) {
+func (u *TestMessageUseCase) CreateComment(ctx context.Context, messageID, userID int64, username, content string) (*domain.Comment, error) {
 	if content == "" {
 		return nil, errors.New("content is required")
 	}
@@ -286,9 +839,9 @@ func (u *TestMessageUseCase) CreateComment(messageID, userID int64, username, co
 	}
 
 	// Check if message exists
-	_, err := u.repo.GetByID(messageID)
+	_, err := u.repo.GetByID(ctx, messageID)
 	if err != nil {
-		return nil, errors.New("message not found")
+		return nil, domain.ErrMessageNotFound
 	}
 
 	// Create comment
@@ -302,7 +855,7 @@ func (u *TestMessageUseCase) CreateComment(messageID, userID int64, username, co
 	}
 
 	// Save comment
-	commentID, err := u.repo.CreateComment(comment)
+	commentID, err := u.repo.CreateComment(ctx, comment)
 	if err != nil {
 		return nil, err
 	}
@@ -356,7 +909,7 @@ func TestMessageUseCase_CreateMessage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			message, err := uc.CreateMessage(tt.userID, tt.username, tt.content)
+			message, err := uc.CreateMessage(context.Background(), tt.userID, tt.username, tt.content)
 
 			if tt.wantErr {
 				if err == nil {
@@ -399,13 +952,13 @@ func TestMessageUseCase_GetMessages(t *testing.T) {
 
 	// Create test messages
 	for i := 0; i < 5; i++ {
-		_, err := uc.CreateMessage(1, "testuser", "Test message "+string(rune(i+'1')))
+		_, err := uc.CreateMessage(context.Background(), 1, "testuser", "Test message "+string(rune(i+'1')))
 		if err != nil {
 			t.Fatalf("Failed to create test message: %v", err)
 		}
 	}
 
-	messages, total, err := uc.GetMessages(3, 0)
+	messages, total, err := uc.GetMessages(context.Background(), 3, 0)
 	if err != nil {
 		t.Fatalf("Failed to get messages: %v", err)
 	}
@@ -426,19 +979,19 @@ func TestMessageUseCase_BanMessage(t *testing.T) {
 	uc := NewTestMessageUseCase(repo, authClient, hub)
 
 	// Create test message
-	message, err := uc.CreateMessage(1, "testuser", "Test message")
+	message, err := uc.CreateMessage(context.Background(), 1, "testuser", "Test message")
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
 
 	// Ban the message
-	err = uc.BanMessage(message.ID)
+	err = uc.BanMessage(context.Background(), message.ID)
 	if err != nil {
 		t.Fatalf("Failed to ban message: %v", err)
 	}
 
 	// Verify message is banned
-	banned, err := uc.GetByID(message.ID)
+	banned, err := uc.GetByID(context.Background(), message.ID)
 	if err != nil {
 		t.Fatalf("Failed to get banned message: %v", err)
 	}
@@ -448,37 +1001,329 @@ func TestMessageUseCase_BanMessage(t *testing.T) {
 	}
 
 	// Test banning non-existent message
-	err = uc.BanMessage(999)
+	err = uc.BanMessage(context.Background(), 999)
 	if err == nil {
 		t.Error("Expected error when banning non-existent message")
 	}
 }
 
-func TestMessageUseCase_CreateComment(t *testing.T) {
+func TestMessageUseCase_GetModerationView(t *testing.T) {
 	repo := NewMockMessageRepository()
-	authClient := NewMockAuthClient()
-	hub := NewMockHub()
-	uc := NewTestMessageUseCase(repo, authClient, hub)
+	useCase := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub())
+	ctx := context.Background()
 
-	// Create test message
-	message, err := uc.CreateMessage(1, "testuser", "Test message")
+	message, err := useCase.CreateMessage(ctx, 1, "testuser", "Reported post")
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
+	for _, userID := range []int64{1, 2, 2} {
+		if _, err := useCase.CreateComment(ctx, message.ID, userID, "user", "A comment"); err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+	}
+	if err := useCase.BanMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if err := useCase.LockMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to lock message: %v", err)
+	}
 
-	tests := []struct {
-		name      string
-		messageID int64
-		userID    int64
-		username  string
-		content   string
-		wantErr   bool
-	}{
-		{
-			name:      "Valid comment creation",
-			messageID: message.ID,
-			userID:    2,
-			username:  "admin",
+	view, err := useCase.GetModerationView(ctx, message.ID)
+	if err != nil {
+		t.Fatalf("GetModerationView failed: %v", err)
+	}
+	if !view.IsBanned || !view.IsLocked || view.IsPinned || view.IsHidden {
+		t.Errorf("Expected a banned, locked message, got %+v", view.Message)
+	}
+	if view.CommentCount != 3 || view.ParticipantCount != 2 {
+		t.Errorf("Expected 3 comments from 2 participants, got %d from %d", view.CommentCount, view.ParticipantCount)
+	}
+	if view.Author == nil || view.Author.ID != 1 || view.Author.IsBanned {
+		t.Errorf("Expected the author's account, got %+v", view.Author)
+	}
+
+	// Anonymous messages have no author to look up
+	anonymous, err := useCase.CreateMessage(ctx, 0, "anonymous", "Anonymous post")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if view, err := useCase.GetModerationView(ctx, anonymous.ID); err != nil || view.Author != nil {
+		t.Errorf("Expected no author for an anonymous message, got %+v, %v", view, err)
+	}
+
+	if _, err := useCase.GetModerationView(ctx, 999); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestMessageUseCase_SubscribeToMessage(t *testing.T) {
+	repo := NewMockMessageRepository()
+	useCase := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub())
+	ctx := context.Background()
+
+	message, err := useCase.CreateMessage(ctx, 1, "testuser", "Watch this thread")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if err := useCase.SubscribeToMessage(ctx, message.ID, 1); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	comment, err := useCase.CreateComment(ctx, message.ID, 2, "admin", "A reply")
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	notifications, total, err := useCase.GetNotifications(ctx, 1, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get notifications: %v", err)
+	}
+	if total != 1 || notifications[0].CommentID != comment.ID || notifications[0].MessageID != message.ID {
+		t.Fatalf("Expected a notification of comment %d, got %d: %+v", comment.ID, total, notifications)
+	}
+
+	// Own comments don't notify, and unsubscribing stops notifications
+	if _, err := useCase.CreateComment(ctx, message.ID, 1, "testuser", "My own reply"); err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	if err := useCase.UnsubscribeFromMessage(ctx, message.ID, 1); err != nil {
+		t.Fatalf("Failed to unsubscribe: %v", err)
+	}
+	if _, err := useCase.CreateComment(ctx, message.ID, 2, "admin", "Another reply"); err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	if _, total, _ := useCase.GetNotifications(ctx, 1, 10, 0); total != 1 {
+		t.Errorf("Expected still 1 notification, got %d", total)
+	}
+
+	if err := useCase.BanMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to ban message: %v", err)
+	}
+	if err := useCase.SubscribeToMessage(ctx, message.ID, 1); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound subscribing to a banned message, got %v", err)
+	}
+	if err := useCase.SubscribeToMessage(ctx, 999, 1); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound for a missing message, got %v", err)
+	}
+}
+
+func TestMessageUseCase_OnUserBanned(t *testing.T) {
+	useCase := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	ctx := context.Background()
+
+	var banned []int64
+	useCase.OnUserBanned(func(userID int64) { banned = append(banned, userID) })
+
+	if _, err := useCase.CreateMessage(ctx, 1, "testuser", "Soon to be banned"); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if _, err := useCase.BanUserContent(ctx, 1); err != nil {
+		t.Fatalf("BanUserContent failed: %v", err)
+	}
+	if _, err := useCase.UnbanUserContent(ctx, 1); err != nil {
+		t.Fatalf("UnbanUserContent failed: %v", err)
+	}
+	if len(banned) != 1 || banned[0] != 1 {
+		t.Errorf("Expected the hook to be called once for user 1, got %v", banned)
+	}
+}
+
+func TestMessageUseCase_HideMessage(t *testing.T) {
+	uc := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub())
+
+	message, err := uc.CreateMessage(context.Background(), 1, "testuser", "Test message")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	// Only the author may hide a message
+	if err := uc.HideMessage(context.Background(), message.ID, 2); !errors.Is(err, domain.ErrNotMessageAuthor) {
+		t.Fatalf("Expected ErrNotMessageAuthor, got %v", err)
+	}
+
+	if err := uc.HideMessage(context.Background(), message.ID, 1); err != nil {
+		t.Fatalf("Failed to hide message: %v", err)
+	}
+	messages, total, err := uc.GetMessages(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if total != 0 || len(messages) != 0 {
+		t.Errorf("Expected the hidden message to leave the list, got %d messages", total)
+	}
+	hidden, err := uc.GetByID(context.Background(), message.ID)
+	if err != nil {
+		t.Fatalf("Failed to get hidden message: %v", err)
+	}
+	if !hidden.IsHidden || hidden.IsBanned {
+		t.Errorf("Expected the message to be hidden but not banned, got %+v", hidden)
+	}
+
+	if err := uc.UnhideMessage(context.Background(), message.ID, 1); err != nil {
+		t.Fatalf("Failed to unhide message: %v", err)
+	}
+	if _, total, _ := uc.GetMessages(context.Background(), 10, 0); total != 1 {
+		t.Errorf("Expected the unhidden message back in the list, got %d messages", total)
+	}
+}
+
+func TestMessageUseCase_RestoreMessage(t *testing.T) {
+	repo := NewMockMessageRepository()
+	uc := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	uc.SetTrashRetention(time.Hour)
+	ctx := context.Background()
+
+	message, err := uc.CreateMessage(ctx, 1, "testuser", "Test message")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	if err := uc.DeleteMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to delete message: %v", err)
+	}
+	if _, err := uc.RestoreMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to restore message: %v", err)
+	}
+	if _, err := uc.GetByID(ctx, message.ID); err != nil {
+		t.Errorf("Expected the restored message, got %v", err)
+	}
+
+	// Once it has been deleted longer than the retention it's purged
+	if err := uc.DeleteMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to delete message: %v", err)
+	}
+	repo.trashed[message.ID] = time.Now().Add(-2 * time.Hour)
+	if err := uc.PurgeTrash(ctx); err != nil {
+		t.Fatalf("Failed to purge trash: %v", err)
+	}
+	if _, err := uc.RestoreMessage(ctx, message.ID); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound after the purge, got %v", err)
+	}
+
+	// Without a trash, deletes are permanent
+	uc.SetTrashRetention(0)
+	message, err = uc.CreateMessage(ctx, 1, "testuser", "Gone for good")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if err := uc.DeleteMessage(ctx, message.ID); err != nil {
+		t.Fatalf("Failed to delete message: %v", err)
+	}
+	if _, err := uc.RestoreMessage(ctx, message.ID); !errors.Is(err, domain.ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound without a trash, got %v", err)
+	}
+}
+
+func TestMessageUseCase_BanMatchingMessages(t *testing.T) {
+	uc := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub())
+	ctx := context.Background()
+
+	var ids []int64
+	for _, content := range []string{"Cheap pills at spam.example.com", "A real question", "More at SPAM.example.com/offer"} {
+		message, err := uc.CreateMessage(ctx, 1, "testuser", content)
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+
+	if _, err := uc.BanMatchingMessages(ctx, "(unclosed"); !errors.Is(err, domain.ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern, got %v", err)
+	}
+
+	banned, err := uc.BanMatchingMessages(ctx, `(?i)spam\.example\.com`)
+	if err != nil {
+		t.Fatalf("Failed to ban matching messages: %v", err)
+	}
+	if banned != 2 {
+		t.Errorf("Expected 2 messages banned, got %d", banned)
+	}
+	for i, id := range ids {
+		message, err := uc.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to get message: %v", err)
+		}
+		if message.IsBanned != (i != 1) {
+			t.Errorf("Message %q: expected banned=%t", message.Content, i != 1)
+		}
+	}
+
+	// Already banned messages aren't counted again
+	if banned, _ := uc.BanMatchingMessages(ctx, "spam"); banned != 0 {
+		t.Errorf("Expected nothing left to ban, got %d", banned)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := uc.BanMatchingMessages(cancelled, "question"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMessageUseCase_UnbanMessages(t *testing.T) {
+	hub := NewMockHub()
+	uc := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), hub)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, content := range []string{"Banned", "Also banned", "Never banned"} {
+		message, err := uc.CreateMessage(ctx, 1, "testuser", content)
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		ids = append(ids, message.ID)
+	}
+	for _, id := range ids[:2] {
+		if err := uc.BanMessage(ctx, id); err != nil {
+			t.Fatalf("Failed to ban message: %v", err)
+		}
+	}
+	hub.broadcastedMessages = nil
+
+	unbanned, failed, err := uc.UnbanMessages(ctx, []int64{ids[0], 999, ids[1], ids[2]})
+	if err != nil {
+		t.Fatalf("Failed to unban messages: %v", err)
+	}
+	if fmt.Sprint(unbanned) != fmt.Sprint([]int64{ids[0], ids[1], ids[2]}) {
+		t.Errorf("Expected every existing message to succeed in order, got %v", unbanned)
+	}
+	if fmt.Sprint(failed) != "[999]" {
+		t.Errorf("Expected the missing ID to fail, got %v", failed)
+	}
+	for _, id := range ids {
+		if message, _ := uc.GetByID(ctx, id); message.IsBanned {
+			t.Errorf("Expected message %d to be unbanned", id)
+		}
+	}
+	if len(hub.broadcastedMessages) != 3 {
+		t.Errorf("Expected each unbanned message to be broadcast, got %d broadcasts", len(hub.broadcastedMessages))
+	}
+}
+
+func TestMessageUseCase_CreateComment(t *testing.T) {
+	repo := NewMockMessageRepository()
+	authClient := NewMockAuthClient()
+	hub := NewMockHub()
+	uc := NewTestMessageUseCase(repo, authClient, hub)
+
+	// Create test message
+	message, err := uc.CreateMessage(context.Background(), 1, "testuser", "Test message")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		messageID int64
+		userID    int64
+		username  string
+		content   string
+		wantErr   bool
+	}{
+		{
+			name:      "Valid comment creation",
+			messageID: message.ID,
+			userID:    2,
+			username:  "admin",
 			content:   "Test comment",
 			wantErr:   false,
 		},
@@ -510,7 +1355,7 @@ func TestMessageUseCase_CreateComment(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			comment, err := uc.CreateComment(tt.messageID, tt.userID, tt.username, tt.content)
+			comment, err := uc.CreateComment(context.Background(), tt.messageID, tt.userID, tt.username, tt.content)
 
 			if tt.wantErr {
 				if err == nil {
@@ -539,3 +1384,743 @@ func TestMessageUseCase_CreateComment(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageUseCase_RecordsMentions(t *testing.T) {
+	repo := NewMockMessageRepository()
+	useCase := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub())
+
+	// admin (user 2) has posted before so the username can be resolved
+	if _, err := useCase.CreateMessage(context.Background(), 2, "admin", "Welcome"); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	// Duplicate and unknown mentions are dropped
+	message, err := useCase.CreateMessage(context.Background(), 1, "testuser", "@admin @admin can you help? cc @ghost")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	mentions, total, err := useCase.GetUserMentions(context.Background(), 2, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get mentions: %v", err)
+	}
+	if total != 1 {
+		t.Fatalf("Expected 1 mention, got %d", total)
+	}
+	if mentions[0].SourceType != domain.MentionSourceMessage || mentions[0].SourceID != message.ID {
+		t.Errorf("Expected mention from message %d, got %s %d", message.ID, mentions[0].SourceType, mentions[0].SourceID)
+	}
+}
+
+func TestMessageUseCase_ContentModes(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		content      string
+		expectedBody string
+		expectedHTML string
+	}{
+		{
+			name:         "Plain stores content as-is",
+			mode:         ContentModePlain,
+			content:      "Hi <script>alert(1)</script> **there**",
+			expectedBody: "Hi <script>alert(1)</script> **there**",
+		},
+		{
+			name:         "Sanitized strips script tags",
+			mode:         ContentModeSanitized,
+			content:      "Hi <script>alert(1)</script><b>there</b>",
+			expectedBody: "Hi there",
+		},
+		{
+			name:         "Markdown renders bold and drops raw HTML",
+			mode:         ContentModeMarkdown,
+			content:      "**bold** <script>alert(1)</script>",
+			expectedBody: "**bold** <script>alert(1)</script>",
+			expectedHTML: "<p><strong>bold</strong> alert(1)</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCase := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub())
+			if err := useCase.(*MessageUseCase).SetContentMode(tt.mode); err != nil {
+				t.Fatalf("Failed to set content mode: %v", err)
+			}
+
+			message, err := useCase.CreateMessage(context.Background(), 1, "testuser", tt.content)
+			if err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if message.Content != tt.expectedBody {
+				t.Errorf("Expected content %q, got %q", tt.expectedBody, message.Content)
+			}
+			if strings.TrimSpace(message.ContentHTML) != tt.expectedHTML {
+				t.Errorf("Expected content_html %q, got %q", tt.expectedHTML, message.ContentHTML)
+			}
+
+			preview, err := useCase.PreviewContent(context.Background(), tt.content)
+			if err != nil {
+				t.Fatalf("Failed to preview content: %v", err)
+			}
+			if preview.Content != message.Content || preview.ContentHTML != message.ContentHTML {
+				t.Errorf("Expected the preview to match the stored message, got %+v", preview)
+			}
+		})
+	}
+
+	sanitizing := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub())
+	if err := sanitizing.(*MessageUseCase).SetContentMode(ContentModeSanitized); err != nil {
+		t.Fatalf("Failed to set content mode: %v", err)
+	}
+	var verrs domain.ValidationErrors
+	if _, err := sanitizing.PreviewContent(context.Background(), "<b></b>"); !errors.As(err, &verrs) {
+		t.Errorf("Expected a validation error previewing content that sanitizes to nothing, got %v", err)
+	}
+
+	useCase := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub())
+	if err := useCase.(*MessageUseCase).SetContentMode("html"); err == nil {
+		t.Error("Expected error for unknown content mode")
+	}
+}
+
+func TestMessageUseCase_CommentTTL(t *testing.T) {
+	repo := NewMockMessageRepository()
+	useCase := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub())
+	ctx := context.Background()
+
+	announcement, err := useCase.CreateMessageWithOptions(ctx, 1, "testuser", "Announcement", domain.MessageOptions{
+		CommentTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	chatter, err := useCase.CreateMessage(ctx, 1, "testuser", "Chatter")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+
+	long, err := useCase.CreateComment(ctx, announcement.ID, 1, "testuser", "Kept for an hour")
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	short, err := useCase.CreateComment(ctx, chatter.ID, 1, "testuser", "Kept for the default")
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+
+	if lifetime := long.ExpiresAt.Sub(long.CreatedAt).Round(time.Second); lifetime != time.Hour {
+		t.Errorf("Expected comment on 1h TTL message to live 1h, got %s", lifetime)
+	}
+	if lifetime := short.ExpiresAt.Sub(short.CreatedAt).Round(time.Second); lifetime != config.DefaultCommentTTL {
+		t.Errorf("Expected comment on default message to live %s, got %s", config.DefaultCommentTTL, lifetime)
+	}
+	if !long.ExpiresAt.After(short.ExpiresAt) {
+		t.Error("Expected comment on 1h TTL message to outlive the default one")
+	}
+
+	// Imported comments follow the same TTLs
+	imported := []*domain.Comment{
+		{MessageID: announcement.ID, Username: "olduser", Content: "Imported for an hour"},
+		{MessageID: chatter.ID, Username: "olduser", Content: "Imported for the default"},
+	}
+	if _, err := useCase.ImportComments(ctx, imported); err != nil {
+		t.Fatalf("Failed to import comments: %v", err)
+	}
+	if lifetime := imported[0].ExpiresAt.Sub(imported[0].CreatedAt).Round(time.Second); lifetime != time.Hour {
+		t.Errorf("Expected imported comment on 1h TTL message to live 1h, got %s", lifetime)
+	}
+	if lifetime := imported[1].ExpiresAt.Sub(imported[1].CreatedAt).Round(time.Second); lifetime != config.DefaultCommentTTL {
+		t.Errorf("Expected imported comment on default message to live %s, got %s", config.DefaultCommentTTL, lifetime)
+	}
+
+	_, err = useCase.CreateMessageWithOptions(ctx, 1, "testuser", "Forever", domain.MessageOptions{
+		CommentTTL: config.DefaultMaxCommentTTL + time.Second,
+	})
+	if !errors.Is(err, domain.ErrInvalidCommentTTL) {
+		t.Errorf("Expected ErrInvalidCommentTTL above the max, got %v", err)
+	}
+}
+
+func TestMessageUseCase_UpdateComment(t *testing.T) {
+	repo := NewMockMessageRepository()
+	hub := NewMockHub()
+	useCase := NewMessageUseCase(repo, NewMockAuthClient(), hub).(*MessageUseCase)
+	ctx := context.Background()
+
+	message, err := useCase.CreateMessage(ctx, 1, "testuser", "Message")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	comment, err := useCase.CreateComment(ctx, message.ID, 1, "testuser", "Original")
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	originalExpiry := comment.ExpiresAt
+
+	if _, err := useCase.UpdateComment(ctx, comment.ID, 2, "Not mine"); !errors.Is(err, domain.ErrNotCommentAuthor) {
+		t.Errorf("Expected ErrNotCommentAuthor for another user, got %v", err)
+	}
+	var verrs domain.ValidationErrors
+	if _, err := useCase.UpdateComment(ctx, comment.ID, 1, strings.Repeat("a", domain.DefaultMaxCommentLength+1)); !errors.As(err, &verrs) {
+		t.Errorf("Expected validation error for overlong content, got %v", err)
+	}
+
+	updated, err := useCase.UpdateComment(ctx, comment.ID, 1, "Edited")
+	if err != nil {
+		t.Fatalf("Failed to update comment: %v", err)
+	}
+	if updated.Content != "Edited" || repo.comments[comment.ID].Content != "Edited" {
+		t.Errorf("Expected stored content 'Edited', got '%s'", repo.comments[comment.ID].Content)
+	}
+	if !updated.ExpiresAt.Equal(originalExpiry) {
+		t.Errorf("Expected expiry to be kept by default, got %v instead of %v", updated.ExpiresAt, originalExpiry)
+	}
+	if len(hub.broadcastedComments) != 2 {
+		t.Errorf("Expected the edit to be broadcast, got %d comment broadcasts", len(hub.broadcastedComments))
+	}
+
+	useCase.SetResetCommentExpiryOnEdit(true)
+	repo.comments[comment.ID].ExpiresAt = time.Now().Add(time.Second)
+	updated, err = useCase.UpdateComment(ctx, comment.ID, 1, "Edited again")
+	if err != nil {
+		t.Fatalf("Failed to update comment: %v", err)
+	}
+	if time.Until(updated.ExpiresAt) < config.DefaultCommentTTL-time.Minute {
+		t.Errorf("Expected expiry to be reset to a full lifetime, got %v", updated.ExpiresAt)
+	}
+
+	repo.comments[comment.ID].ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := useCase.UpdateComment(ctx, comment.ID, 1, "Too late"); !errors.Is(err, domain.ErrCommentExpired) {
+		t.Errorf("Expected ErrCommentExpired, got %v", err)
+	}
+}
+
+func TestMessageUseCase_DuplicateContent(t *testing.T) {
+	repo := NewMockMessageRepository()
+	useCase := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	ctx := context.Background()
+
+	// The check is off by default
+	for i := 0; i < 2; i++ {
+		if _, err := useCase.CreateMessage(ctx, 1, "testuser", "Allowed twice"); err != nil {
+			t.Fatalf("Expected duplicates to be allowed with no window, got %v", err)
+		}
+	}
+
+	useCase.SetDuplicateWindow(time.Minute)
+	first, err := useCase.CreateMessage(ctx, 1, "testuser", "Buy now!")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if _, err := useCase.CreateMessage(ctx, 1, "testuser", "Buy now!"); !errors.Is(err, domain.ErrDuplicateContent) {
+		t.Errorf("Expected ErrDuplicateContent within the window, got %v", err)
+	}
+
+	// Other users, anonymous posts and different content are unaffected
+	if _, err := useCase.CreateMessage(ctx, 2, "otheruser", "Buy now!"); err != nil {
+		t.Errorf("Expected another user to post the same content, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := useCase.CreateMessage(ctx, 0, "anonymous", "Hello"); err != nil {
+			t.Errorf("Expected anonymous posts to skip the check, got %v", err)
+		}
+	}
+	if _, err := useCase.CreateMessage(ctx, 1, "testuser", "Buy now!!"); err != nil {
+		t.Errorf("Expected different content to be allowed, got %v", err)
+	}
+
+	// Once the window has passed the content can be posted again
+	first.CreatedAt = time.Now().Add(-2 * time.Minute)
+	if _, err := useCase.CreateMessage(ctx, 1, "testuser", "Buy now!"); err != nil {
+		t.Errorf("Expected the content to be allowed after the window, got %v", err)
+	}
+}
+
+func TestMessageUseCase_BannedWords(t *testing.T) {
+	repo := NewMockMessageRepository()
+	useCase := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub())
+	useCase.(*MessageUseCase).SetBannedWords([]string{"Spam", " "})
+	ctx := context.Background()
+
+	_, err := useCase.CreateMessage(ctx, 0, "SPAM-bot", "Hello there")
+	var verrs domain.ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "username" {
+		t.Fatalf("Expected a username field error, got %v", err)
+	}
+
+	message, err := useCase.CreateMessage(ctx, 0, "anonymous", "No spammers here")
+	if err != nil {
+		t.Fatalf("Expected words containing a banned word to be allowed, got %v", err)
+	}
+
+	if _, err := useCase.CreateComment(ctx, message.ID, 0, "anonymous", "buy spam now"); !errors.As(err, &verrs) {
+		t.Errorf("Expected banned word in comment content to be rejected, got %v", err)
+	}
+}
+
+func TestNewUseCase_EnforcesBansAndBroadcasts(t *testing.T) {
+	authClient := NewMockAuthClient()
+	authClient.users[3] = &domain.User{ID: 3, Username: "banned", Role: "user", IsBanned: true}
+	hub := NewMockHub()
+	cfg := config.NewConfig()
+	cfg.BannedWords = []string{"spam"}
+	uc := NewUseCase(NewMockMessageRepository(), authClient, hub, cfg)
+	ctx := context.Background()
+
+	if _, err := uc.CreateMessage(ctx, 3, "banned", "Hello"); err == nil {
+		t.Error("Expected banned user to be rejected")
+	}
+	if _, err := uc.CreateMessage(ctx, 1, "testuser", "Buy spam now"); err == nil {
+		t.Error("Expected configured banned words to be rejected")
+	}
+
+	message, err := uc.CreateMessage(ctx, 1, "testuser", "Hello")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if len(hub.broadcastedMessages) != 1 || hub.broadcastedMessages[0].ID != message.ID {
+		t.Errorf("Expected the new message to be broadcast, got %d broadcasts", len(hub.broadcastedMessages))
+	}
+}
+
+// gatedListRepository counts List calls and holds each one until release is closed
+type gatedListRepository struct {
+	*MockMessageRepository
+	calls   atomic.Int64
+	release chan struct{}
+}
+
+func (r *gatedListRepository) List(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	r.calls.Add(1)
+	<-r.release
+	return r.MockMessageRepository.List(ctx, limit, offset)
+}
+
+func TestMessageUseCase_CoalescesConcurrentLists(t *testing.T) {
+	repo := &gatedListRepository{MockMessageRepository: NewMockMessageRepository(), release: make(chan struct{})}
+	uc := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	uc.SetListCacheTTL(time.Minute)
+	ctx := context.Background()
+
+	close(repo.release)
+	if _, err := uc.CreateMessage(ctx, 1, "testuser", "Hello"); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	repo.release = make(chan struct{})
+
+	const clients = 20
+	var wg sync.WaitGroup
+	totals := make([]int64, clients)
+	errs := make([]error, clients)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, totals[i], errs[i] = uc.GetMessages(ctx, 10, 0)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	for i := 0; i < clients; i++ {
+		if errs[i] != nil || totals[i] != 1 {
+			t.Fatalf("Client %d: expected total 1, got %d (err %v)", i, totals[i], errs[i])
+		}
+	}
+	if calls := repo.calls.Load(); calls != 1 {
+		t.Errorf("Expected %d concurrent lists to query the repository once, got %d", clients, calls)
+	}
+
+	// A new message clears the cached first page
+	if _, err := uc.CreateMessage(ctx, 1, "testuser", "Hello again"); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	_, total, err := uc.GetMessages(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if total != 2 || repo.calls.Load() != 2 {
+		t.Errorf("Expected a fresh query with total 2 after creating a message, got total %d after %d queries", total, repo.calls.Load())
+	}
+}
+
+func TestNewUseCase_ConfiguredLengthLimits(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.MaxMessageLength = 10
+	cfg.MaxCommentLength = 5
+	cfg.MaxUsernameLength = 4
+	uc := NewUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub(), cfg)
+	ctx := context.Background()
+
+	var verrs domain.ValidationErrors
+	if _, err := uc.CreateMessage(ctx, 1, "user", "Eleven char"); !errors.As(err, &verrs) {
+		t.Errorf("Expected message over the configured limit to be rejected, got %v", err)
+	}
+	if _, err := uc.CreateMessage(ctx, 1, "users", "Hello"); !errors.As(err, &verrs) {
+		t.Errorf("Expected username over the configured limit to be rejected, got %v", err)
+	}
+
+	message, err := uc.CreateMessage(ctx, 1, "user", "Ten chars!")
+	if err != nil {
+		t.Fatalf("Expected message at the configured limit to be accepted, got %v", err)
+	}
+	if _, err := uc.CreateComment(ctx, message.ID, 0, "anon", "Six ch"); !errors.As(err, &verrs) {
+		t.Errorf("Expected comment over the configured limit to be rejected, got %v", err)
+	}
+	if _, err := uc.CreateComment(ctx, message.ID, 0, "anon", "Five!"); err != nil {
+		t.Errorf("Expected comment at the configured limit to be accepted, got %v", err)
+	}
+}
+
+func TestMessageUseCase_ReadOnly(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.ReadOnly = true
+	uc := NewUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub(), cfg)
+	ctx := context.Background()
+
+	if !uc.IsReadOnly() {
+		t.Fatal("Expected READ_ONLY to start the use case read-only")
+	}
+	uc.SetReadOnly(false)
+	message, err := uc.CreateMessage(ctx, 1, "testuser", "Before maintenance")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	uc.SetReadOnly(true)
+
+	writes := map[string]func() error{
+		"CreateMessage": func() error {
+			_, err := uc.CreateMessage(ctx, 1, "testuser", "During maintenance")
+			return err
+		},
+		"CreateComment": func() error {
+			_, err := uc.CreateComment(ctx, message.ID, 1, "testuser", "During maintenance")
+			return err
+		},
+		"BanMessage":    func() error { return uc.BanMessage(ctx, message.ID) },
+		"DeleteMessage": func() error { return uc.DeleteMessage(ctx, message.ID) },
+		"SyncUsername": func() error {
+			_, err := uc.SyncUsername(ctx, 1, "renamed")
+			return err
+		},
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, domain.ErrReadOnly) {
+			t.Errorf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+
+	got, err := uc.GetByID(ctx, message.ID)
+	if err != nil {
+		t.Fatalf("Expected reads to work in read-only mode, got %v", err)
+	}
+	if got.IsBanned || got.Username != "testuser" {
+		t.Errorf("Expected the message to be untouched in read-only mode, got %+v", got)
+	}
+}
+
+func TestNewUseCase_ConfiguredListCacheTTL(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.ListCacheTTL = 3 * time.Second
+	uc := NewUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub(), cfg)
+
+	if uc.lists.ttl != cfg.ListCacheTTL {
+		t.Errorf("Expected list cache TTL %s, got %s", cfg.ListCacheTTL, uc.lists.ttl)
+	}
+}
+
+func TestMessageUseCase_BumpWindow(t *testing.T) {
+	repo := NewMockMessageRepository()
+	uc := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	uc.SetBumpWindow(time.Hour)
+	ctx := context.Background()
+
+	fresh, err := uc.CreateMessage(ctx, 1, "testuser", "Fresh thread")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	old, err := uc.CreateMessage(ctx, 1, "testuser", "Ancient thread")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	recent := time.Now().UTC().Add(-10 * time.Minute)
+	repo.messages[fresh.ID].CreatedAt = recent
+	repo.messages[fresh.ID].LastActivityAt = recent
+	posted := time.Now().UTC().Add(-2 * time.Hour)
+	repo.messages[old.ID].CreatedAt = posted
+	repo.messages[old.ID].LastActivityAt = posted
+
+	if _, err := uc.CreateComment(ctx, fresh.ID, 0, "anonymous", "Bump"); err != nil {
+		t.Fatalf("Failed to comment on fresh thread: %v", err)
+	}
+	if !repo.messages[fresh.ID].LastActivityAt.After(repo.messages[fresh.ID].CreatedAt) {
+		t.Error("Expected a comment within the bump window to bump the message")
+	}
+
+	if _, err := uc.CreateComment(ctx, old.ID, 0, "anonymous", "Necro"); err != nil {
+		t.Fatalf("Expected comment outside the bump window to still be created, got %v", err)
+	}
+	if !repo.messages[old.ID].LastActivityAt.Equal(posted) {
+		t.Errorf("Expected a comment outside the bump window not to bump, last activity moved to %v", repo.messages[old.ID].LastActivityAt)
+	}
+}
+
+func TestMessageUseCase_ImportComments(t *testing.T) {
+	repo := NewMockMessageRepository()
+	uc := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	uc.SetLimits(domain.Limits{MaxMessageLength: 100, MaxCommentLength: 10, MaxUsernameLength: 20})
+	uc.SetBannedWords([]string{"spam"})
+	uc.SetBumpWindow(time.Hour)
+	ctx := context.Background()
+
+	fresh, err := uc.CreateMessage(ctx, 1, "testuser", "Fresh thread")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	old, err := uc.CreateMessage(ctx, 1, "testuser", "Ancient thread")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	recent := time.Now().UTC().Add(-10 * time.Minute)
+	repo.messages[fresh.ID].CreatedAt = recent
+	repo.messages[fresh.ID].LastActivityAt = recent
+	posted := time.Now().UTC().Add(-2 * time.Hour)
+	repo.messages[old.ID].CreatedAt = posted
+	repo.messages[old.ID].LastActivityAt = posted
+
+	for _, tt := range []struct {
+		name    string
+		comment domain.Comment
+	}{
+		{name: "Over the length limit", comment: domain.Comment{MessageID: fresh.ID, Username: "olduser", Content: "Eleven char"}},
+		{name: "Banned word", comment: domain.Comment{MessageID: fresh.ID, Username: "olduser", Content: "Buy spam"}},
+	} {
+		comment := tt.comment
+		if _, err := uc.ImportComments(ctx, []*domain.Comment{&comment}); !errors.Is(err, domain.ErrInvalidComment) {
+			t.Errorf("%s: expected ErrInvalidComment, got %v", tt.name, err)
+		}
+	}
+
+	ids, err := uc.ImportComments(ctx, []*domain.Comment{
+		{MessageID: fresh.ID, Username: "olduser", Content: "Bump"},
+		{MessageID: old.ID, Username: "olduser", Content: "Necro"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to import comments: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 IDs, got %d", len(ids))
+	}
+	if !repo.messages[fresh.ID].LastActivityAt.After(recent) {
+		t.Error("Expected an import within the bump window to bump the message")
+	}
+	if !repo.messages[old.ID].LastActivityAt.Equal(posted) {
+		t.Errorf("Expected an import outside the bump window not to bump, last activity moved to %v", repo.messages[old.ID].LastActivityAt)
+	}
+
+	if err := uc.LockMessage(ctx, fresh.ID); err != nil {
+		t.Fatalf("Failed to lock message: %v", err)
+	}
+	_, err = uc.ImportComments(ctx, []*domain.Comment{{MessageID: fresh.ID, Username: "olduser", Content: "Blocked"}})
+	if !errors.Is(err, domain.ErrThreadLocked) {
+		t.Errorf("Expected ErrThreadLocked importing onto a locked thread, got %v", err)
+	}
+}
+
+func TestNewUseCase_ConfiguredBumpWindow(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.BumpWindow = time.Hour
+	uc := NewUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub(), cfg)
+
+	if uc.bumpWindow != cfg.BumpWindow {
+		t.Errorf("Expected bump window %s, got %s", cfg.BumpWindow, uc.bumpWindow)
+	}
+}
+
+func TestMessageUseCase_ContentEncoding(t *testing.T) {
+	uc := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	ctx := context.Background()
+
+	var verrs domain.ValidationErrors
+	if _, err := uc.CreateMessage(ctx, 1, "testuser", "Broken \xff bytes"); !errors.As(err, &verrs) {
+		t.Errorf("Expected validation errors for invalid UTF-8, got %v", err)
+	}
+
+	// "é" as e followed by a combining acute accent
+	decomposed := "Caf\u0065\u0301"
+	message, err := uc.CreateMessage(ctx, 1, "testuser", decomposed)
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if message.Content != decomposed {
+		t.Errorf("Expected content stored as submitted, got %q", message.Content)
+	}
+
+	uc.SetNormalizeContent(true)
+	message, err = uc.CreateMessage(ctx, 1, "testuser", decomposed)
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if message.Content != "Caf\u00e9" {
+		t.Errorf("Expected content normalized to NFC, got %q", message.Content)
+	}
+	comment, err := uc.CreateComment(ctx, message.ID, 1, "testuser", decomposed)
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	if comment.Content != "Caf\u00e9" {
+		t.Errorf("Expected comment normalized to NFC, got %q", comment.Content)
+	}
+}
+
+func TestMessageUseCase_CommentsNeverExpire(t *testing.T) {
+	repo := NewMockMessageRepository()
+	uc := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+	uc.SetCommentsExpire(false)
+	ctx := context.Background()
+
+	message, err := uc.CreateMessage(ctx, 1, "testuser", "Thread")
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	comment, err := uc.CreateComment(ctx, message.ID, 1, "testuser", "Forever")
+	if err != nil {
+		t.Fatalf("Failed to create comment: %v", err)
+	}
+	if !comment.ExpiresAt.Equal(domain.CommentNeverExpires) {
+		t.Errorf("Expected the comment never to expire, got expiry %v", comment.ExpiresAt)
+	}
+
+	// A comment left over from when expiry was on
+	stale, err := repo.CreateComment(ctx, &domain.Comment{MessageID: message.ID, UserID: 1, Username: "testuser", Content: "Old", ExpiresAt: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to create stale comment: %v", err)
+	}
+
+	if err := uc.CleanupExpiredComments(ctx); err != nil {
+		t.Fatalf("Failed to clean up comments: %v", err)
+	}
+	comments, err := uc.GetComments(ctx, message.ID, domain.SortAsc)
+	if err != nil {
+		t.Fatalf("Failed to get comments: %v", err)
+	}
+	if len(comments) != 2 {
+		t.Fatalf("Expected both comments to survive cleanup, got %d", len(comments))
+	}
+	if _, ok := repo.comments[stale]; !ok {
+		t.Error("Expected cleanup to leave the stale comment in the repository")
+	}
+}
+
+func TestMessageUseCase_RequireAuth(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name                   string
+		messages, comments     bool
+		anonMessage, anonReply bool
+	}{
+		{name: "Both open", anonMessage: true, anonReply: true},
+		{name: "Comments require auth", comments: true, anonMessage: true},
+		{name: "Messages require auth", messages: true, anonReply: true},
+		{name: "Both require auth", messages: true, comments: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewMessageUseCase(NewMockMessageRepository(), NewMockAuthClient(), NewMockHub()).(*MessageUseCase)
+			uc.SetRequireAuth(tt.messages, tt.comments)
+
+			// Authenticated callers can always post
+			message, err := uc.CreateMessage(ctx, 1, "testuser", "Signed in")
+			if err != nil {
+				t.Fatalf("Expected an authenticated message to be created, got %v", err)
+			}
+			if _, err := uc.CreateComment(ctx, message.ID, 1, "testuser", "Signed in"); err != nil {
+				t.Errorf("Expected an authenticated comment to be created, got %v", err)
+			}
+
+			_, err = uc.CreateMessage(ctx, 0, "anonymous", "Anonymous")
+			if tt.anonMessage && err != nil {
+				t.Errorf("Expected an anonymous message to be created, got %v", err)
+			}
+			if !tt.anonMessage && !errors.Is(err, domain.ErrAuthRequired) {
+				t.Errorf("Expected ErrAuthRequired for an anonymous message, got %v", err)
+			}
+
+			_, err = uc.CreateComment(ctx, message.ID, 0, "anonymous", "Anonymous")
+			if tt.anonReply && err != nil {
+				t.Errorf("Expected an anonymous comment to be created, got %v", err)
+			}
+			if !tt.anonReply && !errors.Is(err, domain.ErrAuthRequired) {
+				t.Errorf("Expected ErrAuthRequired for an anonymous comment, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMessageUseCase_SearchRanking(t *testing.T) {
+	repo := NewMockMessageRepository()
+	uc := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub())
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	create := func(content string, age time.Duration) int64 {
+		id, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: content})
+		if err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		repo.messages[id].CreatedAt = now.Add(-age)
+		return id
+	}
+	olderPartial := create("Concatenate by category", 3*24*time.Hour)
+	recentExact := create("My cat", time.Hour)
+	ancientExact := create("Cat, cat and more cat", 90*24*time.Hour)
+
+	if recent, older := searchScore("My cat", "cat", now.Add(-time.Hour), now), searchScore("Concatenate by category", "cat", now.Add(-3*24*time.Hour), now); recent <= older {
+		t.Errorf("Expected a recent whole-word match to outscore an older partial one, got %v <= %v", recent, older)
+	}
+
+	results, total, err := uc.SearchMessages(ctx, "cat", 10, 0, false)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	var got []int64
+	for _, result := range results {
+		got = append(got, result.ID)
+	}
+	if want := []int64{recentExact, olderPartial, ancientExact}; total != 3 || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected ranking %v of 3, got %v of %d", want, got, total)
+	}
+
+	// Pagination applies to the ranked order
+	page, _, err := uc.SearchMessages(ctx, "cat", 1, 1, false)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != olderPartial {
+		t.Errorf("Expected the second ranked message on page 2, got %+v", page)
+	}
+}
+
+func TestMessageUseCase_SearchTotalCappedAtRanked(t *testing.T) {
+	repo := NewMockMessageRepository()
+	uc := NewMessageUseCase(repo, NewMockAuthClient(), NewMockHub())
+	ctx := context.Background()
+
+	for i := 0; i < maxRankedSearchResults+5; i++ {
+		if _, err := repo.Create(ctx, &domain.Message{UserID: 1, Username: "testuser", Content: "Cat"}); err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+	}
+
+	results, total, err := uc.SearchMessages(ctx, "cat", 10, maxRankedSearchResults-5, false)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if total != maxRankedSearchResults || len(results) != 5 {
+		t.Errorf("Expected the last 5 of %d ranked results, got %d of %d", maxRankedSearchResults, len(results), total)
+	}
+}
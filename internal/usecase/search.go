@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+const (
+	// maxRankedSearchResults caps how many of the newest matches a search
+	// ranks; older matches beyond it are never returned
+	maxRankedSearchResults = 1000
+	// searchRecencyHalfLife is the age at which a match's score halves
+	searchRecencyHalfLife = 7 * 24 * time.Hour
+	// wholeWordWeight is how much an occurrence of the query as a whole word
+	// counts, against 1 for an occurrence inside a longer word
+	wholeWordWeight = 2
+)
+
+// searchScore rates how well content matches query, combining term frequency
+// with recency. Whole-word occurrences count more than ones inside other
+// words, repeats add less and less, and the score halves every
+// searchRecencyHalfLife of age as of now.
+func searchScore(content, query string, createdAt, now time.Time) float64 {
+	text := []rune(content)
+	queryLen := utf8.RuneCountInString(query)
+
+	var frequency float64
+	for _, start := range domain.MatchOffsets(content, query) {
+		if isWordBoundary(text, start-1) && isWordBoundary(text, start+queryLen) {
+			frequency += wholeWordWeight
+		} else {
+			frequency++
+		}
+	}
+
+	age := max(now.Sub(createdAt), 0)
+	return math.Log1p(frequency) * math.Pow(0.5, float64(age)/float64(searchRecencyHalfLife))
+}
+
+// isWordBoundary reports whether position i of text is outside it or not part of a word
+func isWordBoundary(text []rune, i int) bool {
+	if i < 0 || i >= len(text) {
+		return true
+	}
+	return !unicode.IsLetter(text[i]) && !unicode.IsDigit(text[i])
+}
+
+// rankSearchResults orders messages by searchScore, best first. Equal
+// scores keep the newest first so the order is deterministic.
+func rankSearchResults(messages []*domain.Message, query string, now time.Time) {
+	scores := make(map[int64]float64, len(messages))
+	for _, message := range messages {
+		scores[message.ID] = searchScore(message.Content, query, message.CreatedAt, now)
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		if scores[a.ID] != scores[b.ID] {
+			return scores[a.ID] > scores[b.ID]
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+}
@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/atmega-p471/forum-service/internal/domain"
+)
+
+// wordFilter matches whole words against a case-insensitive banned list
+type wordFilter map[string]bool
+
+// newWordFilter builds a filter from words, ignoring blank entries
+func newWordFilter(words []string) wordFilter {
+	filter := make(wordFilter, len(words))
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			filter[word] = true
+		}
+	}
+	return filter
+}
+
+// matches reports whether text contains any banned word
+func (f wordFilter) matches(text string) bool {
+	if len(f) == 0 {
+		return false
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		if f[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// check reports every field of a post that contains a banned word
+func (f wordFilter) check(username, content string) error {
+	var errs domain.ValidationErrors
+	if f.matches(content) {
+		errs = append(errs, domain.FieldError{Field: "content", Message: "content contains a banned word"})
+	}
+	if f.matches(username) {
+		errs = append(errs, domain.FieldError{Field: "username", Message: "username contains a banned word"})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
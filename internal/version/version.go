@@ -0,0 +1,32 @@
+// Package version describes the running build. Version, Commit and BuildTime
+// are set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/atmega-p471/forum-service/internal/version.Version=v1.2.0"
+package version
+
+import "runtime"
+
+// Set with -ldflags "-X ..." at build time; the defaults mark a local build
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info is the build information reported by the version endpoints
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -1,28 +1,33 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/atmega-p471/forum-service/internal/config"
 	"github.com/atmega-p471/forum-service/internal/delivery/grpc"
 	"github.com/atmega-p471/forum-service/internal/delivery/grpc/client"
+	"github.com/atmega-p471/forum-service/internal/delivery/grpc/interceptor"
 	httpHandler "github.com/atmega-p471/forum-service/internal/delivery/http"
 	"github.com/atmega-p471/forum-service/internal/delivery/ws"
+	"github.com/atmega-p471/forum-service/internal/ratelimit"
 	"github.com/atmega-p471/forum-service/internal/repository"
 	"github.com/atmega-p471/forum-service/internal/usecase"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	httpSwagger "github.com/swaggo/http-swagger"
 	grpclib "google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
 	// Swagger docs
-	_ "github.com/atmega-p471/forum-service/docs"
+	"github.com/atmega-p471/forum-service/docs"
 )
 
 // @title Forum Service API
@@ -46,13 +51,15 @@ func main() {
 
 	// Load configuration
 	cfg := config.NewConfig()
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal().Err(err).Msg("Invalid configuration")
+	}
 
 	// Connect to SQLite database
-	db, err := sql.Open("sqlite3", cfg.DBPath)
+	db, err := sql.Open("sqlite3", repository.DSN(cfg.DBPath, cfg.DBBusyTimeout, cfg.DBJournalMode))
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to connect to database")
 	}
-	defer db.Close()
 
 	// Check database connection
 	if err := db.Ping(); err != nil {
@@ -65,11 +72,14 @@ func main() {
 	}
 
 	// Initialize WebSocket hub
-	hub := ws.NewHub()
+	hub := ws.NewHubWithBuffer(int(cfg.HubBufferSize))
+	hub.SetMaxConnsPerIP(int(cfg.WSMaxConnsPerIP))
+	hub.SetReplaySize(int(cfg.WSReplaySize))
 	go hub.Run()
 
 	// Initialize repositories
 	repo := repository.NewRepository(db)
+	repo.LogSlowQueries(logger, cfg.SlowQueryThreshold)
 
 	// Initialize auth client
 	authConn, err := grpclib.Dial(cfg.AuthServiceAddr, grpclib.WithInsecure())
@@ -80,20 +90,22 @@ func main() {
 	authClient := client.NewAuthClient(authConn)
 
 	// Initialize use cases
-	messageUsecase := usecase.NewUseCase(repo, authClient, hub, cfg)
+	messageUsecase := usecase.NewUseCase(repo.Message, authClient, hub, cfg)
+	messageUsecase.StartCleanupScheduler()
+	messageUsecase.StartRetentionScheduler(cfg.MessageRetention)
 
 	// Initialize gRPC server
-	grpcServer := grpclib.NewServer()
-	forumServer := grpc.NewForumServer(messageUsecase, logger)
+	grpcServer := grpclib.NewServer(grpclib.UnaryInterceptor(interceptor.AuthUnary(authClient)))
+	forumServer := grpc.NewForumServer(messageUsecase, hub, logger)
 	forumServer.Register(grpcServer)
 	reflection.Register(grpcServer)
 
 	go func() {
-		lis, err := net.Listen("tcp", ":9092")
+		lis, err := net.Listen("tcp", cfg.GRPCAddr)
 		if err != nil {
 			logger.Fatal().Err(err).Msg("Failed to listen for gRPC")
 		}
-		logger.Info().Msg("gRPC server is running on :9092")
+		logger.Info().Str("address", cfg.GRPCAddr).Msg("gRPC server is running")
 		if err := grpcServer.Serve(lis); err != nil {
 			logger.Fatal().Err(err).Msg("Failed to serve gRPC")
 		}
@@ -103,18 +115,37 @@ func main() {
 	router := http.NewServeMux()
 
 	// Swagger
+	httpHandler.ConfigureSwagger(docs.SwaggerInfo, cfg)
 	router.Handle("/swagger/", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8082/swagger/doc.json"), //The url pointing to API definition
+		httpSwagger.URL("/swagger/doc.json"), //The url pointing to API definition
 	))
 
 	// Initialize HTTP handler
-	handler := httpHandler.NewHandler(messageUsecase, hub, authClient)
+	handler := httpHandler.NewHandler(messageUsecase, hub, authClient, cfg)
 	handler.RegisterRoutes(router)
+	messageUsecase.OnUserBanned(handler.ForgetUser)
+	if cfg.RateLimitBackend == config.RateLimitBackendRedis {
+		// Share rate limits with every other instance using the same Redis
+		rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		defer rdb.Close()
+		if err := rdb.Ping(context.Background()).Err(); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to connect to Redis")
+		}
+		handler.SetRateLimiters(
+			ratelimit.NewRedis(rdb, "messages", cfg.MessagesPerMinute),
+			ratelimit.NewRedis(rdb, "comments", cfg.CommentsPerMinute),
+			ratelimit.NewRedis(rdb, "previews", cfg.PreviewsPerMinute),
+		)
+	}
 
 	// Start HTTP server
+	httpServer := &http.Server{
+		Addr:    cfg.HTTPAddr,
+		Handler: router,
+	}
 	go func() {
-		logger.Info().Msg("HTTP server is running on :8082")
-		if err := http.ListenAndServe(":8082", router); err != nil {
+		logger.Info().Str("address", cfg.HTTPAddr).Msg("HTTP server is running")
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatal().Err(err).Msg("Failed to start HTTP server")
 		}
 	}()
@@ -123,10 +154,37 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
+
+	shutdown(logger, shutdownTimeout, httpServer, grpcServer, messageUsecase, hub, repo)
+}
+
+// shutdownTimeout is how long in-flight HTTP requests get to finish on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// shutdown stops the service in dependency order: background jobs and the hub
+// first, which ends SSE, WebSocket and gRPC streams, then the HTTP and gRPC
+// servers once in-flight requests have finished, and the database last.
+func shutdown(logger zerolog.Logger, timeout time.Duration, httpServer *http.Server, grpcServer *grpclib.Server,
+	messageUsecase *usecase.MessageUseCase, hub *ws.Hub, repo *repository.Repository) {
 	logger.Info().Msg("Shutting down servers...")
 
+	messageUsecase.StopSchedulers()
+	hub.Stop()
+
+	// Stop HTTP server, waiting up to timeout for in-flight requests
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Error().Err(err).Msg("Failed to shutdown HTTP server gracefully")
+	}
+
 	// Stop gRPC server
 	grpcServer.GracefulStop()
 
+	// Release the database
+	if err := repo.Close(); err != nil {
+		logger.Error().Err(err).Msg("Failed to close repository")
+	}
+
 	logger.Info().Msg("Server exited properly")
 }
@@ -108,9 +108,11 @@ func (x *Message) GetIsBanned() bool {
 
 // GetMessages request and response
 type GetMessagesRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Limit         int64                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
-	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Limit  int64                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Include banned messages; only honoured for authenticated admins
+	IncludeBanned bool `protobuf:"varint,3,opt,name=include_banned,json=includeBanned,proto3" json:"include_banned,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -159,6 +161,13 @@ func (x *GetMessagesRequest) GetOffset() int64 {
 	return 0
 }
 
+func (x *GetMessagesRequest) GetIncludeBanned() bool {
+	if x != nil {
+		return x.IncludeBanned
+	}
+	return false
+}
+
 type GetMessagesResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Messages      []*Message             `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
@@ -494,6 +503,484 @@ func (x *UnbanMessageResponse) GetSuccess() bool {
 	return false
 }
 
+// BanMessages and UnbanMessages request and response. Results are in the
+// order the IDs were given.
+type BatchModerationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ids           []int64                `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchModerationRequest) Reset() {
+	*x = BatchModerationRequest{}
+	mi := &file_proto_forum_forum_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchModerationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchModerationRequest) ProtoMessage() {}
+
+func (x *BatchModerationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchModerationRequest.ProtoReflect.Descriptor instead.
+func (*BatchModerationRequest) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BatchModerationRequest) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type BatchModerationResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchModerationResult) Reset() {
+	*x = BatchModerationResult{}
+	mi := &file_proto_forum_forum_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchModerationResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchModerationResult) ProtoMessage() {}
+
+func (x *BatchModerationResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchModerationResult.ProtoReflect.Descriptor instead.
+func (*BatchModerationResult) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *BatchModerationResult) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *BatchModerationResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *BatchModerationResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type BatchModerationResponse struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	Results       []*BatchModerationResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchModerationResponse) Reset() {
+	*x = BatchModerationResponse{}
+	mi := &file_proto_forum_forum_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchModerationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchModerationResponse) ProtoMessage() {}
+
+func (x *BatchModerationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchModerationResponse.ProtoReflect.Descriptor instead.
+func (*BatchModerationResponse) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *BatchModerationResponse) GetResults() []*BatchModerationResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// StreamMessages request
+type StreamMessagesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamMessagesRequest) Reset() {
+	*x = StreamMessagesRequest{}
+	mi := &file_proto_forum_forum_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamMessagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamMessagesRequest) ProtoMessage() {}
+
+func (x *StreamMessagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamMessagesRequest.ProtoReflect.Descriptor instead.
+func (*StreamMessagesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{12}
+}
+
+// SyncUsername request and response
+type SyncUsernameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int64                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncUsernameRequest) Reset() {
+	*x = SyncUsernameRequest{}
+	mi := &file_proto_forum_forum_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncUsernameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncUsernameRequest) ProtoMessage() {}
+
+func (x *SyncUsernameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncUsernameRequest.ProtoReflect.Descriptor instead.
+func (*SyncUsernameRequest) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SyncUsernameRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *SyncUsernameRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type SyncUsernameResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Updated       int64                  `protobuf:"varint,1,opt,name=updated,proto3" json:"updated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncUsernameResponse) Reset() {
+	*x = SyncUsernameResponse{}
+	mi := &file_proto_forum_forum_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncUsernameResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncUsernameResponse) ProtoMessage() {}
+
+func (x *SyncUsernameResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncUsernameResponse.ProtoReflect.Descriptor instead.
+func (*SyncUsernameResponse) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SyncUsernameResponse) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+// BanUserContent and UnbanUserContent request and response
+type UserContentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int64                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserContentRequest) Reset() {
+	*x = UserContentRequest{}
+	mi := &file_proto_forum_forum_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserContentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserContentRequest) ProtoMessage() {}
+
+func (x *UserContentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserContentRequest.ProtoReflect.Descriptor instead.
+func (*UserContentRequest) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UserContentRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+type UserContentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Updated       int64                  `protobuf:"varint,1,opt,name=updated,proto3" json:"updated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserContentResponse) Reset() {
+	*x = UserContentResponse{}
+	mi := &file_proto_forum_forum_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserContentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserContentResponse) ProtoMessage() {}
+
+func (x *UserContentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserContentResponse.ProtoReflect.Descriptor instead.
+func (*UserContentResponse) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *UserContentResponse) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+// GetVersion request and response
+type GetVersionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetVersionRequest) Reset() {
+	*x = GetVersionRequest{}
+	mi := &file_proto_forum_forum_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetVersionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVersionRequest) ProtoMessage() {}
+
+func (x *GetVersionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVersionRequest.ProtoReflect.Descriptor instead.
+func (*GetVersionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{17}
+}
+
+type GetVersionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Commit        string                 `protobuf:"bytes,2,opt,name=commit,proto3" json:"commit,omitempty"`
+	BuildTime     string                 `protobuf:"bytes,3,opt,name=build_time,json=buildTime,proto3" json:"build_time,omitempty"`
+	GoVersion     string                 `protobuf:"bytes,4,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetVersionResponse) Reset() {
+	*x = GetVersionResponse{}
+	mi := &file_proto_forum_forum_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetVersionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVersionResponse) ProtoMessage() {}
+
+func (x *GetVersionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_forum_forum_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVersionResponse.ProtoReflect.Descriptor instead.
+func (*GetVersionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_forum_forum_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *GetVersionResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetCommit() string {
+	if x != nil {
+		return x.Commit
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetBuildTime() string {
+	if x != nil {
+		return x.BuildTime
+	}
+	return ""
+}
+
+func (x *GetVersionResponse) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
 var File_proto_forum_forum_proto protoreflect.FileDescriptor
 
 const file_proto_forum_forum_proto_rawDesc = "" +
@@ -506,10 +993,11 @@ const file_proto_forum_forum_proto_rawDesc = "" +
 	"\acontent\x18\x04 \x01(\tR\acontent\x12\x1d\n" +
 	"\n" +
 	"created_at\x18\x05 \x01(\tR\tcreatedAt\x12\x1b\n" +
-	"\tis_banned\x18\x06 \x01(\bR\bisBanned\"B\n" +
+	"\tis_banned\x18\x06 \x01(\bR\bisBanned\"i\n" +
 	"\x12GetMessagesRequest\x12\x14\n" +
 	"\x05limit\x18\x01 \x01(\x03R\x05limit\x12\x16\n" +
-	"\x06offset\x18\x02 \x01(\x03R\x06offset\"W\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12%\n" +
+	"\x0einclude_banned\x18\x03 \x01(\bR\rincludeBanned\"W\n" +
 	"\x13GetMessagesResponse\x12*\n" +
 	"\bmessages\x18\x01 \x03(\v2\x0e.forum.MessageR\bmessages\x12\x14\n" +
 	"\x05total\x18\x02 \x01(\x03R\x05total\"e\n" +
@@ -526,13 +1014,47 @@ const file_proto_forum_forum_proto_rawDesc = "" +
 	"\x13UnbanMessageRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\"0\n" +
 	"\x14UnbanMessageResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2\xb4\x02\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"*\n" +
+	"\x16BatchModerationRequest\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\x03R\x03ids\"W\n" +
+	"\x15BatchModerationResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"Q\n" +
+	"\x17BatchModerationResponse\x126\n" +
+	"\aresults\x18\x01 \x03(\v2\x1c.forum.BatchModerationResultR\aresults\"\x17\n" +
+	"\x15StreamMessagesRequest\"J\n" +
+	"\x13SyncUsernameRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x03R\x06userId\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\"0\n" +
+	"\x14SyncUsernameResponse\x12\x18\n" +
+	"\aupdated\x18\x01 \x01(\x03R\aupdated\"-\n" +
+	"\x12UserContentRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x03R\x06userId\"/\n" +
+	"\x13UserContentResponse\x12\x18\n" +
+	"\aupdated\x18\x01 \x01(\x03R\aupdated\"\x13\n" +
+	"\x11GetVersionRequest\"\x84\x01\n" +
+	"\x12GetVersionResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x16\n" +
+	"\x06commit\x18\x02 \x01(\tR\x06commit\x12\x1d\n" +
+	"\n" +
+	"build_time\x18\x03 \x01(\tR\tbuildTime\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x04 \x01(\tR\tgoVersion2\xc2\x06\n" +
 	"\fForumService\x12F\n" +
 	"\vGetMessages\x12\x19.forum.GetMessagesRequest\x1a\x1a.forum.GetMessagesResponse\"\x00\x12L\n" +
 	"\rCreateMessage\x12\x1b.forum.CreateMessageRequest\x1a\x1c.forum.CreateMessageResponse\"\x00\x12C\n" +
 	"\n" +
 	"BanMessage\x12\x18.forum.BanMessageRequest\x1a\x19.forum.BanMessageResponse\"\x00\x12I\n" +
-	"\fUnbanMessage\x12\x1a.forum.UnbanMessageRequest\x1a\x1b.forum.UnbanMessageResponse\"\x00B,Z*github.com/atmega-p471/forum-service/proto/forumb\x06proto3"
+	"\fUnbanMessage\x12\x1a.forum.UnbanMessageRequest\x1a\x1b.forum.UnbanMessageResponse\"\x00\x12N\n" +
+	"\vBanMessages\x12\x1d.forum.BatchModerationRequest\x1a\x1e.forum.BatchModerationResponse\"\x00\x12P\n" +
+	"\rUnbanMessages\x12\x1d.forum.BatchModerationRequest\x1a\x1e.forum.BatchModerationResponse\"\x00\x12B\n" +
+	"\x0eStreamMessages\x12\x1c.forum.StreamMessagesRequest\x1a\x0e.forum.Message\"\x000\x01\x12I\n" +
+	"\fSyncUsername\x12\x1a.forum.SyncUsernameRequest\x1a\x1b.forum.SyncUsernameResponse\"\x00\x12I\n" +
+	"\x0eBanUserContent\x12\x19.forum.UserContentRequest\x1a\x1a.forum.UserContentResponse\"\x00\x12K\n" +
+	"\x10UnbanUserContent\x12\x19.forum.UserContentRequest\x1a\x1a.forum.UserContentResponse\"\x00\x12C\n" +
+	"\n" +
+	"GetVersion\x12\x18.forum.GetVersionRequest\x1a\x19.forum.GetVersionResponse\"\x00B2Z0github.com/atmega-p471/forum-service/proto/forumb\x06proto3"
 
 var (
 	file_proto_forum_forum_proto_rawDescOnce sync.Once
@@ -546,34 +1068,59 @@ func file_proto_forum_forum_proto_rawDescGZIP() []byte {
 	return file_proto_forum_forum_proto_rawDescData
 }
 
-var file_proto_forum_forum_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_proto_forum_forum_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_proto_forum_forum_proto_goTypes = []any{
-	(*Message)(nil),               // 0: forum.Message
-	(*GetMessagesRequest)(nil),    // 1: forum.GetMessagesRequest
-	(*GetMessagesResponse)(nil),   // 2: forum.GetMessagesResponse
-	(*CreateMessageRequest)(nil),  // 3: forum.CreateMessageRequest
-	(*CreateMessageResponse)(nil), // 4: forum.CreateMessageResponse
-	(*BanMessageRequest)(nil),     // 5: forum.BanMessageRequest
-	(*BanMessageResponse)(nil),    // 6: forum.BanMessageResponse
-	(*UnbanMessageRequest)(nil),   // 7: forum.UnbanMessageRequest
-	(*UnbanMessageResponse)(nil),  // 8: forum.UnbanMessageResponse
+	(*Message)(nil),                 // 0: forum.Message
+	(*GetMessagesRequest)(nil),      // 1: forum.GetMessagesRequest
+	(*GetMessagesResponse)(nil),     // 2: forum.GetMessagesResponse
+	(*CreateMessageRequest)(nil),    // 3: forum.CreateMessageRequest
+	(*CreateMessageResponse)(nil),   // 4: forum.CreateMessageResponse
+	(*BanMessageRequest)(nil),       // 5: forum.BanMessageRequest
+	(*BanMessageResponse)(nil),      // 6: forum.BanMessageResponse
+	(*UnbanMessageRequest)(nil),     // 7: forum.UnbanMessageRequest
+	(*UnbanMessageResponse)(nil),    // 8: forum.UnbanMessageResponse
+	(*BatchModerationRequest)(nil),  // 9: forum.BatchModerationRequest
+	(*BatchModerationResult)(nil),   // 10: forum.BatchModerationResult
+	(*BatchModerationResponse)(nil), // 11: forum.BatchModerationResponse
+	(*StreamMessagesRequest)(nil),   // 12: forum.StreamMessagesRequest
+	(*SyncUsernameRequest)(nil),     // 13: forum.SyncUsernameRequest
+	(*SyncUsernameResponse)(nil),    // 14: forum.SyncUsernameResponse
+	(*UserContentRequest)(nil),      // 15: forum.UserContentRequest
+	(*UserContentResponse)(nil),     // 16: forum.UserContentResponse
+	(*GetVersionRequest)(nil),       // 17: forum.GetVersionRequest
+	(*GetVersionResponse)(nil),      // 18: forum.GetVersionResponse
 }
 var file_proto_forum_forum_proto_depIdxs = []int32{
-	0, // 0: forum.GetMessagesResponse.messages:type_name -> forum.Message
-	0, // 1: forum.CreateMessageResponse.message:type_name -> forum.Message
-	1, // 2: forum.ForumService.GetMessages:input_type -> forum.GetMessagesRequest
-	3, // 3: forum.ForumService.CreateMessage:input_type -> forum.CreateMessageRequest
-	5, // 4: forum.ForumService.BanMessage:input_type -> forum.BanMessageRequest
-	7, // 5: forum.ForumService.UnbanMessage:input_type -> forum.UnbanMessageRequest
-	2, // 6: forum.ForumService.GetMessages:output_type -> forum.GetMessagesResponse
-	4, // 7: forum.ForumService.CreateMessage:output_type -> forum.CreateMessageResponse
-	6, // 8: forum.ForumService.BanMessage:output_type -> forum.BanMessageResponse
-	8, // 9: forum.ForumService.UnbanMessage:output_type -> forum.UnbanMessageResponse
-	6, // [6:10] is the sub-list for method output_type
-	2, // [2:6] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: forum.GetMessagesResponse.messages:type_name -> forum.Message
+	0,  // 1: forum.CreateMessageResponse.message:type_name -> forum.Message
+	10, // 2: forum.BatchModerationResponse.results:type_name -> forum.BatchModerationResult
+	1,  // 3: forum.ForumService.GetMessages:input_type -> forum.GetMessagesRequest
+	3,  // 4: forum.ForumService.CreateMessage:input_type -> forum.CreateMessageRequest
+	5,  // 5: forum.ForumService.BanMessage:input_type -> forum.BanMessageRequest
+	7,  // 6: forum.ForumService.UnbanMessage:input_type -> forum.UnbanMessageRequest
+	9,  // 7: forum.ForumService.BanMessages:input_type -> forum.BatchModerationRequest
+	9,  // 8: forum.ForumService.UnbanMessages:input_type -> forum.BatchModerationRequest
+	12, // 9: forum.ForumService.StreamMessages:input_type -> forum.StreamMessagesRequest
+	13, // 10: forum.ForumService.SyncUsername:input_type -> forum.SyncUsernameRequest
+	15, // 11: forum.ForumService.BanUserContent:input_type -> forum.UserContentRequest
+	15, // 12: forum.ForumService.UnbanUserContent:input_type -> forum.UserContentRequest
+	17, // 13: forum.ForumService.GetVersion:input_type -> forum.GetVersionRequest
+	2,  // 14: forum.ForumService.GetMessages:output_type -> forum.GetMessagesResponse
+	4,  // 15: forum.ForumService.CreateMessage:output_type -> forum.CreateMessageResponse
+	6,  // 16: forum.ForumService.BanMessage:output_type -> forum.BanMessageResponse
+	8,  // 17: forum.ForumService.UnbanMessage:output_type -> forum.UnbanMessageResponse
+	11, // 18: forum.ForumService.BanMessages:output_type -> forum.BatchModerationResponse
+	11, // 19: forum.ForumService.UnbanMessages:output_type -> forum.BatchModerationResponse
+	0,  // 20: forum.ForumService.StreamMessages:output_type -> forum.Message
+	14, // 21: forum.ForumService.SyncUsername:output_type -> forum.SyncUsernameResponse
+	16, // 22: forum.ForumService.BanUserContent:output_type -> forum.UserContentResponse
+	16, // 23: forum.ForumService.UnbanUserContent:output_type -> forum.UserContentResponse
+	18, // 24: forum.ForumService.GetVersion:output_type -> forum.GetVersionResponse
+	14, // [14:25] is the sub-list for method output_type
+	3,  // [3:14] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_proto_forum_forum_proto_init() }
@@ -587,7 +1134,7 @@ func file_proto_forum_forum_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_forum_forum_proto_rawDesc), len(file_proto_forum_forum_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,10 +19,17 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ForumService_GetMessages_FullMethodName   = "/forum.ForumService/GetMessages"
-	ForumService_CreateMessage_FullMethodName = "/forum.ForumService/CreateMessage"
-	ForumService_BanMessage_FullMethodName    = "/forum.ForumService/BanMessage"
-	ForumService_UnbanMessage_FullMethodName  = "/forum.ForumService/UnbanMessage"
+	ForumService_GetMessages_FullMethodName      = "/forum.ForumService/GetMessages"
+	ForumService_CreateMessage_FullMethodName    = "/forum.ForumService/CreateMessage"
+	ForumService_BanMessage_FullMethodName       = "/forum.ForumService/BanMessage"
+	ForumService_UnbanMessage_FullMethodName     = "/forum.ForumService/UnbanMessage"
+	ForumService_BanMessages_FullMethodName      = "/forum.ForumService/BanMessages"
+	ForumService_UnbanMessages_FullMethodName    = "/forum.ForumService/UnbanMessages"
+	ForumService_StreamMessages_FullMethodName   = "/forum.ForumService/StreamMessages"
+	ForumService_SyncUsername_FullMethodName     = "/forum.ForumService/SyncUsername"
+	ForumService_BanUserContent_FullMethodName   = "/forum.ForumService/BanUserContent"
+	ForumService_UnbanUserContent_FullMethodName = "/forum.ForumService/UnbanUserContent"
+	ForumService_GetVersion_FullMethodName       = "/forum.ForumService/GetVersion"
 )
 
 // ForumServiceClient is the client API for ForumService service.
@@ -39,6 +46,20 @@ type ForumServiceClient interface {
 	BanMessage(ctx context.Context, in *BanMessageRequest, opts ...grpc.CallOption) (*BanMessageResponse, error)
 	// Unban a message
 	UnbanMessage(ctx context.Context, in *UnbanMessageRequest, opts ...grpc.CallOption) (*UnbanMessageResponse, error)
+	// Ban several messages at once (admin only)
+	BanMessages(ctx context.Context, in *BatchModerationRequest, opts ...grpc.CallOption) (*BatchModerationResponse, error)
+	// Unban several messages at once (admin only)
+	UnbanMessages(ctx context.Context, in *BatchModerationRequest, opts ...grpc.CallOption) (*BatchModerationResponse, error)
+	// Stream new and updated messages as they are broadcast
+	StreamMessages(ctx context.Context, in *StreamMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error)
+	// Propagate a username change to all of the user's messages and comments
+	SyncUsername(ctx context.Context, in *SyncUsernameRequest, opts ...grpc.CallOption) (*SyncUsernameResponse, error)
+	// Hide all of a user's messages, e.g. when the auth service bans them
+	BanUserContent(ctx context.Context, in *UserContentRequest, opts ...grpc.CallOption) (*UserContentResponse, error)
+	// Restore all of a user's messages, e.g. when the auth service unbans them
+	UnbanUserContent(ctx context.Context, in *UserContentRequest, opts ...grpc.CallOption) (*UserContentResponse, error)
+	// Report which build of the service is running
+	GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetVersionResponse, error)
 }
 
 type forumServiceClient struct {
@@ -89,6 +110,85 @@ func (c *forumServiceClient) UnbanMessage(ctx context.Context, in *UnbanMessageR
 	return out, nil
 }
 
+func (c *forumServiceClient) BanMessages(ctx context.Context, in *BatchModerationRequest, opts ...grpc.CallOption) (*BatchModerationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchModerationResponse)
+	err := c.cc.Invoke(ctx, ForumService_BanMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumServiceClient) UnbanMessages(ctx context.Context, in *BatchModerationRequest, opts ...grpc.CallOption) (*BatchModerationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchModerationResponse)
+	err := c.cc.Invoke(ctx, ForumService_UnbanMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumServiceClient) StreamMessages(ctx context.Context, in *StreamMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ForumService_ServiceDesc.Streams[0], ForumService_StreamMessages_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamMessagesRequest, Message]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ForumService_StreamMessagesClient = grpc.ServerStreamingClient[Message]
+
+func (c *forumServiceClient) SyncUsername(ctx context.Context, in *SyncUsernameRequest, opts ...grpc.CallOption) (*SyncUsernameResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncUsernameResponse)
+	err := c.cc.Invoke(ctx, ForumService_SyncUsername_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumServiceClient) BanUserContent(ctx context.Context, in *UserContentRequest, opts ...grpc.CallOption) (*UserContentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserContentResponse)
+	err := c.cc.Invoke(ctx, ForumService_BanUserContent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumServiceClient) UnbanUserContent(ctx context.Context, in *UserContentRequest, opts ...grpc.CallOption) (*UserContentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserContentResponse)
+	err := c.cc.Invoke(ctx, ForumService_UnbanUserContent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *forumServiceClient) GetVersion(ctx context.Context, in *GetVersionRequest, opts ...grpc.CallOption) (*GetVersionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetVersionResponse)
+	err := c.cc.Invoke(ctx, ForumService_GetVersion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ForumServiceServer is the server API for ForumService service.
 // All implementations must embed UnimplementedForumServiceServer
 // for forward compatibility.
@@ -103,6 +203,20 @@ type ForumServiceServer interface {
 	BanMessage(context.Context, *BanMessageRequest) (*BanMessageResponse, error)
 	// Unban a message
 	UnbanMessage(context.Context, *UnbanMessageRequest) (*UnbanMessageResponse, error)
+	// Ban several messages at once (admin only)
+	BanMessages(context.Context, *BatchModerationRequest) (*BatchModerationResponse, error)
+	// Unban several messages at once (admin only)
+	UnbanMessages(context.Context, *BatchModerationRequest) (*BatchModerationResponse, error)
+	// Stream new and updated messages as they are broadcast
+	StreamMessages(*StreamMessagesRequest, grpc.ServerStreamingServer[Message]) error
+	// Propagate a username change to all of the user's messages and comments
+	SyncUsername(context.Context, *SyncUsernameRequest) (*SyncUsernameResponse, error)
+	// Hide all of a user's messages, e.g. when the auth service bans them
+	BanUserContent(context.Context, *UserContentRequest) (*UserContentResponse, error)
+	// Restore all of a user's messages, e.g. when the auth service unbans them
+	UnbanUserContent(context.Context, *UserContentRequest) (*UserContentResponse, error)
+	// Report which build of the service is running
+	GetVersion(context.Context, *GetVersionRequest) (*GetVersionResponse, error)
 	mustEmbedUnimplementedForumServiceServer()
 }
 
@@ -125,6 +239,27 @@ func (UnimplementedForumServiceServer) BanMessage(context.Context, *BanMessageRe
 func (UnimplementedForumServiceServer) UnbanMessage(context.Context, *UnbanMessageRequest) (*UnbanMessageResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UnbanMessage not implemented")
 }
+func (UnimplementedForumServiceServer) BanMessages(context.Context, *BatchModerationRequest) (*BatchModerationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BanMessages not implemented")
+}
+func (UnimplementedForumServiceServer) UnbanMessages(context.Context, *BatchModerationRequest) (*BatchModerationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnbanMessages not implemented")
+}
+func (UnimplementedForumServiceServer) StreamMessages(*StreamMessagesRequest, grpc.ServerStreamingServer[Message]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMessages not implemented")
+}
+func (UnimplementedForumServiceServer) SyncUsername(context.Context, *SyncUsernameRequest) (*SyncUsernameResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncUsername not implemented")
+}
+func (UnimplementedForumServiceServer) BanUserContent(context.Context, *UserContentRequest) (*UserContentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BanUserContent not implemented")
+}
+func (UnimplementedForumServiceServer) UnbanUserContent(context.Context, *UserContentRequest) (*UserContentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnbanUserContent not implemented")
+}
+func (UnimplementedForumServiceServer) GetVersion(context.Context, *GetVersionRequest) (*GetVersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
+}
 func (UnimplementedForumServiceServer) mustEmbedUnimplementedForumServiceServer() {}
 func (UnimplementedForumServiceServer) testEmbeddedByValue()                      {}
 
@@ -218,6 +353,125 @@ func _ForumService_UnbanMessage_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ForumService_BanMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServiceServer).BanMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ForumService_BanMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServiceServer).BanMessages(ctx, req.(*BatchModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ForumService_UnbanMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServiceServer).UnbanMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ForumService_UnbanMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServiceServer).UnbanMessages(ctx, req.(*BatchModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ForumService_StreamMessages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamMessagesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ForumServiceServer).StreamMessages(m, &grpc.GenericServerStream[StreamMessagesRequest, Message]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ForumService_StreamMessagesServer = grpc.ServerStreamingServer[Message]
+
+func _ForumService_SyncUsername_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncUsernameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServiceServer).SyncUsername(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ForumService_SyncUsername_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServiceServer).SyncUsername(ctx, req.(*SyncUsernameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ForumService_BanUserContent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserContentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServiceServer).BanUserContent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ForumService_BanUserContent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServiceServer).BanUserContent(ctx, req.(*UserContentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ForumService_UnbanUserContent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserContentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServiceServer).UnbanUserContent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ForumService_UnbanUserContent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServiceServer).UnbanUserContent(ctx, req.(*UserContentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ForumService_GetVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForumServiceServer).GetVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ForumService_GetVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForumServiceServer).GetVersion(ctx, req.(*GetVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ForumService_ServiceDesc is the grpc.ServiceDesc for ForumService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -241,7 +495,37 @@ var ForumService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UnbanMessage",
 			Handler:    _ForumService_UnbanMessage_Handler,
 		},
+		{
+			MethodName: "BanMessages",
+			Handler:    _ForumService_BanMessages_Handler,
+		},
+		{
+			MethodName: "UnbanMessages",
+			Handler:    _ForumService_UnbanMessages_Handler,
+		},
+		{
+			MethodName: "SyncUsername",
+			Handler:    _ForumService_SyncUsername_Handler,
+		},
+		{
+			MethodName: "BanUserContent",
+			Handler:    _ForumService_BanUserContent_Handler,
+		},
+		{
+			MethodName: "UnbanUserContent",
+			Handler:    _ForumService_UnbanUserContent_Handler,
+		},
+		{
+			MethodName: "GetVersion",
+			Handler:    _ForumService_GetVersion_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMessages",
+			Handler:       _ForumService_StreamMessages_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/forum/forum.proto",
 }
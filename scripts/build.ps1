@@ -11,7 +11,13 @@ switch ($Command) {
     }
     'build' {
         Write-Host "Building application..."
-        go build -o bin/forum-service.exe
+        $version = git describe --tags --always --dirty 2>$null
+        if (-not $version) { $version = "dev" }
+        $commit = git rev-parse --short HEAD 2>$null
+        if (-not $commit) { $commit = "unknown" }
+        $buildTime = (Get-Date).ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ")
+        $pkg = "github.com/atmega-p471/forum-service/internal/version"
+        go build -ldflags "-X $pkg.Version=$version -X $pkg.Commit=$commit -X $pkg.BuildTime=$buildTime" -o bin/forum-service.exe
     }
     'run' {
         Write-Host "Running application..."
@@ -103,8 +103,8 @@ func TestFunctional_MessageWorkflow(t *testing.T) {
 			t.Fatalf("Failed to decode response: %v", err)
 		}
 
-		if _, ok := result["messages"]; !ok {
-			t.Error("Response should contain messages array")
+		if _, ok := result["items"]; !ok {
+			t.Error("Response should contain items array")
 		}
 
 		if _, ok := result["total"]; !ok {
@@ -370,9 +370,9 @@ func TestFunctional_Pagination(t *testing.T) {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	messages, ok := result["messages"].([]interface{})
+	messages, ok := result["items"].([]interface{})
 	if !ok {
-		t.Fatal("Response should contain messages array")
+		t.Fatal("Response should contain items array")
 	}
 
 	if len(messages) > 2 {
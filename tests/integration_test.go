@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -135,7 +136,7 @@ func NewTestMessageUseCase(repo domain.MessageRepository, authClient AuthClientI
 	}
 }
 
-func (u *TestMessageUseCase) CreateMessage(userID int64, username, content string) (*domain.Message, error) {
+func (u *TestMessageUseCase) CreateMessage(ctx context.Context, userID int64, username, content string) (*domain.Message, error) {
 	if content == "" {
 		return nil, fmt.Errorf("content is required")
 	}
@@ -164,7 +165,7 @@ func (u *TestMessageUseCase) CreateMessage(userID int64, username, content strin
 	}
 
 	// Save message
-	messageID, err := u.repo.Create(message)
+	messageID, err := u.repo.Create(ctx, message)
 	if err != nil {
 		return nil, err
 	}
@@ -178,20 +179,20 @@ func (u *TestMessageUseCase) CreateMessage(userID int64, username, content strin
 	return message, nil
 }
 
-func (u *TestMessageUseCase) GetMessages(limit, offset int64) ([]*domain.Message, int64, error) {
-	return u.repo.List(limit, offset)
+func (u *TestMessageUseCase) GetMessages(ctx context.Context, limit, offset int64) ([]*domain.Message, int64, error) {
+	return u.repo.List(ctx, limit, offset)
 }
 
-func (u *TestMessageUseCase) BanMessage(id int64) error {
-	message, err := u.repo.GetByID(id)
+func (u *TestMessageUseCase) BanMessage(ctx context.Context, id int64) error {
+	message, err := u.repo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 	if message == nil {
-		return fmt.Errorf("message not found")
+		return domain.ErrMessageNotFound
 	}
 
-	err = u.repo.Ban(id)
+	err = u.repo.Ban(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -202,11 +203,11 @@ func (u *TestMessageUseCase) BanMessage(id int64) error {
 	return nil
 }
 
-func (u *TestMessageUseCase) GetByID(id int64) (*domain.Message, error) {
-	return u.repo.GetByID(id)
+func (u *TestMessageUseCase) GetByID(ctx context.Context, id int64) (*domain.Message, error) {
+	return u.repo.GetByID(ctx, id)
 }
 
-func (u *TestMessageUseCase) CreateComment(messageID, userID int64, username, content string) (*domain.Comment, error) {
+func (u *TestMessageUseCase) CreateComment(ctx context.Context, messageID, userID int64, username, content string) (*domain.Comment, error) {
 	if content == "" {
 		return nil, fmt.Errorf("content is required")
 	}
@@ -226,9 +227,9 @@ func (u *TestMessageUseCase) CreateComment(messageID, userID int64, username, co
 	}
 
 	// Check if message exists
-	_, err := u.repo.GetByID(messageID)
+	_, err := u.repo.GetByID(ctx, messageID)
 	if err != nil {
-		return nil, fmt.Errorf("message not found")
+		return nil, domain.ErrMessageNotFound
 	}
 
 	// Create comment
@@ -242,7 +243,7 @@ func (u *TestMessageUseCase) CreateComment(messageID, userID int64, username, co
 	}
 
 	// Save comment
-	commentID, err := u.repo.CreateComment(comment)
+	commentID, err := u.repo.CreateComment(ctx, comment)
 	if err != nil {
 		return nil, err
 	}
@@ -251,24 +252,24 @@ func (u *TestMessageUseCase) CreateComment(messageID, userID int64, username, co
 	return comment, nil
 }
 
-func (u *TestMessageUseCase) GetComments(messageID int64) ([]*domain.Comment, error) {
-	return u.repo.GetComments(messageID)
+func (u *TestMessageUseCase) GetComments(ctx context.Context, messageID int64, order domain.SortOrder) ([]*domain.Comment, error) {
+	return u.repo.GetComments(ctx, messageID, order, false)
 }
 
-func (u *TestMessageUseCase) DeleteComment(id int64) error {
-	return u.repo.DeleteComment(id)
+func (u *TestMessageUseCase) DeleteComment(ctx context.Context, id int64) error {
+	return u.repo.DeleteComment(ctx, id)
 }
 
-func (u *TestMessageUseCase) GetAllMessages() ([]*domain.Message, error) {
-	return u.repo.GetAllMessages()
+func (u *TestMessageUseCase) GetAllMessages(ctx context.Context) ([]*domain.Message, error) {
+	return u.repo.GetAllMessages(ctx)
 }
 
-func (u *TestMessageUseCase) UnbanMessage(id int64) error {
-	return u.repo.Unban(id)
+func (u *TestMessageUseCase) UnbanMessage(ctx context.Context, id int64) error {
+	return u.repo.Unban(ctx, id)
 }
 
-func (u *TestMessageUseCase) DeleteMessage(id int64) error {
-	return u.repo.Delete(id)
+func (u *TestMessageUseCase) DeleteMessage(ctx context.Context, id int64) error {
+	return u.repo.Delete(ctx, id)
 }
 
 func TestIntegration_MessageFlow(t *testing.T) {
@@ -282,7 +283,7 @@ func TestIntegration_MessageFlow(t *testing.T) {
 	messageUseCase := NewTestMessageUseCase(repo.Message, authClient, hub)
 
 	// Test creating a message through usecase
-	message, err := messageUseCase.CreateMessage(1, "testuser", "Integration test message")
+	message, err := messageUseCase.CreateMessage(context.Background(), 1, "testuser", "Integration test message")
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
@@ -296,7 +297,7 @@ func TestIntegration_MessageFlow(t *testing.T) {
 	}
 
 	// Test retrieving messages
-	messages, total, err := messageUseCase.GetMessages(10, 0)
+	messages, total, err := messageUseCase.GetMessages(context.Background(), 10, 0)
 	if err != nil {
 		t.Fatalf("Failed to get messages: %v", err)
 	}
@@ -310,7 +311,7 @@ func TestIntegration_MessageFlow(t *testing.T) {
 	}
 
 	// Test creating a comment
-	comment, err := messageUseCase.CreateComment(message.ID, 2, "admin", "Test comment")
+	comment, err := messageUseCase.CreateComment(context.Background(), message.ID, 2, "admin", "Test comment")
 	if err != nil {
 		t.Fatalf("Failed to create comment: %v", err)
 	}
@@ -320,7 +321,7 @@ func TestIntegration_MessageFlow(t *testing.T) {
 	}
 
 	// Test getting comments
-	comments, err := messageUseCase.GetComments(message.ID)
+	comments, err := messageUseCase.GetComments(context.Background(), message.ID, domain.SortAsc)
 	if err != nil {
 		t.Fatalf("Failed to get comments: %v", err)
 	}
@@ -330,13 +331,13 @@ func TestIntegration_MessageFlow(t *testing.T) {
 	}
 
 	// Test banning message
-	err = messageUseCase.BanMessage(message.ID)
+	err = messageUseCase.BanMessage(context.Background(), message.ID)
 	if err != nil {
 		t.Fatalf("Failed to ban message: %v", err)
 	}
 
 	// Verify message is banned
-	bannedMessage, err := messageUseCase.GetByID(message.ID)
+	bannedMessage, err := messageUseCase.GetByID(context.Background(), message.ID)
 	if err != nil {
 		t.Fatalf("Failed to get banned message: %v", err)
 	}
@@ -346,7 +347,7 @@ func TestIntegration_MessageFlow(t *testing.T) {
 	}
 
 	// Test that banned messages don't appear in list
-	messages, total, err = messageUseCase.GetMessages(10, 0)
+	messages, total, err = messageUseCase.GetMessages(context.Background(), 10, 0)
 	if err != nil {
 		t.Fatalf("Failed to get messages after ban: %v", err)
 	}
@@ -356,13 +357,13 @@ func TestIntegration_MessageFlow(t *testing.T) {
 	}
 
 	// Test deleting comment
-	err = messageUseCase.DeleteComment(comment.ID)
+	err = messageUseCase.DeleteComment(context.Background(), comment.ID)
 	if err != nil {
 		t.Fatalf("Failed to delete comment: %v", err)
 	}
 
 	// Verify comment is deleted
-	comments, err = messageUseCase.GetComments(message.ID)
+	comments, err = messageUseCase.GetComments(context.Background(), message.ID, domain.SortAsc)
 	if err != nil {
 		t.Fatalf("Failed to get comments after deletion: %v", err)
 	}
@@ -387,13 +388,13 @@ func TestIntegration_DatabasePersistence(t *testing.T) {
 	}
 
 	// Create message
-	messageID, err := repo.Message.Create(testMessage)
+	messageID, err := repo.Message.Create(context.Background(), testMessage)
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
 
 	// Retrieve message
-	retrievedMessage, err := repo.Message.GetByID(messageID)
+	retrievedMessage, err := repo.Message.GetByID(context.Background(), messageID)
 	if err != nil {
 		t.Fatalf("Failed to retrieve message: %v", err)
 	}
@@ -418,13 +419,13 @@ func TestIntegration_DatabasePersistence(t *testing.T) {
 	}
 
 	// Create comment
-	commentID, err := repo.Message.CreateComment(testComment)
+	commentID, err := repo.Message.CreateComment(context.Background(), testComment)
 	if err != nil {
 		t.Fatalf("Failed to create comment: %v", err)
 	}
 
 	// Retrieve comment
-	retrievedComment, err := repo.Message.GetCommentByID(commentID)
+	retrievedComment, err := repo.Message.GetCommentByID(context.Background(), commentID)
 	if err != nil {
 		t.Fatalf("Failed to retrieve comment: %v", err)
 	}
@@ -439,13 +440,13 @@ func TestIntegration_DatabasePersistence(t *testing.T) {
 	}
 
 	// Test foreign key constraint (comments should be deleted when message is deleted)
-	err = repo.Message.Delete(messageID)
+	err = repo.Message.Delete(context.Background(), messageID)
 	if err != nil {
 		t.Fatalf("Failed to delete message: %v", err)
 	}
 
 	// Verify comment is also deleted due to foreign key constraint
-	_, err = repo.Message.GetCommentByID(commentID)
+	_, err = repo.Message.GetCommentByID(context.Background(), commentID)
 	if err == nil {
 		t.Error("Expected comment to be deleted when message is deleted")
 	}
@@ -461,7 +462,7 @@ func TestIntegration_AuthValidation(t *testing.T) {
 	messageUseCase := NewTestMessageUseCase(repo.Message, authClient, hub)
 
 	// Test with valid user
-	message, err := messageUseCase.CreateMessage(1, "testuser", "Valid user message")
+	message, err := messageUseCase.CreateMessage(context.Background(), 1, "testuser", "Valid user message")
 	if err != nil {
 		t.Fatalf("Failed to create message with valid user: %v", err)
 	}
@@ -471,19 +472,19 @@ func TestIntegration_AuthValidation(t *testing.T) {
 	}
 
 	// Test with banned user (should fail)
-	_, err = messageUseCase.CreateMessage(3, "banned", "Banned user message")
+	_, err = messageUseCase.CreateMessage(context.Background(), 3, "banned", "Banned user message")
 	if err == nil {
 		t.Error("Expected error when creating message with banned user")
 	}
 
 	// Test with non-existent user (should fail)
-	_, err = messageUseCase.CreateMessage(999, "nonexistent", "Non-existent user message")
+	_, err = messageUseCase.CreateMessage(context.Background(), 999, "nonexistent", "Non-existent user message")
 	if err == nil {
 		t.Error("Expected error when creating message with non-existent user")
 	}
 
 	// Test anonymous user (should succeed)
-	anonymousMessage, err := messageUseCase.CreateMessage(0, "anonymous", "Anonymous message")
+	anonymousMessage, err := messageUseCase.CreateMessage(context.Background(), 0, "anonymous", "Anonymous message")
 	if err != nil {
 		t.Fatalf("Failed to create anonymous message: %v", err)
 	}
@@ -507,7 +508,7 @@ func TestIntegration_CommentExpiration(t *testing.T) {
 		IsBanned:  false,
 	}
 
-	messageID, err := repo.Message.Create(testMessage)
+	messageID, err := repo.Message.Create(context.Background(), testMessage)
 	if err != nil {
 		t.Fatalf("Failed to create message: %v", err)
 	}
@@ -522,7 +523,7 @@ func TestIntegration_CommentExpiration(t *testing.T) {
 		ExpiresAt: time.Now().Add(-1 * time.Hour), // Expired 1 hour ago
 	}
 
-	expiredCommentID, err := repo.Message.CreateComment(expiredComment)
+	expiredCommentID, err := repo.Message.CreateComment(context.Background(), expiredComment)
 	if err != nil {
 		t.Fatalf("Failed to create expired comment: %v", err)
 	}
@@ -537,13 +538,13 @@ func TestIntegration_CommentExpiration(t *testing.T) {
 		ExpiresAt: time.Now().Add(24 * time.Hour),
 	}
 
-	validCommentID, err := repo.Message.CreateComment(validComment)
+	validCommentID, err := repo.Message.CreateComment(context.Background(), validComment)
 	if err != nil {
 		t.Fatalf("Failed to create valid comment: %v", err)
 	}
 
 	// Get comments - should only return non-expired ones
-	comments, err := repo.Message.GetComments(messageID)
+	comments, err := repo.Message.GetComments(context.Background(), messageID, domain.SortAsc, false)
 	if err != nil {
 		t.Fatalf("Failed to get comments: %v", err)
 	}
@@ -558,19 +559,19 @@ func TestIntegration_CommentExpiration(t *testing.T) {
 	}
 
 	// Test cleanup of expired comments
-	err = repo.Message.DeleteExpiredComments()
+	err = repo.Message.DeleteExpiredComments(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to delete expired comments: %v", err)
 	}
 
 	// Verify expired comment is deleted
-	_, err = repo.Message.GetCommentByID(expiredCommentID)
+	_, err = repo.Message.GetCommentByID(context.Background(), expiredCommentID)
 	if err == nil {
 		t.Error("Expected expired comment to be deleted")
 	}
 
 	// Verify valid comment still exists
-	_, err = repo.Message.GetCommentByID(validCommentID)
+	_, err = repo.Message.GetCommentByID(context.Background(), validCommentID)
 	if err != nil {
 		t.Errorf("Expected valid comment to still exist: %v", err)
 	}